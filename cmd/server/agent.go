@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/agent"
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+)
+
+// runAgent runs `bleeding-edge agent`: it connects to the BleedingEdge server at
+// AGENT_SERVER_URL and serves the local Docker daemon to it until interrupted.
+// The server must be reached over https:// unless --insecure is given.
+func runAgent(args []string) {
+	logger := initLogger(getEnv("LOG_LEVEL", "info"))
+
+	flags := flag.NewFlagSet("agent", flag.ExitOnError)
+	insecure := flags.Bool("insecure", false, "allow an http:// AGENT_SERVER_URL, sending the token and Docker calls unencrypted")
+	flags.Parse(args)
+
+	cfg := agent.Config{
+		ServerURL: getEnv("AGENT_SERVER_URL", ""),
+		Host:      getEnv("AGENT_HOST", ""),
+		Token:     getEnv("AGENT_TOKEN", ""),
+		Insecure:  *insecure,
+	}
+
+	// The agent pulls with the registry credentials of its own host
+	creds, err := credentials.Load(getEnv("DOCKER_CONFIG", credentials.DefaultDockerConfigDir()), getEnv("REGISTRY_CREDENTIALS_FILE", ""), logger)
+	if err != nil {
+		logger.Error("invalid registry credentials", "error", err)
+		os.Exit(1)
+	}
+
+	client, err := docker.NewClientWithLogger(logger)
+	if err != nil {
+		logger.Error("failed to initialize Docker client", "error", err)
+		os.Exit(1)
+	}
+	client.WithCredentials(creds)
+	defer client.Close()
+	if err := verifyDockerConnection(client); err != nil {
+		logger.Error("failed to connect to Docker daemon", "error", err)
+		os.Exit(1)
+	}
+
+	a, err := agent.New(cfg, client, logger)
+	if err != nil {
+		logger.Error("invalid agent configuration", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.Insecure && strings.HasPrefix(cfg.ServerURL, "http://") {
+		logger.Warn("connecting to the server without TLS; the agent token and Docker calls are sent unencrypted", "server", cfg.ServerURL)
+	}
+	logger.Info("starting agent", "server", cfg.ServerURL, "docker_host", cfg.Host)
+	a.Run(ctx)
+	logger.Info("agent stopped")
+}
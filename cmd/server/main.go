@@ -2,30 +2,80 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/agent"
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
 	"github.com/bleeding-edge/bleeding-edge/internal/handlers"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/bleeding-edge/bleeding-edge/internal/scheduler"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	// `bleeding-edge agent` serves a remote server instead of the dashboard
+	if len(os.Args) > 1 && os.Args[1] == "agent" {
+		runAgent(os.Args[2:])
+		return
+	}
+
 	// Get configuration from environment
 	port := getEnv("PORT", "8080")
 	logLevel := getEnv("LOG_LEVEL", "info")
 	dockerHost := getEnv("DOCKER_HOST", "unix:///var/run/docker.sock")
+	hostsFile := getEnv("HOSTS_FILE", "")
+	dockerHosts := getEnv("DOCKER_HOSTS", "")
+	dockerConfig := getEnv("DOCKER_CONFIG", credentials.DefaultDockerConfigDir())
+	credentialsFile := getEnv("REGISTRY_CREDENTIALS_FILE", "")
+	registryCacheTTL := getEnv("REGISTRY_CACHE_TTL", "15m")
+	registryInsecure := getEnv("REGISTRY_INSECURE", "")
 	updateCheckTimeout := getEnv("UPDATE_CHECK_TIMEOUT", "5m")
-
-	// Initialize structured logger
+	updateCheckSchedule := getEnv("UPDATE_CHECK_SCHEDULE", "1h")
+	updateCheckParallelism := getEnv("UPDATE_CHECK_PARALLELISM", strconv.Itoa(services.DefaultCheckParallelism))
+	authFile := getEnv("AUTH_FILE", "")
+	authUsers := getEnv("AUTH_USERS", "")
+	authTokens := getEnv("AUTH_TOKENS", "")
+	sessionTTL := getEnv("SESSION_TTL", "24h")
+	dataDir := getEnv("DATA_DIR", "data")
+	webhooksFile := getEnv("WEBHOOKS_FILE", "")
+	webhookURL := getEnv("WEBHOOK_URL", "")
+	webhookPreset := getEnv("WEBHOOK_PRESET", "")
+	webhookSecret := getEnv("WEBHOOK_SECRET", "")
+	smtpHost := getEnv("SMTP_HOST", "")
+	smtpPort := getEnv("SMTP_PORT", "587")
+	smtpSecurity := getEnv("SMTP_SECURITY", notify.SecuritySTARTTLS)
+	digestSchedule := getEnv("DIGEST_SCHEDULE", "@daily")
+	publicURL := getEnv("PUBLIC_URL", "http://localhost:"+port)
+
+	// Initialize structured logger; services log through the default logger
 	logger := initLogger(logLevel)
+	slog.SetDefault(logger)
 
 	// Validate environment variables
-	if err := validateConfig(port, logLevel, dockerHost, updateCheckTimeout); err != nil {
+	if err := validateConfig(port, logLevel, dockerHost, updateCheckTimeout, updateCheckSchedule, updateCheckParallelism, registryCacheTTL, sessionTTL, smtpPort, digestSchedule); err != nil {
 		logger.Error("invalid configuration", "error", err)
 		os.Exit(1)
 	}
@@ -34,24 +84,180 @@ func main() {
 		"port", port,
 		"log_level", logLevel,
 		"docker_host", dockerHost,
+		"hosts_file", hostsFile,
+		"docker_config", dockerConfig,
+		"registry_credentials_file", credentialsFile,
+		"registry_cache_ttl", registryCacheTTL,
 		"update_check_timeout", updateCheckTimeout,
+		"update_check_schedule", updateCheckSchedule,
+		"update_check_parallelism", updateCheckParallelism,
+		"auth_file", authFile,
+		"session_ttl", sessionTTL,
+		"data_dir", dataDir,
+		"webhooks_file", webhooksFile,
+		"smtp_host", smtpHost,
+		"digest_schedule", digestSchedule,
 	)
 
-	// Initialize Docker client wrapper with logger
-	dockerClient, err := docker.NewClientWithLogger(logger)
+	// Export traces over OTLP when the standard OTEL_* variables configure an endpoint
+	shutdownTracing, err := tracing.Setup(context.Background(), "bleedingedge")
+	if err != nil {
+		logger.Error("invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+	if tracing.Enabled() {
+		logger.Info("tracing enabled")
+	}
+
+	// Load users and API tokens; without any users the server is left open
+	authConfig, err := auth.LoadConfig(authFile, authUsers, authTokens)
+	if err != nil {
+		logger.Error("invalid authentication configuration", "error", err)
+		os.Exit(1)
+	}
+	ttl, _ := time.ParseDuration(sessionTTL)
+	authenticator := auth.NewAuthenticator(authConfig, ttl)
+	if authenticator.Enabled() {
+		logger.Info("authentication enabled",
+			"users", len(authConfig.Users),
+			"tokens", len(authConfig.Tokens),
+		)
+	} else {
+		logger.Warn("authentication disabled: no users configured, anyone who can reach the server controls Docker")
+	}
+
+	// Set up email when an SMTP server is configured
+	var mailer *notify.Mailer
+	if smtpHost != "" {
+		smtpPortNumber, _ := strconv.Atoi(smtpPort)
+		mailer, err = notify.NewMailer(notify.SMTPConfig{
+			Host:     smtpHost,
+			Port:     smtpPortNumber,
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+			From:     getEnv("SMTP_FROM", ""),
+			To:       splitList(getEnv("SMTP_TO", "")),
+			Security: smtpSecurity,
+			BaseURL:  publicURL,
+		})
+		if err != nil {
+			logger.Error("invalid SMTP configuration", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("email notifications enabled", "smtp_host", smtpHost, "security", smtpSecurity)
+	}
+
+	// Load the webhooks notified of available updates and update results
+	webhookConfig, err := notify.LoadConfig(webhooksFile, webhookURL, webhookPreset, webhookSecret)
+	if err != nil {
+		logger.Error("invalid webhook configuration", "error", err)
+		os.Exit(1)
+	}
+	notifier, err := notify.New(webhookConfig.Webhooks, mailer, logger)
+	if err != nil {
+		logger.Error("invalid webhook configuration", "error", err)
+		os.Exit(1)
+	}
+	if len(webhookConfig.Webhooks) > 0 {
+		logger.Info("webhook notifications enabled", "webhooks", len(webhookConfig.Webhooks))
+	}
+
+	// Load registry credentials for pulls and update checks; registries
+	// without credentials are accessed anonymously
+	registryCredentials, err := credentials.Load(dockerConfig, credentialsFile, logger)
+	if err != nil {
+		logger.Error("invalid registry credentials", "error", err)
+		os.Exit(1)
+	}
+
+	// Connect to every Docker host; without HOSTS_FILE or DOCKER_HOSTS the
+	// single daemon configured by DOCKER_HOST is managed
+	hostsConfig, err := docker.LoadHostsConfig(hostsFile, dockerHosts)
+	if err != nil {
+		logger.Error("invalid Docker hosts configuration", "error", err)
+		os.Exit(1)
+	}
+	// Agent hosts are served by the agents that connect to agentServer
+	agentServer := agent.NewServer(logger)
+	hosts, err := docker.ConnectHosts(hostsConfig.Hosts, registryCredentials, agentServer.Client, logger)
 	if err != nil {
 		logger.Error("failed to initialize Docker client", "error", err)
 		os.Exit(1)
 	}
-	defer dockerClient.Close()
+	defer hosts.Close()
+
+	// Verify Docker connectivity. With several hosts, an unreachable one is
+	// shown on the dashboard instead of stopping the server. Agents connect
+	// once the server is up.
+	for _, cfg := range hostsConfig.Hosts {
+		if cfg.Agent != nil {
+			logger.Info("waiting for agent to connect", "docker_host", cfg.Name)
+			continue
+		}
+		host, _ := hosts.Get(cfg.Name)
+		if err := verifyDockerConnection(host.Client); err != nil {
+			if hosts.Len() == 1 {
+				logger.Error("failed to connect to Docker daemon", "error", err)
+				os.Exit(1)
+			}
+			logger.Warn("failed to connect to Docker daemon", "docker_host", host.Name, "error", err)
+			continue
+		}
+		logger.Info("successfully connected to Docker daemon", "docker_host", host.Name)
+	}
 
-	// Verify Docker connectivity
-	if err := verifyDockerConnection(dockerClient); err != nil {
-		logger.Error("failed to connect to Docker daemon", "error", err)
+	// Open the audit log of every operation
+	auditLog, err := audit.Open(filepath.Join(dataDir, "audit.db"))
+	if err != nil {
+		logger.Error("failed to open audit log", "error", err)
 		os.Exit(1)
 	}
+	defer auditLog.Close()
 
-	logger.Info("successfully connected to Docker daemon")
+	// Open the update history used to revert updates
+	historyStore, err := history.Open(filepath.Join(dataDir, "history.db"))
+	if err != nil {
+		logger.Error("failed to open update history", "error", err)
+		os.Exit(1)
+	}
+	defer historyStore.Close()
+
+	// Initialize registry client used for update detection
+	registryClient := registry.NewClientWithLogger(logger).
+		WithCredentials(registryCredentials).
+		WithInsecureRegistries(splitList(registryInsecure))
+
+	// Cache resolved digests across checks, hosts and restarts; 0 disables the cache
+	var resolver registry.Resolver = registryClient
+	if cacheTTL, _ := time.ParseDuration(registryCacheTTL); cacheTTL > 0 {
+		registryCache, err := registry.OpenCache(filepath.Join(dataDir, "registry.db"), registryClient, cacheTTL, logger)
+		if err != nil {
+			logger.Error("failed to open registry cache", "error", err)
+			os.Exit(1)
+		}
+		defer registryCache.Close()
+		resolver = registryCache
+	}
+
+	// Start the background update checker; pages render from its cache
+	checkTimeout, _ := time.ParseDuration(updateCheckTimeout)
+	checkSchedule, _ := scheduler.ParseSchedule(updateCheckSchedule)
+	checkParallelism, _ := strconv.Atoi(updateCheckParallelism)
+	updateCache := services.NewUpdateCache()
+	updateChecker := scheduler.NewUpdateChecker(hosts, resolver, updateCache, checkSchedule, checkTimeout, notifier, logger).
+		WithParallelism(checkParallelism)
+	go updateChecker.Run(context.Background())
+
+	// Apply updates to containers that opted in via labels
+	autoUpdater := scheduler.NewAutoUpdater(hosts, updateCache, auditLog, historyStore, notifier, logger)
+	go autoUpdater.Run(context.Background())
+
+	// Email a digest of pending updates
+	if mailer != nil && digestSchedule != "off" {
+		schedule, _ := scheduler.ParseSchedule(digestSchedule)
+		digestSender := scheduler.NewDigestSender(hosts, updateCache, mailer, schedule, logger)
+		go digestSender.Run(context.Background())
+	}
 
 	// Load templates
 	tmpl, err := loadTemplates()
@@ -61,35 +267,103 @@ func main() {
 	}
 
 	// Initialize handlers
-	homeHandler := handlers.NewHomeHandler(dockerClient, tmpl, logger)
-	detailHandler := handlers.NewDetailHandler(dockerClient, tmpl, logger)
-	opsHandler := handlers.NewOperationsHandler(dockerClient, logger)
+	homeHandler := handlers.NewHomeHandler(hosts, updateCache, registryClient, tmpl, logger)
+	detailHandler := handlers.NewDetailHandler(hosts, updateCache, historyStore, tmpl, logger)
+	jobManager := jobs.NewManager(logger)
+	opsHandler := handlers.NewOperationsHandler(hosts, updateCache, jobManager, auditLog, historyStore, notifier, logger)
+	jobsHandler := handlers.NewJobsHandler(jobManager, logger)
+	updatesHandler := handlers.NewUpdatesHandler(updateChecker, logger)
+	authHandler := handlers.NewAuthHandler(authenticator, tmpl, logger)
+	apiHandler := handlers.NewAPIHandler(hosts, updateCache, historyStore, logger)
+	activityHandler := handlers.NewActivityHandler(auditLog, tmpl, logger)
 
 	// Initialize HTTP router
 	router := mux.NewRouter()
 
 	// Add middleware
+	router.Use(tracing.Middleware(routeTemplate))
 	router.Use(loggingMiddleware(logger))
 	router.Use(recoveryMiddleware(logger))
+	router.Use(authMiddleware(authenticator, logger))
 
 	// Configure routes
+	router.HandleFunc("/login", authHandler.HandleLoginPage).Methods("GET")
+	router.HandleFunc("/login", authHandler.HandleLogin).Methods("POST")
+	router.HandleFunc("/logout", authHandler.HandleLogout).Methods("POST")
 	router.Handle("/", homeHandler).Methods("GET")
-	router.HandleFunc("/container/{id}", detailHandler.ServeHTTP).Methods("GET")
-	router.HandleFunc("/container/{id}/update", opsHandler.HandleUpdate).Methods("POST")
-	router.HandleFunc("/container/{id}/start", opsHandler.HandleStart).Methods("POST")
-	router.HandleFunc("/container/{id}/stop", opsHandler.HandleStop).Methods("POST")
-	router.HandleFunc("/container/{id}/restart", opsHandler.HandleRestart).Methods("POST")
+	// Container routes without a host prefix address the default (first) host
+	for _, r := range []*mux.Router{router, router.PathPrefix("/host/{host}").Subrouter()} {
+		r.HandleFunc("/container/{id}", detailHandler.ServeHTTP).Methods("GET")
+		r.HandleFunc("/container/{id}/update", opsHandler.HandleUpdate).Methods("POST")
+		r.HandleFunc("/container/{id}/services/{service}/update", opsHandler.HandleServiceUpdate).Methods("POST")
+		r.HandleFunc("/container/{id}/history/{entry}/revert", opsHandler.HandleRevert).Methods("POST")
+		r.HandleFunc("/container/{id}/start", opsHandler.HandleStart).Methods("POST")
+		r.HandleFunc("/container/{id}/stop", opsHandler.HandleStop).Methods("POST")
+		r.HandleFunc("/container/{id}/restart", opsHandler.HandleRestart).Methods("POST")
+	}
+	router.HandleFunc("/updates/check", updatesHandler.HandleCheck).Methods("POST")
+	router.HandleFunc("/jobs/{id}", jobsHandler.HandleGet).Methods("GET")
+	router.HandleFunc("/jobs/{id}/events", jobsHandler.HandleEvents).Methods("GET")
+	router.Handle("/activity", activityHandler).Methods("GET")
+	router.Handle("/metrics", handlers.RequireAudit(promhttp.Handler())).Methods("GET")
+
+	// Versioned JSON API
+	api := router.PathPrefix(handlers.APIPrefix).Subrouter()
+	api.HandleFunc("/hosts", apiHandler.HandleListHosts).Methods("GET")
+	for _, r := range []*mux.Router{api, api.PathPrefix("/hosts/{host}").Subrouter()} {
+		r.HandleFunc("/groups", apiHandler.HandleListGroups).Methods("GET")
+		r.HandleFunc("/groups/{id}", apiHandler.HandleGetGroup).Methods("GET")
+		r.HandleFunc("/groups/{id}/update", opsHandler.HandleUpdate).Methods("POST")
+		r.HandleFunc("/groups/{id}/services/{service}/update", opsHandler.HandleServiceUpdate).Methods("POST")
+		r.HandleFunc("/groups/{id}/history", apiHandler.HandleGroupHistory).Methods("GET")
+		r.HandleFunc("/groups/{id}/history/{entry}/revert", opsHandler.HandleRevert).Methods("POST")
+		r.HandleFunc("/containers/{id}", apiHandler.HandleGetContainer).Methods("GET")
+		r.HandleFunc("/containers/{id}/start", opsHandler.HandleStart).Methods("POST")
+		r.HandleFunc("/containers/{id}/stop", opsHandler.HandleStop).Methods("POST")
+		r.HandleFunc("/containers/{id}/restart", opsHandler.HandleRestart).Methods("POST")
+	}
+	api.HandleFunc("/updates/check", updatesHandler.HandleCheck).Methods("POST")
+	api.HandleFunc("/jobs/{id}", jobsHandler.HandleGet).Methods("GET")
+	api.HandleFunc("/jobs/{id}/events", jobsHandler.HandleEvents).Methods("GET")
+	api.HandleFunc("/activity", activityHandler.HandleExport).Methods("GET")
+	api.HandleFunc("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "web/api/openapi.yaml")
+	}).Methods("GET")
 
 	// Serve static files
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
 
-	// Start HTTP server
+	// Agent connections are long-lived websockets, served outside the request
+	// middleware; agents authenticate with their own tokens
+	root := http.NewServeMux()
+	root.Handle(agent.Path, agentServer)
+	root.Handle("/", router)
+
+	// Start HTTP server until interrupted
 	addr := fmt.Sprintf(":%s", port)
+	server := &http.Server{Addr: addr, Handler: root}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
 	logger.Info("starting server", "address", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Error("server failed", "error", err)
 		os.Exit(1)
 	}
+	logger.Info("server stopped")
+
+	// Flush spans still waiting to be exported
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		logger.Warn("failed to flush traces", "error", err)
+	}
 }
 
 // initLogger initializes a structured logger with the specified level
@@ -112,7 +386,8 @@ func initLogger(level string) *slog.Logger {
 		Level: logLevel,
 	})
 
-	return slog.New(handler)
+	// Records logged with a traced context carry its trace and span IDs
+	return slog.New(tracing.NewLogHandler(handler))
 }
 
 // loadTemplates loads all HTML templates
@@ -125,7 +400,7 @@ func loadTemplates() (*template.Template, error) {
 }
 
 // verifyDockerConnection checks if the Docker daemon is accessible
-func verifyDockerConnection(cli *docker.Client) error {
+func verifyDockerConnection(cli docker.DockerClient) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -151,7 +426,7 @@ func loggingMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 
 			duration := time.Since(start)
 
-			logger.Info("http request",
+			logger.InfoContext(r.Context(), "http request",
 				"method", r.Method,
 				"path", r.URL.Path,
 				"status", wrapped.statusCode,
@@ -168,7 +443,7 @@ func recoveryMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
-					logger.Error("panic recovered",
+					logger.ErrorContext(r.Context(), "panic recovered",
 						"error", err,
 						"method", r.Method,
 						"path", r.URL.Path,
@@ -182,6 +457,78 @@ func recoveryMiddleware(logger *slog.Logger) mux.MiddlewareFunc {
 	}
 }
 
+// authMiddleware requires a session cookie or bearer token on every route
+// except the login page, static assets and the OpenAPI document. Browsers are
+// redirected to the login page; API clients get 401 Unauthorized. With
+// authentication disabled every request acts as auth.Anonymous.
+func authMiddleware(authenticator *auth.Authenticator, logger *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !authenticator.Enabled() {
+				next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), auth.Anonymous())))
+				return
+			}
+			if isPublicPath(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			identity, ok := authenticator.Authenticate(r)
+			if !ok {
+				logger.Warn("unauthenticated request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"remote_addr", r.RemoteAddr,
+				)
+
+				// Page loads go to the login form and come back afterwards
+				if r.Method == http.MethodGet && wantsHTML(r) {
+					http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+					return
+				}
+
+				w.Header().Set("WWW-Authenticate", `Bearer realm="BleedingEdge"`)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.OperationResult{
+					Success:   false,
+					Error:     "Authentication required",
+					Timestamp: time.Now(),
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+		})
+	}
+}
+
+// routeTemplate returns the path template of the route a request matched, e.g.
+// /container/{id}, so request spans are named by route rather than by path
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return ""
+}
+
+// isPublicPath reports whether a path is reachable without signing in
+func isPublicPath(path string) bool {
+	return path == "/login" ||
+		path == "/logout" ||
+		path == handlers.APIPrefix+"/openapi.yaml" ||
+		strings.HasPrefix(path, "/static/")
+}
+
+// wantsHTML reports whether a request comes from a browser navigating to a page
+func wantsHTML(r *http.Request) bool {
+	return !strings.HasPrefix(r.URL.Path, handlers.APIPrefix+"/") &&
+		r.Header.Get("HX-Request") == "" &&
+		strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
 // responseWriter wraps http.ResponseWriter to capture the status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -193,6 +540,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer so http.ResponseController can flush streamed responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -201,8 +553,19 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList splits a comma-separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
 // validateConfig validates the configuration values
-func validateConfig(port, logLevel, dockerHost, updateCheckTimeout string) error {
+func validateConfig(port, logLevel, dockerHost, updateCheckTimeout, updateCheckSchedule, updateCheckParallelism, registryCacheTTL, sessionTTL, smtpPort, digestSchedule string) error {
 	// Validate port
 	if port == "" {
 		return fmt.Errorf("PORT cannot be empty")
@@ -229,5 +592,37 @@ func validateConfig(port, logLevel, dockerHost, updateCheckTimeout string) error
 		return fmt.Errorf("invalid UPDATE_CHECK_TIMEOUT: %s (must be a valid duration like 5m, 10s, etc.)", updateCheckTimeout)
 	}
 
+	// Validate update check schedule
+	if _, err := scheduler.ParseSchedule(updateCheckSchedule); err != nil {
+		return fmt.Errorf("invalid UPDATE_CHECK_SCHEDULE: %w", err)
+	}
+
+	// Validate update check parallelism
+	if n, err := strconv.Atoi(updateCheckParallelism); err != nil || n < 1 {
+		return fmt.Errorf("invalid UPDATE_CHECK_PARALLELISM: %s (must be a positive number like 4)", updateCheckParallelism)
+	}
+
+	// Validate registry cache lifetime
+	if ttl, err := time.ParseDuration(registryCacheTTL); err != nil || ttl < 0 {
+		return fmt.Errorf("invalid REGISTRY_CACHE_TTL: %s (must be a duration like 15m, or 0 to disable the cache)", registryCacheTTL)
+	}
+
+	// Validate session lifetime
+	if ttl, err := time.ParseDuration(sessionTTL); err != nil || ttl <= 0 {
+		return fmt.Errorf("invalid SESSION_TTL: %s (must be a positive duration like 12h, 30m, etc.)", sessionTTL)
+	}
+
+	// Validate SMTP port
+	if _, err := strconv.Atoi(smtpPort); err != nil {
+		return fmt.Errorf("invalid SMTP_PORT: %s (must be a number like 587)", smtpPort)
+	}
+
+	// Validate digest schedule
+	if digestSchedule != "off" {
+		if _, err := scheduler.ParseSchedule(digestSchedule); err != nil {
+			return fmt.Errorf("invalid DIGEST_SCHEDULE: %w", err)
+		}
+	}
+
 	return nil
 }
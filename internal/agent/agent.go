@@ -0,0 +1,276 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"golang.org/x/net/websocket"
+)
+
+// Reconnection backoff bounds; the backoff resets once a connection stays up
+// for maxBackoff
+const (
+	minBackoff  = time.Second
+	maxBackoff  = time.Minute
+	dialTimeout = 30 * time.Second
+)
+
+// Config configures an agent
+type Config struct {
+	ServerURL string // BleedingEdge server to connect to, e.g. https://bleedingedge.example.com
+	Host      string // Name of the agent's host in the server's hosts configuration
+	Token     string // Token whose hex SHA-256 is configured as the host's agent hash
+	Insecure  bool   // Allow an http:// server URL, sending the token and every call unencrypted
+}
+
+// Agent serves a server's calls against the local Docker daemon
+type Agent struct {
+	cfg    Config
+	url    *url.URL // Websocket URL of the server's agent endpoint
+	client docker.DockerClient
+	logger *slog.Logger
+}
+
+// New creates an agent that serves calls with client
+func New(cfg Config, client docker.DockerClient, logger *slog.Logger) (*Agent, error) {
+	u, err := url.Parse(cfg.ServerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server url: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		if !cfg.Insecure {
+			return nil, fmt.Errorf("invalid server url %q: the token would be sent unencrypted, use https:// or allow http:// with --insecure", cfg.ServerURL)
+		}
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	default:
+		return nil, fmt.Errorf("invalid server url %q: must be http:// or https://", cfg.ServerURL)
+	}
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("host name is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + Path
+	u.RawQuery = url.Values{"host": {cfg.Host}}.Encode()
+
+	return &Agent{
+		cfg:    cfg,
+		url:    u,
+		client: client,
+		logger: logger.With("docker_host", cfg.Host),
+	}, nil
+}
+
+// Run connects to the server and serves its calls until ctx is cancelled,
+// reconnecting with exponential backoff whenever the connection is lost
+func (a *Agent) Run(ctx context.Context) {
+	backoff := minBackoff
+	for {
+		connected := time.Now()
+		err := a.connect(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(connected) > maxBackoff {
+			backoff = minBackoff
+		}
+		a.logger.Warn("connection to server lost",
+			"server", a.cfg.ServerURL,
+			"error", err,
+			"retry_in", backoff.String(),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// connect opens a connection to the server and serves it until it closes
+func (a *Agent) connect(ctx context.Context) error {
+	origin := *a.url
+	origin.Scheme = strings.Replace(origin.Scheme, "ws", "http", 1)
+	config, err := websocket.NewConfig(a.url.String(), origin.String())
+	if err != nil {
+		return err
+	}
+	config.Header.Set("Authorization", "Bearer "+a.cfg.Token)
+
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	conn, err := config.DialContext(dialCtx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	a.logger.Info("connected to server", "server", a.cfg.ServerURL)
+	return a.serve(ctx, conn)
+}
+
+// serve runs the calls received over conn until it closes or ctx is cancelled.
+// Calls run concurrently and are cancelled when the server asks or the
+// connection is lost.
+func (a *Agent) serve(ctx context.Context, conn *websocket.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	// Closing the connection unblocks Receive once ctx is cancelled
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	var sendMu sync.Mutex
+	send := func(msg message) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if err := websocket.JSON.Send(conn, msg); err != nil {
+			a.logger.Debug("failed to send message to server", "error", err)
+		}
+	}
+
+	var mu sync.Mutex
+	running := make(map[uint64]context.CancelFunc)
+	for {
+		// The server pings regularly, so silence means the connection is gone
+		conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		var msg message
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		switch msg.Type {
+		case typeCall:
+			callCtx, callCancel := context.WithCancel(ctx)
+			mu.Lock()
+			running[msg.ID] = callCancel
+			mu.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer callCancel()
+				reply := a.handle(jobs.WithReporter(callCtx, callReporter{id: msg.ID, send: send}), msg)
+				mu.Lock()
+				delete(running, msg.ID)
+				mu.Unlock()
+				send(reply)
+			}()
+		case typeCancel:
+			mu.Lock()
+			if callCancel, ok := running[msg.ID]; ok {
+				callCancel()
+			}
+			mu.Unlock()
+		}
+	}
+}
+
+// handle runs a call and returns its result message
+func (a *Agent) handle(ctx context.Context, msg message) message {
+	start := time.Now()
+	p := msg.Params
+	if p == nil {
+		p = &params{}
+	}
+
+	value, err := a.dispatch(ctx, msg.Method, p)
+	reply := message{ID: msg.ID, Type: typeResult}
+	if err == nil && value != nil {
+		reply.Result, err = json.Marshal(value)
+	}
+	if err != nil {
+		reply.Error = err.Error()
+	}
+
+	if msg.Method != methodPing {
+		a.logger.Debug("handled call from server",
+			"method", msg.Method,
+			"error", reply.Error,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+	return reply
+}
+
+// dispatch calls the Docker client method a call names
+func (a *Agent) dispatch(ctx context.Context, method string, p *params) (any, error) {
+	switch method {
+	case methodPing:
+		return nil, nil
+	case methodListContainers:
+		return result(a.client.ListContainers(ctx))
+	case methodInspectContainer:
+		return result(a.client.InspectContainer(ctx, p.ID))
+	case methodPullImage:
+		return nil, a.client.PullImage(ctx, p.Image)
+	case methodGetImageDigest:
+		return result(a.client.GetImageDigest(ctx, p.Image))
+	case methodInspectImage:
+		return result(a.client.InspectImage(ctx, p.Image))
+	case methodStartContainer:
+		return nil, a.client.StartContainer(ctx, p.ID)
+	case methodStopContainer:
+		return nil, a.client.StopContainer(ctx, p.ID)
+	case methodRestartContainer:
+		return nil, a.client.RestartContainer(ctx, p.ID)
+	case methodRemoveContainer:
+		return nil, a.client.RemoveContainer(ctx, p.ID)
+	case methodRenameContainer:
+		return nil, a.client.RenameContainer(ctx, p.ID, p.Name)
+	case methodContainerLogs:
+		return result(a.client.ContainerLogs(ctx, p.ID, p.Since, p.Tail))
+	case methodCreateContainer:
+		if p.Config == nil {
+			return nil, fmt.Errorf("container config is required")
+		}
+		return result(a.client.CreateContainer(ctx, p.Config, p.HostConfig, p.NetworkingConfig, p.Name))
+	case methodExecuteCommand:
+		// The server only runs docker compose; no other program is run for it
+		if p.Command != "docker" {
+			return nil, fmt.Errorf("command %q is not allowed", p.Command)
+		}
+		return nil, a.client.ExecuteCommand(ctx, p.WorkDir, p.Command, p.Args)
+	default:
+		return nil, fmt.Errorf("unknown method %q", method)
+	}
+}
+
+// result adapts a client method's return values to dispatch's
+func result[T any](value T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// callReporter sends the job events of a running call back to the server
+type callReporter struct {
+	id   uint64
+	send func(message)
+}
+
+// Report implements jobs.Reporter
+func (r callReporter) Report(event jobs.Event) {
+	r.send(message{ID: r.id, Type: typeEvent, Event: &event})
+}
@@ -0,0 +1,234 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// connectAgent runs a server and an agent serving mock in-process and returns
+// the server's client for the agent's host once the agent is connected
+func connectAgent(t *testing.T, mock *docker.MockClient) *Remote {
+	t.Helper()
+	server := NewServer(discardLogger)
+	remote := server.Register("edge", hashToken("secret"))
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	a, err := New(Config{ServerURL: ts.URL, Host: "edge", Token: "secret", Insecure: true}, mock, discardLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !remote.Connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("agent did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return remote
+}
+
+func TestAgentServesDockerClient(t *testing.T) {
+	var created struct {
+		image string
+		name  string
+		mode  container.NetworkMode
+	}
+	var logsSince time.Time
+	mock := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{{ID: "abc123", Names: []string{"/redis"}, Image: "redis:7"}}, nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			created.image, created.name, created.mode = config.Image, name, hostConfig.NetworkMode
+			return "def456", nil
+		},
+		ContainerLogsFunc: func(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+			logsSince = since
+			return "ready\n", nil
+		},
+		StartContainerFunc: func(ctx context.Context, id string) error {
+			return errors.New("no such container: " + id)
+		},
+	}
+	remote := connectAgent(t, mock)
+	ctx := context.Background()
+
+	containers, err := remote.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("ListContainers() error = %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != "abc123" || containers[0].Names[0] != "/redis" {
+		t.Errorf("unexpected containers %+v", containers)
+	}
+
+	id, err := remote.CreateContainer(ctx, &container.Config{Image: "redis:7"}, &container.HostConfig{NetworkMode: "host"}, nil, "redis")
+	if err != nil {
+		t.Fatalf("CreateContainer() error = %v", err)
+	}
+	if id != "def456" || created.image != "redis:7" || created.name != "redis" || created.mode != "host" {
+		t.Errorf("unexpected create: id %q, %+v", id, created)
+	}
+
+	since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if logs, err := remote.ContainerLogs(ctx, "abc123", since, 50); err != nil || logs != "ready\n" {
+		t.Errorf("ContainerLogs() = %q, %v", logs, err)
+	}
+	if !logsSince.Equal(since) {
+		t.Errorf("expected logs since %v, got %v", since, logsSince)
+	}
+
+	// The agent's errors are returned by the server's client
+	if err := remote.StartContainer(ctx, "gone"); err == nil || err.Error() != "no such container: gone" {
+		t.Errorf("expected the agent's error, got %v", err)
+	}
+
+	// Only docker commands are run on the agent's host
+	if err := remote.ExecuteCommand(ctx, "/srv/app", "sh", []string{"-c", "id"}); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected other commands to be refused, got %v", err)
+	}
+
+	if !docker.RunsCommandsRemotely(remote) {
+		t.Error("expected agent hosts to run commands remotely")
+	}
+}
+
+// eventRecorder is a jobs.Reporter that keeps the events it receives
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []jobs.Event
+}
+
+func (r *eventRecorder) Report(event jobs.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func TestAgentForwardsJobEvents(t *testing.T) {
+	mock := &docker.MockClient{
+		PullImageFunc: func(ctx context.Context, imageName string) error {
+			jobs.Progress(ctx, "layer1", "Downloading", 512, 1024)
+			jobs.Progress(ctx, "layer1", "Pull complete", 1024, 1024)
+			return nil
+		},
+		ExecuteCommandFunc: func(ctx context.Context, workDir string, command string, args []string) error {
+			jobs.Log(ctx, "Container app-web-1 Recreated")
+			return nil
+		},
+	}
+	remote := connectAgent(t, mock)
+
+	recorder := &eventRecorder{}
+	ctx := jobs.WithReporter(context.Background(), recorder)
+	if err := remote.PullImage(ctx, "nginx:latest"); err != nil {
+		t.Fatalf("PullImage() error = %v", err)
+	}
+	if err := remote.ExecuteCommand(ctx, "/srv/app", "docker", []string{"compose", "up", "-d"}); err != nil {
+		t.Fatalf("ExecuteCommand() error = %v", err)
+	}
+
+	// Events arrive before the result of the call that reported them
+	var messages []string
+	for _, event := range recorder.events {
+		messages = append(messages, string(event.Type)+" "+event.Message)
+	}
+	expected := []string{"progress Downloading", "progress Pull complete", "log Container app-web-1 Recreated"}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected events %v, got %v", expected, messages)
+	}
+	if recorder.events[0].Layer != "layer1" || recorder.events[0].Current != 512 || recorder.events[0].Total != 1024 {
+		t.Errorf("unexpected progress event %+v", recorder.events[0])
+	}
+}
+
+func TestAgentCancelsCalls(t *testing.T) {
+	cancelled := make(chan struct{})
+	mock := &docker.MockClient{
+		StopContainerFunc: func(ctx context.Context, id string) error {
+			<-ctx.Done()
+			close(cancelled)
+			return ctx.Err()
+		},
+	}
+	remote := connectAgent(t, mock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := remote.StopContainer(ctx, "abc123"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to end the call, got %v", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the call to be cancelled on the agent")
+	}
+
+	// The connection stays usable
+	if _, err := remote.ListContainers(context.Background()); err != nil {
+		t.Errorf("ListContainers() after a cancelled call error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		expectURL   string
+		expectError bool
+	}{
+		{name: "http", cfg: Config{ServerURL: "http://be.lan:8080", Host: "nas", Token: "t"}, expectError: true},
+		{name: "insecure http", cfg: Config{ServerURL: "http://be.lan:8080", Host: "nas", Token: "t", Insecure: true}, expectURL: "ws://be.lan:8080/agent/connect?host=nas"},
+		{name: "https with path", cfg: Config{ServerURL: "https://example.com/bleedingedge/", Host: "nas", Token: "t"}, expectURL: "wss://example.com/bleedingedge/agent/connect?host=nas"},
+		{name: "unsupported scheme", cfg: Config{ServerURL: "ftp://example.com", Host: "nas", Token: "t"}, expectError: true},
+		{name: "missing host", cfg: Config{ServerURL: "https://example.com", Token: "t"}, expectError: true},
+		{name: "missing token", cfg: Config{ServerURL: "https://example.com", Host: "nas"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cfg, &docker.MockClient{}, discardLogger)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("New() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && a.url.String() != tt.expectURL {
+				t.Errorf("expected url %s, got %s", tt.expectURL, a.url)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package agent lets BleedingEdge manage Docker hosts it cannot reach directly.
+// An agent runs next to the Docker socket on the host, dials out to the server
+// over an authenticated websocket and serves the docker.DockerClient calls the
+// server sends it, so hosts behind NAT or firewalls need no inbound port.
+package agent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Path is where agents connect to the server, with their host's name in the
+// host query parameter and their token as a bearer token
+const Path = "/agent/connect"
+
+// Message types. The server sends calls and cancels; the agent answers each call
+// with a result, reporting job events for it while it runs.
+const (
+	typeCall   = "call"
+	typeCancel = "cancel"
+	typeEvent  = "event"
+	typeResult = "result"
+)
+
+// Methods mirror docker.DockerClient; ping only checks the connection is alive
+const (
+	methodPing             = "ping"
+	methodListContainers   = "list_containers"
+	methodInspectContainer = "inspect_container"
+	methodPullImage        = "pull_image"
+	methodGetImageDigest   = "get_image_digest"
+	methodInspectImage     = "inspect_image"
+	methodStartContainer   = "start_container"
+	methodStopContainer    = "stop_container"
+	methodRestartContainer = "restart_container"
+	methodRemoveContainer  = "remove_container"
+	methodRenameContainer  = "rename_container"
+	methodContainerLogs    = "container_logs"
+	methodCreateContainer  = "create_container"
+	methodExecuteCommand   = "execute_command"
+)
+
+// pingInterval is how often the server checks an agent connection is alive.
+// Agents reconnect when they hear nothing for idleTimeout.
+const (
+	pingInterval = 30 * time.Second
+	idleTimeout  = 3 * pingInterval
+)
+
+// message is a single websocket frame in either direction
+type message struct {
+	ID     uint64          `json:"id"`               // Call the message belongs to
+	Type   string          `json:"type"`             // call, cancel, event or result
+	Method string          `json:"method,omitempty"` // Called method, for calls
+	Params *params         `json:"params,omitempty"` // Arguments, for calls
+	Result json.RawMessage `json:"result,omitempty"` // Return value, for results
+	Error  string          `json:"error,omitempty"`  // Why the call failed, for results
+	Event  *jobs.Event     `json:"event,omitempty"`  // Progress reported by the call, for events
+}
+
+// params holds the arguments of every method; each uses the fields it needs
+type params struct {
+	ID               string                    `json:"id,omitempty"`
+	Image            string                    `json:"image,omitempty"`
+	Name             string                    `json:"name,omitempty"`
+	Since            time.Time                 `json:"since,omitempty"`
+	Tail             int                       `json:"tail,omitempty"`
+	Config           *container.Config         `json:"config,omitempty"`
+	HostConfig       *container.HostConfig     `json:"host_config,omitempty"`
+	NetworkingConfig *network.NetworkingConfig `json:"networking_config,omitempty"`
+	WorkDir          string                    `json:"work_dir,omitempty"`
+	Command          string                    `json:"command,omitempty"`
+	Args             []string                  `json:"args,omitempty"`
+}
@@ -0,0 +1,378 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/websocket"
+)
+
+// ErrNotConnected is returned by calls to a host whose agent is not connected
+var ErrNotConnected = errors.New("agent is not connected")
+
+// Server accepts connections from the agents of configured agent hosts
+type Server struct {
+	logger  *slog.Logger
+	mu      sync.Mutex
+	remotes map[string]*Remote
+}
+
+// NewServer creates a server without any agent hosts
+func NewServer(logger *slog.Logger) *Server {
+	return &Server{
+		logger:  logger,
+		remotes: make(map[string]*Remote),
+	}
+}
+
+// Register adds an agent host whose agent authenticates with a token of the
+// given hex SHA-256 hash. The returned client serves the host while its agent
+// is connected.
+func (s *Server) Register(name, hash string) *Remote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remote := &Remote{name: name, hash: strings.ToLower(hash)}
+	s.remotes[name] = remote
+	return remote
+}
+
+// Client registers a configured agent host, for docker.ConnectHosts
+func (s *Server) Client(cfg docker.HostConfig) docker.DockerClient {
+	return s.Register(cfg.Name, cfg.Agent.Hash)
+}
+
+// ServeHTTP accepts an agent's websocket connection and serves its host's
+// calls over it until it closes
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("host")
+	s.mu.Lock()
+	remote := s.remotes[name]
+	s.mu.Unlock()
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if remote == nil || !remote.authorized(token) {
+		s.logger.Warn("rejected agent connection",
+			"docker_host", name,
+			"remote_addr", r.RemoteAddr,
+		)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Agents are not browsers, so there is no Origin to check
+	websocket.Server{Handler: func(conn *websocket.Conn) {
+		s.logger.Info("agent connected", "docker_host", name, "remote_addr", r.RemoteAddr)
+		err := remote.serve(conn)
+		s.logger.Warn("agent disconnected", "docker_host", name, "error", err)
+	}}.ServeHTTP(w, r)
+}
+
+// Remote is the docker.DockerClient of an agent host. Its calls are sent to the
+// host's agent and fail with ErrNotConnected while no agent is connected.
+type Remote struct {
+	name string
+	hash string
+
+	mu      sync.Mutex
+	session *session
+}
+
+// authorized reports whether token hashes to the host's configured hash
+func (r *Remote) authorized(token string) bool {
+	sum := sha256.Sum256([]byte(token))
+	return token != "" && subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(r.hash)) == 1
+}
+
+// serve makes conn the host's connection, replacing any previous one, and
+// handles it until it closes
+func (r *Remote) serve(conn *websocket.Conn) error {
+	sess := newSession(conn)
+	r.mu.Lock()
+	previous := r.session
+	r.session = sess
+	r.mu.Unlock()
+	if previous != nil {
+		previous.close(errors.New("replaced by a new connection"))
+	}
+
+	go sess.keepAlive()
+	err := sess.readLoop()
+
+	r.mu.Lock()
+	if r.session == sess {
+		r.session = nil
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// Connected reports whether the host's agent is connected
+func (r *Remote) Connected() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session != nil
+}
+
+// Close drops the agent's connection; the agent reconnects on its own
+func (r *Remote) Close() error {
+	r.mu.Lock()
+	sess := r.session
+	r.mu.Unlock()
+	if sess != nil {
+		sess.close(errors.New("server closed the connection"))
+	}
+	return nil
+}
+
+// RunsCommandsRemotely reports that docker compose runs on the agent's host,
+// where the compose files are
+func (r *Remote) RunsCommandsRemotely() bool {
+	return true
+}
+
+// call sends a call to the host's agent and decodes its result into result
+func (r *Remote) call(ctx context.Context, method string, p params, result any) (err error) {
+	ctx, span := tracing.Start(ctx, "agent."+method, attribute.String("bleedingedge.host", r.name))
+	defer func() { tracing.End(span, err) }()
+
+	r.mu.Lock()
+	sess := r.session
+	r.mu.Unlock()
+	if sess == nil {
+		return fmt.Errorf("host %s: %w", r.name, ErrNotConnected)
+	}
+	return sess.call(ctx, method, p, result)
+}
+
+// ListContainers lists all containers on the agent's host
+func (r *Remote) ListContainers(ctx context.Context) ([]types.Container, error) {
+	var containers []types.Container
+	err := r.call(ctx, methodListContainers, params{}, &containers)
+	return containers, err
+}
+
+// InspectContainer returns detailed information about a container
+func (r *Remote) InspectContainer(ctx context.Context, id string) (types.ContainerJSON, error) {
+	var inspect types.ContainerJSON
+	err := r.call(ctx, methodInspectContainer, params{ID: id}, &inspect)
+	return inspect, err
+}
+
+// PullImage pulls an image; the agent reports layer progress to ctx's job
+func (r *Remote) PullImage(ctx context.Context, imageName string) error {
+	return r.call(ctx, methodPullImage, params{Image: imageName}, nil)
+}
+
+// GetImageDigest returns the digest of an image
+func (r *Remote) GetImageDigest(ctx context.Context, imageName string) (string, error) {
+	var digest string
+	err := r.call(ctx, methodGetImageDigest, params{Image: imageName}, &digest)
+	return digest, err
+}
+
+// InspectImage returns detailed information about a local image
+func (r *Remote) InspectImage(ctx context.Context, imageName string) (image.InspectResponse, error) {
+	var inspect image.InspectResponse
+	err := r.call(ctx, methodInspectImage, params{Image: imageName}, &inspect)
+	return inspect, err
+}
+
+// StartContainer starts a container
+func (r *Remote) StartContainer(ctx context.Context, id string) error {
+	return r.call(ctx, methodStartContainer, params{ID: id}, nil)
+}
+
+// StopContainer stops a container
+func (r *Remote) StopContainer(ctx context.Context, id string) error {
+	return r.call(ctx, methodStopContainer, params{ID: id}, nil)
+}
+
+// RestartContainer restarts a container
+func (r *Remote) RestartContainer(ctx context.Context, id string) error {
+	return r.call(ctx, methodRestartContainer, params{ID: id}, nil)
+}
+
+// RemoveContainer removes a container
+func (r *Remote) RemoveContainer(ctx context.Context, id string) error {
+	return r.call(ctx, methodRemoveContainer, params{ID: id}, nil)
+}
+
+// RenameContainer renames a container
+func (r *Remote) RenameContainer(ctx context.Context, id string, newName string) error {
+	return r.call(ctx, methodRenameContainer, params{ID: id, Name: newName}, nil)
+}
+
+// ContainerLogs returns up to tail lines of a container's output written since the given time
+func (r *Remote) ContainerLogs(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+	var logs string
+	err := r.call(ctx, methodContainerLogs, params{ID: id, Since: since, Tail: tail}, &logs)
+	return logs, err
+}
+
+// CreateContainer creates a new container
+func (r *Remote) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+	var id string
+	err := r.call(ctx, methodCreateContainer, params{
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: networkingConfig,
+		Name:             name,
+	}, &id)
+	return id, err
+}
+
+// ExecuteCommand runs a command on the agent's host; agents only run docker
+func (r *Remote) ExecuteCommand(ctx context.Context, workDir string, command string, args []string) error {
+	return r.call(ctx, methodExecuteCommand, params{WorkDir: workDir, Command: command, Args: args}, nil)
+}
+
+// session is one agent connection. Calls are multiplexed over it by ID.
+type session struct {
+	conn   *websocket.Conn
+	sendMu sync.Mutex
+
+	mu     sync.Mutex
+	nextID uint64
+	calls  map[uint64]*pendingCall
+
+	closeOnce sync.Once
+	done      chan struct{}
+	err       error // Why the session closed, set before done is closed
+}
+
+// pendingCall is a call waiting for its result
+type pendingCall struct {
+	ctx    context.Context // Receives the job events the agent reports
+	result chan message
+}
+
+func newSession(conn *websocket.Conn) *session {
+	return &session{
+		conn:  conn,
+		calls: make(map[uint64]*pendingCall),
+		done:  make(chan struct{}),
+	}
+}
+
+// send writes a message to the agent
+func (s *session) send(msg message) error {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	return websocket.JSON.Send(s.conn, msg)
+}
+
+// call sends a call and waits for its result. If ctx is done first, the agent
+// is told to cancel the call.
+func (s *session) call(ctx context.Context, method string, p params, result any) error {
+	pending := &pendingCall{ctx: ctx, result: make(chan message, 1)}
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.calls[id] = pending
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.calls, id)
+		s.mu.Unlock()
+	}()
+
+	if err := s.send(message{ID: id, Type: typeCall, Method: method, Params: &p}); err != nil {
+		s.close(err)
+		return s.err
+	}
+
+	select {
+	case msg := <-pending.result:
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if result != nil && len(msg.Result) > 0 {
+			return json.Unmarshal(msg.Result, result)
+		}
+		return nil
+	case <-ctx.Done():
+		s.send(message{ID: id, Type: typeCancel})
+		return ctx.Err()
+	case <-s.done:
+		return s.err
+	}
+}
+
+// readLoop routes the agent's events and results to their calls until the
+// connection closes
+func (s *session) readLoop() error {
+	for {
+		var msg message
+		if err := websocket.JSON.Receive(s.conn, &msg); err != nil {
+			s.close(err)
+			return s.err
+		}
+
+		s.mu.Lock()
+		pending := s.calls[msg.ID]
+		s.mu.Unlock()
+		if pending == nil {
+			// The call was cancelled or timed out; drop what is left of it
+			continue
+		}
+		switch msg.Type {
+		case typeEvent:
+			if msg.Event != nil {
+				jobs.Forward(pending.ctx, *msg.Event)
+			}
+		case typeResult:
+			select {
+			case pending.result <- msg:
+			default:
+			}
+		}
+	}
+}
+
+// keepAlive pings the agent until the session closes, closing it if the agent
+// stops answering
+func (s *session) keepAlive() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), pingInterval)
+			err := s.call(ctx, methodPing, params{}, nil)
+			cancel()
+			if err != nil {
+				s.close(fmt.Errorf("agent did not answer ping: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// close ends the session, failing its pending calls with err
+func (s *session) close(err error) {
+	s.closeOnce.Do(func() {
+		s.err = fmt.Errorf("agent connection lost: %w", err)
+		close(s.done)
+		s.conn.Close()
+	})
+}
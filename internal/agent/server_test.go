@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+)
+
+func TestServerAuthenticatesAgents(t *testing.T) {
+	server := NewServer(discardLogger)
+	server.Register("edge", hashToken("secret"))
+
+	tests := []struct {
+		name  string
+		host  string
+		token string
+	}{
+		{name: "wrong token", host: "edge", token: "guess"},
+		{name: "no token", host: "edge"},
+		{name: "unknown host", host: "other", token: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, Path+"?host="+tt.host, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestRemoteNotConnected(t *testing.T) {
+	server := NewServer(discardLogger)
+	remote := server.Client(docker.HostConfig{Name: "edge", Agent: &docker.AgentConfig{Hash: hashToken("secret")}})
+
+	if _, err := remote.ListContainers(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected, got %v", err)
+	}
+}
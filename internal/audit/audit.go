@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SystemUser is recorded as the user of operations BleedingEdge runs on its own
+const SystemUser = "system"
+
+// Operations recorded in the audit log
+const (
+	OperationUpdate     = "update"
+	OperationStart      = "start"
+	OperationStop       = "stop"
+	OperationRestart    = "restart"
+	OperationAutoUpdate = "auto-update"
+	OperationRevert     = "revert"
+)
+
+// Result is the outcome of a recorded operation
+type Result string
+
+const (
+	// ResultSuccess means the operation completed
+	ResultSuccess Result = "success"
+	// ResultFailure means the operation failed; the record's Error says why
+	ResultFailure Result = "failure"
+)
+
+// recordsBucket holds records keyed by their big-endian ID, so keys sort by age
+var recordsBucket = []byte("records")
+
+// ImageChange is the image a container ran before and after an operation
+type ImageChange struct {
+	Container string `json:"container"`        // Container name
+	Image     string `json:"image"`            // Image reference, e.g. nginx:latest
+	Before    string `json:"before,omitempty"` // Repo digest before the operation
+	After     string `json:"after,omitempty"`  // Repo digest after the operation
+}
+
+// Record is one operation in the audit log
+type Record struct {
+	ID         uint64                `json:"id"`               // Assigned by Append, increasing
+	Time       time.Time             `json:"time"`             // When the operation started
+	User       string                `json:"user"`             // Who ran it; SystemUser for automatic updates, empty without authentication
+	Operation  string                `json:"operation"`        // update, start, stop, restart, auto-update or revert
+	Host       string                `json:"host,omitempty"`   // Docker host the target runs on
+	Target     string                `json:"target"`           // Container, compose project or project/service name
+	TargetID   string                `json:"target_id"`        // Container ID or compose project name
+	Images     []ImageChange         `json:"images,omitempty"` // Digests of the affected containers, for updates
+	DurationMS int64                 `json:"duration_ms"`      // How long the operation took
+	Result     Result                `json:"result"`           // success or failure
+	Error      *models.ErrorResponse `json:"error,omitempty"`  // Set when Result is failure
+}
+
+// ImageChanges pairs each container with the digests it ran before and after
+// an operation, both keyed by container name
+func ImageChanges(containers []models.ContainerInfo, before, after map[string]string) []ImageChange {
+	var changes []ImageChange
+	for _, c := range containers {
+		changes = append(changes, ImageChange{
+			Container: c.Name,
+			Image:     c.Image,
+			Before:    before[c.Name],
+			After:     after[c.Name],
+		})
+	}
+	return changes
+}
+
+// Filter selects records from the audit log. Zero fields match everything.
+type Filter struct {
+	User      string    // Exact user
+	Operation string    // Exact operation
+	Host      string    // Exact Docker host
+	Target    string    // Case-insensitive substring of the target name
+	Result    Result    // Exact result
+	Since     time.Time // Records at or after this time
+	Until     time.Time // Records before this time
+	Limit     int       // Maximum number of records, newest first
+}
+
+// Matches reports whether a record is selected by the filter
+func (f Filter) Matches(record Record) bool {
+	switch {
+	case f.User != "" && record.User != f.User:
+		return false
+	case f.Operation != "" && record.Operation != f.Operation:
+		return false
+	case f.Host != "" && record.Host != f.Host:
+		return false
+	case f.Target != "" && !strings.Contains(strings.ToLower(record.Target), strings.ToLower(f.Target)):
+		return false
+	case f.Result != "" && record.Result != f.Result:
+		return false
+	case !f.Since.IsZero() && record.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !record.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Log is the persistent audit log of container operations, stored in an
+// embedded bbolt database
+type Log struct {
+	db *bolt.DB
+}
+
+// Open opens the audit log at path, creating the file and its directory if needed
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(recordsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize audit log: %w", err)
+	}
+
+	return &Log{db: db}, nil
+}
+
+// Close closes the underlying database
+func (l *Log) Close() error {
+	return l.db.Close()
+}
+
+// Append stores a record, assigning its ID, and returns the stored record
+func (l *Log) Append(record Record) (Record, error) {
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(recordsBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(recordKey(id), data)
+	})
+	if err != nil {
+		return record, fmt.Errorf("failed to append audit record: %w", err)
+	}
+	return record, nil
+}
+
+// List returns the records selected by the filter, newest first
+func (l *Log) List(filter Filter) ([]Record, error) {
+	records := []Record{}
+	err := l.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(recordsBucket).Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var record Record
+			if err := json.Unmarshal(value, &record); err != nil {
+				return fmt.Errorf("record %d: %w", binary.BigEndian.Uint64(key), err)
+			}
+			if !filter.Matches(record) {
+				continue
+			}
+			records = append(records, record)
+			if filter.Limit > 0 && len(records) >= filter.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return records, nil
+}
+
+// recordKey encodes a record ID as a sortable bucket key
+func recordKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+func openTestLog(t *testing.T) (*Log, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "data", "audit.db")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { log.Close() })
+	return log, path
+}
+
+func TestLogAppendAndList(t *testing.T) {
+	log, path := openTestLog(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	records := []Record{
+		{Time: start, User: "alice", Operation: OperationStart, Target: "nginx", Result: ResultSuccess},
+		{Time: start.Add(time.Hour), User: "bob", Operation: OperationUpdate, Target: "shop/web", Result: ResultFailure,
+			Images: []ImageChange{{Container: "shop-web-1", Image: "nginx:latest", Before: "sha256:old", After: "sha256:old"}},
+			Error:  &models.ErrorResponse{Operation: "update", Container: "shop/web", Message: "Failed to pull image.", RolledBack: true}},
+		{Time: start.Add(2 * time.Hour), User: SystemUser, Operation: OperationAutoUpdate, Host: "edge", Target: "Shop", Result: ResultSuccess},
+	}
+	for _, record := range records {
+		stored, err := log.Append(record)
+		if err != nil {
+			t.Fatalf("failed to append: %v", err)
+		}
+		if stored.ID == 0 {
+			t.Error("expected an ID to be assigned")
+		}
+	}
+
+	tests := []struct {
+		name      string
+		filter    Filter
+		expectIDs []uint64
+	}{
+		{name: "everything newest first", expectIDs: []uint64{3, 2, 1}},
+		{name: "by user", filter: Filter{User: "alice"}, expectIDs: []uint64{1}},
+		{name: "by operation", filter: Filter{Operation: OperationUpdate}, expectIDs: []uint64{2}},
+		{name: "by host", filter: Filter{Host: "edge"}, expectIDs: []uint64{3}},
+		{name: "by target substring", filter: Filter{Target: "shop"}, expectIDs: []uint64{3, 2}},
+		{name: "by result", filter: Filter{Result: ResultSuccess}, expectIDs: []uint64{3, 1}},
+		{name: "time range", filter: Filter{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)}, expectIDs: []uint64{2}},
+		{name: "limit", filter: Filter{Limit: 1}, expectIDs: []uint64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := log.List(tt.filter)
+			if err != nil {
+				t.Fatalf("failed to list: %v", err)
+			}
+			if len(got) != len(tt.expectIDs) {
+				t.Fatalf("expected %d records, got %d", len(tt.expectIDs), len(got))
+			}
+			for i, id := range tt.expectIDs {
+				if got[i].ID != id {
+					t.Errorf("record %d: expected ID %d, got %d", i, id, got[i].ID)
+				}
+			}
+		})
+	}
+
+	// Records survive reopening the database
+	log.Close()
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen audit log: %v", err)
+	}
+	defer reopened.Close()
+
+	got, err := reopened.List(Filter{Operation: OperationUpdate})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("expected the update record after reopening, got %v (%v)", got, err)
+	}
+	if got[0].Error == nil || !got[0].Error.RolledBack || got[0].Images[0].Before != "sha256:old" {
+		t.Errorf("expected error details and digests to round-trip, got %+v", got[0])
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	records := []Record{
+		{ID: 2, Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), User: "bob", Operation: OperationUpdate, Host: "local", Target: "nginx",
+			Result: ResultFailure, DurationMS: 1500,
+			Images: []ImageChange{{Container: "nginx", Image: "nginx:latest", Before: "sha256:a", After: "sha256:b"}},
+			Error:  &models.ErrorResponse{Message: "Port is already in use, again", Details: "line1\nline2"}},
+		{ID: 1, User: "alice", Operation: OperationStart, Target: "redis", Result: ResultSuccess},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, records); err != nil {
+		t.Fatalf("failed to write CSV: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV back: %v", err)
+	}
+	if len(rows) != 3 || len(rows[0]) != len(csvHeader) {
+		t.Fatalf("expected header and 2 rows of %d columns, got %v", len(csvHeader), rows)
+	}
+
+	row := rows[1]
+	expected := map[int]string{
+		0: "2", 1: "2024-05-01T12:00:00Z", 2: "bob", 6: "failure", 7: "1500",
+		8: "nginx nginx:latest sha256:a->sha256:b", 9: "Port is already in use, again", 10: "line1\nline2", 11: "false", 12: "local",
+	}
+	for col, value := range expected {
+		if row[col] != value {
+			t.Errorf("column %s: expected %q, got %q", csvHeader[col], value, row[col])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader names the columns written by WriteCSV
+var csvHeader = []string{
+	"id", "time", "user", "operation", "target", "target_id", "result",
+	"duration_ms", "images", "error", "details", "rolled_back", "host",
+}
+
+// WriteCSV writes records as CSV with a header row. Image changes are joined
+// into one column as "container image before->after" entries separated by "; ".
+func WriteCSV(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		var images []string
+		for _, change := range record.Images {
+			images = append(images, change.Container+" "+change.Image+" "+change.Before+"->"+change.After)
+		}
+
+		var errMsg, details, rolledBack string
+		if record.Error != nil {
+			errMsg = record.Error.Message
+			details = record.Error.Details
+			rolledBack = strconv.FormatBool(record.Error.RolledBack)
+		}
+
+		row := []string{
+			strconv.FormatUint(record.ID, 10),
+			record.Time.UTC().Format(time.RFC3339),
+			record.User,
+			record.Operation,
+			record.Target,
+			record.TargetID,
+			string(record.Result),
+			strconv.FormatInt(record.DurationMS, 10),
+			strings.Join(images, "; "),
+			errMsg,
+			details,
+			rolledBack,
+			record.Host,
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionCookie is the name of the cookie holding the UI session ID
+const SessionCookie = "bleedingedge_session"
+
+// ErrInvalidCredentials is returned when a username or password is wrong
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// dummyHash is compared against when a username is unknown so that failed
+// logins take the same time whether or not the user exists
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("bleedingedge"), bcrypt.DefaultCost)
+
+// Method is how a request was authenticated
+type Method string
+
+const (
+	// MethodSession means the request carried a UI session cookie
+	MethodSession Method = "session"
+	// MethodToken means the request carried a bearer API token
+	MethodToken Method = "token"
+	// MethodNone means authentication is disabled, see Anonymous
+	MethodNone Method = "none"
+)
+
+// Identity is the authenticated user behind a request
+type Identity struct {
+	Username string
+	Method   Method
+	Token    string  // Token name when Method is MethodToken
+	Grants   []Grant // Roles the user holds, see Identity.Can
+}
+
+// Anonymous is the identity given to every request when authentication is
+// disabled: an unnamed admin
+func Anonymous() Identity {
+	return Identity{Method: MethodNone, Grants: []Grant{{Role: RoleAdmin}}}
+}
+
+// Session is a signed-in UI session
+type Session struct {
+	ID       string
+	Username string
+	Expires  time.Time
+}
+
+// Authenticator checks passwords, API tokens and session cookies
+type Authenticator struct {
+	users  map[string]User
+	tokens map[string]Token // Keyed by token hash
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]Session
+}
+
+// NewAuthenticator creates an authenticator for the configured users and
+// tokens. Sessions expire ttl after sign-in and are kept in memory.
+func NewAuthenticator(cfg *Config, ttl time.Duration) *Authenticator {
+	a := &Authenticator{
+		users:    make(map[string]User),
+		tokens:   make(map[string]Token),
+		ttl:      ttl,
+		sessions: make(map[string]Session),
+	}
+	for _, user := range cfg.Users {
+		a.users[user.Username] = user
+	}
+	for _, token := range cfg.Tokens {
+		a.tokens[strings.ToLower(token.Hash)] = token
+	}
+	return a
+}
+
+// Enabled reports whether any users are configured; without users the
+// server runs unauthenticated
+func (a *Authenticator) Enabled() bool {
+	return len(a.users) > 0
+}
+
+// Login checks a username and password and starts a new session
+func (a *Authenticator) Login(username, password string) (Session, error) {
+	user, ok := a.users[username]
+	hash := []byte(user.PasswordHash)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return Session{}, ErrInvalidCredentials
+	}
+
+	session := Session{
+		ID:       newSessionID(),
+		Username: username,
+		Expires:  time.Now().Add(a.ttl),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prune()
+	a.sessions[session.ID] = session
+	return session, nil
+}
+
+// Logout ends a session
+func (a *Authenticator) Logout(sessionID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, sessionID)
+}
+
+// Authenticate identifies the user behind a request from its bearer token or session cookie
+func (a *Authenticator) Authenticate(r *http.Request) (Identity, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			return Identity{}, false
+		}
+		return a.authenticateToken(strings.TrimSpace(token))
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return Identity{}, false
+	}
+	return a.authenticateSession(cookie.Value)
+}
+
+// authenticateToken looks up a bearer token by its hash
+func (a *Authenticator) authenticateToken(token string) (Identity, bool) {
+	if token == "" {
+		return Identity{}, false
+	}
+	t, ok := a.tokens[HashToken(token)]
+	if !ok {
+		return Identity{}, false
+	}
+	return Identity{Username: t.User, Method: MethodToken, Token: t.Name, Grants: a.users[t.User].EffectiveGrants()}, true
+}
+
+// authenticateSession looks up an unexpired session
+func (a *Authenticator) authenticateSession(id string) (Identity, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	session, ok := a.sessions[id]
+	if !ok {
+		return Identity{}, false
+	}
+	if time.Now().After(session.Expires) {
+		delete(a.sessions, id)
+		return Identity{}, false
+	}
+	return Identity{Username: session.Username, Method: MethodSession, Grants: a.users[session.Username].EffectiveGrants()}, true
+}
+
+// SetSessionCookie sends the session cookie for a new session
+func SetSessionCookie(w http.ResponseWriter, r *http.Request, session Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.Expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie removes the session cookie from the browser
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// prune drops expired sessions; a.mu must be held
+func (a *Authenticator) prune() {
+	now := time.Now()
+	for id, session := range a.sessions {
+		if now.After(session.Expires) {
+			delete(a.sessions, id)
+		}
+	}
+}
+
+// newSessionID returns a random session identifier
+func newSessionID() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context that carries the authenticated user
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFrom returns the authenticated user carried by the context, if any
+func IdentityFrom(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
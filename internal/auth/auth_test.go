@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hashPassword(t *testing.T, password string) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	return string(hash)
+}
+
+func TestLoadConfig(t *testing.T) {
+	hash := hashPassword(t, "secret")
+	tokenHash := HashToken("token")
+
+	file := filepath.Join(t.TempDir(), "auth.yaml")
+	content := "users:\n  - username: admin\n    password_hash: \"" + hash + "\"\ntokens:\n  - name: ci\n    user: admin\n    hash: " + tokenHash + "\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write auth file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		users        string
+		tokens       string
+		expectError  bool
+		expectUsers  int
+		expectTokens int
+	}{
+		{name: "nothing configured", expectUsers: 0},
+		{name: "file", path: file, expectUsers: 1, expectTokens: 1},
+		{name: "env", users: "alice:" + hash + ", bob:" + hash, tokens: "ci:alice:" + tokenHash, expectUsers: 2, expectTokens: 1},
+		{name: "file and env", path: file, users: "alice:" + hash, expectUsers: 2, expectTokens: 1},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), expectError: true},
+		{name: "plaintext password", users: "alice:secret", expectError: true},
+		{name: "duplicate user", path: file, users: "admin:" + hash, expectError: true},
+		{name: "malformed user", users: "alice", expectError: true},
+		{name: "token for unknown user", users: "alice:" + hash, tokens: "ci:bob:" + tokenHash, expectError: true},
+		{name: "token not hashed", users: "alice:" + hash, tokens: "ci:alice:token", expectError: true},
+		{name: "env with role", users: "alice:" + hash + ":operator", expectUsers: 1},
+		{name: "unknown role", users: "alice:" + hash + ":root", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadConfig(tt.path, tt.users, tt.tokens)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(cfg.Users) != tt.expectUsers || len(cfg.Tokens) != tt.expectTokens {
+				t.Errorf("expected %d users and %d tokens, got %d and %d", tt.expectUsers, tt.expectTokens, len(cfg.Users), len(cfg.Tokens))
+			}
+		})
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	cfg := &Config{
+		Users:  []User{{Username: "admin", PasswordHash: hashPassword(t, "secret")}},
+		Tokens: []Token{{Name: "ci", User: "admin", Hash: HashToken("s3cr3t-token")}},
+	}
+	a := NewAuthenticator(cfg, time.Hour)
+
+	if !a.Enabled() {
+		t.Fatal("expected authentication to be enabled")
+	}
+	if _, err := a.Login("admin", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := a.Login("nobody", "secret"); err != ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	session, err := a.Login("admin", "secret")
+	if err != nil {
+		t.Fatalf("expected login to succeed: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		header       string
+		cookie       string
+		expectOK     bool
+		expectMethod Method
+	}{
+		{name: "no credentials"},
+		{name: "session cookie", cookie: session.ID, expectOK: true, expectMethod: MethodSession},
+		{name: "unknown session", cookie: "bogus"},
+		{name: "bearer token", header: "Bearer s3cr3t-token", expectOK: true, expectMethod: MethodToken},
+		{name: "wrong token", header: "Bearer nope"},
+		{name: "basic auth", header: "Basic YWRtaW46c2VjcmV0"},
+		{name: "bad header wins over cookie", header: "Bearer nope", cookie: session.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+
+			identity, ok := a.Authenticate(req)
+			if ok != tt.expectOK {
+				t.Fatalf("expected ok=%v, got %v", tt.expectOK, ok)
+			}
+			if ok && (identity.Username != "admin" || identity.Method != tt.expectMethod) {
+				t.Errorf("unexpected identity %+v", identity)
+			}
+		})
+	}
+
+	a.Logout(session.ID)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.ID})
+	if _, ok := a.Authenticate(req); ok {
+		t.Error("expected session to be invalid after logout")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	cfg := &Config{Users: []User{{Username: "admin", PasswordHash: hashPassword(t, "secret")}}}
+	a := NewAuthenticator(cfg, -time.Second)
+
+	session, err := a.Login("admin", "secret")
+	if err != nil {
+		t.Fatalf("expected login to succeed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookie, Value: session.ID})
+	if _, ok := a.Authenticate(req); ok {
+		t.Error("expected expired session to be rejected")
+	}
+}
+
+func TestIdentityCan(t *testing.T) {
+	web := map[string]string{"com.docker.compose.project": "web", "team": "frontend"}
+	db := map[string]string{"com.docker.compose.project": "db", "team": "data"}
+	standalone := map[string]string{"team": "frontend"}
+
+	tests := []struct {
+		name    string
+		user    User
+		action  Action
+		labels  map[string]string
+		allowed bool
+	}{
+		{name: "no roles means admin", user: User{}, action: ActionUpdate, labels: db, allowed: true},
+		{name: "viewer can view", user: User{Role: RoleViewer}, action: ActionView, labels: web, allowed: true},
+		{name: "viewer cannot operate", user: User{Role: RoleViewer}, action: ActionOperate, labels: web},
+		{name: "operator can operate", user: User{Role: RoleOperator}, action: ActionOperate, labels: web, allowed: true},
+		{name: "operator cannot update", user: User{Role: RoleOperator}, action: ActionUpdate, labels: web},
+		{name: "updater can update", user: User{Role: RoleUpdater}, action: ActionUpdate, labels: web, allowed: true},
+		{
+			name:    "project grant in scope",
+			user:    User{Role: RoleViewer, Grants: []Grant{{Role: RoleUpdater, Projects: []string{"web"}}}},
+			action:  ActionUpdate,
+			labels:  web,
+			allowed: true,
+		},
+		{
+			name:   "project grant out of scope",
+			user:   User{Role: RoleViewer, Grants: []Grant{{Role: RoleUpdater, Projects: []string{"web"}}}},
+			action: ActionUpdate,
+			labels: db,
+		},
+		{
+			name:   "project grant never covers standalone containers",
+			user:   User{Grants: []Grant{{Role: RoleUpdater, Projects: []string{"web"}}}},
+			action: ActionView,
+			labels: standalone,
+		},
+		{
+			name:    "label grant in scope",
+			user:    User{Grants: []Grant{{Role: RoleOperator, Labels: map[string]string{"team": "frontend"}}}},
+			action:  ActionOperate,
+			labels:  standalone,
+			allowed: true,
+		},
+		{
+			name:   "label grant out of scope",
+			user:   User{Grants: []Grant{{Role: RoleOperator, Labels: map[string]string{"team": "frontend"}}}},
+			action: ActionView,
+			labels: db,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := Identity{Username: "alice", Grants: tt.user.EffectiveGrants()}
+			if got := identity.Can(tt.action, tt.labels); got != tt.allowed {
+				t.Errorf("expected allowed=%v, got %v", tt.allowed, got)
+			}
+		})
+	}
+}
+
+func TestAuthenticateCarriesGrants(t *testing.T) {
+	cfg := &Config{
+		Users:  []User{{Username: "viewer", PasswordHash: hashPassword(t, "secret"), Role: RoleViewer}},
+		Tokens: []Token{{Name: "ci", User: "viewer", Hash: HashToken("token")}},
+	}
+	a := NewAuthenticator(cfg, time.Hour)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	identity, ok := a.Authenticate(req)
+	if !ok {
+		t.Fatal("expected token to authenticate")
+	}
+	if !identity.Can(ActionView, nil) || identity.CanAny(ActionOperate) {
+		t.Errorf("expected token to act with the viewer role, got %+v", identity.Grants)
+	}
+
+	if Allowed(context.Background(), ActionView, nil) || AllowedAny(context.Background(), ActionView) {
+		t.Error("expected requests without an identity to be denied")
+	}
+	if !Allowed(WithIdentity(context.Background(), Anonymous()), ActionAudit, nil) {
+		t.Error("expected the anonymous identity to be allowed everything")
+	}
+	if Allowed(WithIdentity(context.Background(), identity), ActionUpdate, nil) {
+		t.Error("expected viewer to be denied updates")
+	}
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// User is a local account that can sign in to the web UI
+type User struct {
+	Username     string  `yaml:"username"`
+	PasswordHash string  `yaml:"password_hash"`    // bcrypt hash, e.g. from `htpasswd -nbB user password`
+	Role         Role    `yaml:"role,omitempty"`   // Role on every container
+	Grants       []Grant `yaml:"grants,omitempty"` // Roles limited to some compose projects or labels
+}
+
+// EffectiveGrants returns the grants the user signs in with. Users without a
+// role or grants are admins, as they were before roles existed.
+func (u User) EffectiveGrants() []Grant {
+	var grants []Grant
+	if u.Role != "" {
+		grants = append(grants, Grant{Role: u.Role})
+	}
+	grants = append(grants, u.Grants...)
+	if len(grants) == 0 {
+		grants = []Grant{{Role: RoleAdmin}}
+	}
+	return grants
+}
+
+// Token is a bearer API token belonging to a user
+type Token struct {
+	Name string `yaml:"name"` // Label shown in logs, e.g. "ci"
+	User string `yaml:"user"` // Username the token acts as
+	Hash string `yaml:"hash"` // Hex SHA-256 of the token, see HashToken
+}
+
+// Config lists the users and API tokens allowed to access the server
+type Config struct {
+	Users  []User  `yaml:"users"`
+	Tokens []Token `yaml:"tokens"`
+}
+
+// LoadConfig reads users and tokens from an optional YAML file and from the
+// AUTH_USERS and AUTH_TOKENS style lists. users is a comma-separated list of
+// "username:bcrypt-hash[:role]" entries and tokens of "name:username:sha256-hex" entries.
+func LoadConfig(path, users, tokens string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read auth file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse auth file %s: %w", path, err)
+		}
+	}
+
+	for _, entry := range splitList(users) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid user entry %q: expected username:bcrypt-hash[:role]", entry)
+		}
+		user := User{Username: parts[0], PasswordHash: parts[1]}
+		if len(parts) == 3 {
+			user.Role = Role(parts[2])
+		}
+		cfg.Users = append(cfg.Users, user)
+	}
+
+	for _, entry := range splitList(tokens) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid token entry %q: expected name:username:sha256-hex", entry)
+		}
+		cfg.Tokens = append(cfg.Tokens, Token{Name: parts[0], User: parts[1], Hash: parts[2]})
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that every user has a bcrypt hash and known roles, and that
+// every token belongs to a known user
+func (c *Config) Validate() error {
+	users := make(map[string]bool)
+	for _, user := range c.Users {
+		if user.Username == "" {
+			return fmt.Errorf("user with empty username")
+		}
+		if users[user.Username] {
+			return fmt.Errorf("duplicate user %q", user.Username)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return fmt.Errorf("user %q: password_hash is not a bcrypt hash: %w", user.Username, err)
+		}
+		if user.Role != "" && !user.Role.Valid() {
+			return fmt.Errorf("user %q: unknown role %q (must be viewer, operator, updater or admin)", user.Username, user.Role)
+		}
+		for _, grant := range user.Grants {
+			if err := grant.validate(); err != nil {
+				return fmt.Errorf("user %q: %w", user.Username, err)
+			}
+		}
+		users[user.Username] = true
+	}
+
+	for _, token := range c.Tokens {
+		if !users[token.User] {
+			return fmt.Errorf("token %q: unknown user %q", token.Name, token.User)
+		}
+		if b, err := hex.DecodeString(token.Hash); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("token %q: hash must be a hex SHA-256 digest", token.Name)
+		}
+	}
+
+	return nil
+}
+
+// HashToken returns the hex SHA-256 digest stored in place of a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
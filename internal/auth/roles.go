@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// composeProjectLabel is the label Docker Compose sets to a container's project name
+const composeProjectLabel = "com.docker.compose.project"
+
+// Role is a named set of actions a user may perform
+type Role string
+
+const (
+	// RoleViewer can see the grid and detail pages
+	RoleViewer Role = "viewer"
+	// RoleOperator can also start, stop and restart containers
+	RoleOperator Role = "operator"
+	// RoleUpdater can also update containers and run update checks
+	RoleUpdater Role = "updater"
+	// RoleAdmin can also view the activity log; users without any roles are admins
+	RoleAdmin Role = "admin"
+)
+
+// Action is something a user does to a container, or to the server as a whole
+type Action string
+
+const (
+	// ActionView shows a container on the grid, detail page and API
+	ActionView Action = "view"
+	// ActionOperate starts, stops or restarts a container
+	ActionOperate Action = "operate"
+	// ActionUpdate recreates a container on a newer image
+	ActionUpdate Action = "update"
+	// ActionAudit views and exports the activity log of every container
+	ActionAudit Action = "audit"
+)
+
+// roleLevels orders roles so each one includes the actions of those below it
+var roleLevels = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleUpdater:  3,
+	RoleAdmin:    4,
+}
+
+// actionLevels is the lowest role level allowed to perform each action
+var actionLevels = map[Action]int{
+	ActionView:    roleLevels[RoleViewer],
+	ActionOperate: roleLevels[RoleOperator],
+	ActionUpdate:  roleLevels[RoleUpdater],
+	ActionAudit:   roleLevels[RoleAdmin],
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Allows reports whether the role includes an action
+func (r Role) Allows(action Action) bool {
+	level, ok := actionLevels[action]
+	return ok && roleLevels[r] >= level
+}
+
+// Grant gives a user a role, optionally limited to some containers. A grant
+// without projects or labels applies to every container.
+type Grant struct {
+	Role     Role              `yaml:"role"`
+	Projects []string          `yaml:"projects,omitempty"` // Compose projects the grant covers
+	Labels   map[string]string `yaml:"labels,omitempty"`   // Label selector; containers must carry every label with the given value
+}
+
+// Matches reports whether the grant covers a container with the given labels
+func (g Grant) Matches(labels map[string]string) bool {
+	if len(g.Projects) > 0 {
+		project := labels[composeProjectLabel]
+		found := false
+		for _, p := range g.Projects {
+			found = found || (project != "" && p == project)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for key, value := range g.Labels {
+		if v, ok := labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks that the grant names a known role
+func (g Grant) validate() error {
+	if !g.Role.Valid() {
+		return fmt.Errorf("unknown role %q (must be viewer, operator, updater or admin)", g.Role)
+	}
+	return nil
+}
+
+// Can reports whether any of the identity's grants allows the action on a
+// container with the given labels
+func (i Identity) Can(action Action, labels map[string]string) bool {
+	for _, grant := range i.Grants {
+		if grant.Role.Allows(action) && grant.Matches(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAny reports whether the identity may perform the action on at least some containers
+func (i Identity) CanAny(action Action) bool {
+	for _, grant := range i.Grants {
+		if grant.Role.Allows(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether the user behind the context may perform the action
+// on a container with the given labels. A context without an identity is
+// allowed nothing; with authentication disabled requests carry Anonymous.
+func Allowed(ctx context.Context, action Action, labels map[string]string) bool {
+	identity, ok := IdentityFrom(ctx)
+	return ok && identity.Can(action, labels)
+}
+
+// AllowedAny reports whether the user behind the context may perform the
+// action on at least some containers
+func AllowedAny(ctx context.Context, action Action) bool {
+	identity, ok := IdentityFrom(ctx)
+	return ok && identity.CanAny(action)
+}
@@ -0,0 +1,306 @@
+// Package credentials looks up the credentials used to pull images from and
+// check updates against private registries.
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dockerHub is the normalized host of Docker Hub
+const dockerHub = "docker.io"
+
+// dockerHubServerURL is the key the Docker CLI stores Docker Hub credentials under
+const dockerHubServerURL = "https://index.docker.io/v1/"
+
+// Credential helper results are cached for helperTTL; helperTimeout bounds one run
+const (
+	helperTTL     = 5 * time.Minute
+	helperTimeout = 10 * time.Second
+)
+
+// Credentials authenticate to a registry
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string // OAuth refresh token stored by `docker login` for some registries, used instead of a password
+}
+
+// Empty reports whether c holds no credentials, i.e. access is anonymous
+func (c Credentials) Empty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+// RegistryConfig holds the credentials for one registry in a credentials file
+type RegistryConfig struct {
+	Registry     string `yaml:"registry"`                // Registry host, e.g. ghcr.io or harbor.example.com:8443
+	Username     string `yaml:"username"`                // User or robot account
+	Password     string `yaml:"password,omitempty"`      // Password or access token
+	PasswordFile string `yaml:"password_file,omitempty"` // File holding the password, e.g. a Docker secret
+}
+
+// FileConfig is the BleedingEdge credentials file
+type FileConfig struct {
+	Registries []RegistryConfig `yaml:"registries"`
+}
+
+// dockerConfig is the part of the Docker CLI's config.json holding credentials
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// Store looks up registry credentials from a credentials file and the Docker
+// CLI's config.json, in that order. Docker credential helpers configured with
+// credsStore or credHelpers are run as docker-credential-<name> binaries.
+type Store struct {
+	files       map[string]Credentials // From the credentials file, keyed by normalized host
+	auths       map[string]Credentials // From config.json, keyed by normalized host
+	credsStore  string
+	credHelpers map[string]string // Helper name keyed by normalized host
+	logger      *slog.Logger
+
+	// helper runs a credential helper; replaced in tests
+	helper func(ctx context.Context, name, serverURL string) (Credentials, error)
+
+	mu     sync.Mutex
+	cached map[string]cachedCredentials
+}
+
+// cachedCredentials is a credential helper result
+type cachedCredentials struct {
+	credentials Credentials
+	err         error
+	expires     time.Time
+}
+
+// DefaultDockerConfigDir returns the Docker CLI's default configuration
+// directory, ~/.docker, used when DOCKER_CONFIG is not set
+func DefaultDockerConfigDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// Load reads config.json from dockerConfigDir, if it exists, and the optional
+// credentials file at path
+func Load(dockerConfigDir, path string, logger *slog.Logger) (*Store, error) {
+	s := &Store{
+		files:       make(map[string]Credentials),
+		auths:       make(map[string]Credentials),
+		credHelpers: make(map[string]string),
+		logger:      logger,
+		helper:      runHelper,
+		cached:      make(map[string]cachedCredentials),
+	}
+
+	if dockerConfigDir != "" {
+		if err := s.loadDockerConfig(filepath.Join(dockerConfigDir, "config.json")); err != nil {
+			return nil, err
+		}
+	}
+	if path != "" {
+		if err := s.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// loadDockerConfig reads the auths and credential helpers of a Docker CLI
+// config.json; a missing file is not an error
+func (s *Store) loadDockerConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read docker config: %w", err)
+	}
+
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse docker config %s: %w", path, err)
+	}
+
+	for server, auth := range cfg.Auths {
+		creds := Credentials{Username: auth.Username, Password: auth.Password, IdentityToken: auth.IdentityToken}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return fmt.Errorf("invalid auth for %s in docker config %s: %w", server, path, err)
+			}
+			username, password, ok := strings.Cut(string(decoded), ":")
+			if !ok {
+				return fmt.Errorf("invalid auth for %s in docker config %s: expected user:password", server, path)
+			}
+			creds.Username, creds.Password = username, password
+		}
+		// With a credsStore, auths entries are empty placeholders
+		if !creds.Empty() {
+			s.auths[normalizeHost(server)] = creds
+		}
+	}
+	s.credsStore = cfg.CredsStore
+	for server, helper := range cfg.CredHelpers {
+		s.credHelpers[normalizeHost(server)] = helper
+	}
+	return nil
+}
+
+// loadFile reads a BleedingEdge credentials file
+func (s *Store) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var cfg FileConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+	}
+
+	for _, registry := range cfg.Registries {
+		if registry.Registry == "" {
+			return fmt.Errorf("credentials file %s: registry is required", path)
+		}
+		if registry.Password != "" && registry.PasswordFile != "" {
+			return fmt.Errorf("credentials file %s: registry %s has both password and password_file", path, registry.Registry)
+		}
+		password := registry.Password
+		if registry.PasswordFile != "" {
+			data, err := os.ReadFile(registry.PasswordFile)
+			if err != nil {
+				return fmt.Errorf("credentials file %s: registry %s: %w", path, registry.Registry, err)
+			}
+			password = strings.TrimRight(string(data), "\r\n")
+		}
+		s.files[normalizeHost(registry.Registry)] = Credentials{Username: registry.Username, Password: password}
+	}
+	return nil
+}
+
+// Lookup returns the credentials for a registry host, or empty credentials
+// for anonymous access. A nil store always returns empty credentials.
+func (s *Store) Lookup(ctx context.Context, host string) (Credentials, error) {
+	if s == nil {
+		return Credentials{}, nil
+	}
+	host = normalizeHost(host)
+
+	if creds, ok := s.files[host]; ok {
+		return creds, nil
+	}
+	if helper, ok := s.credHelpers[host]; ok {
+		return s.fromHelper(ctx, helper, host)
+	}
+	// A failing credsStore, e.g. one from a desktop machine whose helper is not
+	// installed here, falls back to the auths entries and anonymous access
+	if s.credsStore != "" {
+		if creds, err := s.fromHelper(ctx, s.credsStore, host); err == nil && !creds.Empty() {
+			return creds, nil
+		}
+	}
+	return s.auths[host], nil
+}
+
+// fromHelper asks a credential helper for a host's credentials, caching the
+// answer, or its failure, for helperTTL
+func (s *Store) fromHelper(ctx context.Context, helper, host string) (Credentials, error) {
+	key := helper + "|" + host
+	s.mu.Lock()
+	cached, ok := s.cached[key]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.credentials, cached.err
+	}
+
+	serverURL := host
+	if host == dockerHub {
+		serverURL = dockerHubServerURL
+	}
+	ctx, cancel := context.WithTimeout(ctx, helperTimeout)
+	defer cancel()
+	creds, err := s.helper(ctx, helper, serverURL)
+	if err != nil {
+		s.logger.Warn("credential helper failed",
+			"helper", helper,
+			"registry", host,
+			"error", err,
+		)
+		err = fmt.Errorf("credential helper %s failed for %s: %w", helper, host, err)
+	}
+
+	s.mu.Lock()
+	s.cached[key] = cachedCredentials{credentials: creds, err: err, expires: time.Now().Add(helperTTL)}
+	s.mu.Unlock()
+	return creds, err
+}
+
+// runHelper runs `docker-credential-<name> get` with the server URL on stdin,
+// as the Docker CLI does
+func runHelper(ctx context.Context, name, serverURL string) (Credentials, error) {
+	cmd := exec.CommandContext(ctx, "docker-credential-"+name, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Helpers report a missing entry on stdout with a failing exit code
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return Credentials{}, nil
+		}
+		return Credentials{}, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()+stdout.String()))
+	}
+	return parseHelperOutput(stdout.Bytes())
+}
+
+// parseHelperOutput decodes a credential helper's answer. A username of
+// <token> marks the secret as an identity token.
+func parseHelperOutput(output []byte) (Credentials, error) {
+	var body struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(output, &body); err != nil {
+		return Credentials{}, fmt.Errorf("invalid credential helper output: %w", err)
+	}
+	if body.Username == "<token>" {
+		return Credentials{IdentityToken: body.Secret}, nil
+	}
+	return Credentials{Username: body.Username, Password: body.Secret}, nil
+}
+
+// normalizeHost reduces a registry address as written in config files, e.g.
+// https://ghcr.io/v1/, to its host. Every Docker Hub address becomes docker.io.
+func normalizeHost(server string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	host, _, _ = strings.Cut(host, "/")
+	host = strings.ToLower(host)
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHub
+	}
+	return host
+}
@@ -0,0 +1,203 @@
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	hubAuth := base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))
+	writeFile(t, dir, "config.json", `{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "`+hubAuth+`"},
+			"ghcr.io": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("octocat:from-config"))+`"},
+			"quay.io": {"identitytoken": "refresh-me"},
+			"desktop.example.com": {}
+		},
+		"credsStore": "desktop",
+		"credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}
+	}`)
+	writeFile(t, dir, "harbor-token", "robot-secret\n")
+	file := writeFile(t, dir, "credentials.yml", `
+registries:
+  - registry: https://ghcr.io
+    username: octocat
+    password: from-file
+  - registry: harbor.example.com:8443
+    username: robot$bleedingedge
+    password_file: `+filepath.Join(dir, "harbor-token")+`
+`)
+
+	store, err := Load(dir, file, discardLogger)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var helperCalls []string
+	store.helper = func(ctx context.Context, name, serverURL string) (Credentials, error) {
+		helperCalls = append(helperCalls, name+" "+serverURL)
+		switch {
+		case name == "ecr-login":
+			return Credentials{Username: "AWS", Password: "ecr-token"}, nil
+		case name == "desktop" && serverURL == "desktop.example.com":
+			return Credentials{Username: "me", Password: "from-desktop"}, nil
+		case name == "desktop":
+			return Credentials{}, nil
+		}
+		return Credentials{}, errors.New("unexpected helper " + name)
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		expected Credentials
+	}{
+		{name: "docker hub api host", host: "registry-1.docker.io", expected: Credentials{Username: "hubuser", Password: "hubpass"}},
+		{name: "file takes precedence", host: "ghcr.io", expected: Credentials{Username: "octocat", Password: "from-file"}},
+		{name: "password file", host: "harbor.example.com:8443", expected: Credentials{Username: "robot$bleedingedge", Password: "robot-secret"}},
+		{name: "identity token", host: "quay.io", expected: Credentials{IdentityToken: "refresh-me"}},
+		{name: "registry helper", host: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", expected: Credentials{Username: "AWS", Password: "ecr-token"}},
+		{name: "creds store", host: "desktop.example.com", expected: Credentials{Username: "me", Password: "from-desktop"}},
+		{name: "anonymous", host: "registry.example.com", expected: Credentials{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.Lookup(context.Background(), tt.host)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	// Helper answers are cached
+	calls := len(helperCalls)
+	if _, err := store.Lookup(context.Background(), "desktop.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(helperCalls) != calls {
+		t.Errorf("expected the cached helper answer to be reused, got calls %v", helperCalls)
+	}
+}
+
+func TestLookupHelperFailure(t *testing.T) {
+	dir := t.TempDir()
+	auth := base64.StdEncoding.EncodeToString([]byte("octocat:ghp_x"))
+	writeFile(t, dir, "config.json", `{
+		"auths": {"ghcr.io": {"auth": "`+auth+`"}},
+		"credsStore": "desktop",
+		"credHelpers": {"gcr.io": "gcloud"}
+	}`)
+	store, err := Load(dir, "", discardLogger)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	calls := 0
+	store.helper = func(ctx context.Context, name, serverURL string) (Credentials, error) {
+		calls++
+		return Credentials{}, errors.New("executable file not found")
+	}
+
+	// A registry's own helper failing is an error, and so is the cached failure
+	for range 2 {
+		if _, err := store.Lookup(context.Background(), "gcr.io"); err == nil {
+			t.Error("expected the helper's error")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the failure to be cached, got %d helper runs", calls)
+	}
+
+	// A failing credsStore falls back to auths and anonymous access
+	if creds, err := store.Lookup(context.Background(), "ghcr.io"); err != nil || creds.Username != "octocat" {
+		t.Errorf("expected the auths entry, got %+v, %v", creds, err)
+	}
+	if creds, err := store.Lookup(context.Background(), "quay.io"); err != nil || !creds.Empty() {
+		t.Errorf("expected anonymous access, got %+v, %v", creds, err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		file        string
+		expectError bool
+	}{
+		{name: "no config", expectError: false},
+		{name: "invalid config", config: `{"auths":`, expectError: true},
+		{name: "invalid auth", config: `{"auths": {"ghcr.io": {"auth": "bm8tY29sb24="}}}`, expectError: true},
+		{name: "missing registry", file: "registries:\n  - username: me\n    password: secret\n", expectError: true},
+		{name: "password and password file", file: "registries:\n  - registry: ghcr.io\n    password: a\n    password_file: /run/secrets/b\n", expectError: true},
+		{name: "missing password file", file: "registries:\n  - registry: ghcr.io\n    password_file: /nonexistent\n", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.config != "" {
+				writeFile(t, dir, "config.json", tt.config)
+			}
+			var file string
+			if tt.file != "" {
+				file = writeFile(t, dir, "credentials.yml", tt.file)
+			}
+			_, err := Load(dir, file, discardLogger)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Load() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestParseHelperOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		expected    Credentials
+		expectError bool
+	}{
+		{name: "password", output: `{"ServerURL":"ghcr.io","Username":"octocat","Secret":"ghp_x"}`, expected: Credentials{Username: "octocat", Password: "ghp_x"}},
+		{name: "identity token", output: `{"ServerURL":"https://index.docker.io/v1/","Username":"<token>","Secret":"refresh"}`, expected: Credentials{IdentityToken: "refresh"}},
+		{name: "garbage", output: `not json`, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHelperOutput([]byte(tt.output))
+			if (err != nil) != tt.expectError {
+				t.Fatalf("parseHelperOutput() error = %v, expectError %v", err, tt.expectError)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNilStoreIsAnonymous(t *testing.T) {
+	var store *Store
+	if creds, err := store.Lookup(context.Background(), "ghcr.io"); err != nil || !creds.Empty() {
+		t.Errorf("expected anonymous access, got %+v, %v", creds, err)
+	}
+}
@@ -1,16 +1,32 @@
 package docker
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
+	"strconv"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DockerClient defines the interface for Docker operations
@@ -19,18 +35,24 @@ type DockerClient interface {
 	InspectContainer(ctx context.Context, id string) (types.ContainerJSON, error)
 	PullImage(ctx context.Context, imageName string) error
 	GetImageDigest(ctx context.Context, imageName string) (string, error)
+	InspectImage(ctx context.Context, imageName string) (image.InspectResponse, error)
 	StartContainer(ctx context.Context, id string) error
 	StopContainer(ctx context.Context, id string) error
 	RestartContainer(ctx context.Context, id string) error
 	RemoveContainer(ctx context.Context, id string) error
-	CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error)
+	RenameContainer(ctx context.Context, id string, newName string) error
+	ContainerLogs(ctx context.Context, id string, since time.Time, tail int) (string, error)
+	CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error)
 	ExecuteCommand(ctx context.Context, workDir string, command string, args []string) error
 }
 
 // Client is a concrete implementation of DockerClient
 type Client struct {
-	cli    *client.Client
-	logger *slog.Logger
+	cli     *client.Client
+	logger  *slog.Logger
+	cliArgs []string // Global docker CLI flags selecting the same daemon, e.g. --host
+
+	credentials *credentials.Store // Registry credentials sent with pulls
 }
 
 // NewClient creates a new Docker client
@@ -61,6 +83,19 @@ func NewClientWithLogger(logger *slog.Logger) (*Client, error) {
 	}, nil
 }
 
+// WithCredentials sends the registry credentials in store with image pulls
+func (c *Client) WithCredentials(store *credentials.Store) *Client {
+	c.credentials = store
+	return c
+}
+
+// observeRequest records the latency and outcome of a Docker Engine API call in
+// the metrics and ends its trace span
+func observeRequest(span trace.Span, method string, duration time.Duration, err error) {
+	metrics.ObserveDockerRequest(method, duration, err)
+	tracing.End(span, err)
+}
+
 // Close closes the Docker client connection
 func (c *Client) Close() error {
 	return c.cli.Close()
@@ -69,20 +104,22 @@ func (c *Client) Close() error {
 // ListContainers lists all containers (running and stopped)
 func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error) {
 	start := time.Now()
-	c.logger.Debug("listing containers")
+	ctx, span := tracing.Start(ctx, "docker.container_list")
+	c.logger.DebugContext(ctx, "listing containers")
 	
 	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_list", duration, err)
 	if err != nil {
-		c.logger.Error("failed to list containers",
+		c.logger.ErrorContext(ctx, "failed to list containers",
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
 		)
 		return nil, err
 	}
 	
-	c.logger.Debug("listed containers successfully",
+	c.logger.DebugContext(ctx, "listed containers successfully",
 		"count", len(containers),
 		"duration_ms", duration.Milliseconds(),
 	)
@@ -92,13 +129,15 @@ func (c *Client) ListContainers(ctx context.Context) ([]types.Container, error)
 // InspectContainer returns detailed information about a container
 func (c *Client) InspectContainer(ctx context.Context, id string) (types.ContainerJSON, error) {
 	start := time.Now()
-	c.logger.Debug("inspecting container", "container_id", id)
+	ctx, span := tracing.Start(ctx, "docker.container_inspect", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "inspecting container", "container_id", id)
 	
 	containerJSON, err := c.cli.ContainerInspect(ctx, id)
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_inspect", duration, err)
 	if err != nil {
-		c.logger.Error("failed to inspect container",
+		c.logger.ErrorContext(ctx, "failed to inspect container",
 			"container_id", id,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -106,7 +145,7 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (types.Contain
 		return types.ContainerJSON{}, err
 	}
 	
-	c.logger.Debug("inspected container successfully",
+	c.logger.DebugContext(ctx, "inspected container successfully",
 		"container_id", id,
 		"container_name", containerJSON.Name,
 		"duration_ms", duration.Milliseconds(),
@@ -117,12 +156,18 @@ func (c *Client) InspectContainer(ctx context.Context, id string) (types.Contain
 // PullImage pulls an image from the registry
 func (c *Client) PullImage(ctx context.Context, imageName string) error {
 	start := time.Now()
-	c.logger.Debug("pulling image", "image", imageName)
+	ctx, span := tracing.Start(ctx, "docker.image_pull", attribute.String("container.image.name", imageName))
+	c.logger.DebugContext(ctx, "pulling image", "image", imageName)
 	
-	out, err := c.cli.ImagePull(ctx, imageName, image.PullOptions{})
+	options, err := c.pullOptions(ctx, imageName)
+	var out io.ReadCloser
+	if err == nil {
+		out, err = c.cli.ImagePull(ctx, imageName, options)
+	}
 	if err != nil {
 		duration := time.Since(start)
-		c.logger.Error("failed to pull image",
+		observeRequest(span, "image_pull", duration, err)
+		c.logger.ErrorContext(ctx, "failed to pull image",
 			"image", imageName,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -130,13 +175,15 @@ func (c *Client) PullImage(ctx context.Context, imageName string) error {
 		return err
 	}
 	defer out.Close()
-	
-	// Consume the output to ensure the pull completes
-	_, err = io.Copy(io.Discard, out)
-	
+
+	// Consume the output to ensure the pull completes, forwarding layer progress
+	// to the job reporter (if any) and surfacing errors reported mid-stream
+	err = decodePullProgress(ctx, out)
+
 	duration := time.Since(start)
+	observeRequest(span, "image_pull", duration, err)
 	if err != nil {
-		c.logger.Error("failed to complete image pull",
+		c.logger.ErrorContext(ctx, "failed to complete image pull",
 			"image", imageName,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -144,23 +191,72 @@ func (c *Client) PullImage(ctx context.Context, imageName string) error {
 		return err
 	}
 	
-	c.logger.Debug("pulled image successfully",
+	c.logger.DebugContext(ctx, "pulled image successfully",
 		"image", imageName,
 		"duration_ms", duration.Milliseconds(),
 	)
 	return nil
 }
 
+// pullOptions returns the options for pulling an image, with the credentials
+// of its registry if there are any
+func (c *Client) pullOptions(ctx context.Context, imageName string) (image.PullOptions, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return image.PullOptions{}, fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+	host := reference.Domain(named)
+	creds, err := c.credentials.Lookup(ctx, host)
+	if err != nil || creds.Empty() {
+		return image.PullOptions{}, err
+	}
+
+	auth, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      creds.Username,
+		Password:      creds.Password,
+		IdentityToken: creds.IdentityToken,
+		ServerAddress: host,
+	})
+	if err != nil {
+		return image.PullOptions{}, err
+	}
+	return image.PullOptions{RegistryAuth: auth}, nil
+}
+
+// decodePullProgress reads a pull's JSON message stream until it ends
+func decodePullProgress(ctx context.Context, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+
+		var current, total int64
+		if msg.Progress != nil {
+			current, total = msg.Progress.Current, msg.Progress.Total
+		}
+		jobs.Progress(ctx, msg.ID, msg.Status, current, total)
+	}
+}
+
 // GetImageDigest returns the digest of an image
 func (c *Client) GetImageDigest(ctx context.Context, imageName string) (string, error) {
 	start := time.Now()
-	c.logger.Debug("getting image digest", "image", imageName)
+	ctx, span := tracing.Start(ctx, "docker.image_inspect", attribute.String("container.image.name", imageName))
+	c.logger.DebugContext(ctx, "getting image digest", "image", imageName)
 	
 	inspect, _, err := c.cli.ImageInspectWithRaw(ctx, imageName)
 	
 	duration := time.Since(start)
+	observeRequest(span, "image_inspect", duration, err)
 	if err != nil {
-		c.logger.Error("failed to get image digest",
+		c.logger.ErrorContext(ctx, "failed to get image digest",
 			"image", imageName,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -176,7 +272,7 @@ func (c *Client) GetImageDigest(ctx context.Context, imageName string) (string,
 		digest = inspect.ID
 	}
 	
-	c.logger.Debug("got image digest successfully",
+	c.logger.DebugContext(ctx, "got image digest successfully",
 		"image", imageName,
 		"digest", digest,
 		"duration_ms", duration.Milliseconds(),
@@ -184,16 +280,45 @@ func (c *Client) GetImageDigest(ctx context.Context, imageName string) (string,
 	return digest, nil
 }
 
+// InspectImage returns detailed information about a local image
+func (c *Client) InspectImage(ctx context.Context, imageName string) (image.InspectResponse, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "docker.image_inspect", attribute.String("container.image.name", imageName))
+	c.logger.DebugContext(ctx, "inspecting image", "image", imageName)
+	
+	inspect, err := c.cli.ImageInspect(ctx, imageName)
+	
+	duration := time.Since(start)
+	observeRequest(span, "image_inspect", duration, err)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to inspect image",
+			"image", imageName,
+			"error", err,
+			"duration_ms", duration.Milliseconds(),
+		)
+		return image.InspectResponse{}, err
+	}
+	
+	c.logger.DebugContext(ctx, "inspected image successfully",
+		"image", imageName,
+		"repo_digests", len(inspect.RepoDigests),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return inspect, nil
+}
+
 // StartContainer starts a container
 func (c *Client) StartContainer(ctx context.Context, id string) error {
 	start := time.Now()
-	c.logger.Debug("starting container", "container_id", id)
+	ctx, span := tracing.Start(ctx, "docker.container_start", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "starting container", "container_id", id)
 	
 	err := c.cli.ContainerStart(ctx, id, container.StartOptions{})
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_start", duration, err)
 	if err != nil {
-		c.logger.Error("failed to start container",
+		c.logger.ErrorContext(ctx, "failed to start container",
 			"container_id", id,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -201,7 +326,7 @@ func (c *Client) StartContainer(ctx context.Context, id string) error {
 		return err
 	}
 	
-	c.logger.Debug("started container successfully",
+	c.logger.DebugContext(ctx, "started container successfully",
 		"container_id", id,
 		"duration_ms", duration.Milliseconds(),
 	)
@@ -211,13 +336,15 @@ func (c *Client) StartContainer(ctx context.Context, id string) error {
 // StopContainer stops a container
 func (c *Client) StopContainer(ctx context.Context, id string) error {
 	start := time.Now()
-	c.logger.Debug("stopping container", "container_id", id)
+	ctx, span := tracing.Start(ctx, "docker.container_stop", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "stopping container", "container_id", id)
 	
 	err := c.cli.ContainerStop(ctx, id, container.StopOptions{})
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_stop", duration, err)
 	if err != nil {
-		c.logger.Error("failed to stop container",
+		c.logger.ErrorContext(ctx, "failed to stop container",
 			"container_id", id,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -225,7 +352,7 @@ func (c *Client) StopContainer(ctx context.Context, id string) error {
 		return err
 	}
 	
-	c.logger.Debug("stopped container successfully",
+	c.logger.DebugContext(ctx, "stopped container successfully",
 		"container_id", id,
 		"duration_ms", duration.Milliseconds(),
 	)
@@ -235,13 +362,15 @@ func (c *Client) StopContainer(ctx context.Context, id string) error {
 // RestartContainer restarts a container
 func (c *Client) RestartContainer(ctx context.Context, id string) error {
 	start := time.Now()
-	c.logger.Debug("restarting container", "container_id", id)
+	ctx, span := tracing.Start(ctx, "docker.container_restart", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "restarting container", "container_id", id)
 	
 	err := c.cli.ContainerRestart(ctx, id, container.StopOptions{})
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_restart", duration, err)
 	if err != nil {
-		c.logger.Error("failed to restart container",
+		c.logger.ErrorContext(ctx, "failed to restart container",
 			"container_id", id,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -249,7 +378,7 @@ func (c *Client) RestartContainer(ctx context.Context, id string) error {
 		return err
 	}
 	
-	c.logger.Debug("restarted container successfully",
+	c.logger.DebugContext(ctx, "restarted container successfully",
 		"container_id", id,
 		"duration_ms", duration.Milliseconds(),
 	)
@@ -259,13 +388,15 @@ func (c *Client) RestartContainer(ctx context.Context, id string) error {
 // RemoveContainer removes a container
 func (c *Client) RemoveContainer(ctx context.Context, id string) error {
 	start := time.Now()
-	c.logger.Debug("removing container", "container_id", id)
+	ctx, span := tracing.Start(ctx, "docker.container_remove", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "removing container", "container_id", id)
 	
 	err := c.cli.ContainerRemove(ctx, id, container.RemoveOptions{Force: true})
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_remove", duration, err)
 	if err != nil {
-		c.logger.Error("failed to remove container",
+		c.logger.ErrorContext(ctx, "failed to remove container",
 			"container_id", id,
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
@@ -273,26 +404,106 @@ func (c *Client) RemoveContainer(ctx context.Context, id string) error {
 		return err
 	}
 	
-	c.logger.Debug("removed container successfully",
+	c.logger.DebugContext(ctx, "removed container successfully",
 		"container_id", id,
 		"duration_ms", duration.Milliseconds(),
 	)
 	return nil
 }
 
+// RenameContainer renames a container
+func (c *Client) RenameContainer(ctx context.Context, id string, newName string) error {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "docker.container_rename", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "renaming container", "container_id", id, "new_name", newName)
+
+	err := c.cli.ContainerRename(ctx, id, newName)
+
+	duration := time.Since(start)
+	observeRequest(span, "container_rename", duration, err)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "failed to rename container",
+			"container_id", id,
+			"new_name", newName,
+			"error", err,
+			"duration_ms", duration.Milliseconds(),
+		)
+		return err
+	}
+
+	c.logger.DebugContext(ctx, "renamed container successfully",
+		"container_id", id,
+		"new_name", newName,
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// ContainerLogs returns up to tail lines of a container's stdout and stderr written since the given time
+func (c *Client) ContainerLogs(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "docker.container_logs", attribute.String("container.id", id))
+	c.logger.DebugContext(ctx, "fetching container logs", "container_id", id)
+
+	inspect, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		observeRequest(span, "container_logs", time.Since(start), err)
+		return "", err
+	}
+
+	reader, err := c.cli.ContainerLogs(ctx, id, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      strconv.FormatInt(since.Unix(), 10),
+		Tail:       strconv.Itoa(tail),
+	})
+	if err != nil {
+		observeRequest(span, "container_logs", time.Since(start), err)
+		c.logger.ErrorContext(ctx, "failed to fetch container logs",
+			"container_id", id,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return "", err
+	}
+	defer reader.Close()
+
+	// Without a TTY the daemon multiplexes stdout and stderr into one stream
+	var logs bytes.Buffer
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(&logs, reader)
+	} else {
+		_, err = stdcopy.StdCopy(&logs, &logs, reader)
+	}
+	observeRequest(span, "container_logs", time.Since(start), err)
+	if err != nil {
+		return "", err
+	}
+
+	c.logger.DebugContext(ctx, "fetched container logs successfully",
+		"container_id", id,
+		"bytes", logs.Len(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return logs.String(), nil
+}
+
 // CreateContainer creates a new container
-func (c *Client) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
+// All endpoints in networkingConfig are attached at creation time (requires API 1.44+ for more than one)
+func (c *Client) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
 	start := time.Now()
-	c.logger.Debug("creating container",
+	ctx, span := tracing.Start(ctx, "docker.container_create", attribute.String("container.name", name))
+	c.logger.DebugContext(ctx, "creating container",
 		"name", name,
 		"image", config.Image,
 	)
 	
-	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, name)
+	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, nil, name)
 	
 	duration := time.Since(start)
+	observeRequest(span, "container_create", duration, err)
 	if err != nil {
-		c.logger.Error("failed to create container",
+		c.logger.ErrorContext(ctx, "failed to create container",
 			"name", name,
 			"image", config.Image,
 			"error", err,
@@ -301,7 +512,7 @@ func (c *Client) CreateContainer(ctx context.Context, config *container.Config,
 		return "", err
 	}
 	
-	c.logger.Debug("created container successfully",
+	c.logger.DebugContext(ctx, "created container successfully",
 		"name", name,
 		"container_id", resp.ID,
 		"image", config.Image,
@@ -311,23 +522,30 @@ func (c *Client) CreateContainer(ctx context.Context, config *container.Config,
 }
 
 // ExecuteCommand executes a command in a specific working directory
-// This is used for running docker compose commands
+// This is used for running docker compose commands; their output is reported
+// to the job in ctx as it is written and included in the error on failure
 func (c *Client) ExecuteCommand(ctx context.Context, workDir string, command string, args []string) error {
 	start := time.Now()
-	c.logger.Debug("executing command",
+	ctx, span := tracing.Start(ctx, "docker.execute_command", attribute.String("process.command", command))
+	defer span.End()
+	c.logger.DebugContext(ctx, "executing command",
 		"command", command,
 		"args", args,
 		"workdir", workDir,
 	)
 	
+	if command == "docker" && len(c.cliArgs) > 0 {
+		args = append(append([]string(nil), c.cliArgs...), args...)
+	}
 	cmd := exec.CommandContext(ctx, command, args...)
 	cmd.Dir = workDir
 	
-	output, err := cmd.CombinedOutput()
+	output, err := RunStreaming(ctx, cmd)
 	
 	duration := time.Since(start)
 	if err != nil {
-		c.logger.Error("failed to execute command",
+		tracing.RecordError(span, err)
+		c.logger.ErrorContext(ctx, "failed to execute command",
 			"command", command,
 			"args", args,
 			"workdir", workDir,
@@ -335,10 +553,10 @@ func (c *Client) ExecuteCommand(ctx context.Context, workDir string, command str
 			"error", err,
 			"duration_ms", duration.Milliseconds(),
 		)
-		return err
+		return fmt.Errorf("%w\nOutput: %s", err, output)
 	}
 	
-	c.logger.Debug("executed command successfully",
+	c.logger.DebugContext(ctx, "executed command successfully",
 		"command", command,
 		"args", args,
 		"workdir", workDir,
@@ -346,3 +564,30 @@ func (c *Client) ExecuteCommand(ctx context.Context, workDir string, command str
 	)
 	return nil
 }
+
+// RunStreaming runs a command, reporting each line of its combined output to the
+// job reporter as it is written, and returns the full output
+func RunStreaming(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.WriteString(line + "\n")
+			jobs.Log(ctx, line)
+		}
+		// Drain anything left (e.g. an over-long line) so the command never blocks
+		io.Copy(&output, reader)
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	<-done
+	return output.Bytes(), err
+}
@@ -0,0 +1,287 @@
+package docker
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultHostName names the host configured by the DOCKER_* environment
+// variables when no other hosts are configured
+const DefaultHostName = "local"
+
+// hostNamePattern restricts host names to what can appear unescaped in URL paths
+var hostNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// HostConfig describes how to reach a Docker daemon
+type HostConfig struct {
+	Name  string       `yaml:"name"`            // Shown on the dashboard and used in URLs, e.g. /host/web-1/container/:id
+	Host  string       `yaml:"host"`            // unix://, tcp:// or ssh:// URL; empty uses the DOCKER_* environment
+	TLS   *TLSConfig   `yaml:"tls,omitempty"`   // Client certificates for tcp:// hosts
+	Agent *AgentConfig `yaml:"agent,omitempty"` // Reached through an agent that connects to the server instead of a URL
+}
+
+// TLSConfig holds the PEM files used to connect to a daemon over TLS
+type TLSConfig struct {
+	CA   string `yaml:"ca"`   // CA certificate the daemon's certificate must chain to
+	Cert string `yaml:"cert"` // Client certificate, for daemons started with --tlsverify
+	Key  string `yaml:"key"`  // Client key
+}
+
+// AgentConfig holds the credentials of an agent running on a host, see
+// `bleeding-edge agent`
+type AgentConfig struct {
+	Hash string `yaml:"hash"` // Hex SHA-256 of the agent's token
+}
+
+// HostsConfig lists the Docker hosts to manage
+type HostsConfig struct {
+	Hosts []HostConfig `yaml:"hosts"`
+}
+
+// LoadHostsConfig reads hosts from an optional YAML file and from a
+// comma-separated list of "name=url" entries. Without any, the single host
+// configured by the DOCKER_* environment variables is used, named DefaultHostName.
+func LoadHostsConfig(path, hosts string) (*HostsConfig, error) {
+	cfg := &HostsConfig{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read hosts file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse hosts file %s: %w", path, err)
+		}
+	}
+
+	for _, entry := range strings.Split(hosts, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, host, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid host entry %q: expected name=url", entry)
+		}
+		cfg.Hosts = append(cfg.Hosts, HostConfig{Name: name, Host: host})
+	}
+
+	if len(cfg.Hosts) == 0 {
+		cfg.Hosts = []HostConfig{{Name: DefaultHostName}}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that host names are unique and URL-safe, and that every
+// host has a supported URL
+func (c *HostsConfig) Validate() error {
+	names := make(map[string]bool)
+	for _, host := range c.Hosts {
+		if !hostNamePattern.MatchString(host.Name) {
+			return fmt.Errorf("invalid host name %q: use letters, digits, '.', '_' and '-'", host.Name)
+		}
+		if names[host.Name] {
+			return fmt.Errorf("duplicate host %q", host.Name)
+		}
+		names[host.Name] = true
+
+		if host.Agent != nil {
+			if host.Host != "" || host.TLS != nil {
+				return fmt.Errorf("host %q: agent hosts have no url or tls", host.Name)
+			}
+			if b, err := hex.DecodeString(host.Agent.Hash); err != nil || len(b) != 32 {
+				return fmt.Errorf("host %q: agent hash must be a hex SHA-256 digest", host.Name)
+			}
+			continue
+		}
+		if host.Host == "" {
+			if len(c.Hosts) > 1 {
+				return fmt.Errorf("host %q: url is required when several hosts are configured", host.Name)
+			}
+			continue
+		}
+		u, err := url.Parse(host.Host)
+		if err != nil {
+			return fmt.Errorf("host %q: invalid url: %w", host.Name, err)
+		}
+		switch u.Scheme {
+		case "unix", "npipe":
+		case "tcp":
+			if u.Host == "" {
+				return fmt.Errorf("host %q: tcp url needs an address, e.g. tcp://10.0.0.5:2376", host.Name)
+			}
+		case "ssh":
+			if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+				return fmt.Errorf("host %q: ssh url must be ssh://[user@]host[:port]", host.Name)
+			}
+		default:
+			return fmt.Errorf("host %q: unsupported url %q (must be unix://, tcp:// or ssh://)", host.Name, host.Host)
+		}
+		if host.TLS != nil {
+			if u.Scheme != "tcp" {
+				return fmt.Errorf("host %q: tls is only supported for tcp:// hosts", host.Name)
+			}
+			if host.TLS.CA == "" || (host.TLS.Cert == "") != (host.TLS.Key == "") {
+				return fmt.Errorf("host %q: tls needs a ca, and a cert and key together", host.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// NewHostClient creates a Docker client for a configured host. Docker Compose
+// commands run through the client are pointed at the same daemon.
+func NewHostClient(cfg HostConfig, logger *slog.Logger) (*Client, error) {
+	logger = logger.With("docker_host", cfg.Name)
+	if cfg.Host == "" {
+		return NewClientWithLogger(logger)
+	}
+
+	opts := []client.Opt{client.WithAPIVersionNegotiation()}
+	cliArgs := []string{"--host", cfg.Host}
+
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "ssh" {
+		// The daemon is reached through `docker system dial-stdio` on the remote
+		// host; the HTTP host is a placeholder as the dialer ignores it
+		opts = append(opts, client.WithHost("http://docker.example.com"), client.WithDialContext(sshDialer(u)))
+	} else {
+		opts = append(opts, client.WithHost(cfg.Host))
+	}
+	if cfg.TLS != nil {
+		opts = append(opts, client.WithTLSClientConfig(cfg.TLS.CA, cfg.TLS.Cert, cfg.TLS.Key))
+		cliArgs = append(cliArgs, "--tlsverify", "--tlscacert", cfg.TLS.CA)
+		if cfg.TLS.Cert != "" {
+			cliArgs = append(cliArgs, "--tlscert", cfg.TLS.Cert, "--tlskey", cfg.TLS.Key)
+		}
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cli:     cli,
+		logger:  logger,
+		cliArgs: cliArgs,
+	}, nil
+}
+
+// RunsCommandsRemotely reports whether a client runs commands such as docker
+// compose on its Docker host rather than on the machine running the server, as
+// agent connections do
+func RunsCommandsRemotely(cli DockerClient) bool {
+	remote, ok := cli.(interface{ RunsCommandsRemotely() bool })
+	return ok && remote.RunsCommandsRemotely()
+}
+
+// CLIArgs returns the global docker CLI flags that point the docker CLI at the
+// same daemon as the client, for commands such as docker compose that are run
+// directly. Clients configured from the environment need none.
+func CLIArgs(cli DockerClient) []string {
+	if c, ok := cli.(*Client); ok {
+		return append([]string(nil), c.cliArgs...)
+	}
+	return nil
+}
+
+// Host is a named Docker daemon and the client connected to it
+type Host struct {
+	Name   string
+	Client DockerClient
+}
+
+// Hosts is the set of Docker hosts being managed, in configuration order. The
+// first host is the default, addressed by routes without a host name.
+type Hosts struct {
+	list   []Host
+	byName map[string]Host
+}
+
+// NewHosts creates a set of hosts; at least one host is required
+func NewHosts(hosts ...Host) *Hosts {
+	if len(hosts) == 0 {
+		panic("docker: at least one host is required")
+	}
+	h := &Hosts{byName: make(map[string]Host)}
+	for _, host := range hosts {
+		h.list = append(h.list, host)
+		h.byName[host.Name] = host
+	}
+	return h
+}
+
+// SingleHost wraps one client as the only, default host
+func SingleHost(client DockerClient) *Hosts {
+	return NewHosts(Host{Name: DefaultHostName, Client: client})
+}
+
+// ConnectHosts creates a client for each configured host, pulling with the
+// registry credentials in creds. Clients of agent hosts are created by agent;
+// agents pull with the credentials of their own host.
+func ConnectHosts(configs []HostConfig, creds *credentials.Store, agent func(HostConfig) DockerClient, logger *slog.Logger) (*Hosts, error) {
+	hosts := make([]Host, 0, len(configs))
+	for _, cfg := range configs {
+		if cfg.Agent != nil {
+			hosts = append(hosts, Host{Name: cfg.Name, Client: agent(cfg)})
+			continue
+		}
+		cli, err := NewHostClient(cfg, logger)
+		if err != nil {
+			(&Hosts{list: hosts}).Close()
+			return nil, fmt.Errorf("failed to create Docker client for host %s: %w", cfg.Name, err)
+		}
+		hosts = append(hosts, Host{Name: cfg.Name, Client: cli.WithCredentials(creds)})
+	}
+	return NewHosts(hosts...), nil
+}
+
+// All returns every host in configuration order
+func (h *Hosts) All() []Host {
+	return append([]Host(nil), h.list...)
+}
+
+// Get returns the host with the given name
+func (h *Hosts) Get(name string) (Host, bool) {
+	host, ok := h.byName[name]
+	return host, ok
+}
+
+// Default returns the first configured host
+func (h *Hosts) Default() Host {
+	return h.list[0]
+}
+
+// Len returns how many hosts are configured
+func (h *Hosts) Len() int {
+	return len(h.list)
+}
+
+// Close closes the connection of every host's client
+func (h *Hosts) Close() error {
+	var firstErr error
+	for _, host := range h.list {
+		if closer, ok := host.Client.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
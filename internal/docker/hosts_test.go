@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadHostsConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts.yaml")
+	err := os.WriteFile(file, []byte(`hosts:
+  - name: local
+    host: unix:///var/run/docker.sock
+  - name: web-1
+    host: tcp://10.0.0.5:2376
+    tls:
+      ca: /certs/ca.pem
+      cert: /certs/cert.pem
+      key: /certs/key.pem
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		hosts       string
+		expected    []string
+		expectError bool
+	}{
+		{name: "default", expected: []string{DefaultHostName}},
+		{name: "file", path: file, expected: []string{"local", "web-1"}},
+		{name: "file and env", path: file, hosts: "nas=ssh://admin@nas.lan", expected: []string{"local", "web-1", "nas"}},
+		{name: "env list", hosts: "a=unix:///a.sock, b=tcp://b:2375", expected: []string{"a", "b"}},
+		{name: "missing url", hosts: "a=unix:///a.sock,b=", expectError: true},
+		{name: "missing name", hosts: "unix:///a.sock", expectError: true},
+		{name: "duplicate", path: file, hosts: "local=tcp://other:2375", expectError: true},
+		{name: "name unsafe in urls", hosts: "web/1=tcp://web:2375", expectError: true},
+		{name: "unsupported scheme", hosts: "a=http://a:2375", expectError: true},
+		{name: "ssh with path", hosts: "a=ssh://a/var/run", expectError: true},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.yaml"), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := LoadHostsConfig(tt.path, tt.hosts)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("LoadHostsConfig() error = %v, expectError %v", err, tt.expectError)
+			}
+			if tt.expectError {
+				return
+			}
+			var names []string
+			for _, host := range cfg.Hosts {
+				names = append(names, host.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("expected hosts %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestHostsConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        HostConfig
+		expectError bool
+	}{
+		{name: "ca only", host: HostConfig{Name: "a", Host: "tcp://a:2376", TLS: &TLSConfig{CA: "ca.pem"}}},
+		{name: "client certificate", host: HostConfig{Name: "a", Host: "tcp://a:2376", TLS: &TLSConfig{CA: "ca.pem", Cert: "cert.pem", Key: "key.pem"}}},
+		{name: "cert without key", host: HostConfig{Name: "a", Host: "tcp://a:2376", TLS: &TLSConfig{CA: "ca.pem", Cert: "cert.pem"}}, expectError: true},
+		{name: "tls over ssh", host: HostConfig{Name: "a", Host: "ssh://a", TLS: &TLSConfig{CA: "ca.pem"}}, expectError: true},
+		{name: "agent", host: HostConfig{Name: "a", Agent: &AgentConfig{Hash: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}}},
+		{name: "agent with url", host: HostConfig{Name: "a", Host: "tcp://a:2376", Agent: &AgentConfig{Hash: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}}, expectError: true},
+		{name: "agent hash not sha256", host: HostConfig{Name: "a", Agent: &AgentConfig{Hash: "secret"}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&HostsConfig{Hosts: []HostConfig{tt.host}}).Validate()
+			if (err != nil) != tt.expectError {
+				t.Errorf("Validate() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestNewHostClientCLIArgs(t *testing.T) {
+	cli, err := NewHostClient(HostConfig{
+		Name: "web-1",
+		Host: "tcp://10.0.0.5:2376",
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	// docker compose is pointed at the same daemon as the API client
+	if args := CLIArgs(cli); !slices.Equal(args, []string{"--host", "tcp://10.0.0.5:2376"}) {
+		t.Errorf("unexpected CLI args %v", args)
+	}
+	if args := CLIArgs(&MockClient{}); args != nil {
+		t.Errorf("expected no CLI args for other clients, got %v", args)
+	}
+}
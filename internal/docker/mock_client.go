@@ -3,9 +3,12 @@ package docker
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 )
 
 // MockClient is a mock implementation of DockerClient for testing
@@ -14,11 +17,14 @@ type MockClient struct {
 	InspectContainerFunc  func(ctx context.Context, id string) (types.ContainerJSON, error)
 	PullImageFunc         func(ctx context.Context, imageName string) error
 	GetImageDigestFunc    func(ctx context.Context, imageName string) (string, error)
+	InspectImageFunc      func(ctx context.Context, imageName string) (image.InspectResponse, error)
 	StartContainerFunc    func(ctx context.Context, id string) error
 	StopContainerFunc     func(ctx context.Context, id string) error
 	RestartContainerFunc  func(ctx context.Context, id string) error
 	RemoveContainerFunc   func(ctx context.Context, id string) error
-	CreateContainerFunc   func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error)
+	RenameContainerFunc   func(ctx context.Context, id string, newName string) error
+	ContainerLogsFunc     func(ctx context.Context, id string, since time.Time, tail int) (string, error)
+	CreateContainerFunc   func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error)
 	ExecuteCommandFunc    func(ctx context.Context, workDir string, command string, args []string) error
 }
 
@@ -54,6 +60,17 @@ func (m *MockClient) GetImageDigest(ctx context.Context, imageName string) (stri
 	return "sha256:mock-digest", nil
 }
 
+// InspectImage mocks inspecting an image
+func (m *MockClient) InspectImage(ctx context.Context, imageName string) (image.InspectResponse, error) {
+	if m.InspectImageFunc != nil {
+		return m.InspectImageFunc(ctx, imageName)
+	}
+	return image.InspectResponse{
+		ID:          "sha256:mock-image-id",
+		RepoDigests: []string{imageName + "@sha256:mock-digest"},
+	}, nil
+}
+
 // StartContainer mocks starting a container
 func (m *MockClient) StartContainer(ctx context.Context, id string) error {
 	if m.StartContainerFunc != nil {
@@ -86,10 +103,26 @@ func (m *MockClient) RemoveContainer(ctx context.Context, id string) error {
 	return nil
 }
 
+// RenameContainer mocks renaming a container
+func (m *MockClient) RenameContainer(ctx context.Context, id string, newName string) error {
+	if m.RenameContainerFunc != nil {
+		return m.RenameContainerFunc(ctx, id, newName)
+	}
+	return nil
+}
+
+// ContainerLogs mocks fetching container logs
+func (m *MockClient) ContainerLogs(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+	if m.ContainerLogsFunc != nil {
+		return m.ContainerLogsFunc(ctx, id, since, tail)
+	}
+	return "", nil
+}
+
 // CreateContainer mocks creating a container
-func (m *MockClient) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
+func (m *MockClient) CreateContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
 	if m.CreateContainerFunc != nil {
-		return m.CreateContainerFunc(ctx, config, hostConfig, name)
+		return m.CreateContainerFunc(ctx, config, hostConfig, networkingConfig, name)
 	}
 	return "mock-container-id", nil
 }
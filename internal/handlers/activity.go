@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
+)
+
+// activityPageLimit caps how many records the Activity page shows at once
+const activityPageLimit = 200
+
+// ActivityHandler serves the audit log as a page and as JSON or CSV exports
+type ActivityHandler struct {
+	audit    *audit.Log
+	template *template.Template
+	logger   *slog.Logger
+}
+
+// ActivityList is the response body of GET /api/v1/activity
+type ActivityList struct {
+	Records []audit.Record `json:"records"`
+}
+
+// NewActivityHandler creates a new activity handler
+func NewActivityHandler(auditLog *audit.Log, tmpl *template.Template, logger *slog.Logger) *ActivityHandler {
+	return &ActivityHandler{
+		audit:    auditLog,
+		template: tmpl,
+		logger:   logger,
+	}
+}
+
+// ServeHTTP handles GET /activity requests
+func (h *ActivityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !canAudit(r) {
+		http.Error(w, "You do not have permission to view the activity log.", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseActivityFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Limit == 0 || filter.Limit > activityPageLimit {
+		filter.Limit = activityPageLimit
+	}
+
+	records, err := h.audit.List(filter)
+	if err != nil {
+		h.logger.Error("failed to read audit log", "error", err)
+		http.Error(w, "Failed to load the activity log", http.StatusInternalServerError)
+		return
+	}
+
+	// Export links keep the current filters
+	exportQuery := url.Values{}
+	for _, key := range []string{"user", "operation", "host", "target", "result", "since", "until"} {
+		if value := query.Get(key); value != "" {
+			exportQuery.Set(key, value)
+		}
+	}
+
+	data := map[string]interface{}{
+		"Title":       "BleedingEdge - Activity",
+		"Records":     records,
+		"Filter":      query,
+		"Operations":  []string{audit.OperationUpdate, audit.OperationAutoUpdate, audit.OperationRevert, audit.OperationStart, audit.OperationStop, audit.OperationRestart},
+		"Limited":     len(records) == filter.Limit,
+		"ExportQuery": template.URL(exportQuery.Encode()), // Already query-escaped
+		"User":        currentUser(r),
+	}
+
+	if err := h.template.ExecuteTemplate(w, "activity.html", data); err != nil {
+		h.logger.Error("failed to render template",
+			"error", err,
+			"template", "activity.html",
+		)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// HandleExport handles GET /api/v1/activity requests. Records are returned as
+// JSON, or as a CSV download with ?format=csv.
+func (h *ActivityHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if !canAudit(r) {
+		writeAPIError(w, http.StatusForbidden, "You do not have permission to view the activity log")
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := parseActivityFilter(query)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, err := h.audit.List(filter)
+	if err != nil {
+		h.logger.Error("failed to read audit log", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "Failed to load the activity log")
+		return
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, ActivityList{Records: records})
+	case "csv":
+		filename := fmt.Sprintf("bleedingedge-activity-%s.csv", time.Now().UTC().Format("20060102-150405"))
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		if err := audit.WriteCSV(w, records); err != nil {
+			h.logger.Error("failed to write activity CSV", "error", err)
+		}
+	default:
+		writeAPIError(w, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+// canAudit reports whether the signed-in user may see the activity of every
+// container, which needs the admin role without a project or label scope
+func canAudit(r *http.Request) bool {
+	return auth.Allowed(r.Context(), auth.ActionAudit, nil)
+}
+
+// parseActivityFilter reads audit log filters from query parameters. Times
+// are RFC 3339 timestamps or dates; an "until" date includes the whole day.
+func parseActivityFilter(query url.Values) (audit.Filter, error) {
+	filter := audit.Filter{
+		User:      query.Get("user"),
+		Operation: query.Get("operation"),
+		Host:      query.Get("host"),
+		Target:    query.Get("target"),
+		Result:    audit.Result(query.Get("result")),
+	}
+
+	if value := query.Get("since"); value != "" {
+		since, _, err := parseFilterTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since %q: %w", value, err)
+		}
+		filter.Since = since
+	}
+
+	if value := query.Get("until"); value != "" {
+		until, isDate, err := parseFilterTime(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until %q: %w", value, err)
+		}
+		if isDate {
+			until = until.AddDate(0, 0, 1)
+		}
+		filter.Until = until
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			return filter, fmt.Errorf("invalid limit %q: must be a non-negative number", value)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseFilterTime parses an RFC 3339 timestamp or a YYYY-MM-DD date, reporting
+// whether it was a date
+func parseFilterTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("must be a date (2006-01-02) or RFC 3339 time")
+	}
+	return t, true, nil
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// APIPrefix is the path prefix of the versioned JSON API
+const APIPrefix = "/api/v1"
+
+// APIHandler serves dashboard data as JSON for scripts and integrations
+type APIHandler struct {
+	hosts   *docker.Hosts
+	cache   *services.UpdateCache
+	history *history.Store
+	logger  *slog.Logger
+}
+
+// GroupList is the response body of GET /api/v1/groups
+type GroupList struct {
+	Groups           []models.ContainerGroup `json:"groups"`
+	LastChecked      time.Time               `json:"last_checked"`                // Zero until the first update check completes
+	UnreachableHosts map[string]string       `json:"unreachable_hosts,omitempty"` // Errors of hosts whose groups are missing
+}
+
+// NewAPIHandler creates a new API handler
+func NewAPIHandler(hosts *docker.Hosts, cache *services.UpdateCache, historyStore *history.Store, logger *slog.Logger) *APIHandler {
+	return &APIHandler{
+		hosts:   hosts,
+		cache:   cache,
+		history: historyStore,
+		logger:  logger,
+	}
+}
+
+// HandleListGroups handles GET /api/v1/groups requests, listing the groups of
+// every host, and GET /api/v1/hosts/:host/groups requests
+func (h *APIHandler) HandleListGroups(w http.ResponseWriter, r *http.Request) {
+	if _, ok := mux.Vars(r)["host"]; ok {
+		groups, ok := h.loadGroups(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, GroupList{Groups: groups, LastChecked: h.cache.LastChecked()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	groups, hostErrors := services.GetAllContainerGroups(ctx, h.hosts)
+	if len(hostErrors) == h.hosts.Len() {
+		h.logger.Error("failed to get container groups",
+			"errors", hostErrors,
+			"operation", "list_containers",
+		)
+		writeAPIError(w, http.StatusInternalServerError, "Failed to load containers. Please check Docker daemon connection.")
+		return
+	}
+
+	// Never block on registries here; checks run in the background
+	groups = visibleGroups(r, groups)
+	h.cache.Apply(groups)
+	list := GroupList{
+		Groups:      groups,
+		LastChecked: h.cache.LastChecked(),
+	}
+	for host, err := range hostErrors {
+		if list.UnreachableHosts == nil {
+			list.UnreachableHosts = make(map[string]string)
+		}
+		list.UnreachableHosts[host] = err.Error()
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// HandleGetGroup handles GET /api/v1/groups/:id requests
+func (h *APIHandler) HandleGetGroup(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	groups, ok := h.loadGroups(w, r)
+	if !ok {
+		return
+	}
+
+	for _, group := range groups {
+		if group.ID == id {
+			writeJSON(w, http.StatusOK, group)
+			return
+		}
+	}
+
+	writeAPIError(w, http.StatusNotFound, "Container group not found")
+}
+
+// HandleGroupHistory handles GET /api/v1/groups/:id/history requests. The
+// recorded container configurations are left out of the response.
+func (h *APIHandler) HandleGroupHistory(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	groups, ok := h.loadGroups(w, r)
+	if !ok {
+		return
+	}
+
+	for i := range groups {
+		if groups[i].ID == id {
+			entries := groupHistory(h.history, &groups[i], h.logger)
+			writeJSON(w, http.StatusOK, HistoryList{Entries: withoutConfig(entries)})
+			return
+		}
+	}
+
+	writeAPIError(w, http.StatusNotFound, "Container group not found")
+}
+
+// HandleGetContainer handles GET /api/v1/containers/:id requests. The
+// container can be referenced by full ID, ID prefix or name.
+func (h *APIHandler) HandleGetContainer(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	groups, ok := h.loadGroups(w, r)
+	if !ok {
+		return
+	}
+
+	for _, group := range groups {
+		for _, c := range group.Containers {
+			if c.ID == id || c.Name == id || strings.HasPrefix(c.ID, id) {
+				writeJSON(w, http.StatusOK, c)
+				return
+			}
+		}
+	}
+
+	writeAPIError(w, http.StatusNotFound, "Container not found")
+}
+
+// loadGroups lists the container groups of the requested host with the latest
+// cached update status, sending an error response if the host is unknown or
+// cannot be reached
+func (h *APIHandler) loadGroups(w http.ResponseWriter, r *http.Request) ([]models.ContainerGroup, bool) {
+	host, ok := requestHost(h.hosts, r)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Docker host not found")
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	groups, err := services.GetHostGroups(ctx, host)
+	if err != nil {
+		h.logger.Error("failed to get container groups",
+			"docker_host", host.Name,
+			"error", err,
+			"operation", "list_containers",
+		)
+		writeAPIError(w, http.StatusInternalServerError, "Failed to load containers. Please check Docker daemon connection.")
+		return nil, false
+	}
+
+	// Never block on registries here; checks run in the background
+	groups = visibleGroups(r, groups)
+	h.cache.Apply(groups)
+	return groups, true
+}
+
+// writeJSON sends v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError sends an error in the same shape as failed operations
+func writeAPIError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, models.OperationResult{
+		Success:   false,
+		Error:     message,
+		Timestamp: time.Now(),
+	})
+}
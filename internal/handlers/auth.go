@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// AuthHandler handles signing in and out of the web UI
+type AuthHandler struct {
+	auth     *auth.Authenticator
+	template *template.Template
+	logger   *slog.Logger
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(authenticator *auth.Authenticator, tmpl *template.Template, logger *slog.Logger) *AuthHandler {
+	return &AuthHandler{
+		auth:     authenticator,
+		template: tmpl,
+		logger:   logger,
+	}
+}
+
+// HandleLoginPage handles GET /login requests
+func (h *AuthHandler) HandleLoginPage(w http.ResponseWriter, r *http.Request) {
+	h.renderLogin(w, http.StatusOK, "", safeRedirect(r.URL.Query().Get("next")))
+}
+
+// HandleLogin handles POST /login requests by checking the submitted
+// credentials and starting a session
+func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	username := r.PostFormValue("username")
+	next := safeRedirect(r.PostFormValue("next"))
+
+	session, err := h.auth.Login(username, r.PostFormValue("password"))
+	if err != nil {
+		h.logger.Warn("login failed",
+			"username", username,
+			"remote_addr", r.RemoteAddr,
+		)
+		h.renderLogin(w, http.StatusUnauthorized, "Invalid username or password", next)
+		return
+	}
+
+	h.logger.Info("user logged in", "username", username, "remote_addr", r.RemoteAddr)
+	auth.SetSessionCookie(w, r, session)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// HandleLogout handles POST /logout requests
+func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+		h.auth.Logout(cookie.Value)
+	}
+	if identity, ok := auth.IdentityFrom(r.Context()); ok {
+		h.logger.Info("user logged out", "username", identity.Username)
+	}
+
+	auth.ClearSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderLogin renders the login page with an optional error
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, statusCode int, errorMsg, next string) {
+	data := map[string]interface{}{
+		"Title": "BleedingEdge - Sign in",
+		"Error": errorMsg,
+		"Next":  next,
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(statusCode)
+	if err := h.template.ExecuteTemplate(w, "login.html", data); err != nil {
+		h.logger.Error("failed to render template",
+			"error", err,
+			"template", "login.html",
+		)
+	}
+}
+
+// safeRedirect returns next if it is a local path, so the login form cannot
+// be used to redirect to another site
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// currentUser returns the signed-in username for templates, or "" without authentication
+func currentUser(r *http.Request) string {
+	if identity, ok := auth.IdentityFrom(r.Context()); ok {
+		return identity.Username
+	}
+	return ""
+}
+
+// allowedAll reports whether the signed-in user may perform the action on every given container
+func allowedAll(r *http.Request, action auth.Action, containers []models.ContainerInfo) bool {
+	for _, c := range containers {
+		if !auth.Allowed(r.Context(), action, c.Labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// containerLabels returns the labels of each container, e.g. to check
+// permissions on a job acting on them later
+func containerLabels(containers []models.ContainerInfo) []map[string]string {
+	labels := make([]map[string]string, len(containers))
+	for i, c := range containers {
+		labels[i] = c.Labels
+	}
+	return labels
+}
+
+// RequireAudit serves next only to users who may see the activity of every
+// container, such as /metrics, whose series cover every container and registry
+func RequireAudit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !canAudit(r) {
+			writeAPIError(w, http.StatusForbidden, "You do not have permission to view metrics")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// visibleGroups drops the containers the signed-in user may not view, and the
+// groups left without containers
+func visibleGroups(r *http.Request, groups []models.ContainerGroup) []models.ContainerGroup {
+	visible := groups[:0]
+	for _, group := range groups {
+		var containers []models.ContainerInfo
+		for _, c := range group.Containers {
+			if auth.Allowed(r.Context(), auth.ActionView, c.Labels) {
+				containers = append(containers, c)
+			}
+		}
+		if len(containers) > 0 {
+			group.Containers = containers
+			visible = append(visible, group)
+		}
+	}
+	return visible
+}
+
+// containerPermissions maps the ID of each container in a group to whether the
+// signed-in user may perform the action on it, for showing buttons in templates
+func containerPermissions(r *http.Request, action auth.Action, group *models.ContainerGroup) map[string]bool {
+	permissions := make(map[string]bool)
+	for _, c := range group.Containers {
+		permissions[c.ID] = auth.Allowed(r.Context(), action, c.Labels)
+	}
+	return permissions
+}
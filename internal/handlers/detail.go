@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
 	"github.com/bleeding-edge/bleeding-edge/internal/services"
 	"github.com/gorilla/mux"
@@ -15,21 +17,25 @@ import (
 
 // DetailHandler handles the container detail view
 type DetailHandler struct {
-	client   docker.DockerClient
+	hosts    *docker.Hosts
+	cache    *services.UpdateCache
+	history  *history.Store
 	template *template.Template
 	logger   *slog.Logger
 }
 
 // NewDetailHandler creates a new detail handler
-func NewDetailHandler(client docker.DockerClient, tmpl *template.Template, logger *slog.Logger) *DetailHandler {
+func NewDetailHandler(hosts *docker.Hosts, cache *services.UpdateCache, historyStore *history.Store, tmpl *template.Template, logger *slog.Logger) *DetailHandler {
 	return &DetailHandler{
-		client:   client,
+		hosts:    hosts,
+		cache:    cache,
+		history:  historyStore,
 		template: tmpl,
 		logger:   logger,
 	}
 }
 
-// ServeHTTP handles GET /container/:id requests
+// ServeHTTP handles GET /container/:id and GET /host/:host/container/:id requests
 func (h *DetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Extract container/group ID from URL path using gorilla/mux
 	vars := mux.Vars(r)
@@ -39,15 +45,22 @@ func (h *DetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	host, ok := requestHost(h.hosts, r)
+	if !ok {
+		http.Error(w, "Docker host not found.", http.StatusNotFound)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
-	h.logger.Info("handling detail page request", "id", id)
+	h.logger.Info("handling detail page request", "id", id, "docker_host", host.Name)
 
-	// Get all container groups to find the requested one
-	groups, err := services.GetContainerGroups(ctx, h.client)
+	// Get all container groups of the host to find the requested one
+	groups, err := services.GetHostGroups(ctx, host)
 	if err != nil {
 		h.logger.Error("failed to get container groups",
+			"docker_host", host.Name,
 			"error", err,
 			"operation", "list_containers",
 			"container_id", id,
@@ -56,22 +69,9 @@ func (h *DetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check for updates only if requested
-	checkUpdates := r.URL.Query().Get("check_updates")
-	if checkUpdates == "true" {
-		// Use a longer timeout for update checks since pulling images can take time
-		updateCtx, updateCancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer updateCancel()
-		
-		if err := services.CheckUpdates(updateCtx, h.client, groups); err != nil {
-			h.logger.Warn("failed to check updates",
-				"error", err,
-				"operation", "check_updates",
-				"container_id", id,
-			)
-			// Continue rendering even if update check fails
-		}
-	}
+	// Apply the latest background check results; never block on registries here
+	groups = visibleGroups(r, groups)
+	h.cache.Apply(groups)
 
 	// Find the requested group
 	var group *models.ContainerGroup
@@ -91,11 +91,29 @@ func (h *DetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Offer to revert recorded updates whose previous digests are no longer running
+	entries := groupHistory(h.history, group, h.logger)
+	revertable := make(map[uint64]bool)
+	for _, entry := range entries {
+		revertable[entry.ID] = canRevert(group, entry)
+	}
+
 	// Prepare template data
+	lastChecked := h.cache.LastChecked()
 	data := map[string]interface{}{
-		"Group":        group,
-		"Title":        "BleedingEdge - " + group.Name,
-		"CheckUpdates": checkUpdates != "true",
+		"Group":       group,
+		"MultiHost":   h.hosts.Len() > 1,
+		"Title":       "BleedingEdge - " + group.Name,
+		"Checked":     !lastChecked.IsZero(),
+		"LastChecked": formatAge(lastChecked, time.Now()),
+		"User":        currentUser(r),
+		"CanCheck":    auth.AllowedAny(r.Context(), auth.ActionUpdate),
+		"CanAudit":    canAudit(r),
+		"CanUpdate":   allowedAll(r, auth.ActionUpdate, group.Containers),
+		"Updatable":   containerPermissions(r, auth.ActionUpdate, group),
+		"Operable":    containerPermissions(r, auth.ActionOperate, group),
+		"History":     entries,
+		"Revertable":  revertable,
 	}
 
 	// Render template
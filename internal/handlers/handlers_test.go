@@ -3,18 +3,34 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"html/template"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/bleeding-edge/bleeding-edge/internal/scheduler"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/bleeding-edge/bleeding-edge/internal/testutil"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
 	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHomeHandler(t *testing.T) {
@@ -59,9 +75,9 @@ func TestHomeHandler(t *testing.T) {
 
 			tmpl := template.Must(template.New("grid.html").Parse(`{{.Title}}`))
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-			handler := NewHomeHandler(mockClient, tmpl, logger)
+			handler := NewHomeHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), nil, tmpl, logger)
 
-			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req := newRequest(http.MethodGet, "/", nil)
 			w := httptest.NewRecorder()
 
 			handler.ServeHTTP(w, req)
@@ -118,9 +134,9 @@ func TestDetailHandler(t *testing.T) {
 
 			tmpl := template.Must(template.New("detail.html").Parse(`{{.Title}}`))
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-			handler := NewDetailHandler(mockClient, tmpl, logger)
+			handler := NewDetailHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), testutil.OpenHistory(t), tmpl, logger)
 
-			req := httptest.NewRequest(http.MethodGet, "/container/"+tt.containerID, nil)
+			req := newRequest(http.MethodGet, "/container/"+tt.containerID, nil)
 			req = mux.SetURLVars(req, map[string]string{"id": tt.containerID})
 			w := httptest.NewRecorder()
 
@@ -167,9 +183,9 @@ func TestOperationsHandlerStart(t *testing.T) {
 			tt.setupMock(mockClient)
 
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-			handler := NewOperationsHandler(mockClient, logger)
+			handler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobs.NewManager(logger), testutil.OpenAuditLog(t), testutil.OpenHistory(t), newTestNotifier(t), logger)
 
-			req := httptest.NewRequest(http.MethodPost, "/container/"+tt.containerID+"/start", nil)
+			req := newRequest(http.MethodPost, "/container/"+tt.containerID+"/start", nil)
 			req = mux.SetURLVars(req, map[string]string{"id": tt.containerID})
 			w := httptest.NewRecorder()
 
@@ -196,8 +212,8 @@ func TestOperationsHandlerUpdate(t *testing.T) {
 		name           string
 		containerID    string
 		setupMock      func(*docker.MockClient)
-		expectedStatus int
 		expectSuccess  bool
+		expectRollback bool
 	}{
 		{
 			name:        "successful standalone update",
@@ -218,10 +234,12 @@ func TestOperationsHandlerUpdate(t *testing.T) {
 					return types.ContainerJSON{
 						ContainerJSONBase: &types.ContainerJSONBase{
 							Name: "/nginx",
+							State: &types.ContainerState{Running: true, Status: "running"},
 							HostConfig: &container.HostConfig{},
 						},
 						Config: &container.Config{
-							Image: "nginx:latest",
+							Image:  "nginx:latest",
+							Labels: map[string]string{services.LabelVerifyWindow: "0s"},
 						},
 					}, nil
 				}
@@ -234,16 +252,41 @@ func TestOperationsHandlerUpdate(t *testing.T) {
 				m.RemoveContainerFunc = func(ctx context.Context, id string) error {
 					return nil
 				}
-				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
+				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
 					return "new-container-id", nil
 				}
 				m.StartContainerFunc = func(ctx context.Context, id string) error {
 					return nil
 				}
 			},
-			expectedStatus: http.StatusOK,
 			expectSuccess:  true,
 		},
+		{
+			name:        "failed standalone update is rolled back",
+			containerID: "container1",
+			setupMock: func(m *docker.MockClient) {
+				m.ListContainersFunc = func(ctx context.Context) ([]types.Container, error) {
+					return []types.Container{
+						{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+					}, nil
+				}
+				m.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					return types.ContainerJSON{
+						ContainerJSONBase: &types.ContainerJSONBase{
+							Name:       "/nginx",
+							State:      &types.ContainerState{Running: true, Status: "running"},
+							HostConfig: &container.HostConfig{},
+						},
+						Config: &container.Config{Image: "nginx:latest"},
+					}, nil
+				}
+				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+					return "", fmt.Errorf("port is already allocated")
+				}
+			},
+			expectSuccess:  false,
+			expectRollback: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -252,73 +295,928 @@ func TestOperationsHandlerUpdate(t *testing.T) {
 			tt.setupMock(mockClient)
 
 			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-			handler := NewOperationsHandler(mockClient, logger)
+			jobManager := jobs.NewManager(logger)
+			handler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobManager, testutil.OpenAuditLog(t), testutil.OpenHistory(t), newTestNotifier(t), logger)
 
-			req := httptest.NewRequest(http.MethodPost, "/container/"+tt.containerID+"/update", nil)
+			req := newRequest(http.MethodPost, "/container/"+tt.containerID+"/update", nil)
 			req = mux.SetURLVars(req, map[string]string{"id": tt.containerID})
 			w := httptest.NewRecorder()
 
 			handler.HandleUpdate(w, req)
 
-			if w.Code != tt.expectedStatus {
-				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			// Updates are accepted immediately and run as a background job
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("expected status %d, got %d", http.StatusAccepted, w.Code)
 			}
 
-			var result models.OperationResult
-			if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			var accepted models.OperationResult
+			if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
 				t.Fatalf("failed to decode response: %v", err)
 			}
+			if accepted.JobID == "" {
+				t.Fatal("expected a job ID")
+			}
+			if location := w.Header().Get("Location"); location != "/jobs/"+accepted.JobID {
+				t.Errorf("expected Location /jobs/%s, got %q", accepted.JobID, location)
+			}
+
+			result := waitForJob(t, jobManager, accepted.JobID)
 
 			if result.Success != tt.expectSuccess {
 				t.Errorf("expected success=%v, got %v", tt.expectSuccess, result.Success)
 			}
+			if result.RolledBack != tt.expectRollback {
+				t.Errorf("expected rolled back=%v, got %v (%s)", tt.expectRollback, result.RolledBack, result.Error)
+			}
 		})
 	}
 }
 
-func TestFormatErrorMessage(t *testing.T) {
+func TestOperationsHandlerUpdateMovesToTrackedTag(t *testing.T) {
+	var pulled, createdImage string
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "db1", Names: []string{"/db"}, Image: "postgres:16.3", State: "running",
+					Labels: map[string]string{services.LabelTrack: "semver:minor"}},
+			}, nil
+		},
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Name:       "/db",
+					State:      &types.ContainerState{Running: true, Status: "running"},
+					HostConfig: &container.HostConfig{},
+				},
+				Config: &container.Config{
+					Image:  "postgres:16.3",
+					Labels: map[string]string{services.LabelVerifyWindow: "0s"},
+				},
+			}, nil
+		},
+		PullImageFunc: func(ctx context.Context, imageName string) error {
+			pulled = imageName
+			return nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			createdImage = config.Image
+			return "new-container-id", nil
+		},
+	}
+
+	// The last update check found a newer tag
+	cache := services.NewUpdateCache()
+	cache.Store([]models.ContainerGroup{{
+		ID:         "db1",
+		Host:       docker.DefaultHostName,
+		Type:       models.GroupTypeStandalone,
+		Containers: []models.ContainerInfo{{ID: "db1", Host: docker.DefaultHostName, Image: "postgres:16.3", HasUpdate: true, LatestTag: "16.4"}},
+	}}, time.Now(), time.Second)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	jobManager := jobs.NewManager(logger)
+	handler := NewOperationsHandler(docker.SingleHost(mockClient), cache, jobManager, testutil.OpenAuditLog(t), testutil.OpenHistory(t), newTestNotifier(t), logger)
+
+	req := newRequest(http.MethodPost, "/container/db1/update", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "db1"})
+	w := httptest.NewRecorder()
+	handler.HandleUpdate(w, req)
+
+	var accepted models.OperationResult
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result := waitForJob(t, jobManager, accepted.JobID); !result.Success {
+		t.Fatalf("expected update to succeed, got %s", result.Error)
+	}
+	if pulled != "postgres:16.4" || createdImage != "postgres:16.4" {
+		t.Errorf("expected container recreated on postgres:16.4, pulled %q, created from %q", pulled, createdImage)
+	}
+}
+
+func TestOperationsHandlerServiceUpdate(t *testing.T) {
+	containers := []types.Container{
+		{ID: "standalone1", Names: []string{"/redis"}, Image: "redis:7", State: "running"},
+		{ID: "web1", Names: []string{"/myapp-web-1"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{
+				"com.docker.compose.project":             "myapp",
+				"com.docker.compose.service":             "web",
+				"com.docker.compose.project.working_dir": "/srv/myapp",
+			}},
+	}
+
 	tests := []struct {
-		name     string
-		err      error
-		expected string
+		name           string
+		groupID        string
+		service        string
+		expectedStatus int
 	}{
+		{name: "unknown group", groupID: "missing", service: "web", expectedStatus: http.StatusNotFound},
+		{name: "standalone container", groupID: "standalone1", service: "web", expectedStatus: http.StatusBadRequest},
+		{name: "unknown service", groupID: "myapp", service: "db", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockClient := &docker.MockClient{
+				ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+					return containers, nil
+				},
+			}
+
+			logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			handler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobs.NewManager(logger), testutil.OpenAuditLog(t), testutil.OpenHistory(t), newTestNotifier(t), logger)
+
+			req := newRequest(http.MethodPost, "/container/"+tt.groupID+"/services/"+tt.service+"/update", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.groupID, "service": tt.service})
+			w := httptest.NewRecorder()
+
+			handler.HandleServiceUpdate(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+// newRequest creates a request acting as auth.Anonymous, as every request does
+// when authentication is disabled
+func newRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(auth.WithIdentity(req.Context(), auth.Anonymous()))
+}
+
+// newTestNotifier returns a notifier without webhooks
+func newTestNotifier(t *testing.T) *notify.Notifier {
+	t.Helper()
+	notifier, err := notify.New(nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+	return notifier
+}
+
+// waitForJob waits for a background job to finish and returns its result
+func waitForJob(t *testing.T, jobManager *jobs.Manager, id string) models.OperationResult {
+	t.Helper()
+
+	job, ok := jobManager.Get(id)
+	if !ok {
+		t.Fatalf("job %s not found", id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, live := job.Subscribe(ctx)
+	for range live {
+	}
+
+	events := job.Events()
+	if len(events) == 0 || events[len(events)-1].Result == nil {
+		t.Fatalf("job %s did not finish", id)
+	}
+	return *events[len(events)-1].Result
+}
+
+func TestJobsHandlerEvents(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	jobManager := jobs.NewManager(logger)
+	release := make(chan struct{})
+	job := jobManager.Start(context.Background(), "update", "nginx", []map[string]string{{"team": "web"}}, time.Minute, func(ctx context.Context) models.OperationResult {
+		jobs.Step(ctx, "pull", "Pulling nginx:latest")
+		<-release
+		jobs.Log(ctx, "done pulling")
+		return models.OperationResult{Success: true, Message: "nginx updated successfully"}
+	})
+
+	handler := NewJobsHandler(jobManager, logger)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(auth.WithIdentity(r.Context(), auth.Anonymous()))
+		handler.HandleEvents(w, mux.SetURLVars(r, map[string]string{"id": job.ID}))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+
+	// Finish the job once the stream is open; the handler returns after the done event
+	close(release)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read event stream: %v", err)
+	}
+
+	stream := string(body)
+	for _, expected := range []string{"event: step", "Pulling nginx:latest", "event: log", "done pulling", "event: done", "nginx updated successfully"} {
+		if !strings.Contains(stream, expected) {
+			t.Errorf("expected event stream to contain %q, got:\n%s", expected, stream)
+		}
+	}
+}
+
+func TestJobsHandlerNotFound(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewJobsHandler(jobs.NewManager(logger), logger)
+
+	req := newRequest(http.MethodGet, "/jobs/missing", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": "missing"})
+	w := httptest.NewRecorder()
+
+	handler.HandleGet(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestJobsHandlerPermissions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	jobManager := jobs.NewManager(logger)
+	job := jobManager.Start(context.Background(), "update", "shop", []map[string]string{
+		{"com.docker.compose.project": "shop", "tier": "web"},
+		{"com.docker.compose.project": "shop", "tier": "db"},
+	}, time.Minute, func(ctx context.Context) models.OperationResult {
+		return models.OperationResult{Success: true}
+	})
+	handler := NewJobsHandler(jobManager, logger)
+
+	tests := []struct {
+		name           string
+		grants         []auth.Grant
+		expectedStatus int
+	}{
+		{name: "viewer of the project", grants: []auth.Grant{{Role: auth.RoleViewer, Projects: []string{"shop"}}}, expectedStatus: http.StatusOK},
+		{name: "admin of another project", grants: []auth.Grant{{Role: auth.RoleAdmin, Projects: []string{"blog"}}}, expectedStatus: http.StatusForbidden},
+		{name: "viewer of some containers", grants: []auth.Grant{{Role: auth.RoleViewer, Labels: map[string]string{"tier": "web"}}}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		for _, handle := range []http.HandlerFunc{handler.HandleGet, handler.HandleEvents} {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil)
+				req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Username: "bob", Grants: tt.grants}))
+				w := httptest.NewRecorder()
+
+				handle(w, mux.SetURLVars(req, map[string]string{"id": job.ID}))
+
+				if w.Code != tt.expectedStatus {
+					t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestRequireAudit(t *testing.T) {
+	handler := RequireAudit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("bleedingedge_containers 1"))
+	}))
+
+	tests := []struct {
+		name           string
+		identity       auth.Identity
+		expectedStatus int
+	}{
+		{name: "authentication disabled", identity: auth.Anonymous(), expectedStatus: http.StatusOK},
+		{name: "admin", identity: auth.Identity{Username: "alice", Grants: auth.User{}.EffectiveGrants()}, expectedStatus: http.StatusOK},
+		{name: "scoped admin", identity: auth.Identity{Username: "bob", Grants: []auth.Grant{{Role: auth.RoleAdmin, Projects: []string{"shop"}}}}, expectedStatus: http.StatusForbidden},
+		{name: "viewer", identity: auth.Identity{Username: "carol", Grants: []auth.Grant{{Role: auth.RoleViewer}}}, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			req = req.WithContext(auth.WithIdentity(req.Context(), tt.identity))
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestAPIHandler(t *testing.T) {
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "abc123def456", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running", Labels: map[string]string{}},
+				{
+					ID:     "def456abc123",
+					Names:  []string{"/myapp-web-1"},
+					Image:  "myapp-web",
+					State:  "running",
+					Labels: map[string]string{"com.docker.compose.project": "myapp", "com.docker.compose.service": "web"},
+				},
+			}, nil
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewAPIHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), testutil.OpenHistory(t), logger)
+
+	tests := []struct {
+		name           string
+		handle         http.HandlerFunc
+		id             string
+		expectedStatus int
+		expectedBody   []string
+	}{
+		{
+			name:           "list groups",
+			handle:         handler.HandleListGroups,
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"groups":[`, `"id":"myapp"`, `"type":"compose"`, `"has_updates":false`, `"last_checked"`},
+		},
 		{
-			name:     "container not found",
-			err:      &testError{msg: "No such container: abc123"},
-			expected: "Container not found. It may have been removed.",
+			name:           "get group",
+			handle:         handler.HandleGetGroup,
+			id:             "myapp",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"name":"myapp"`, `"containers":[`, `"com.docker.compose.service":"web"`},
 		},
 		{
-			name:     "operation in progress",
-			err:      &testError{msg: "operation already in progress"},
-			expected: "Operation already in progress. Please wait.",
+			name:           "unknown group",
+			handle:         handler.HandleGetGroup,
+			id:             "missing",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   []string{`"success":false`, `"error":"Container group not found"`},
 		},
 		{
-			name:     "timeout error",
-			err:      &testError{msg: "context deadline exceeded"},
-			expected: "Operation timed out. The container may be unresponsive.",
+			name:           "container by name",
+			handle:         handler.HandleGetContainer,
+			id:             "nginx",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"id":"abc123def456"`, `"image":"nginx:latest"`, `"state":"running"`},
 		},
 		{
-			name:     "unknown error",
-			err:      &testError{msg: "some unknown error"},
-			expected: "some unknown error",
+			name:           "container by ID prefix",
+			handle:         handler.HandleGetContainer,
+			id:             "def456",
+			expectedStatus: http.StatusOK,
+			expectedBody:   []string{`"name":"myapp-web-1"`},
+		},
+		{
+			name:           "unknown container",
+			handle:         handler.HandleGetContainer,
+			id:             "missing",
+			expectedStatus: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatErrorMessage(tt.err)
-			if result != tt.expected {
-				t.Errorf("expected %q, got %q", tt.expected, result)
+			req := newRequest(http.MethodGet, APIPrefix+"/test", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			tt.handle(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			body := w.Body.String()
+			for _, expected := range tt.expectedBody {
+				if !strings.Contains(body, expected) {
+					t.Errorf("expected body to contain %s, got %s", expected, body)
+				}
 			}
 		})
 	}
 }
 
-// testError is a simple error implementation for testing
-type testError struct {
-	msg string
+func TestMultipleHosts(t *testing.T) {
+	// Both daemons run a container with the same ID, which must not be confused
+	var started []string
+	host := func(name string) *docker.MockClient {
+		return &docker.MockClient{
+			ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+				return []types.Container{{ID: "container1", Names: []string{"/" + name + "-app"}, Image: "nginx:latest", State: "running"}}, nil
+			},
+			InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+				return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/" + name + "-app"}}, nil
+			},
+			StartContainerFunc: func(ctx context.Context, id string) error {
+				started = append(started, name+"/"+id)
+				return nil
+			},
+		}
+	}
+	hosts := docker.NewHosts(
+		docker.Host{Name: "local", Client: host("local")},
+		docker.Host{Name: "edge", Client: host("edge")},
+		docker.Host{Name: "offline", Client: &docker.MockClient{
+			ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+				return nil, fmt.Errorf("connection refused")
+			},
+		}},
+	)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("grid", func(t *testing.T) {
+		tmpl := template.Must(template.New("grid.html").Parse(`{{range .Groups}}{{.Path}} {{end}}|{{range $host, $err := .HostErrors}}{{$host}}{{end}}`))
+		handler := NewHomeHandler(hosts, services.NewUpdateCache(), nil, tmpl, logger)
+
+		tests := []struct {
+			query          string
+			expectedStatus int
+			expectedBody   string
+		}{
+			{query: "", expectedStatus: http.StatusOK, expectedBody: "/host/local/container/container1 /host/edge/container/container1 |offline"},
+			{query: "?host=edge", expectedStatus: http.StatusOK, expectedBody: "/host/edge/container/container1 |"},
+			{query: "?host=missing", expectedStatus: http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newRequest(http.MethodGet, "/"+tt.query, nil))
+			if w.Code != tt.expectedStatus {
+				t.Errorf("%q: expected status %d, got %d", tt.query, tt.expectedStatus, w.Code)
+			}
+			if tt.expectedBody != "" && w.Body.String() != tt.expectedBody {
+				t.Errorf("%q: expected %q, got %q", tt.query, tt.expectedBody, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("operations use the host's daemon", func(t *testing.T) {
+		auditLog := testutil.OpenAuditLog(t)
+		handler := NewOperationsHandler(hosts, services.NewUpdateCache(), jobs.NewManager(logger), auditLog, testutil.OpenHistory(t), newTestNotifier(t), logger)
+
+		for _, name := range []string{"edge", "missing"} {
+			req := newRequest(http.MethodPost, "/host/"+name+"/container/container1/start", nil)
+			req = mux.SetURLVars(req, map[string]string{"host": name, "id": "container1"})
+			w := httptest.NewRecorder()
+			handler.HandleStart(w, req)
+
+			if expected := map[string]int{"edge": http.StatusOK, "missing": http.StatusNotFound}[name]; w.Code != expected {
+				t.Errorf("%s: expected status %d, got %d", name, expected, w.Code)
+			}
+		}
+		if len(started) != 1 || started[0] != "edge/container1" {
+			t.Errorf("expected only the container on edge to be started, got %v", started)
+		}
+
+		records, err := auditLog.List(audit.Filter{})
+		if err != nil || len(records) != 1 || records[0].Host != "edge" || records[0].Target != "edge-app" {
+			t.Errorf("expected the start to be audited for edge, got %+v (%v)", records, err)
+		}
+	})
+
+	t.Run("api", func(t *testing.T) {
+		handler := NewAPIHandler(hosts, services.NewUpdateCache(), testutil.OpenHistory(t), logger)
+
+		w := httptest.NewRecorder()
+		handler.HandleListHosts(w, newRequest(http.MethodGet, APIPrefix+"/hosts", nil))
+		var list HostList
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("failed to decode hosts: %v", err)
+		}
+		if len(list.Hosts) != 3 || !list.Hosts[0].Default || !list.Hosts[1].Reachable || list.Hosts[1].GroupCount != 1 ||
+			list.Hosts[2].Reachable || list.Hosts[2].Error == "" {
+			t.Errorf("unexpected hosts: %+v", list.Hosts)
+		}
+
+		w = httptest.NewRecorder()
+		handler.HandleListGroups(w, newRequest(http.MethodGet, APIPrefix+"/groups", nil))
+		body := w.Body.String()
+		for _, want := range []string{`"host":"local"`, `"host":"edge"`, `"unreachable_hosts":{"offline":`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected %s in %s", want, body)
+			}
+		}
+
+		req := newRequest(http.MethodGet, APIPrefix+"/hosts/edge/containers/container1", nil)
+		req = mux.SetURLVars(req, map[string]string{"host": "edge", "id": "container1"})
+		w = httptest.NewRecorder()
+		handler.HandleGetContainer(w, req)
+		if !strings.Contains(w.Body.String(), `"name":"edge-app"`) {
+			t.Errorf("expected the container on edge, got %s", w.Body.String())
+		}
+	})
+}
+
+func TestAuthHandlerLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	authenticator := auth.NewAuthenticator(&auth.Config{
+		Users: []auth.User{{Username: "admin", PasswordHash: string(hash)}},
+	}, time.Hour)
+
+	tmpl := template.Must(template.New("login.html").Parse(`{{.Error}}|{{.Next}}`))
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	handler := NewAuthHandler(authenticator, tmpl, logger)
+
+	tests := []struct {
+		name             string
+		password         string
+		next             string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{name: "valid credentials", password: "secret", next: "/container/nginx", expectedStatus: http.StatusSeeOther, expectedLocation: "/container/nginx"},
+		{name: "offsite redirect ignored", password: "secret", next: "//evil.example.com", expectedStatus: http.StatusSeeOther, expectedLocation: "/"},
+		{name: "wrong password", password: "wrong", next: "/", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"username": {"admin"}, "password": {tt.password}, "next": {tt.next}}
+			req := newRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+
+			handler.HandleLogin(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code != http.StatusSeeOther {
+				if len(w.Result().Cookies()) != 0 {
+					t.Error("expected no session cookie after a failed login")
+				}
+				return
+			}
+			if location := w.Header().Get("Location"); location != tt.expectedLocation {
+				t.Errorf("expected redirect to %q, got %q", tt.expectedLocation, location)
+			}
+
+			// The issued cookie authenticates later requests until logout
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != auth.SessionCookie || !cookies[0].HttpOnly {
+				t.Fatalf("expected an HttpOnly session cookie, got %v", cookies)
+			}
+			req = newRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookies[0])
+			if _, ok := authenticator.Authenticate(req); !ok {
+				t.Fatal("expected session cookie to authenticate")
+			}
+
+			req = newRequest(http.MethodPost, "/logout", nil)
+			req.AddCookie(cookies[0])
+			w = httptest.NewRecorder()
+			handler.HandleLogout(w, req)
+
+			req = newRequest(http.MethodGet, "/", nil)
+			req.AddCookie(cookies[0])
+			if _, ok := authenticator.Authenticate(req); ok {
+				t.Error("expected session to end after logout")
+			}
+		})
+	}
+}
+
+func TestRoleBasedAccess(t *testing.T) {
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "web1", Names: []string{"/shop-web-1"}, Image: "nginx:latest", State: "running",
+					Labels: map[string]string{"com.docker.compose.project": "shop", "com.docker.compose.service": "web"}},
+				{ID: "db1", Names: []string{"/billing-db-1"}, Image: "postgres:16", State: "running",
+					Labels: map[string]string{"com.docker.compose.project": "billing", "com.docker.compose.service": "db"}},
+			}, nil
+		},
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{Name: "/shop-web-1"},
+				Config:            &container.Config{Labels: map[string]string{"com.docker.compose.project": "shop"}},
+			}, nil
+		},
+		StartContainerFunc: func(ctx context.Context, id string) error {
+			return nil
+		},
+	}
+
+	// Operator on the shop project, viewer everywhere else
+	identity := auth.Identity{
+		Username: "alice",
+		Method:   auth.MethodSession,
+		Grants: auth.User{
+			Role:   auth.RoleViewer,
+			Grants: []auth.Grant{{Role: auth.RoleOperator, Projects: []string{"shop"}}},
+		}.EffectiveGrants(),
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	opsHandler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobs.NewManager(logger), testutil.OpenAuditLog(t), testutil.OpenHistory(t), newTestNotifier(t), logger)
+
+	tests := []struct {
+		name           string
+		handle         http.HandlerFunc
+		id             string
+		expectedStatus int
+	}{
+		{name: "start in scope", handle: opsHandler.HandleStart, id: "web1", expectedStatus: http.StatusOK},
+		{name: "update needs updater", handle: opsHandler.HandleUpdate, id: "shop", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/container/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+			w := httptest.NewRecorder()
+
+			tt.handle(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("start out of scope", func(t *testing.T) {
+		mockClient.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{Name: "/billing-db-1"},
+				Config:            &container.Config{Labels: map[string]string{"com.docker.compose.project": "billing"}},
+			}, nil
+		}
+		req := newRequest(http.MethodPost, "/container/db1/start", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "db1"})
+		req = req.WithContext(auth.WithIdentity(req.Context(), identity))
+		w := httptest.NewRecorder()
+
+		opsHandler.HandleStart(w, req)
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d", http.StatusForbidden, w.Code)
+		}
+	})
+
+	t.Run("groups hidden without view", func(t *testing.T) {
+		scoped := identity
+		scoped.Grants = []auth.Grant{{Role: auth.RoleViewer, Projects: []string{"shop"}}}
+
+		apiHandler := NewAPIHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), testutil.OpenHistory(t), logger)
+		req := newRequest(http.MethodGet, APIPrefix+"/groups", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), scoped))
+		w := httptest.NewRecorder()
+
+		apiHandler.HandleListGroups(w, req)
+
+		body := w.Body.String()
+		if !strings.Contains(body, `"id":"shop"`) || strings.Contains(body, `"id":"billing"`) {
+			t.Errorf("expected only the shop project, got %s", body)
+		}
+	})
+}
+
+func TestHandleRevert(t *testing.T) {
+	var createdImage string
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+			}, nil
+		},
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Name:       "/nginx",
+					State:      &types.ContainerState{Running: true, Status: "running"},
+					HostConfig: &container.HostConfig{},
+				},
+				Config: &container.Config{Image: "nginx:latest", Labels: map[string]string{services.LabelVerifyWindow: "0s"}},
+			}, nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			createdImage = config.Image
+			return "new-container-id", nil
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	jobManager := jobs.NewManager(logger)
+	auditLog := testutil.OpenAuditLog(t)
+	historyStore := testutil.OpenHistory(t)
+	handler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobManager, auditLog, historyStore, newTestNotifier(t), logger)
+
+	snapshot := models.ContainerSnapshot{
+		Name:        "nginx",
+		Image:       "nginx:latest",
+		ImageDigest: "sha256:old",
+		NewDigest:   "sha256:new",
+		Params: &models.ContainerParams{
+			Image:      "nginx:latest",
+			Name:       "nginx",
+			Config:     &container.Config{Image: "nginx:latest", Labels: map[string]string{services.LabelVerifyWindow: "0s"}},
+			HostConfig: &container.HostConfig{},
+			NetworkingConfig: &network.NetworkingConfig{
+				EndpointsConfig: map[string]*network.EndpointSettings{},
+			},
+		},
+	}
+	entry, err := historyStore.Add(history.Entry{Operation: audit.OperationUpdate, Target: "nginx", GroupType: models.GroupTypeStandalone, Containers: []models.ContainerSnapshot{snapshot}})
+	if err != nil {
+		t.Fatalf("failed to seed history: %v", err)
+	}
+	other, err := historyStore.Add(history.Entry{Operation: audit.OperationUpdate, Target: "redis", GroupType: models.GroupTypeStandalone, Containers: []models.ContainerSnapshot{snapshot}})
+	if err != nil {
+		t.Fatalf("failed to seed history: %v", err)
+	}
+
+	revert := func(entryID string) *httptest.ResponseRecorder {
+		req := newRequest(http.MethodPost, "/container/container1/history/"+entryID+"/revert", nil)
+		req = mux.SetURLVars(req, map[string]string{"id": "container1", "entry": entryID})
+		w := httptest.NewRecorder()
+		handler.HandleRevert(w, req)
+		return w
+	}
+
+	// Entries must exist and belong to the container
+	for _, entryID := range []string{"999", fmt.Sprint(other.ID)} {
+		if w := revert(entryID); w.Code != http.StatusNotFound {
+			t.Errorf("entry %s: expected status %d, got %d", entryID, http.StatusNotFound, w.Code)
+		}
+	}
+	if w := revert("latest"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an invalid entry, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w := revert(fmt.Sprint(entry.ID))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+	}
+	var accepted models.OperationResult
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	result := waitForJob(t, jobManager, accepted.JobID)
+	if !result.Success {
+		t.Fatalf("expected revert to succeed, got %s: %s", result.Error, result.Details)
+	}
+	if createdImage != "nginx@sha256:old" {
+		t.Errorf("expected the container to be recreated pinned to the previous digest, got %q", createdImage)
+	}
+
+	records, err := auditLog.List(audit.Filter{Operation: audit.OperationRevert})
+	if err != nil || len(records) != 1 || records[0].Target != "nginx" {
+		t.Errorf("expected one revert record, got %+v (%v)", records, err)
+	}
+}
+
+func TestActivityHandler(t *testing.T) {
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{Name: "/nginx"}}, nil
+		},
+		StopContainerFunc: func(ctx context.Context, id string) error {
+			return fmt.Errorf("container is not running")
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	auditLog := testutil.OpenAuditLog(t)
+	opsHandler := NewOperationsHandler(docker.SingleHost(mockClient), services.NewUpdateCache(), jobs.NewManager(logger), auditLog, testutil.OpenHistory(t), newTestNotifier(t), logger)
+	activityHandler := NewActivityHandler(auditLog, template.Must(template.New("activity.html").Parse(`{{len .Records}}`)), logger)
+
+	admin := auth.Identity{Username: "alice", Grants: auth.User{}.EffectiveGrants()}
+	withIdentity := func(req *http.Request, identity auth.Identity) *http.Request {
+		return req.WithContext(auth.WithIdentity(req.Context(), identity))
+	}
+
+	// Lifecycle operations are recorded with who ran them and how they ended
+	for _, op := range []http.HandlerFunc{opsHandler.HandleStart, opsHandler.HandleStop} {
+		req := newRequest(http.MethodPost, "/container/abc/op", nil)
+		req = withIdentity(mux.SetURLVars(req, map[string]string{"id": "abc"}), admin)
+		op(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		identity       auth.Identity
+		expectedStatus int
+		expectedType   string
+		expectedBody   []string
+	}{
+		{
+			name:           "json",
+			identity:       admin,
+			expectedStatus: http.StatusOK,
+			expectedType:   "application/json",
+			expectedBody:   []string{`"operation":"stop"`, `"result":"failure"`, `"message":"Container is not running."`, `"operation":"start"`, `"user":"alice"`},
+		},
+		{
+			name:           "filtered csv",
+			query:          "?format=csv&result=success",
+			identity:       admin,
+			expectedStatus: http.StatusOK,
+			expectedType:   "text/csv; charset=utf-8",
+			expectedBody:   []string{"id,time,user,operation", ",alice,start,nginx,abc,success,"},
+		},
+		{
+			name:           "invalid time",
+			query:          "?since=yesterday",
+			identity:       admin,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "scoped admin",
+			identity:       auth.Identity{Username: "bob", Grants: []auth.Grant{{Role: auth.RoleAdmin, Projects: []string{"shop"}}}},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withIdentity(newRequest(http.MethodGet, APIPrefix+"/activity"+tt.query, nil), tt.identity)
+			w := httptest.NewRecorder()
+
+			activityHandler.HandleExport(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedType != "" && w.Header().Get("Content-Type") != tt.expectedType {
+				t.Errorf("expected %s, got %q", tt.expectedType, w.Header().Get("Content-Type"))
+			}
+			body := w.Body.String()
+			for _, expected := range tt.expectedBody {
+				if !strings.Contains(body, expected) {
+					t.Errorf("expected body to contain %s, got %s", expected, body)
+				}
+			}
+			if strings.Contains(tt.query, "result=success") && strings.Contains(body, "failure") {
+				t.Errorf("expected failures to be filtered out, got %s", body)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		t        time.Time
+		expected string
+	}{
+		{name: "never", t: time.Time{}, expected: "never"},
+		{name: "seconds", t: now.Add(-30 * time.Second), expected: "just now"},
+		{name: "one minute", t: now.Add(-time.Minute), expected: "1 minute ago"},
+		{name: "minutes", t: now.Add(-42 * time.Minute), expected: "42 minutes ago"},
+		{name: "hours", t: now.Add(-3 * time.Hour), expected: "3 hours ago"},
+		{name: "days", t: now.Add(-50 * time.Hour), expected: "2 days ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatAge(tt.t, now); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
 }
 
-func (e *testError) Error() string {
-	return e.msg
+func TestUpdatesHandlerCheck(t *testing.T) {
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+			}, nil
+		},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	cache := services.NewUpdateCache()
+	schedule, _ := scheduler.ParseSchedule("1h")
+	checker := scheduler.NewUpdateChecker(docker.SingleHost(mockClient), &registry.MockClient{}, cache, schedule, time.Minute, newTestNotifier(t), logger)
+	handler := NewUpdatesHandler(checker, logger)
+
+	req := newRequest(http.MethodPost, "/updates/check", nil)
+	w := httptest.NewRecorder()
+
+	handler.HandleCheck(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+
+	var result models.OperationResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !result.Success {
+		t.Errorf("expected success, got error %q", result.Error)
+	}
+	if _, ok := cache.Get(docker.DefaultHostName, "container1"); !ok {
+		t.Error("expected check results to be cached")
+	}
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// HistoryList is the response body of GET /api/v1/groups/:id/history
+type HistoryList struct {
+	Entries []history.Entry `json:"entries"`
+}
+
+// groupHistory returns the recorded updates of a group, newest first. A
+// history that cannot be read is logged and shown as empty.
+func groupHistory(store *history.Store, group *models.ContainerGroup, logger *slog.Logger) []history.Entry {
+	entries, err := store.List(group.Host, group.Name)
+	if err != nil {
+		logger.Error("failed to read update history",
+			"group", group.Name,
+			"error", err,
+		)
+		return []history.Entry{}
+	}
+
+	// Entries of a standalone container and a compose project of the same name are kept apart
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.GroupType == group.Type {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// withoutConfig drops the recorded container configurations from entries,
+// since they include environment variables that may hold secrets
+func withoutConfig(entries []history.Entry) []history.Entry {
+	for i := range entries {
+		containers := make([]models.ContainerSnapshot, len(entries[i].Containers))
+		for j, snapshot := range entries[i].Containers {
+			snapshot.Params = nil
+			containers[j] = snapshot
+		}
+		entries[i].Containers = containers
+	}
+	return entries
+}
+
+// canRevert reports whether a history entry holds everything needed to revert
+// it, and whether any of its containers now runs a different (or unknown) digest
+func canRevert(group *models.ContainerGroup, entry history.Entry) bool {
+	differs := false
+	for _, snapshot := range entry.Containers {
+		if snapshot.ImageDigest == "" || (group.Type != models.GroupTypeCompose && snapshot.Params == nil) {
+			return false
+		}
+		for _, c := range group.Containers {
+			same := c.Name == snapshot.Name
+			if group.Type == models.GroupTypeCompose {
+				same = snapshot.Service != "" && c.Labels["com.docker.compose.service"] == snapshot.Service
+			}
+			if same && (c.ImageDigest == "" || c.ImageDigest != snapshot.ImageDigest) {
+				differs = true
+			}
+		}
+	}
+	return differs
+}
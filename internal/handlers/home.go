@@ -2,71 +2,104 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
 	"github.com/bleeding-edge/bleeding-edge/internal/services"
 )
 
 // HomeHandler handles the main grid view
 type HomeHandler struct {
-	client   docker.DockerClient
+	hosts    *docker.Hosts
+	cache    *services.UpdateCache
+	registry *registry.Client
 	template *template.Template
 	logger   *slog.Logger
 }
 
-// NewHomeHandler creates a new home handler
-func NewHomeHandler(client docker.DockerClient, tmpl *template.Template, logger *slog.Logger) *HomeHandler {
+// NewHomeHandler creates a new home handler. The registry client, which may be
+// nil, reports the registries' remaining pull quotas.
+func NewHomeHandler(hosts *docker.Hosts, cache *services.UpdateCache, registryClient *registry.Client, tmpl *template.Template, logger *slog.Logger) *HomeHandler {
 	return &HomeHandler{
-		client:   client,
+		hosts:    hosts,
+		cache:    cache,
+		registry: registryClient,
 		template: tmpl,
 		logger:   logger,
 	}
 }
 
-// ServeHTTP handles GET / requests
+// ServeHTTP handles GET / requests. With several Docker hosts, ?host=name
+// shows the containers of one host only.
 func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 	defer cancel()
 
 	h.logger.Info("handling home page request")
 
-	// Get container groups
-	groups, err := services.GetContainerGroups(ctx, h.client)
-	if err != nil {
+	hostFilter := r.URL.Query().Get("host")
+	if _, ok := h.hosts.Get(hostFilter); hostFilter != "" && !ok {
+		http.Error(w, "Docker host not found.", http.StatusNotFound)
+		return
+	}
+
+	// Get container groups of every host; an unreachable host does not hide the others
+	groups, hostErrors := services.GetAllContainerGroups(ctx, h.hosts)
+	if len(hostErrors) == h.hosts.Len() {
 		h.logger.Error("failed to get container groups",
-			"error", err,
+			"errors", hostErrors,
 			"operation", "list_containers",
 		)
 		http.Error(w, "Failed to load containers. Please check Docker daemon connection.", http.StatusInternalServerError)
 		return
 	}
 
-	// Skip update checks on initial page load to render quickly
-	// Update checks can be done via a separate endpoint or on detail pages
-	checkUpdates := r.URL.Query().Get("check_updates")
-	if checkUpdates == "true" {
-		// Use a longer timeout for update checks since pulling images can take time
-		updateCtx, updateCancel := context.WithTimeout(context.Background(), 5*time.Minute)
-		defer updateCancel()
-		
-		if err := services.CheckUpdates(updateCtx, h.client, groups); err != nil {
-			h.logger.Warn("failed to check updates",
-				"error", err,
-				"operation", "check_updates",
-			)
-			// Continue rendering even if update check fails
+	unreachable := make(map[string]string)
+	for host, err := range hostErrors {
+		h.logger.Warn("failed to get container groups of host",
+			"docker_host", host,
+			"error", err,
+			"operation", "list_containers",
+		)
+		if hostFilter == "" || host == hostFilter {
+			unreachable[host] = err.Error()
 		}
 	}
+	if hostFilter != "" {
+		groups = slices.DeleteFunc(groups, func(g models.ContainerGroup) bool { return g.Host != hostFilter })
+	}
+	hostNames := make([]string, 0, h.hosts.Len())
+	for _, host := range h.hosts.All() {
+		hostNames = append(hostNames, host.Name)
+	}
+
+	// Apply the latest background check results; never block on registries here
+	groups = visibleGroups(r, groups)
+	h.cache.Apply(groups)
+	lastChecked := h.cache.LastChecked()
 
 	// Prepare template data
 	data := map[string]interface{}{
-		"Groups":       groups,
-		"Title":        "BleedingEdge - Container Manager",
-		"CheckUpdates": checkUpdates != "true", // Show check updates button if not already checked
+		"Groups":      groups,
+		"Hosts":       hostNames,
+		"MultiHost":   h.hosts.Len() > 1,
+		"HostFilter":  hostFilter,
+		"HostErrors":  unreachable,
+		"Title":       "BleedingEdge - Container Manager",
+		"Checked":     !lastChecked.IsZero(),
+		"LastChecked": formatAge(lastChecked, time.Now()),
+		"RateLimits":  h.registry.RateLimits(),
+		"User":        currentUser(r),
+		"CanCheck":    auth.AllowedAny(r.Context(), auth.ActionUpdate),
+		"CanAudit":    canAudit(r),
 	}
 
 	// Render template
@@ -81,3 +114,30 @@ func (h *HomeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Info("home page rendered successfully", "group_count", len(groups))
 }
+
+// formatAge renders how long ago t was in a human-friendly form
+func formatAge(t, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	age := now.Sub(t)
+	switch {
+	case age < time.Minute:
+		return "just now"
+	case age < time.Hour:
+		return pluralize(int(age/time.Minute), "minute") + " ago"
+	case age < 24*time.Hour:
+		return pluralize(int(age/time.Hour), "hour") + " ago"
+	default:
+		return pluralize(int(age/(24*time.Hour)), "day") + " ago"
+	}
+}
+
+// pluralize formats a count with a singular or plural unit
+func pluralize(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/gorilla/mux"
+)
+
+// HostStatus describes a configured Docker host in GET /api/v1/hosts
+type HostStatus struct {
+	Name       string `json:"name"`
+	Default    bool   `json:"default"`         // Addressed by routes without /hosts/:host
+	Reachable  bool   `json:"reachable"`       // False if its containers could not be listed
+	GroupCount int    `json:"group_count"`     // Compose projects and standalone containers visible to the user
+	Error      string `json:"error,omitempty"` // Why the host is unreachable
+}
+
+// HostList is the response body of GET /api/v1/hosts
+type HostList struct {
+	Hosts []HostStatus `json:"hosts"`
+}
+
+// requestHost resolves the Docker host a request addresses: the {host} route
+// variable, or the default host for routes without one
+func requestHost(hosts *docker.Hosts, r *http.Request) (docker.Host, bool) {
+	name, ok := mux.Vars(r)["host"]
+	if !ok {
+		return hosts.Default(), true
+	}
+	return hosts.Get(name)
+}
+
+// HandleListHosts handles GET /api/v1/hosts requests
+func (h *APIHandler) HandleListHosts(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	groups, hostErrors := services.GetAllContainerGroups(ctx, h.hosts)
+	counts := make(map[string]int)
+	for _, group := range visibleGroups(r, groups) {
+		counts[group.Host]++
+	}
+
+	list := HostList{Hosts: []HostStatus{}}
+	for _, host := range h.hosts.All() {
+		status := HostStatus{
+			Name:       host.Name,
+			Default:    host.Name == h.hosts.Default().Name,
+			Reachable:  hostErrors[host.Name] == nil,
+			GroupCount: counts[host.Name],
+		}
+		if err := hostErrors[host.Name]; err != nil {
+			status.Error = err.Error()
+		}
+		list.Hosts = append(list.Hosts, status)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
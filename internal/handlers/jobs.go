@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/gorilla/mux"
+)
+
+// sseKeepAlive is how often a comment is sent on idle event streams so proxies keep them open
+const sseKeepAlive = 15 * time.Second
+
+// JobsHandler exposes the progress of background jobs
+type JobsHandler struct {
+	jobs   *jobs.Manager
+	logger *slog.Logger
+}
+
+// JobStatus is the JSON snapshot of a job returned by GET /jobs/:id
+type JobStatus struct {
+	ID        string       `json:"id"`
+	Operation string       `json:"operation"`
+	Target    string       `json:"target"`
+	Status    jobs.Status  `json:"status"`
+	Started   time.Time    `json:"started"`
+	Events    []jobs.Event `json:"events"`
+}
+
+// NewJobsHandler creates a new jobs handler
+func NewJobsHandler(jobManager *jobs.Manager, logger *slog.Logger) *JobsHandler {
+	return &JobsHandler{
+		jobs:   jobManager,
+		logger: logger,
+	}
+}
+
+// HandleGet handles GET /jobs/:id requests with a snapshot of the job
+func (h *JobsHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	status := JobStatus{
+		ID:        job.ID,
+		Operation: job.Operation,
+		Target:    job.Target,
+		Status:    job.Status(),
+		Started:   job.Started,
+		Events:    job.Events(),
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// job returns the requested job if the signed-in user may view every container
+// it acts on, writing an error response otherwise
+func (h *JobsHandler) job(w http.ResponseWriter, r *http.Request) (*jobs.Job, bool) {
+	job, ok := h.jobs.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Job not found")
+		return nil, false
+	}
+	for _, labels := range job.Labels {
+		if !auth.Allowed(r.Context(), auth.ActionView, labels) {
+			h.logger.Warn("job access forbidden",
+				"job_id", job.ID,
+				"target", job.Target,
+				"username", currentUser(r),
+			)
+			writeAPIError(w, http.StatusForbidden, "You do not have permission to view this job")
+			return nil, false
+		}
+	}
+	return job, true
+}
+
+// HandleEvents handles GET /jobs/:id/events requests by streaming the job's
+// events as Server-Sent Events. Past events are replayed first, and the stream
+// ends after the final "done" event.
+func (h *JobsHandler) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.job(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	next := 0
+	send := func(event jobs.Event) bool {
+		if event.Seq < next {
+			return true
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data); err != nil {
+			return false
+		}
+		next = event.Seq + 1
+		return rc.Flush() == nil
+	}
+
+	history, live := job.Subscribe(r.Context())
+	for _, event := range history {
+		if !send(event) {
+			return
+		}
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, open := <-live:
+			if !open {
+				// Send anything dropped for being slow, including the final event
+				for _, event := range job.Events() {
+					if !send(event) {
+						return
+					}
+				}
+				return
+			}
+			if !send(event) {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil || rc.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -6,30 +6,54 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
 	"github.com/bleeding-edge/bleeding-edge/internal/services"
 	"github.com/gorilla/mux"
 )
 
+// updateTimeout bounds a single update job
+const updateTimeout = 5 * time.Minute
+
 // OperationsHandler handles container lifecycle and update operations
 type OperationsHandler struct {
-	client docker.DockerClient
-	logger *slog.Logger
+	hosts    *docker.Hosts
+	cache    *services.UpdateCache
+	jobs     *jobs.Manager
+	audit    *audit.Log
+	history  *history.Store
+	notifier *notify.Notifier
+	logger   *slog.Logger
 }
 
-// NewOperationsHandler creates a new operations handler
-func NewOperationsHandler(client docker.DockerClient, logger *slog.Logger) *OperationsHandler {
+// NewOperationsHandler creates a new operations handler that records every
+// operation in the audit log, what each update replaced in the history, and
+// notifies webhooks of update results. Updates move containers to the newer
+// tags found by the last update check in cache.
+func NewOperationsHandler(hosts *docker.Hosts, cache *services.UpdateCache, jobManager *jobs.Manager, auditLog *audit.Log, historyStore *history.Store, notifier *notify.Notifier, logger *slog.Logger) *OperationsHandler {
 	return &OperationsHandler{
-		client: client,
-		logger: logger,
+		hosts:    hosts,
+		cache:    cache,
+		jobs:     jobManager,
+		audit:    auditLog,
+		history:  historyStore,
+		notifier: notifier,
+		logger:   logger,
 	}
 }
 
 // HandleUpdate handles POST /container/:id/update requests
+// The update runs as a background job; follow it at /jobs/:id/events
 func (h *OperationsHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -38,109 +62,371 @@ func (h *OperationsHandler) HandleUpdate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
 	h.logger.Info("handling update request", "id", id)
 
 	// Get container groups to determine if this is a compose project or standalone
-	groups, err := services.GetContainerGroups(ctx, h.client)
-	if err != nil {
-		h.logger.Error("failed to get container groups", "id", id, "error", err)
-		h.sendErrorResponse(w, "update", id, "Failed to load container information", http.StatusInternalServerError)
+	group, client, ok := h.findGroup(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	if !allowedAll(r, auth.ActionUpdate, group.Containers) {
+		h.sendForbidden(w, r, "update", group.Name)
 		return
 	}
 
-	// Find the requested group
-	var group *models.ContainerGroup
-	for i := range groups {
-		if groups[i].ID == id {
-			group = &groups[i]
-			break
+	record := newRecord(r, audit.OperationUpdate, group.Host, group.Name, group.ID)
+	job := h.jobs.Start(r.Context(), "update", group.Name, containerLabels(group.Containers), updateTimeout, func(ctx context.Context) models.OperationResult {
+		snapshots := services.SnapshotContainers(ctx, client, group.Containers)
+
+		var updateErr error
+		if group.Type == models.GroupTypeCompose {
+			// Only services whose image changed are recreated
+			updateErr = services.UpdateComposeProject(ctx, client, group.Name, group.WorkingDir, group.Containers)
+		} else {
+			// Standalone container, moved to a newer tag if it tracks tags
+			updateErr = services.UpdateStandaloneContainerTo(ctx, client, group.ID, services.TrackedImage(group.Containers[0]))
 		}
+		return h.updateResult(ctx, client, record, group, group.Containers, snapshots, updateErr)
+	})
+
+	h.sendJobResponse(w, r, job, fmt.Sprintf("Updating %s", group.Name))
+}
+
+// HandleServiceUpdate handles POST /container/:id/services/:service/update requests
+// It recreates a single service of a compose project without touching the others
+func (h *OperationsHandler) HandleServiceUpdate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	service := vars["service"]
+	if id == "" || service == "" {
+		h.sendErrorResponse(w, "update", "", "Project and service required", http.StatusBadRequest)
+		return
 	}
 
-	if group == nil {
-		h.logger.Warn("container group not found", "id", id)
-		h.sendErrorResponse(w, "update", id, "Container not found", http.StatusNotFound)
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	h.logger.Info("handling service update request", "id", id, "service", service)
+
+	group, client, ok := h.findGroup(ctx, w, r, id)
+	if !ok {
+		return
+	}
+	if group.Type != models.GroupTypeCompose {
+		h.sendErrorResponse(w, "update", group.Name, "Only compose projects have services", http.StatusBadRequest)
 		return
 	}
 
-	// Execute update based on group type
-	var updateErr error
-	if group.Type == models.GroupTypeCompose {
-		// Extract container images
-		images := make([]string, 0, len(group.Containers))
-		for _, container := range group.Containers {
-			images = append(images, container.Image)
+	var serviceContainers []models.ContainerInfo
+	for _, c := range group.Containers {
+		if c.Labels["com.docker.compose.service"] == service {
+			serviceContainers = append(serviceContainers, c)
 		}
-		updateErr = services.UpdateComposeProject(ctx, h.client, group.Name, group.WorkingDir, images)
-	} else {
-		// Standalone container
-		updateErr = services.UpdateStandaloneContainer(ctx, h.client, group.ID)
+	}
+	if len(serviceContainers) == 0 {
+		h.sendErrorResponse(w, "update", group.Name, "Service not found", http.StatusNotFound)
+		return
 	}
 
-	if updateErr != nil {
-		errResp := createErrorResponse("update", group.Name, updateErr)
-		h.sendErrorResponseWithDetails(w, errResp, http.StatusInternalServerError)
+	name := group.Name + "/" + service
+	if !allowedAll(r, auth.ActionUpdate, serviceContainers) {
+		h.sendForbidden(w, r, "update", name)
+		return
+	}
+
+	record := newRecord(r, audit.OperationUpdate, group.Host, name, group.ID)
+	job := h.jobs.Start(r.Context(), "update", name, containerLabels(serviceContainers), updateTimeout, func(ctx context.Context) models.OperationResult {
+		snapshots := services.SnapshotContainers(ctx, client, serviceContainers)
+		err := services.UpdateComposeService(ctx, client, group.Name, group.WorkingDir, group.Containers, service)
+		return h.updateResult(ctx, client, record, group, serviceContainers, snapshots, err)
+	})
+
+	h.sendJobResponse(w, r, job, fmt.Sprintf("Updating %s", name))
+}
+
+// HandleRevert handles POST /container/:id/history/:entry/revert requests
+// It recreates the containers of a recorded update with the configuration and
+// image digests they had before it, as a background job
+func (h *OperationsHandler) HandleRevert(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	entryID, err := strconv.ParseUint(vars["entry"], 10, 64)
+	if id == "" || err != nil {
+		h.sendErrorResponse(w, "revert", id, "Container ID and history entry required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	h.logger.Info("handling revert request", "id", id, "entry", entryID)
+
+	group, client, ok := h.findGroup(ctx, w, r, id)
+	if !ok {
+		return
+	}
+
+	entry, err := h.history.Get(entryID)
+	if err != nil || entry.Target != group.Name || !entry.Matches(group.Host) || entry.GroupType != group.Type || len(entry.Containers) == 0 {
+		h.logger.Warn("history entry not found", "id", id, "entry", entryID, "error", err)
+		h.sendErrorResponse(w, "revert", group.Name, "Update not found in history", http.StatusNotFound)
+		return
+	}
+
+	containers := revertTargets(group, entry)
+	if len(containers) == 0 {
+		h.sendErrorResponse(w, "revert", group.Name, "The services of this update no longer exist", http.StatusNotFound)
 		return
 	}
+	if !allowedAll(r, auth.ActionUpdate, containers) {
+		h.sendForbidden(w, r, "revert", group.Name)
+		return
+	}
+
+	record := newRecord(r, audit.OperationRevert, group.Host, group.Name, group.ID)
+	job := h.jobs.Start(r.Context(), "revert", group.Name, containerLabels(containers), updateTimeout, func(ctx context.Context) models.OperationResult {
+		snapshots := services.SnapshotContainers(ctx, client, containers)
+
+		var revertErr error
+		if group.Type == models.GroupTypeCompose {
+			revertErr = services.RevertComposeServices(ctx, client, group.Name, group.WorkingDir, group.Containers, entry.Containers)
+		} else {
+			revertErr = services.RevertStandaloneContainer(ctx, client, group.ID, entry.Containers[0])
+		}
+		return h.updateResult(ctx, client, record, group, containers, snapshots, revertErr)
+	})
+
+	h.sendJobResponse(w, r, job, fmt.Sprintf("Reverting %s", group.Name))
+}
+
+// revertTargets returns the containers of a group a history entry would
+// recreate: the whole standalone container, or the entry's compose services
+func revertTargets(group *models.ContainerGroup, entry history.Entry) []models.ContainerInfo {
+	if group.Type != models.GroupTypeCompose {
+		return group.Containers
+	}
+
+	var containers []models.ContainerInfo
+	for _, c := range group.Containers {
+		for _, snapshot := range entry.Containers {
+			if snapshot.Service != "" && snapshot.Service == c.Labels["com.docker.compose.service"] {
+				containers = append(containers, c)
+				break
+			}
+		}
+	}
+	return containers
+}
+
+// updateResult converts the outcome of an update or revert job into an
+// OperationResult. It records the operation, with the digests the containers
+// ran before and after, in the audit log, and what it replaced in the history.
+func (h *OperationsHandler) updateResult(ctx context.Context, client docker.DockerClient, record audit.Record, group *models.ContainerGroup, containers []models.ContainerInfo, snapshots []models.ContainerSnapshot, err error) models.OperationResult {
+	name := record.Target
+	before := make(map[string]string)
+	for _, snapshot := range snapshots {
+		before[snapshot.Name] = snapshot.ImageDigest
+	}
+	after := services.ImageDigests(ctx, client, containerNames(containers))
+	record.Images = audit.ImageChanges(containers, before, after)
+
+	for i := range snapshots {
+		snapshots[i].NewDigest = after[snapshots[i].Name]
+	}
+	h.remember(history.Entry{
+		Time:       record.Time,
+		User:       record.User,
+		Operation:  record.Operation,
+		Host:       group.Host,
+		Target:     group.Name,
+		GroupType:  group.Type,
+		Containers: snapshots,
+	})
+
+	if err != nil {
+		errResp := services.NewErrorResponse(record.Operation, name, err)
+		h.logError(errResp)
+		h.record(record, &errResp)
+		return errorResult(errResp)
+	}
+
+	h.record(record, nil)
+
+	verb := "updated"
+	if record.Operation == audit.OperationRevert {
+		verb = "reverted"
+	}
+	h.logger.Info(record.Operation+" completed successfully", "target", name)
+	return models.OperationResult{
+		Success:   true,
+		Message:   fmt.Sprintf("%s %s successfully", name, verb),
+		Timestamp: time.Now(),
+	}
+}
+
+// findGroup looks up a container group by ID on the requested host, returning
+// it with the client of its host and sending an error response if it cannot
+// be found. Containers the signed-in user may not view are left out.
+func (h *OperationsHandler) findGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) (*models.ContainerGroup, docker.DockerClient, bool) {
+	host, ok := requestHost(h.hosts, r)
+	if !ok {
+		h.sendErrorResponse(w, "update", id, "Docker host not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	groups, err := services.GetHostGroups(ctx, host)
+	if err != nil {
+		h.logger.Error("failed to get container groups", "id", id, "docker_host", host.Name, "error", err)
+		h.sendErrorResponse(w, "update", id, "Failed to load container information", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	groups = visibleGroups(r, groups)
+	h.cache.Apply(groups)
+
+	for i := range groups {
+		if groups[i].ID == id {
+			return &groups[i], host.Client, true
+		}
+	}
 
-	h.logger.Info("update completed successfully", "id", id, "type", group.Type)
-	h.sendSuccessResponse(w, "update", group.Name, fmt.Sprintf("%s updated successfully", group.Name))
+	h.logger.Warn("container group not found", "id", id, "docker_host", host.Name)
+	h.sendErrorResponse(w, "update", id, "Container not found", http.StatusNotFound)
+	return nil, nil, false
 }
 
 // HandleStart handles POST /container/:id/start requests
 func (h *OperationsHandler) HandleStart(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	h.handleLifecycleOperation(w, r, id, "start", h.client.StartContainer)
+	h.handleLifecycleOperation(w, r, id, "start", docker.DockerClient.StartContainer)
 }
 
 // HandleStop handles POST /container/:id/stop requests
 func (h *OperationsHandler) HandleStop(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	h.handleLifecycleOperation(w, r, id, "stop", h.client.StopContainer)
+	h.handleLifecycleOperation(w, r, id, "stop", docker.DockerClient.StopContainer)
 }
 
 // HandleRestart handles POST /container/:id/restart requests
 func (h *OperationsHandler) HandleRestart(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
-	h.handleLifecycleOperation(w, r, id, "restart", h.client.RestartContainer)
+	h.handleLifecycleOperation(w, r, id, "restart", docker.DockerClient.RestartContainer)
 }
 
-// handleLifecycleOperation is a helper function for lifecycle operations
-func (h *OperationsHandler) handleLifecycleOperation(w http.ResponseWriter, r *http.Request, id, operation string, operationFunc func(context.Context, string) error) {
+// handleLifecycleOperation is a helper function for lifecycle operations,
+// running operationFunc with the client of the requested host
+func (h *OperationsHandler) handleLifecycleOperation(w http.ResponseWriter, r *http.Request, id, operation string, operationFunc func(docker.DockerClient, context.Context, string) error) {
 	if id == "" {
 		h.sendErrorResponse(w, operation, "", "Container ID required", http.StatusBadRequest)
 		return
 	}
+	host, ok := requestHost(h.hosts, r)
+	if !ok {
+		h.sendErrorResponse(w, operation, id, "Docker host not found", http.StatusNotFound)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	h.logger.Info("handling lifecycle operation", "operation", operation, "id", id)
+	h.logger.Info("handling lifecycle operation", "operation", operation, "id", id, "docker_host", host.Name)
 
-	// Get container name for better error messages
+	// Get container name for better error messages, and labels for permission checks
 	containerName := id
-	containerJSON, err := h.client.InspectContainer(ctx, id)
+	var labels map[string]string
+	containerJSON, err := host.Client.InspectContainer(ctx, id)
 	if err == nil {
 		containerName = strings.TrimPrefix(containerJSON.Name, "/")
+		if containerJSON.Config != nil {
+			labels = containerJSON.Config.Labels
+		}
+	}
+	if !auth.Allowed(r.Context(), auth.ActionOperate, labels) {
+		h.sendForbidden(w, r, operation, containerName)
+		return
 	}
 
 	// Execute the operation
-	if err := operationFunc(ctx, id); err != nil {
-		errResp := createErrorResponse(operation, containerName, err)
+	record := newRecord(r, operation, host.Name, containerName, id)
+	if err := operationFunc(host.Client, ctx, id); err != nil {
+		errResp := services.NewErrorResponse(operation, containerName, err)
+		h.record(record, &errResp)
 		h.sendErrorResponseWithDetails(w, errResp, http.StatusInternalServerError)
 		return
 	}
 
+	h.record(record, nil)
 	h.logger.Info("lifecycle operation completed", "operation", operation, "id", id)
 	h.sendSuccessResponse(w, operation, containerName, fmt.Sprintf("Container %s %sed successfully", containerName, operation))
 }
 
+// newRecord starts an audit record for an operation requested by the signed-in user
+func newRecord(r *http.Request, operation, host, target, targetID string) audit.Record {
+	return audit.Record{
+		Time:      time.Now(),
+		User:      currentUser(r),
+		Operation: operation,
+		Host:      host,
+		Target:    target,
+		TargetID:  targetID,
+	}
+}
+
+// record completes an audit record with its duration and outcome, stores it
+// and notifies webhooks of update results. A failure to write the audit log is
+// logged but does not fail the operation.
+func (h *OperationsHandler) record(record audit.Record, errResp *models.ErrorResponse) {
+	record.DurationMS = time.Since(record.Time).Milliseconds()
+	record.Result = audit.ResultSuccess
+	if errResp != nil {
+		record.Result = audit.ResultFailure
+		record.Error = errResp
+	}
+
+	if _, err := h.audit.Append(record); err != nil {
+		h.logger.Error("failed to write audit record",
+			"operation", record.Operation,
+			"target", record.Target,
+			"error", err,
+		)
+	}
+
+	metrics.ObserveOperation(record.Operation, string(record.Result), time.Since(record.Time))
+
+	if event, ok := notify.FromRecord(record); ok {
+		h.notifier.Notify(event)
+	}
+}
+
+// remember stores what an update replaced in the history, if it changed any
+// image. A failure to write the history is logged but does not fail the operation.
+func (h *OperationsHandler) remember(entry history.Entry) {
+	if !entry.Changed() {
+		return
+	}
+	if _, err := h.history.Add(entry); err != nil {
+		h.logger.Error("failed to write history entry",
+			"operation", entry.Operation,
+			"target", entry.Target,
+			"error", err,
+		)
+	}
+}
+
+// containerNames returns the names of the given containers
+func containerNames(containers []models.ContainerInfo) []string {
+	names := make([]string, 0, len(containers))
+	for _, c := range containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
 // sendSuccessResponse sends a success response in htmx-compatible format
 func (h *OperationsHandler) sendSuccessResponse(w http.ResponseWriter, operation, containerName, message string) {
 	result := models.OperationResult{
@@ -172,87 +458,65 @@ func (h *OperationsHandler) sendErrorResponse(w http.ResponseWriter, operation,
 
 // sendErrorResponseWithDetails sends a detailed error response
 func (h *OperationsHandler) sendErrorResponseWithDetails(w http.ResponseWriter, errResp models.ErrorResponse, statusCode int) {
-	// Log the detailed error
-	h.logger.Error("operation failed",
-		"operation", errResp.Operation,
-		"container", errResp.Container,
-		"message", errResp.Message,
-		"details", errResp.Details,
-	)
-
-	// Convert to OperationResult for backward compatibility with UI
-	result := models.OperationResult{
-		Success:   false,
-		Error:     errResp.Message,
-		Message:   fmt.Sprintf("Failed to %s %s", errResp.Operation, errResp.Container),
-		Timestamp: errResp.Timestamp,
-	}
+	h.logError(errResp)
 
 	// Set headers to ensure fast response (within 2 seconds requirement)
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(errorResult(errResp))
 }
 
-// formatErrorMessage converts technical error messages to user-friendly messages
-func formatErrorMessage(err error) string {
-	errMsg := err.Error()
+// sendForbidden rejects an operation the signed-in user's roles do not allow
+func (h *OperationsHandler) sendForbidden(w http.ResponseWriter, r *http.Request, operation, containerName string) {
+	h.logger.Warn("operation forbidden",
+		"operation", operation,
+		"container", containerName,
+		"username", currentUser(r),
+	)
+	h.sendErrorResponse(w, operation, containerName, "You do not have permission to "+operation+" this container", http.StatusForbidden)
+}
 
-	// Common error patterns and their user-friendly messages
-	if strings.Contains(errMsg, "No such container") {
-		return "Container not found. It may have been removed."
-	}
-	if strings.Contains(errMsg, "already in progress") {
-		return "Operation already in progress. Please wait."
-	}
-	if strings.Contains(errMsg, "is not running") {
-		return "Container is not running."
-	}
-	if strings.Contains(errMsg, "is already stopped") {
-		return "Container is already stopped."
-	}
-	if strings.Contains(errMsg, "failed to pull") || strings.Contains(errMsg, "pull access denied") {
-		return "Failed to pull image. Check your internet connection and image name."
-	}
-	if strings.Contains(errMsg, "permission denied") || strings.Contains(errMsg, "access denied") {
-		return "Permission denied. Check Docker socket permissions."
-	}
-	if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "context deadline exceeded") {
-		return "Operation timed out. The container may be unresponsive."
-	}
-	if strings.Contains(errMsg, "network") && strings.Contains(errMsg, "not found") {
-		return "Network not found. The container's network may have been removed."
-	}
-	if strings.Contains(errMsg, "port is already allocated") {
-		return "Port is already in use. Another container may be using the same port."
-	}
-	if strings.Contains(errMsg, "no such image") {
-		return "Image not found. The image may not exist or has been removed."
-	}
-	if strings.Contains(errMsg, "Conflict") {
-		return "Container name conflict. A container with this name already exists."
+// sendJobResponse acknowledges a background job with 202 Accepted, pointing
+// Location at the job under the same prefix the request used
+func (h *OperationsHandler) sendJobResponse(w http.ResponseWriter, r *http.Request, job *jobs.Job, message string) {
+	location := "/jobs/" + job.ID
+	if strings.HasPrefix(r.URL.Path, APIPrefix+"/") {
+		location = APIPrefix + location
 	}
-	if strings.Contains(errMsg, "working directory") {
-		return "Working directory not found. The compose project directory may have been moved or deleted."
-	}
-	if strings.Contains(errMsg, "docker compose") {
-		return "Docker Compose command failed. Check the compose file and project configuration."
+
+	result := models.OperationResult{
+		Success:   true,
+		Message:   message,
+		JobID:     job.ID,
+		Timestamp: time.Now(),
 	}
 
-	// Return the original error message if no pattern matches
-	return errMsg
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
 }
 
-// createErrorResponse creates a structured error response
-func createErrorResponse(operation, containerName string, err error) models.ErrorResponse {
-	userMessage := formatErrorMessage(err)
-	
-	return models.ErrorResponse{
-		Operation: operation,
-		Container: containerName,
-		Message:   userMessage,
-		Details:   err.Error(),
-		Timestamp: time.Now(),
+// logError logs the details of a failed operation
+func (h *OperationsHandler) logError(errResp models.ErrorResponse) {
+	h.logger.Error("operation failed",
+		"operation", errResp.Operation,
+		"container", errResp.Container,
+		"message", errResp.Message,
+		"details", errResp.Details,
+		"rolled_back", errResp.RolledBack,
+	)
+}
+
+// errorResult converts an ErrorResponse to the OperationResult shape the UI expects
+func errorResult(errResp models.ErrorResponse) models.OperationResult {
+	return models.OperationResult{
+		Success:    false,
+		Error:      errResp.Message,
+		Message:    fmt.Sprintf("Failed to %s %s", errResp.Operation, errResp.Container),
+		Details:    errResp.Details,
+		RolledBack: errResp.RolledBack,
+		Timestamp:  errResp.Timestamp,
 	}
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/auth"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/scheduler"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+)
+
+// UpdatesHandler handles on-demand update checks
+type UpdatesHandler struct {
+	checker *scheduler.UpdateChecker
+	logger  *slog.Logger
+}
+
+// NewUpdatesHandler creates a new updates handler
+func NewUpdatesHandler(checker *scheduler.UpdateChecker, logger *slog.Logger) *UpdatesHandler {
+	return &UpdatesHandler{
+		checker: checker,
+		logger:  logger,
+	}
+}
+
+// HandleCheck handles POST /updates/check requests by running a check
+// immediately and refreshing the cache used by the grid and detail pages
+func (h *UpdatesHandler) HandleCheck(w http.ResponseWriter, r *http.Request) {
+	h.logger.Info("handling update check request")
+
+	if !auth.AllowedAny(r.Context(), auth.ActionUpdate) {
+		h.logger.Warn("update check forbidden", "username", currentUser(r))
+		writeAPIError(w, http.StatusForbidden, "You do not have permission to check for updates")
+		return
+	}
+
+	result := models.OperationResult{
+		Success:   true,
+		Message:   "Update check completed",
+		Timestamp: time.Now(),
+	}
+	statusCode := http.StatusOK
+
+	if err := h.checker.CheckNow(r.Context()); err != nil {
+		h.logger.Error("update check failed",
+			"error", err,
+			"operation", "check_updates",
+		)
+		result.Success = false
+		result.Message = "Failed to check for updates"
+		result.Error = services.FormatErrorMessage(err)
+		statusCode = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,179 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxEntriesPerTarget is how many updates are kept for each container or
+// compose project; older entries are pruned as new ones are added
+const maxEntriesPerTarget = 20
+
+// entriesBucket holds entries keyed by their big-endian ID, so keys sort by age
+var entriesBucket = []byte("entries")
+
+// ErrNotFound is returned by Get for an unknown entry ID
+var ErrNotFound = errors.New("history entry not found")
+
+// Entry is one update of a container or compose project, with everything
+// needed to revert it
+type Entry struct {
+	ID         uint64                     `json:"id"`             // Assigned by Add, increasing
+	Time       time.Time                  `json:"time"`           // When the update started
+	User       string                     `json:"user"`           // Who ran it, as in the audit log
+	Operation  string                     `json:"operation"`      // update, auto-update or revert
+	Host       string                     `json:"host,omitempty"` // Docker host the target runs on
+	Target     string                     `json:"target"`         // Container or compose project name
+	GroupType  models.GroupType           `json:"group_type"`     // compose or standalone
+	Containers []models.ContainerSnapshot `json:"containers"`     // What each affected container ran before the update
+}
+
+// Changed reports whether the update moved any container to a different
+// image. Containers whose digests are unknown count as changed.
+func (e Entry) Changed() bool {
+	for _, c := range e.Containers {
+		if c.ImageDigest == "" || c.NewDigest == "" || c.ImageDigest != c.NewDigest {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether the entry belongs to the named host
+func (e Entry) Matches(host string) bool {
+	return e.Host == "" || e.Host == host
+}
+
+// Store is the persistent update history, stored in an embedded bbolt database
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the history at path, creating the file and its directory if needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(entriesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add stores an entry, assigning its ID, and prunes the oldest entries of the
+// same target on the same host beyond maxEntriesPerTarget
+func (s *Store) Add(entry Entry) (Entry, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(entriesBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(entryKey(id), data); err != nil {
+			return err
+		}
+
+		// Walk back from the newest entry, dropping those past the limit
+		var stale [][]byte
+		kept := 0
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var other Entry
+			if err := json.Unmarshal(value, &other); err != nil || other.Target != entry.Target || other.Host != entry.Host {
+				continue
+			}
+			kept++
+			if kept > maxEntriesPerTarget {
+				stale = append(stale, append([]byte(nil), key...))
+			}
+		}
+		for _, key := range stale {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return entry, fmt.Errorf("failed to add history entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Get returns the entry with the given ID
+func (s *Store) Get(id uint64) (Entry, error) {
+	var entry Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(entriesBucket).Get(entryKey(id))
+		if value == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(value, &entry)
+	})
+	if err != nil {
+		return entry, fmt.Errorf("failed to read history entry %d: %w", id, err)
+	}
+	return entry, nil
+}
+
+// List returns the entries of a container or compose project on a host,
+// newest first. Entries recorded before hosts were tracked have no host and
+// are listed for any host.
+func (s *Store) List(host, target string) ([]Entry, error) {
+	entries := []Entry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(entriesBucket).Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var entry Entry
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return fmt.Errorf("entry %d: %w", binary.BigEndian.Uint64(key), err)
+			}
+			if entry.Target == target && entry.Matches(host) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// entryKey encodes an entry ID as a sortable bucket key
+func entryKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
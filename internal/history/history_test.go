@@ -0,0 +1,116 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStoreAddListGet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "history.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	params := &models.ContainerParams{
+		Image:  "nginx:latest",
+		Name:   "web",
+		Config: &container.Config{Image: "nginx:latest", Env: []string{"MODE=production"}},
+	}
+
+	// More entries than are kept for one target, plus one for the same target on
+	// another host and one recorded before hosts were tracked
+	for i := 0; i < maxEntriesPerTarget+2; i++ {
+		_, err := store.Add(Entry{
+			Time:       start.Add(time.Duration(i) * time.Minute),
+			Operation:  "update",
+			Host:       "local",
+			Target:     "web",
+			GroupType:  models.GroupTypeStandalone,
+			Containers: []models.ContainerSnapshot{{Name: "web", Image: "nginx:latest", ImageDigest: "sha256:old", NewDigest: "sha256:new", Params: params}},
+		})
+		if err != nil {
+			t.Fatalf("failed to add entry: %v", err)
+		}
+	}
+	if _, err := store.Add(Entry{Time: start, Operation: "update", Host: "edge", Target: "web", GroupType: models.GroupTypeStandalone}); err != nil {
+		t.Fatalf("failed to add entry: %v", err)
+	}
+	other, err := store.Add(Entry{Time: start, Operation: "auto-update", Target: "shop", GroupType: models.GroupTypeCompose})
+	if err != nil {
+		t.Fatalf("failed to add entry: %v", err)
+	}
+
+	entries, err := store.List("local", "web")
+	if err != nil {
+		t.Fatalf("failed to list: %v", err)
+	}
+	if len(entries) != maxEntriesPerTarget {
+		t.Fatalf("expected %d entries after pruning, got %d", maxEntriesPerTarget, len(entries))
+	}
+	if entries[0].ID != maxEntriesPerTarget+2 || entries[len(entries)-1].ID != 3 {
+		t.Errorf("expected the newest entries first, got IDs %d..%d", entries[0].ID, entries[len(entries)-1].ID)
+	}
+
+	if shop, err := store.List("edge", "shop"); err != nil || len(shop) != 1 {
+		t.Errorf("expected pruning to leave other targets alone, got %v (%v)", shop, err)
+	}
+	if edge, err := store.List("edge", "web"); err != nil || len(edge) != 1 || edge[0].Host != "edge" {
+		t.Errorf("expected only the entry from the other host, got %v (%v)", edge, err)
+	}
+
+	if _, err := store.Get(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected pruned entry to be gone, got %v", err)
+	}
+
+	// Entries survive reopening the database, configuration included
+	store.Close()
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("failed to reopen history: %v", err)
+	}
+	defer store.Close()
+
+	got, err := store.Get(entries[0].ID)
+	if err != nil {
+		t.Fatalf("failed to get entry: %v", err)
+	}
+	snapshot := got.Containers[0]
+	if snapshot.ImageDigest != "sha256:old" || snapshot.Params == nil || snapshot.Params.Config.Env[0] != "MODE=production" {
+		t.Errorf("expected digest and configuration to round-trip, got %+v", snapshot)
+	}
+	if got, err := store.Get(other.ID); err != nil || got.GroupType != models.GroupTypeCompose {
+		t.Errorf("expected the compose entry, got %+v (%v)", got, err)
+	}
+}
+
+func TestEntryChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		digests  [][2]string
+		expected bool
+	}{
+		{name: "new digest", digests: [][2]string{{"sha256:a", "sha256:b"}}, expected: true},
+		{name: "rolled back", digests: [][2]string{{"sha256:a", "sha256:a"}}, expected: false},
+		{name: "one of several changed", digests: [][2]string{{"sha256:a", "sha256:a"}, {"sha256:c", "sha256:d"}}, expected: true},
+		{name: "unknown digest", digests: [][2]string{{"", "sha256:a"}}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry Entry
+			for _, d := range tt.digests {
+				entry.Containers = append(entry.Containers, models.ContainerSnapshot{ImageDigest: d[0], NewDigest: d[1]})
+			}
+			if got := entry.Changed(); got != tt.expected {
+				t.Errorf("Changed() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -11,14 +11,15 @@ import (
 
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/go-connections/nat"
 	"github.com/testcontainers/testcontainers-go"
 )
 
 // TestHelper provides utilities for integration tests
 type TestHelper struct {
-	t              *testing.T
-	client         docker.DockerClient
+	t                 *testing.T
+	client            docker.DockerClient
 	createdContainers []string
 	createdNetworks   []string
 	tempDirs          []string
@@ -27,8 +28,8 @@ type TestHelper struct {
 // NewTestHelper creates a new test helper
 func NewTestHelper(t *testing.T, client docker.DockerClient) *TestHelper {
 	return &TestHelper{
-		t:              t,
-		client:         client,
+		t:                 t,
+		client:            client,
 		createdContainers: []string{},
 		createdNetworks:   []string{},
 		tempDirs:          []string{},
@@ -51,6 +52,7 @@ func (h *TestHelper) CreateSimpleContainer(ctx context.Context, image, name stri
 		&container.HostConfig{
 			AutoRemove: false,
 		},
+		nil,
 		name,
 	)
 	if err != nil {
@@ -111,6 +113,7 @@ func (h *TestHelper) CreateComplexContainer(ctx context.Context, image, name str
 				Name: "unless-stopped",
 			},
 		},
+		nil,
 		name,
 	)
 	if err != nil {
@@ -127,6 +130,70 @@ func (h *TestHelper) CreateComplexContainer(ctx context.Context, image, name str
 	return containerID, nil
 }
 
+// CreateFullFidelityContainer creates a container exercising configuration that
+// is easy to lose on recreation: mounts, tmpfs, healthcheck, user, hostname,
+// capabilities, log config, sysctls, ulimits, DNS, extra hosts and stop settings
+func (h *TestHelper) CreateFullFidelityContainer(ctx context.Context, image, name string) (string, error) {
+	// Pull image first
+	if err := h.client.PullImage(ctx, image); err != nil {
+		return "", fmt.Errorf("failed to pull image: %w", err)
+	}
+
+	stopTimeout := 7
+	containerID, err := h.client.CreateContainer(ctx,
+		&container.Config{
+			Image:       image,
+			Cmd:         []string{"sleep", "3600"},
+			Hostname:    "fidelity-host",
+			User:        "nobody",
+			WorkingDir:  "/tmp",
+			Env:         []string{"FIDELITY=1"},
+			Labels:      map[string]string{"test.fidelity": "true"},
+			StopSignal:  "SIGINT",
+			StopTimeout: &stopTimeout,
+			Healthcheck: &container.HealthConfig{
+				Test:     []string{"CMD", "true"},
+				Interval: 5 * time.Second,
+				Retries:  3,
+			},
+		},
+		&container.HostConfig{
+			Mounts: []mount.Mount{
+				{Type: mount.TypeVolume, Source: name + "-data", Target: "/data"},
+			},
+			Tmpfs:       map[string]string{"/scratch": "size=16m"},
+			CapAdd:      []string{"NET_ADMIN"},
+			CapDrop:     []string{"MKNOD"},
+			SecurityOpt: []string{"no-new-privileges"},
+			ExtraHosts:  []string{"fidelity.internal:10.0.0.1"},
+			DNS:         []string{"9.9.9.9"},
+			DNSSearch:   []string{"fidelity.local"},
+			Sysctls:     map[string]string{"net.ipv4.ip_unprivileged_port_start": "0"},
+			LogConfig: container.LogConfig{
+				Type:   "json-file",
+				Config: map[string]string{"max-size": "1m"},
+			},
+			Resources: container.Resources{
+				Ulimits: []*container.Ulimit{{Name: "nofile", Soft: 1024, Hard: 2048}},
+			},
+			RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 2},
+		},
+		nil,
+		name,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+
+	h.createdContainers = append(h.createdContainers, containerID)
+
+	if err := h.client.StartContainer(ctx, containerID); err != nil {
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	return containerID, nil
+}
+
 // CreateComposeProject creates a docker compose project for testing
 func (h *TestHelper) CreateComposeProject(ctx context.Context, projectName string) (string, []string, error) {
 	// Create temp directory for compose file
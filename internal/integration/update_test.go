@@ -2,10 +2,13 @@ package integration
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
 	"github.com/bleeding-edge/bleeding-edge/internal/services"
 )
 
@@ -177,6 +180,68 @@ func TestStandaloneContainerComplexUpdate(t *testing.T) {
 	helper.createdContainers = append(helper.createdContainers, newContainerID)
 }
 
+func TestStandaloneContainerUpdatePreservesConfiguration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	client := SetupDockerClient(t)
+	helper := NewTestHelper(t, client)
+	defer helper.Cleanup(ctx)
+
+	containerID, err := helper.CreateFullFidelityContainer(ctx, "alpine:latest", "test-alpine-fidelity")
+	if err != nil {
+		t.Fatalf("failed to create container: %v", err)
+	}
+
+	before, err := client.InspectContainer(ctx, containerID)
+	if err != nil {
+		t.Fatalf("failed to inspect original container: %v", err)
+	}
+
+	if err := services.UpdateStandaloneContainer(ctx, client, containerID); err != nil {
+		t.Fatalf("failed to update container: %v", err)
+	}
+
+	containers, err := client.ListContainers(ctx)
+	if err != nil {
+		t.Fatalf("failed to list containers: %v", err)
+	}
+
+	var newContainerID string
+	for _, c := range containers {
+		if len(c.Names) > 0 && c.Names[0] == "/test-alpine-fidelity" {
+			newContainerID = c.ID
+		}
+	}
+	if newContainerID == "" {
+		t.Fatal("new container not found after update")
+	}
+	helper.createdContainers = append(helper.createdContainers, newContainerID)
+
+	after, err := client.InspectContainer(ctx, newContainerID)
+	if err != nil {
+		t.Fatalf("failed to inspect new container: %v", err)
+	}
+
+	// Everything the user configured must come back identical
+	if !reflect.DeepEqual(before.Config, after.Config) {
+		t.Errorf("config changed across update:\nbefore: %+v\nafter:  %+v", before.Config, after.Config)
+	}
+	if !reflect.DeepEqual(before.HostConfig, after.HostConfig) {
+		t.Errorf("host config changed across update:\nbefore: %+v\nafter:  %+v", before.HostConfig, after.HostConfig)
+	}
+	if !reflect.DeepEqual(before.Mounts, after.Mounts) {
+		t.Errorf("mounts changed across update:\nbefore: %+v\nafter:  %+v", before.Mounts, after.Mounts)
+	}
+	for name := range before.NetworkSettings.Networks {
+		if _, ok := after.NetworkSettings.Networks[name]; !ok {
+			t.Errorf("network %s not reattached after update", name)
+		}
+	}
+}
+
 func TestComposeProjectUpdate(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -204,18 +269,11 @@ func TestComposeProjectUpdate(t *testing.T) {
 		originalIDs[id] = true
 	}
 
-	// Get images from containers
-	var images []string
-	for _, id := range containerIDs {
-		inspect, err := client.InspectContainer(ctx, id)
-		if err != nil {
-			t.Fatalf("failed to inspect container: %v", err)
-		}
-		images = append(images, inspect.Config.Image)
-	}
+	// Find the compose group
+	group := findComposeGroup(t, ctx, client, projectName)
 
-	// Update the compose project
-	err = services.UpdateComposeProject(ctx, client, projectName, workDir, images)
+	// Update the compose project; unchanged services are left running
+	err = services.UpdateComposeProject(ctx, client, projectName, workDir, group.Containers)
 	if err != nil {
 		t.Fatalf("failed to update compose project: %v", err)
 	}
@@ -252,6 +310,68 @@ func TestComposeProjectUpdate(t *testing.T) {
 	}
 }
 
+func TestComposeServiceUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	client := SetupDockerClient(t)
+	helper := NewTestHelper(t, client)
+	defer helper.Cleanup(ctx)
+
+	projectName := "test-compose-service"
+	workDir, _, err := helper.CreateComposeProject(ctx, projectName)
+	if err != nil {
+		t.Fatalf("failed to create compose project: %v", err)
+	}
+
+	group := findComposeGroup(t, ctx, client, projectName)
+	originalIDs := make(map[string]string)
+	for _, c := range group.Containers {
+		originalIDs[c.Labels["com.docker.compose.service"]] = c.ID
+	}
+
+	// Recreate only the web service
+	if err := services.UpdateComposeService(ctx, client, projectName, workDir, group.Containers, "web"); err != nil {
+		t.Fatalf("failed to update compose service: %v", err)
+	}
+
+	group = findComposeGroup(t, ctx, client, projectName)
+	for _, c := range group.Containers {
+		service := c.Labels["com.docker.compose.service"]
+		helper.createdContainers = append(helper.createdContainers, c.ID)
+
+		switch service {
+		case "web":
+			if c.ID == originalIDs[service] {
+				t.Error("expected web service to be recreated")
+			}
+		case "app":
+			if c.ID != originalIDs[service] {
+				t.Error("expected app service to be left running")
+			}
+		}
+	}
+}
+
+// findComposeGroup returns the container group for a compose project
+func findComposeGroup(t *testing.T, ctx context.Context, client docker.DockerClient, projectName string) models.ContainerGroup {
+	t.Helper()
+
+	groups, err := services.GetContainerGroups(ctx, client)
+	if err != nil {
+		t.Fatalf("failed to get container groups: %v", err)
+	}
+	for _, group := range groups {
+		if group.Type == models.GroupTypeCompose && group.Name == projectName {
+			return group
+		}
+	}
+	t.Fatalf("compose project %s not found", projectName)
+	return models.ContainerGroup{}
+}
+
 func TestMixedEnvironment(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -334,7 +454,7 @@ func TestUpdateAvailableDetection(t *testing.T) {
 	}
 
 	// Check for updates
-	err = services.CheckUpdates(ctx, client, groups)
+	err = services.CheckUpdates(ctx, client, registry.NewClient(), groups, services.CheckOptions{})
 	if err != nil {
 		t.Fatalf("failed to check updates: %v", err)
 	}
@@ -382,7 +502,7 @@ func TestNoUpdateAvailable(t *testing.T) {
 	}
 
 	// Check for updates
-	err = services.CheckUpdates(ctx, client, groups)
+	err = services.CheckUpdates(ctx, client, registry.NewClient(), groups, services.CheckOptions{})
 	if err != nil {
 		t.Fatalf("failed to check updates: %v", err)
 	}
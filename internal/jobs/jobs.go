@@ -0,0 +1,252 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Status is the lifecycle state of a job
+type Status string
+
+const (
+	// StatusRunning means the job is still in progress
+	StatusRunning Status = "running"
+	// StatusSucceeded means the job finished without error
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed means the job finished with an error
+	StatusFailed Status = "failed"
+)
+
+// EventType describes what an Event reports
+type EventType string
+
+const (
+	// EventStep marks the start of a named step (pull, stop, create, ...)
+	EventStep EventType = "step"
+	// EventProgress reports image layer pull progress
+	EventProgress EventType = "progress"
+	// EventLog carries a line of command output
+	EventLog EventType = "log"
+	// EventDone is the final event of a job and carries its result
+	EventDone EventType = "done"
+)
+
+// retention is how long finished jobs are kept for late subscribers
+const retention = time.Hour
+
+// Event is a single progress report from a running job
+type Event struct {
+	Seq     int                     `json:"seq"`               // Position in the job's event history
+	Time    time.Time               `json:"time"`              // When the event was reported
+	Type    EventType               `json:"type"`              // step, progress, log or done
+	Step    string                  `json:"step,omitempty"`    // Step identifier for step events
+	Message string                  `json:"message,omitempty"` // Human readable description or output line
+	Layer   string                  `json:"layer,omitempty"`   // Image layer ID for progress events
+	Current int64                   `json:"current,omitempty"` // Bytes transferred for progress events
+	Total   int64                   `json:"total,omitempty"`   // Total bytes for progress events
+	Result  *models.OperationResult `json:"result,omitempty"`  // Outcome, set on the done event
+}
+
+// Job is a background operation whose progress can be followed
+type Job struct {
+	ID        string
+	Operation string              // e.g. "update"
+	Target    string              // Container or project the job acts on
+	Labels    []map[string]string // Labels of each container the job acts on, for permission checks
+	Started   time.Time
+
+	mu          sync.Mutex
+	status      Status
+	finished    time.Time
+	events      []Event
+	subscribers map[chan Event]struct{}
+}
+
+// Status returns the job's current status
+func (j *Job) Status() Status {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Events returns a copy of the events reported so far
+func (j *Job) Events() []Event {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Event(nil), j.events...)
+}
+
+// Report records an event and forwards it to subscribers
+func (j *Job) Report(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.status != StatusRunning {
+		return
+	}
+	event.Seq = len(j.events)
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	j.events = append(j.events, event)
+
+	for ch := range j.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscribers miss live events rather than blocking the job
+		}
+	}
+}
+
+// Subscribe returns the events reported so far and a channel of future events.
+// The channel is closed when the job finishes or the context is cancelled.
+func (j *Job) Subscribe(ctx context.Context) ([]Event, <-chan Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	history := append([]Event(nil), j.events...)
+	ch := make(chan Event, 64)
+	if j.status != StatusRunning {
+		close(ch)
+		return history, ch
+	}
+
+	j.subscribers[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}()
+	return history, ch
+}
+
+// finish records the final result and closes all subscriptions
+func (j *Job) finish(result models.OperationResult) {
+	status := StatusSucceeded
+	if !result.Success {
+		status = StatusFailed
+	}
+	j.Report(Event{Type: EventDone, Message: result.Message, Result: &result})
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = status
+	j.finished = time.Now()
+	for ch := range j.subscribers {
+		close(ch)
+	}
+	j.subscribers = nil
+}
+
+// Manager runs jobs in the background and keeps them for a while after they finish
+type Manager struct {
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager creates a new job manager
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{
+		logger: logger,
+		jobs:   make(map[string]*Job),
+	}
+}
+
+// Start runs fn in the background as a new job. The context passed to fn is
+// detached from ctx, bounded by timeout, and carries the job as its Reporter so
+// services can publish progress with Step, Log and Progress. Only the trace of
+// ctx is kept: the job's span continues the trace of the request that started it.
+// labels are those of the containers the job acts on, which decide who may follow it.
+func (m *Manager) Start(ctx context.Context, operation, target string, labels []map[string]string, timeout time.Duration, fn func(ctx context.Context) models.OperationResult) *Job {
+	job := &Job{
+		ID:          newID(),
+		Operation:   operation,
+		Target:      target,
+		Labels:      labels,
+		Started:     time.Now(),
+		status:      StatusRunning,
+		subscribers: make(map[chan Event]struct{}),
+	}
+
+	m.mu.Lock()
+	m.prune()
+	m.jobs[job.ID] = job
+	m.mu.Unlock()
+
+	m.logger.Info("job started",
+		"job_id", job.ID,
+		"operation", operation,
+		"target", target,
+	)
+
+	parent := trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx))
+	go func() {
+		ctx, span := tracing.Start(parent, "job."+operation,
+			attribute.String("bleedingedge.job_id", job.ID),
+			attribute.String("bleedingedge.target", target),
+		)
+		ctx, cancel := context.WithTimeout(WithReporter(ctx, job), timeout)
+		defer cancel()
+
+		result := fn(ctx)
+		job.finish(result)
+		if !result.Success {
+			tracing.RecordError(span, errors.New(result.Error))
+		}
+		span.End()
+
+		m.logger.Info("job finished",
+			"job_id", job.ID,
+			"operation", operation,
+			"target", target,
+			"success", result.Success,
+			"duration_ms", time.Since(job.Started).Milliseconds(),
+		)
+	}()
+
+	return job
+}
+
+// Get returns a job by ID
+func (m *Manager) Get(id string) (*Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// prune drops jobs that finished more than retention ago; m.mu must be held
+func (m *Manager) prune() {
+	for id, job := range m.jobs {
+		job.mu.Lock()
+		expired := job.status != StatusRunning && time.Since(job.finished) > retention
+		job.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// newID returns a random job identifier
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
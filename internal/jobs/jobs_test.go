@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+func TestManagerRunsJob(t *testing.T) {
+	manager := NewManager(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	job := manager.Start(context.Background(), "update", "nginx", nil, time.Minute, func(ctx context.Context) models.OperationResult {
+		Step(ctx, "pull", "Pulling nginx:latest")
+		Progress(ctx, "layer1", "Downloading", 50, 100)
+		Log(ctx, "pulled")
+		return models.OperationResult{Success: true, Message: "nginx updated successfully"}
+	})
+
+	if got, ok := manager.Get(job.ID); !ok || got != job {
+		t.Fatal("expected job to be retrievable by ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, live := job.Subscribe(ctx)
+	for range live {
+	}
+
+	if job.Status() != StatusSucceeded {
+		t.Errorf("expected status %s, got %s", StatusSucceeded, job.Status())
+	}
+
+	events := job.Events()
+	expected := []EventType{EventStep, EventProgress, EventLog, EventDone}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != expected[i] || event.Seq != i {
+			t.Errorf("event %d: expected type %s seq %d, got %s seq %d", i, expected[i], i, event.Type, event.Seq)
+		}
+	}
+	if result := events[3].Result; result == nil || !result.Success {
+		t.Errorf("expected successful result on done event, got %+v", result)
+	}
+}
+
+func TestJobReportsFailure(t *testing.T) {
+	manager := NewManager(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	job := manager.Start(context.Background(), "update", "nginx", nil, time.Minute, func(ctx context.Context) models.OperationResult {
+		return models.OperationResult{Success: false, Error: "pull failed"}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, live := job.Subscribe(ctx)
+	for range live {
+	}
+
+	if job.Status() != StatusFailed {
+		t.Errorf("expected status %s, got %s", StatusFailed, job.Status())
+	}
+
+	// Late subscribers get the full history and a closed channel
+	history, live := job.Subscribe(context.Background())
+	if len(history) != 1 || history[0].Type != EventDone {
+		t.Errorf("expected only the done event in history, got %+v", history)
+	}
+	if _, open := <-live; open {
+		t.Error("expected channel of finished job to be closed")
+	}
+}
+
+func TestReportWithoutReporter(t *testing.T) {
+	// Services report progress unconditionally; without a job it must be a no-op
+	Step(context.Background(), "pull", "Pulling")
+	Log(context.Background(), "line")
+	Progress(context.Background(), "layer", "Downloading", 1, 2)
+}
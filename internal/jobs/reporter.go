@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+)
+
+// Reporter receives progress events from long-running operations
+type Reporter interface {
+	Report(event Event)
+}
+
+type reporterKey struct{}
+
+// WithReporter returns a context that carries the given reporter
+func WithReporter(ctx context.Context, reporter Reporter) context.Context {
+	return context.WithValue(ctx, reporterKey{}, reporter)
+}
+
+// report sends an event to the context's reporter, if any
+func report(ctx context.Context, event Event) {
+	if reporter, ok := ctx.Value(reporterKey{}).(Reporter); ok {
+		reporter.Report(event)
+	}
+}
+
+// Step reports the start of a named step. It is a no-op without a reporter.
+func Step(ctx context.Context, step, message string) {
+	report(ctx, Event{Type: EventStep, Step: step, Message: message})
+}
+
+// Log reports a line of output. It is a no-op without a reporter.
+func Log(ctx context.Context, line string) {
+	report(ctx, Event{Type: EventLog, Message: line})
+}
+
+// Progress reports transfer progress for an image layer. It is a no-op without a reporter.
+func Progress(ctx context.Context, layer, status string, current, total int64) {
+	report(ctx, Event{Type: EventProgress, Layer: layer, Message: status, Current: current, Total: total})
+}
+
+// Forward reports an event produced elsewhere, such as by a remote agent. It is a
+// no-op without a reporter.
+func Forward(ctx context.Context, event Event) {
+	report(ctx, event)
+}
@@ -0,0 +1,105 @@
+// Package metrics defines BleedingEdge's Prometheus metrics. They are
+// registered with the default Prometheus registry and served by promhttp.
+package metrics
+
+import (
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Application metrics, all prefixed with bleedingedge_
+var (
+	Containers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bleedingedge_containers",
+		Help: "Containers by state, as of the last update check.",
+	}, []string{"state"})
+	ContainersUpdateAvailable = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bleedingedge_containers_update_available",
+		Help: "Containers running an image with a newer digest in the registry, as of the last update check.",
+	})
+
+	UpdateChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bleedingedge_update_checks_total",
+		Help: "Update checks by result.",
+	}, []string{"result"})
+	UpdateCheckLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bleedingedge_update_check_last_success_timestamp_seconds",
+		Help: "Unix time the last successful update check completed.",
+	})
+	UpdateCheckLastDuration = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "bleedingedge_update_check_last_duration_seconds",
+		Help: "Duration of the last successful update check.",
+	})
+	// ImageCheckErrors is labelled by registry rather than image to keep its
+	// cardinality bounded; the failing image is logged
+	ImageCheckErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bleedingedge_image_check_errors_total",
+		Help: "Errors checking an image for updates, by the registry the image is pulled from.",
+	}, []string{"registry"})
+	RegistryRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bleedingedge_registry_ratelimit_remaining",
+		Help: "Pulls left in a registry's rate limit window, as last reported by the registry.",
+	}, []string{"registry"})
+
+	Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bleedingedge_operations_total",
+		Help: "Container operations (update, auto-update, revert, start, stop, restart) by result.",
+	}, []string{"operation", "result"})
+	OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bleedingedge_operation_duration_seconds",
+		Help:    "Duration of container operations, including pulls and health verification for updates.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"operation", "result"})
+
+	DockerRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "bleedingedge_docker_request_duration_seconds",
+		Help:    "Latency of Docker Engine API calls by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+	DockerRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bleedingedge_docker_request_errors_total",
+		Help: "Failed Docker Engine API calls by method.",
+	}, []string{"method"})
+)
+
+// ObserveCheck records the containers found by a successful update check
+func ObserveCheck(groups []models.ContainerGroup, completed time.Time, duration time.Duration) {
+	states := make(map[string]int)
+	updates := 0
+	for _, group := range groups {
+		for _, c := range group.Containers {
+			states[c.State]++
+			if c.HasUpdate {
+				updates++
+			}
+		}
+	}
+
+	// States without containers are dropped rather than left at a stale count
+	Containers.Reset()
+	for state, count := range states {
+		Containers.WithLabelValues(state).Set(float64(count))
+	}
+	ContainersUpdateAvailable.Set(float64(updates))
+
+	UpdateChecks.WithLabelValues("success").Inc()
+	UpdateCheckLastSuccess.Set(float64(completed.UnixNano()) / 1e9)
+	UpdateCheckLastDuration.Set(duration.Seconds())
+}
+
+// ObserveOperation records the result and duration of a container operation
+func ObserveOperation(operation, result string, duration time.Duration) {
+	Operations.WithLabelValues(operation, result).Inc()
+	OperationDuration.WithLabelValues(operation, result).Observe(duration.Seconds())
+}
+
+// ObserveDockerRequest records the latency of a Docker Engine API call
+func ObserveDockerRequest(method string, duration time.Duration, err error) {
+	DockerRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+	if err != nil {
+		DockerRequestErrors.WithLabelValues(method).Inc()
+	}
+}
@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveCheck(t *testing.T) {
+	groups := []models.ContainerGroup{
+		{Containers: []models.ContainerInfo{
+			{State: "running", HasUpdate: true},
+			{State: "running"},
+			{State: "exited", HasUpdate: true},
+		}},
+	}
+	ObserveCheck(groups, time.Unix(1714532400, 0), 1500*time.Millisecond)
+
+	// A later check without exited containers drops the stale state
+	groups[0].Containers = groups[0].Containers[:2]
+	ObserveCheck(groups, time.Unix(1714536000, 0), 2*time.Second)
+
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		{name: "running containers", got: testutil.ToFloat64(Containers.WithLabelValues("running")), expected: 2},
+		{name: "updates available", got: testutil.ToFloat64(ContainersUpdateAvailable), expected: 1},
+		{name: "last success", got: testutil.ToFloat64(UpdateCheckLastSuccess), expected: 1714536000},
+		{name: "last duration", got: testutil.ToFloat64(UpdateCheckLastDuration), expected: 2},
+		{name: "successful checks", got: testutil.ToFloat64(UpdateChecks.WithLabelValues("success")), expected: 2},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s = %v, expected %v", tt.name, tt.got, tt.expected)
+		}
+	}
+	if count := testutil.CollectAndCount(Containers); count != 1 {
+		t.Errorf("expected the exited state to be dropped, got %d states", count)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ObserveDockerRequest("container_list", 20*time.Millisecond, nil)
+	ObserveDockerRequest("container_list", 30*time.Millisecond, errors.New("daemon unavailable"))
+	ObserveOperation("update", "success", 12*time.Second)
+
+	w := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, want := range []string{
+		"bleedingedge_docker_request_duration_seconds_count{method=\"container_list\"} 2\n",
+		"bleedingedge_docker_request_duration_seconds_bucket{method=\"container_list\",le=\"0.025\"} 1\n",
+		"bleedingedge_docker_request_errors_total{method=\"container_list\"} 1\n",
+		"bleedingedge_operations_total{operation=\"update\",result=\"success\"} 1\n",
+		"bleedingedge_operation_duration_seconds_bucket{operation=\"update\",result=\"success\",le=\"10\"} 0\n",
+		"bleedingedge_operation_duration_seconds_bucket{operation=\"update\",result=\"success\",le=\"30\"} 1\n",
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, w.Body.String())
+		}
+	}
+}
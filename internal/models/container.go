@@ -1,10 +1,11 @@
 package models
 
 import (
+	"net/url"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/go-connections/nat"
+	"github.com/docker/docker/api/types/network"
 )
 
 // GroupType represents the type of container group
@@ -17,57 +18,155 @@ const (
 	GroupTypeStandalone GroupType = "standalone"
 )
 
+// CheckStatus is the outcome of a container's last update check
+type CheckStatus string
+
+const (
+	// CheckStatusUpToDate means the container runs the newest image of its tag
+	CheckStatusUpToDate CheckStatus = "up_to_date"
+	// CheckStatusUpdateAvailable means a newer digest or allowed tag exists
+	CheckStatusUpdateAvailable CheckStatus = "update_available"
+	// CheckStatusSkipped means the image was built locally and is not in a registry
+	CheckStatusSkipped CheckStatus = "skipped"
+	// CheckStatusPinned means the image is referenced by digest and never changes
+	CheckStatusPinned CheckStatus = "pinned"
+	// CheckStatusError means the check failed; CheckError holds the reason
+	CheckStatusError CheckStatus = "error"
+	// CheckStatusDeferred means the registry is rate limited and the check was
+	// put off; CheckError says when checks resume
+	CheckStatusDeferred CheckStatus = "deferred"
+)
+
+// checkStatusPriority orders statuses from the most to the least pressing,
+// for summarizing a group
+var checkStatusPriority = []CheckStatus{
+	CheckStatusUpdateAvailable,
+	CheckStatusError,
+	CheckStatusDeferred,
+	CheckStatusUpToDate,
+	CheckStatusPinned,
+	CheckStatusSkipped,
+}
+
 // ContainerGroup represents a group of containers (compose project or standalone)
 type ContainerGroup struct {
-	ID         string          // Unique identifier (container ID or project name)
-	Name       string          // Display name
-	Type       GroupType       // "compose" or "standalone"
-	Containers []ContainerInfo // List of containers in group
-	WorkingDir string          // For compose projects
-	HasUpdates bool            // True if any container has updates
-	AllRunning bool            // True if all containers running
+	ID         string          `json:"id"`                    // Unique identifier (container ID or project name)
+	Host       string          `json:"host,omitempty"`        // Name of the Docker host running the group
+	Name       string          `json:"name"`                  // Display name
+	Type       GroupType       `json:"type"`                  // "compose" or "standalone"
+	Containers []ContainerInfo `json:"containers"`            // List of containers in group
+	WorkingDir string          `json:"working_dir,omitempty"` // For compose projects
+	HasUpdates bool            `json:"has_updates"`           // True if any container has updates
+	AllRunning bool            `json:"all_running"`           // True if all containers running
+}
+
+// HostPath returns the URL prefix of the group's host, e.g. /host/web-1, or an
+// empty string for groups not tagged with a host
+func (g ContainerGroup) HostPath() string {
+	if g.Host == "" {
+		return ""
+	}
+	return "/host/" + url.PathEscape(g.Host)
+}
+
+// Path returns the URL path of the group's detail page
+func (g ContainerGroup) Path() string {
+	return g.HostPath() + "/container/" + url.PathEscape(g.ID)
+}
+
+// CheckStatus summarizes the check statuses of the group's containers as the
+// most pressing one, e.g. an available update before a failed check. It is
+// empty until the group has been checked.
+func (g ContainerGroup) CheckStatus() CheckStatus {
+	for _, status := range checkStatusPriority {
+		for _, c := range g.Containers {
+			if c.CheckStatus == status {
+				return status
+			}
+		}
+	}
+	return ""
+}
+
+// CheckError returns why the first failed check of the group's containers
+// failed, or an empty string if none failed
+func (g ContainerGroup) CheckError() string {
+	for _, c := range g.Containers {
+		if c.CheckStatus == CheckStatusError {
+			return c.Name + ": " + c.CheckError
+		}
+	}
+	return ""
 }
 
 // ContainerInfo represents information about a single container
 type ContainerInfo struct {
-	ID           string            // Container ID
-	Name         string            // Container name
-	Image        string            // Image name
-	ImageDigest  string            // Current image digest
-	LatestDigest string            // Latest available image digest
-	State        string            // "running", "stopped", "exited"
-	HasUpdate    bool              // True if update is available
-	Labels       map[string]string // Container labels
+	ID           string            `json:"id"`                      // Container ID
+	Host         string            `json:"host,omitempty"`          // Name of the Docker host running the container
+	Name         string            `json:"name"`                    // Container name
+	Image        string            `json:"image"`                   // Image name
+	ImageID      string            `json:"image_id"`                // Local image ID the container was created from
+	ImageDigest  string            `json:"image_digest,omitempty"`  // Current image digest
+	LatestDigest string            `json:"latest_digest,omitempty"` // Latest available image digest
+	LatestTag    string            `json:"latest_tag,omitempty"`    // Newest version tag allowed by the container's track label
+	State        string            `json:"state"`                   // "running", "stopped", "exited"
+	HasUpdate    bool              `json:"has_update"`              // True if update is available
+	CheckStatus  CheckStatus       `json:"check_status,omitempty"`  // Outcome of the last update check, empty until checked
+	CheckError   string            `json:"check_error,omitempty"`   // Why the last update check failed
+	CheckedAt    time.Time         `json:"checked_at"`              // When the update status was last checked
+	Labels       map[string]string `json:"labels"`                  // Container labels
+}
+
+// UpdateCheckResult represents the cached outcome of an update check for a container
+type UpdateCheckResult struct {
+	ContainerID  string      `json:"container_id"`          // Container ID
+	Image        string      `json:"image"`                 // Image name that was checked
+	ImageDigest  string      `json:"image_digest"`          // Local image digest at check time
+	LatestDigest string      `json:"latest_digest"`         // Remote digest at check time
+	LatestTag    string      `json:"latest_tag"`            // Newest allowed version tag at check time
+	HasUpdate    bool        `json:"has_update"`            // True if an update was available
+	CheckStatus  CheckStatus `json:"check_status"`          // Outcome of the check
+	CheckError   string      `json:"check_error,omitempty"` // Why the check failed
+	CheckedAt    time.Time   `json:"checked_at"`            // When the check completed
 }
 
 // ContainerParams represents the parameters needed to recreate a container
 type ContainerParams struct {
-	Image         string                      // Image name
-	Name          string                      // Container name
-	Env           []string                    // Environment variables
-	Cmd           []string                    // Command
-	Entrypoint    []string                    // Entrypoint
-	PortBindings  nat.PortMap                 // Port bindings
-	Binds         []string                    // Volume binds
-	Networks      []string                    // Network names
-	RestartPolicy container.RestartPolicy     // Restart policy
-	Labels        map[string]string           // Labels
-	Resources     container.Resources         // Resource limits
+	Image            string                    // Image name
+	Name             string                    // Container name
+	Config           *container.Config         // Full container config (env, cmd, healthcheck, user, labels, ...)
+	HostConfig       *container.HostConfig     // Full host config (mounts, devices, capabilities, log config, ...)
+	NetworkingConfig *network.NetworkingConfig // Network endpoints with aliases and static IPs
+}
+
+// ContainerSnapshot records what a container ran before an update, so the
+// update can be reverted to exactly that image and configuration
+type ContainerSnapshot struct {
+	Name        string           `json:"name"`                 // Container name
+	Service     string           `json:"service,omitempty"`    // Compose service name
+	Image       string           `json:"image"`                // Image reference, e.g. nginx:latest
+	ImageDigest string           `json:"image_digest"`         // Repo digest before the update
+	NewDigest   string           `json:"new_digest,omitempty"` // Repo digest after the update
+	Params      *ContainerParams `json:"params,omitempty"`     // Full configuration before the update
 }
 
 // OperationResult represents the result of a container operation
 type OperationResult struct {
-	Success   bool      // True if operation succeeded
-	Message   string    // User-friendly message
-	Error     string    // Error message if failed
-	Timestamp time.Time // When the operation completed
+	Success    bool      `json:"success"`           // True if operation succeeded
+	Message    string    `json:"message"`           // User-friendly message
+	Error      string    `json:"error,omitempty"`   // Error message if failed
+	Details    string    `json:"details,omitempty"` // Technical details if failed, including logs from a failed update verification
+	RolledBack bool      `json:"rolled_back"`       // True if a failed update restored the original container
+	JobID      string    `json:"job_id,omitempty"`  // Background job running the operation, if it was started asynchronously
+	Timestamp  time.Time `json:"timestamp"`         // When the operation completed
 }
 
 // ErrorResponse represents a structured error response for operations
 type ErrorResponse struct {
-	Operation string    // The operation that failed (e.g., "update", "start", "stop")
-	Container string    // The container or project name
-	Message   string    // User-friendly error message
-	Details   string    // Technical error details
-	Timestamp time.Time // When the error occurred
+	Operation  string    `json:"operation"`         // The operation that failed (e.g., "update", "start", "stop")
+	Container  string    `json:"container"`         // The container or project name
+	Message    string    `json:"message"`           // User-friendly error message
+	Details    string    `json:"details,omitempty"` // Technical error details
+	RolledBack bool      `json:"rolled_back"`       // True if a failed update restored the original container
+	Timestamp  time.Time `json:"timestamp"`         // When the error occurred
 }
@@ -0,0 +1,252 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// SMTP connection security modes
+const (
+	SecuritySTARTTLS = "starttls" // Plain connection upgraded with STARTTLS, usually port 587
+	SecurityTLS      = "tls"      // Implicit TLS, usually port 465
+	SecurityNone     = "none"     // No encryption; only for local relays
+)
+
+// smtpTimeout bounds sending a single email
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig configures how emails are sent
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // Authenticates with PLAIN when set
+	Password string
+	From     string
+	To       []string
+	Security string // starttls, tls or none; defaults to starttls
+	BaseURL  string // Public URL of the UI, used for links in emails
+}
+
+// Mailer sends digest and failure emails over SMTP
+type Mailer struct {
+	cfg       SMTPConfig
+	tlsConfig *tls.Config
+}
+
+// NewMailer validates the SMTP configuration and creates a mailer
+func NewMailer(cfg SMTPConfig) (*Mailer, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("SMTP host required")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("invalid SMTP port %d", cfg.Port)
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("sender address required")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("at least one recipient required")
+	}
+	switch cfg.Security {
+	case "":
+		cfg.Security = SecuritySTARTTLS
+	case SecuritySTARTTLS, SecurityTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP security %q (must be starttls, tls or none)", cfg.Security)
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
+	return &Mailer{
+		cfg:       cfg,
+		tlsConfig: &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12},
+	}, nil
+}
+
+// digestGroup is a compose project or standalone container listed in a digest
+type digestGroup struct {
+	Name       string
+	Compose    bool
+	Link       string
+	Containers []models.ContainerInfo
+}
+
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{"short": shortDigest}).Parse(
+	`{{.Count}} container{{if ne .Count 1}}s have updates{{else}} has an update{{end}} available{{if not .Checked.IsZero}} (checked {{.Checked.Format "2006-01-02 15:04 MST"}}){{end}}.
+{{range .Groups}}
+{{if .Compose}}Project {{.Name}}{{else}}Container {{.Name}}{{end}}
+{{.Link}}
+{{range .Containers}}  {{.Name}} ({{.Image}})
+    current: {{short .ImageDigest}}
+    latest:  {{short .LatestDigest}}
+{{end}}{{end}}`))
+
+var failureTemplate = template.Must(template.New("failure").Funcs(template.FuncMap{"short": shortDigest}).Parse(
+	`{{.Event.Title}} at {{.Event.Time.Format "2006-01-02 15:04 MST"}}.
+
+{{.Event.Error}}
+{{if .Event.RolledBack}}The original container was restored and keeps running.
+{{end}}{{with .Event.Details}}
+Details:
+{{.}}
+{{end}}
+{{range .Event.Containers}}  {{.Name}} ({{.Image}})
+    before: {{short .CurrentDigest}}
+    after:  {{short .NewDigest}}
+{{end}}
+{{.Link}}
+`))
+
+// SendDigest emails the containers with pending updates, grouped by compose
+// project. Nothing is sent when there are none; the result reports whether an
+// email was sent.
+func (m *Mailer) SendDigest(ctx context.Context, groups []models.ContainerGroup, checked time.Time) (bool, error) {
+	var listed []digestGroup
+	count := 0
+	for _, group := range groups {
+		entry := digestGroup{
+			Name:    group.Name,
+			Compose: group.Type == models.GroupTypeCompose,
+			Link:    m.cfg.BaseURL + group.Path(),
+		}
+		for _, c := range group.Containers {
+			if c.HasUpdate {
+				entry.Containers = append(entry.Containers, c)
+			}
+		}
+		if len(entry.Containers) > 0 {
+			listed = append(listed, entry)
+			count += len(entry.Containers)
+		}
+	}
+	if count == 0 {
+		return false, nil
+	}
+
+	var body bytes.Buffer
+	data := map[string]interface{}{"Count": count, "Checked": checked, "Groups": listed}
+	if err := digestTemplate.Execute(&body, data); err != nil {
+		return false, fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	subject := fmt.Sprintf("%d container updates available", count)
+	if count == 1 {
+		subject = "1 container update available"
+	}
+	return true, m.send(ctx, subject, body.String())
+}
+
+// SendFailure emails the details of a failed update
+func (m *Mailer) SendFailure(ctx context.Context, event Event) error {
+	var body bytes.Buffer
+	data := map[string]interface{}{
+		"Event": event,
+		"Link":  m.cfg.BaseURL + "/activity?" + failureQuery(event).Encode(),
+	}
+	if err := failureTemplate.Execute(&body, data); err != nil {
+		return fmt.Errorf("failed to render failure email: %w", err)
+	}
+	return m.send(ctx, event.Title(), body.String())
+}
+
+// failureQuery filters the activity log to the failures of an event's target
+func failureQuery(event Event) url.Values {
+	query := url.Values{"result": {"failure"}, "target": {event.Target}}
+	if event.Host != "" {
+		query.Set("host", event.Host)
+	}
+	return query
+}
+
+// send delivers a plain text email to every recipient
+func (m *Mailer) send(ctx context.Context, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if m.cfg.Security == SecurityTLS {
+		tlsConn := tls.Client(conn, m.tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("TLS handshake with %s failed: %w", addr, err)
+		}
+		conn = tlsConn
+	}
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return fmt.Errorf("SMTP handshake with %s failed: %w", addr, err)
+	}
+	defer client.Close()
+
+	if m.cfg.Security == SecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(m.tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS with %s failed: %w", addr, err)
+		}
+	}
+
+	if m.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	for _, to := range m.cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("SMTP server rejected recipient %s: %w", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA failed: %w", err)
+	}
+	if _, err := w.Write(m.message(subject, body)); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected email: %w", err)
+	}
+	return client.Quit()
+}
+
+// message formats the headers and body of an email
+func (m *Mailer) message(subject, body string) []byte {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(m.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[BleedingEdge] "+subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return msg.Bytes()
+}
@@ -0,0 +1,412 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// fakeMessage is an email received by the fake SMTP server
+type fakeMessage struct {
+	From string
+	To   []string
+	Data string
+	TLS  bool   // Whether the message was sent over TLS
+	Auth string // Username the client authenticated as
+}
+
+// fakeSMTP is a minimal local SMTP server supporting STARTTLS and AUTH PLAIN
+type fakeSMTP struct {
+	listener  net.Listener
+	tlsConfig *tls.Config // Offers STARTTLS when set
+	implicit  bool        // Listener already speaks TLS
+	password  string      // Required password for AUTH PLAIN
+
+	mu       sync.Mutex
+	messages []fakeMessage
+}
+
+// newFakeSMTP starts a fake SMTP server for the given security mode and
+// returns it with a certificate pool trusting its certificate
+func newFakeSMTP(t *testing.T, security string) (*fakeSMTP, *x509.CertPool) {
+	t.Helper()
+	cert, pool := selfSignedCert(t)
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &fakeSMTP{listener: listener, password: "s3cret"}
+	switch security {
+	case SecuritySTARTTLS:
+		server.tlsConfig = tlsConfig
+	case SecurityTLS:
+		server.listener = tls.NewListener(listener, tlsConfig)
+		server.implicit = true
+	}
+	t.Cleanup(func() { server.listener.Close() })
+
+	go func() {
+		for {
+			conn, err := server.listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.handle(conn)
+		}
+	}()
+	return server, pool
+}
+
+func (s *fakeSMTP) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) received() []fakeMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeMessage(nil), s.messages...)
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	secure := s.implicit
+	var msg fakeMessage
+
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"fake"}
+			if s.tlsConfig != nil && !secure {
+				lines = append(lines, "STARTTLS")
+			}
+			lines = append(lines, "AUTH PLAIN", "8BITMIME")
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			tp = textproto.NewConn(conn)
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			parts := strings.Split(string(decoded), "\x00")
+			if len(parts) != 3 || parts[2] != s.password {
+				tp.PrintfLine("535 authentication failed")
+				continue
+			}
+			msg.Auth = parts[1]
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			msg.From = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>")
+			if i := strings.Index(msg.From, ">"); i >= 0 {
+				msg.From = msg.From[:i]
+			}
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.To = append(msg.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			msg.TLS = secure
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// selfSignedCert creates a certificate for 127.0.0.1 and a pool trusting it
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// newTestMailer creates a mailer for a fake SMTP server that trusts its certificate
+func newTestMailer(t *testing.T, server *fakeSMTP, pool *x509.CertPool, security, password string) *Mailer {
+	t.Helper()
+	mailer, err := NewMailer(SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "bleedingedge",
+		Password: password,
+		From:     "bleedingedge@example.com",
+		To:       []string{"ops@example.com", "oncall@example.com"},
+		Security: security,
+		BaseURL:  "https://updates.example.com/",
+	})
+	if err != nil {
+		t.Fatalf("NewMailer() error = %v", err)
+	}
+	mailer.tlsConfig.RootCAs = pool
+	return mailer
+}
+
+// body returns the decoded body of a received email
+func body(t *testing.T, msg fakeMessage) (textproto.MIMEHeader, string) {
+	t.Helper()
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(msg.Data)))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("invalid email headers: %v\n%s", err, msg.Data)
+	}
+	rest, _ := io.ReadAll(reader.R)
+	return header, string(rest)
+}
+
+func TestMailerSendDigest(t *testing.T) {
+	server, pool := newFakeSMTP(t, SecuritySTARTTLS)
+	mailer := newTestMailer(t, server, pool, SecuritySTARTTLS, "s3cret")
+
+	groups := []models.ContainerGroup{
+		{ID: "shop", Name: "shop", Type: models.GroupTypeCompose, Containers: []models.ContainerInfo{
+			{Name: "shop-web-1", Image: "nginx:latest", ImageDigest: "sha256:1111111111111111aaaa", LatestDigest: "sha256:2222222222222222bbbb", HasUpdate: true},
+			{Name: "shop-db-1", Image: "postgres:16", ImageDigest: "sha256:3333333333333333", LatestDigest: "sha256:3333333333333333"},
+		}},
+		{ID: "abc123", Host: "edge", Name: "redis", Type: models.GroupTypeStandalone, Containers: []models.ContainerInfo{
+			{Name: "redis", Image: "redis:7", ImageDigest: "sha256:4444444444444444", LatestDigest: "sha256:5555555555555555", HasUpdate: true},
+		}},
+		{ID: "def456", Name: "cache", Type: models.GroupTypeStandalone, Containers: []models.ContainerInfo{
+			{Name: "cache", Image: "memcached:1", HasUpdate: false},
+		}},
+	}
+
+	checked := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	sent, err := mailer.SendDigest(context.Background(), groups, checked)
+	if err != nil || !sent {
+		t.Fatalf("SendDigest() = %v, %v", sent, err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(messages))
+	}
+	msg := messages[0]
+	if !msg.TLS || msg.Auth != "bleedingedge" {
+		t.Errorf("expected an authenticated STARTTLS session, got tls=%v auth=%q", msg.TLS, msg.Auth)
+	}
+	if msg.From != "bleedingedge@example.com" || len(msg.To) != 2 {
+		t.Errorf("unexpected envelope: from %s to %v", msg.From, msg.To)
+	}
+
+	header, text := body(t, msg)
+	if header.Get("Subject") != "[BleedingEdge] 2 container updates available" {
+		t.Errorf("unexpected subject %q", header.Get("Subject"))
+	}
+	for _, want := range []string{
+		"2 containers have updates available (checked 2024-05-01 03:00 UTC)",
+		"Project shop\nhttps://updates.example.com/container/shop\n  shop-web-1 (nginx:latest)\n    current: sha256:111111111111\n    latest:  sha256:222222222222",
+		"Container redis\nhttps://updates.example.com/host/edge/container/abc123",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected digest to contain %q, got:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"shop-db-1", "cache"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("expected %s without updates to be left out, got:\n%s", unwanted, text)
+		}
+	}
+
+	// Nothing to report, nothing sent
+	sent, err = mailer.SendDigest(context.Background(), groups[2:], checked)
+	if err != nil || sent {
+		t.Errorf("expected no digest without updates, got %v, %v", sent, err)
+	}
+	if len(server.received()) != 1 {
+		t.Error("expected no further email")
+	}
+}
+
+func TestMailerSecurity(t *testing.T) {
+	tests := []struct {
+		name      string
+		server    string // Security the fake server offers
+		client    string // Security the mailer uses
+		password  string
+		expectErr string
+		expectTLS bool
+	}{
+		{name: "starttls", server: SecuritySTARTTLS, client: SecuritySTARTTLS, password: "s3cret", expectTLS: true},
+		{name: "implicit tls", server: SecurityTLS, client: SecurityTLS, password: "s3cret", expectTLS: true},
+		{name: "plain local relay", server: SecurityNone, client: SecurityNone, password: "s3cret"},
+		{name: "starttls not offered", server: SecurityNone, client: SecuritySTARTTLS, password: "s3cret", expectErr: "does not support STARTTLS"},
+		{name: "wrong password", server: SecuritySTARTTLS, client: SecuritySTARTTLS, password: "wrong", expectErr: "authentication failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, pool := newFakeSMTP(t, tt.server)
+			mailer := newTestMailer(t, server, pool, tt.client, tt.password)
+
+			err := mailer.send(context.Background(), "test", "hello\n")
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Errorf("expected error containing %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+			messages := server.received()
+			if len(messages) != 1 || messages[0].TLS != tt.expectTLS {
+				t.Errorf("expected 1 email with tls=%v, got %+v", tt.expectTLS, messages)
+			}
+		})
+	}
+}
+
+func TestNotifierEmailsFailedAutoUpdates(t *testing.T) {
+	server, pool := newFakeSMTP(t, SecuritySTARTTLS)
+	mailer := newTestMailer(t, server, pool, SecuritySTARTTLS, "s3cret")
+	notifier, err := New(nil, mailer, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	failed := Event{
+		Type:       EventUpdateFailed,
+		Time:       time.Date(2024, 5, 1, 3, 0, 12, 0, time.UTC),
+		Operation:  audit.OperationAutoUpdate,
+		Target:     "shop",
+		User:       audit.SystemUser,
+		Containers: []Container{{Name: "shop-web-1", Image: "nginx:latest", CurrentDigest: "sha256:1111111111111111", NewDigest: "sha256:1111111111111111"}},
+		Error:      "Health check failed",
+		Details:    "container exited with code 1",
+		RolledBack: true,
+	}
+	notifier.Notify(failed)
+
+	// Manual updates fail in front of the user, and successes go in the digest
+	manual := failed
+	manual.Operation = audit.OperationUpdate
+	notifier.Notify(manual)
+	succeeded := failed
+	succeeded.Type = EventUpdateSucceeded
+	notifier.Notify(succeeded)
+	notifier.Wait()
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(messages))
+	}
+	header, text := body(t, messages[0])
+	if header.Get("Subject") != "[BleedingEdge] Automatic update of shop failed" {
+		t.Errorf("unexpected subject %q", header.Get("Subject"))
+	}
+	for _, want := range []string{
+		"Health check failed",
+		"The original container was restored",
+		"container exited with code 1",
+		"shop-web-1 (nginx:latest)",
+		"https://updates.example.com/activity?result=failure&target=shop",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected email to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestNewMailerValidation(t *testing.T) {
+	valid := SMTPConfig{Host: "smtp.example.com", Port: 587, From: "a@example.com", To: []string{"b@example.com"}}
+
+	tests := []struct {
+		name   string
+		modify func(*SMTPConfig)
+		errMsg string
+	}{
+		{name: "valid", modify: func(c *SMTPConfig) {}},
+		{name: "missing host", modify: func(c *SMTPConfig) { c.Host = "" }, errMsg: "host"},
+		{name: "bad port", modify: func(c *SMTPConfig) { c.Port = 70000 }, errMsg: "port " + strconv.Itoa(70000)},
+		{name: "missing sender", modify: func(c *SMTPConfig) { c.From = "" }, errMsg: "sender"},
+		{name: "missing recipients", modify: func(c *SMTPConfig) { c.To = nil }, errMsg: "recipient"},
+		{name: "unknown security", modify: func(c *SMTPConfig) { c.Security = "ssl" }, errMsg: "unknown SMTP security"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			mailer, err := NewMailer(cfg)
+			if tt.errMsg == "" {
+				if err != nil || mailer.cfg.Security != SecuritySTARTTLS {
+					t.Errorf("expected STARTTLS by default, got %+v (%v)", mailer, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
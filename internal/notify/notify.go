@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+)
+
+// EventType identifies what a notification is about
+type EventType string
+
+const (
+	// EventUpdateAvailable is sent when an update check finds a new digest
+	EventUpdateAvailable EventType = "update.available"
+	// EventUpdateSucceeded is sent when an update, automatic update or revert completes
+	EventUpdateSucceeded EventType = "update.succeeded"
+	// EventUpdateFailed is sent when an update, automatic update or revert fails
+	EventUpdateFailed EventType = "update.failed"
+)
+
+// eventTypes lists every event a webhook can subscribe to
+var eventTypes = []EventType{EventUpdateAvailable, EventUpdateSucceeded, EventUpdateFailed}
+
+// Container is a container an event is about
+type Container struct {
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	CurrentDigest string `json:"current_digest,omitempty"` // Digest running before the update
+	NewDigest     string `json:"new_digest,omitempty"`     // Digest available, or running after the update
+}
+
+// Event is a notification sent to webhooks
+type Event struct {
+	Type       EventType   `json:"type"`
+	Time       time.Time   `json:"time"`
+	Operation  string      `json:"operation,omitempty"` // update, auto-update or revert; empty for update.available
+	Host       string      `json:"host,omitempty"`      // Docker host the target runs on
+	Target     string      `json:"target"`              // Container, compose project or project/service name
+	User       string      `json:"user,omitempty"`      // Who ran the operation
+	Containers []Container `json:"containers"`
+	Error      string      `json:"error,omitempty"`       // User-friendly error for update.failed
+	Details    string      `json:"details,omitempty"`     // Technical error details for update.failed
+	RolledBack bool        `json:"rolled_back,omitempty"` // True if a failed update restored the original container
+}
+
+// FromRecord builds the event for an update, automatic update or revert
+// recorded in the audit log. Other operations are not notified.
+func FromRecord(record audit.Record) (Event, bool) {
+	switch record.Operation {
+	case audit.OperationUpdate, audit.OperationAutoUpdate, audit.OperationRevert:
+	default:
+		return Event{}, false
+	}
+
+	event := Event{
+		Type:       EventUpdateSucceeded,
+		Time:       record.Time,
+		Operation:  record.Operation,
+		Host:       record.Host,
+		Target:     record.Target,
+		User:       record.User,
+		Containers: []Container{},
+	}
+	for _, image := range record.Images {
+		event.Containers = append(event.Containers, Container{
+			Name:          image.Container,
+			Image:         image.Image,
+			CurrentDigest: image.Before,
+			NewDigest:     image.After,
+		})
+	}
+	if record.Result == audit.ResultFailure {
+		event.Type = EventUpdateFailed
+		if record.Error != nil {
+			event.Error = record.Error.Message
+			event.Details = record.Error.Details
+			event.RolledBack = record.Error.RolledBack
+		}
+	}
+	return event, true
+}
+
+// Title is a one-line description of the event
+func (e Event) Title() string {
+	action := "Update"
+	switch e.Operation {
+	case audit.OperationAutoUpdate:
+		action = "Automatic update"
+	case audit.OperationRevert:
+		action = "Revert"
+	}
+
+	switch e.Type {
+	case EventUpdateAvailable:
+		return "Update available for " + e.Target
+	case EventUpdateFailed:
+		return action + " of " + e.Target + " failed"
+	default:
+		return action + " of " + e.Target + " succeeded"
+	}
+}
+
+// Message describes the affected containers and, for failures, the error
+func (e Event) Message() string {
+	var lines []string
+	for _, c := range e.Containers {
+		line := c.Name + " (" + c.Image + ")"
+		if c.CurrentDigest != "" || c.NewDigest != "" {
+			line += ": " + shortDigest(c.CurrentDigest) + " → " + shortDigest(c.NewDigest)
+		}
+		lines = append(lines, line)
+	}
+	if e.Error != "" {
+		lines = append(lines, e.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Summary is the title followed by the message, for chat-style presets
+func (e Event) Summary() string {
+	if message := e.Message(); message != "" {
+		return e.Title() + "\n" + message
+	}
+	return e.Title()
+}
+
+// shortDigest abbreviates a digest to its algorithm and first 12 hex characters
+func shortDigest(digest string) string {
+	if digest == "" {
+		return "unknown"
+	}
+	if len(digest) > 19 {
+		return digest[:19]
+	}
+	return digest
+}
+
+// Notifier delivers events to the configured webhooks, and failed automatic
+// updates to the mailer, in the background
+type Notifier struct {
+	webhooks []*webhook
+	mailer   *Mailer
+	client   *http.Client
+	logger   *slog.Logger
+	wg       sync.WaitGroup
+}
+
+// New creates a notifier for the given webhooks and an optional mailer. It
+// fails if any webhook is misconfigured, e.g. with a template that does not
+// produce JSON.
+func New(configs []WebhookConfig, mailer *Mailer, logger *slog.Logger) (*Notifier, error) {
+	n := &Notifier{
+		mailer: mailer,
+		client: &http.Client{},
+		logger: logger,
+	}
+	for i, cfg := range configs {
+		hook, err := newWebhook(cfg)
+		if err != nil {
+			name := cfg.Name
+			if name == "" {
+				name = fmt.Sprintf("#%d", i+1)
+			}
+			return nil, fmt.Errorf("webhook %s: %w", name, err)
+		}
+		n.webhooks = append(n.webhooks, hook)
+	}
+	return n, nil
+}
+
+// Notify sends an event to every webhook subscribed to its type, and emails
+// failed automatic updates. Delivery, including retries, happens in the
+// background; failures are logged.
+func (n *Notifier) Notify(event Event) {
+	if n.mailer != nil && event.Type == EventUpdateFailed && event.Operation == audit.OperationAutoUpdate {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			if err := n.mailer.SendFailure(context.Background(), event); err != nil {
+				n.logger.Error("failed to send failure email",
+					"target", event.Target,
+					"error", err,
+				)
+			}
+		}()
+	}
+
+	for _, hook := range n.webhooks {
+		if !hook.subscribed(event.Type) {
+			continue
+		}
+
+		n.wg.Add(1)
+		go func(hook *webhook) {
+			defer n.wg.Done()
+			start := time.Now()
+			if err := hook.deliver(context.Background(), n.client, event); err != nil {
+				n.logger.Error("failed to deliver webhook",
+					"webhook", hook.name,
+					"event", event.Type,
+					"target", event.Target,
+					"error", err,
+					"duration_ms", time.Since(start).Milliseconds(),
+				)
+				return
+			}
+			n.logger.Debug("webhook delivered",
+				"webhook", hook.name,
+				"event", event.Type,
+				"target", event.Target,
+			)
+		}(hook)
+	}
+}
+
+// Wait blocks until every notification sent so far has been delivered or has
+// given up retrying
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// receiver is a local webhook endpoint recording the requests it gets
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int // Status codes to answer with, in order; 200 once exhausted
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	t.Helper()
+	r := &receiver{statuses: statuses}
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func TestFromRecord(t *testing.T) {
+	record := audit.Record{
+		Time:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		User:      "alice",
+		Operation: audit.OperationUpdate,
+		Target:    "shop",
+		Images:    []audit.ImageChange{{Container: "shop-web-1", Image: "nginx:latest", Before: "sha256:0123456789abcdef0123", After: "sha256:0123456789abcdef0123"}},
+		Result:    audit.ResultFailure,
+		Error:     &models.ErrorResponse{Message: "Health check failed", Details: "container exited", RolledBack: true},
+	}
+
+	event, ok := FromRecord(record)
+	if !ok {
+		t.Fatal("expected updates to be notified")
+	}
+	if event.Type != EventUpdateFailed || event.User != "alice" || !event.RolledBack || event.Details != "container exited" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if len(event.Containers) != 1 || event.Containers[0].CurrentDigest != "sha256:0123456789abcdef0123" {
+		t.Errorf("expected the container digests, got %+v", event.Containers)
+	}
+	if event.Title() != "Update of shop failed" {
+		t.Errorf("unexpected title %q", event.Title())
+	}
+	expected := "shop-web-1 (nginx:latest): sha256:0123456789ab → sha256:0123456789ab\nHealth check failed"
+	if event.Message() != expected {
+		t.Errorf("Message() = %q, expected %q", event.Message(), expected)
+	}
+
+	record.Operation = audit.OperationAutoUpdate
+	record.Result = audit.ResultSuccess
+	record.Error = nil
+	if event, _ := FromRecord(record); event.Type != EventUpdateSucceeded || event.Title() != "Automatic update of shop succeeded" {
+		t.Errorf("unexpected automatic update event: %+v (%q)", event, event.Title())
+	}
+
+	record.Operation = audit.OperationRestart
+	if _, ok := FromRecord(record); ok {
+		t.Error("expected restarts not to be notified")
+	}
+}
+
+func TestNotifierPresets(t *testing.T) {
+	event := Event{
+		Type:       EventUpdateAvailable,
+		Time:       time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Target:     "web",
+		Containers: []Container{{Name: "web", Image: "nginx:latest", CurrentDigest: "sha256:old", NewDigest: "sha256:new"}},
+	}
+
+	tests := []struct {
+		name     string
+		preset   string
+		path     string
+		expected map[string]interface{}
+		wantPath string
+	}{
+		{
+			name:     "generic",
+			path:     "/hook",
+			wantPath: "/hook",
+			expected: map[string]interface{}{"type": "update.available", "target": "web"},
+		},
+		{
+			name:     "slack",
+			preset:   "slack",
+			path:     "/services/T000/B000/XXX",
+			wantPath: "/services/T000/B000/XXX",
+			expected: map[string]interface{}{"text": "Update available for web\nweb (nginx:latest): sha256:old → sha256:new"},
+		},
+		{
+			name:     "discord",
+			preset:   "discord",
+			path:     "/api/webhooks/1/abc",
+			wantPath: "/api/webhooks/1/abc",
+			expected: map[string]interface{}{"content": "Update available for web\nweb (nginx:latest): sha256:old → sha256:new"},
+		},
+		{
+			name:     "ntfy",
+			preset:   "ntfy",
+			path:     "/updates",
+			wantPath: "/",
+			expected: map[string]interface{}{"topic": "updates", "title": "Update available for web", "priority": float64(3)},
+		},
+		{
+			name:     "gotify",
+			preset:   "gotify",
+			path:     "/message?token=abc",
+			wantPath: "/message",
+			expected: map[string]interface{}{"title": "Update available for web", "message": "web (nginx:latest): sha256:old → sha256:new", "priority": float64(5)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, server := newReceiver(t)
+			notifier, err := New([]WebhookConfig{{URL: server.URL + tt.path, Preset: tt.preset}}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			notifier.Notify(event)
+			notifier.Wait()
+
+			if len(r.requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(r.requests))
+			}
+			req := r.requests[0]
+			if req.URL.Path != tt.wantPath {
+				t.Errorf("expected path %s, got %s", tt.wantPath, req.URL.Path)
+			}
+			if req.Header.Get("Content-Type") != "application/json" || req.Header.Get(EventHeader) != "update.available" {
+				t.Errorf("unexpected headers: %v", req.Header)
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(r.bodies[0], &body); err != nil {
+				t.Fatalf("body is not JSON: %v\n%s", err, r.bodies[0])
+			}
+			for key, want := range tt.expected {
+				if body[key] != want {
+					t.Errorf("expected %s = %v, got %v", key, want, body[key])
+				}
+			}
+		})
+	}
+}
+
+func TestNotifierEventFilterAndTemplate(t *testing.T) {
+	r, server := newReceiver(t)
+	notifier, err := New([]WebhookConfig{{
+		Name:     "failures",
+		URL:      server.URL,
+		Events:   []EventType{EventUpdateFailed},
+		Template: `{"alert": {{json .Title}}, "rolled_back": {{.RolledBack}}, "first": {{json (index .Containers 0).Name}}}`,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+	}}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	containers := []Container{{Name: "web", Image: "nginx:latest"}}
+	notifier.Notify(Event{Type: EventUpdateSucceeded, Operation: "update", Target: "web", Containers: containers})
+	notifier.Notify(Event{Type: EventUpdateFailed, Operation: "revert", Target: "web", Containers: containers, RolledBack: true})
+	notifier.Wait()
+
+	if len(r.bodies) != 1 {
+		t.Fatalf("expected only the failure to be sent, got %d requests", len(r.bodies))
+	}
+	expected := `{"alert": "Revert of web failed", "rolled_back": true, "first": "web"}`
+	if string(r.bodies[0]) != expected {
+		t.Errorf("body = %s, expected %s", r.bodies[0], expected)
+	}
+	if r.requests[0].Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("expected the configured header, got %v", r.requests[0].Header)
+	}
+}
+
+func TestNewRejectsInvalidWebhooks(t *testing.T) {
+	tests := []struct {
+		name   string
+		config WebhookConfig
+		errMsg string
+	}{
+		{name: "missing url", config: WebhookConfig{}, errMsg: "url must be"},
+		{name: "unknown preset", config: WebhookConfig{URL: "https://example.com", Preset: "teams"}, errMsg: "unknown preset"},
+		{name: "unknown event", config: WebhookConfig{URL: "https://example.com", Events: []EventType{"update.started"}}, errMsg: "unknown event"},
+		{name: "bad timeout", config: WebhookConfig{URL: "https://example.com", Timeout: "soon"}, errMsg: "invalid timeout"},
+		{name: "template syntax", config: WebhookConfig{URL: "https://example.com", Template: `{{.Title`}, errMsg: "invalid template"},
+		{name: "template not JSON", config: WebhookConfig{URL: "https://example.com", Template: `{{.Title}}`}, errMsg: "valid JSON"},
+		{name: "ntfy without topic", config: WebhookConfig{URL: "https://ntfy.sh", Preset: "ntfy"}, errMsg: "topic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New([]WebhookConfig{tt.config}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package notify
+
+const presetNtfy = "ntfy"
+
+// presets maps preset names to body templates. The empty preset sends the
+// event itself as JSON.
+var presets = map[string]string{
+	"": `{{json .Event}}`,
+
+	// Slack incoming webhooks and compatible services such as Mattermost and Rocket.Chat
+	"slack": `{"text": {{json .Summary}}}`,
+
+	// Discord webhooks
+	"discord": `{"content": {{json .Summary}}}`,
+
+	// ntfy JSON publishing; the topic is the last path segment of the URL
+	presetNtfy: `{"topic": {{json .Topic}}, "title": {{json .Title}}, "message": {{json .Message}}, ` +
+		`"tags": [{{if eq .Type "update.failed"}}"warning"{{else}}"package"{{end}}], ` +
+		`"priority": {{if eq .Type "update.failed"}}4{{else}}3{{end}}}`,
+
+	// Gotify messages; the URL is the server's /message endpoint with an application token
+	"gotify": `{"title": {{json .Title}}, "message": {{json .Message}}, ` +
+		`"priority": {{if eq .Type "update.failed"}}8{{else}}5{{end}}}`,
+}
@@ -0,0 +1,268 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultTimeout bounds a single delivery attempt
+	defaultTimeout = 10 * time.Second
+	// defaultMaxRetries is how often a failed delivery is retried
+	defaultMaxRetries = 3
+
+	// SignatureHeader carries the HMAC-SHA256 of the body when a secret is set
+	SignatureHeader = "X-BleedingEdge-Signature"
+	// EventHeader carries the event type
+	EventHeader = "X-BleedingEdge-Event"
+)
+
+// retryDelay is the wait before the first retry; it doubles on every retry
+var retryDelay = time.Second
+
+// WebhookConfig configures one webhook
+type WebhookConfig struct {
+	Name       string            `yaml:"name"`                  // Label shown in logs
+	URL        string            `yaml:"url"`                   // Endpoint the event is POSTed to
+	Preset     string            `yaml:"preset,omitempty"`      // slack, discord, ntfy or gotify; empty for the generic JSON event
+	Events     []EventType       `yaml:"events,omitempty"`      // Events to send; empty for all
+	Template   string            `yaml:"template,omitempty"`    // text/template producing the JSON body, overrides the preset
+	Secret     string            `yaml:"secret,omitempty"`      // Signs the body with HMAC-SHA256 when set
+	Headers    map[string]string `yaml:"headers,omitempty"`     // Extra request headers, e.g. Authorization
+	Timeout    string            `yaml:"timeout,omitempty"`     // Per-attempt timeout, e.g. 5s; defaults to 10s
+	MaxRetries *int              `yaml:"max_retries,omitempty"` // Retries after a failed attempt; defaults to 3
+}
+
+// Config lists the webhooks events are sent to
+type Config struct {
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// LoadConfig reads webhooks from an optional YAML file and adds a single
+// webhook configured by the WEBHOOK_URL style settings when webhookURL is set
+func LoadConfig(path, webhookURL, preset, secret string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read webhooks file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse webhooks file %s: %w", path, err)
+		}
+	}
+
+	if webhookURL != "" {
+		cfg.Webhooks = append(cfg.Webhooks, WebhookConfig{
+			Name:   "default",
+			URL:    webhookURL,
+			Preset: preset,
+			Secret: secret,
+		})
+	}
+
+	return cfg, nil
+}
+
+// webhook is a validated WebhookConfig ready to deliver events
+type webhook struct {
+	name       string
+	url        string
+	topic      string // ntfy topic, taken from the configured URL
+	events     []EventType
+	body       *template.Template
+	secret     string
+	headers    map[string]string
+	timeout    time.Duration
+	maxRetries int
+}
+
+// templateData is what body templates are executed with. Event fields and
+// methods such as .Title, .Message and .Summary are available directly.
+type templateData struct {
+	Event
+	Topic string // ntfy topic
+}
+
+// templateFuncs are available to body templates
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {{json .Summary}} for a quoted string
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"short": shortDigest,
+}
+
+func newWebhook(cfg WebhookConfig) (*webhook, error) {
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("url must be an http or https URL, got %q", cfg.URL)
+	}
+
+	hook := &webhook{
+		name:       cfg.Name,
+		url:        cfg.URL,
+		events:     cfg.Events,
+		secret:     cfg.Secret,
+		headers:    cfg.Headers,
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+	}
+	if hook.name == "" {
+		hook.name = parsed.Host
+	}
+
+	for _, event := range cfg.Events {
+		if !slices.Contains(eventTypes, event) {
+			return nil, fmt.Errorf("unknown event %q (must be update.available, update.succeeded or update.failed)", event)
+		}
+	}
+
+	if cfg.Timeout != "" {
+		if hook.timeout, err = time.ParseDuration(cfg.Timeout); err != nil || hook.timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", cfg.Timeout)
+		}
+	}
+	if cfg.MaxRetries != nil {
+		if *cfg.MaxRetries < 0 {
+			return nil, fmt.Errorf("max_retries must not be negative")
+		}
+		hook.maxRetries = *cfg.MaxRetries
+	}
+
+	body, ok := presets[cfg.Preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (must be slack, discord, ntfy or gotify)", cfg.Preset)
+	}
+	if cfg.Template != "" {
+		body = cfg.Template
+	}
+	if hook.body, err = template.New(hook.name).Funcs(templateFuncs).Parse(body); err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	// ntfy takes the topic in the JSON body when publishing to the server root
+	if cfg.Preset == presetNtfy {
+		hook.topic = path.Base(parsed.Path)
+		if hook.topic == "." || hook.topic == "/" {
+			return nil, fmt.Errorf("ntfy url must end with the topic, e.g. https://ntfy.sh/updates")
+		}
+		parsed.Path = path.Dir(parsed.Path)
+		hook.url = parsed.String()
+	}
+
+	// Catch templates that do not produce JSON at startup rather than on the first event
+	sample := Event{Type: EventUpdateFailed, Time: time.Now(), Operation: "update", Target: "example", Containers: []Container{{Name: "example", Image: "example:latest"}}, Error: "example"}
+	if _, err := hook.render(sample); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// subscribed reports whether the webhook wants events of the given type
+func (w *webhook) subscribed(eventType EventType) bool {
+	return len(w.events) == 0 || slices.Contains(w.events, eventType)
+}
+
+// render executes the body template for an event
+func (w *webhook) render(event Event) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := w.body.Execute(&buf, templateData{Event: event, Topic: w.topic}); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("template did not produce valid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+// deliver sends an event, retrying with exponential backoff on network
+// errors, rate limiting and server errors
+func (w *webhook) deliver(ctx context.Context, client *http.Client, event Event) error {
+	body, err := w.render(event)
+	if err != nil {
+		return err
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := w.send(ctx, client, event.Type, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.maxRetries {
+			return fmt.Errorf("attempt %d: %w", attempt+1, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// send makes one delivery attempt and reports whether a failure is worth retrying
+func (w *webhook) send(ctx context.Context, client *http.Client, eventType EventType, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "BleedingEdge")
+	req.Header.Set(EventHeader, string(eventType))
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the signature header value for a body: "sha256=" followed by
+// the hex HMAC-SHA256 of the body keyed with the secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
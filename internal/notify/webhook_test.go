@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWebhookRetriesWithBackoff(t *testing.T) {
+	previous := retryDelay
+	retryDelay = time.Millisecond
+	defer func() { retryDelay = previous }()
+
+	zero := 0
+	tests := []struct {
+		name       string
+		statuses   []int
+		maxRetries *int
+		expectErr  bool
+		attempts   int
+	}{
+		{name: "server errors then success", statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}, attempts: 3},
+		{name: "gives up after retries", statuses: []int{500, 500, 500, 500, 500}, expectErr: true, attempts: 4},
+		{name: "client errors are not retried", statuses: []int{http.StatusBadRequest}, expectErr: true, attempts: 1},
+		{name: "retries disabled", statuses: []int{http.StatusServiceUnavailable}, maxRetries: &zero, expectErr: true, attempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, server := newReceiver(t, tt.statuses...)
+			hook, err := newWebhook(WebhookConfig{URL: server.URL, MaxRetries: tt.maxRetries})
+			if err != nil {
+				t.Fatalf("newWebhook() error = %v", err)
+			}
+
+			err = hook.deliver(context.Background(), server.Client(), Event{Type: EventUpdateSucceeded, Target: "web"})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("deliver() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if len(r.requests) != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, len(r.requests))
+			}
+		})
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	r, server := newReceiver(t)
+	hook, err := newWebhook(WebhookConfig{URL: server.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("newWebhook() error = %v", err)
+	}
+
+	if err := hook.deliver(context.Background(), server.Client(), Event{Type: EventUpdateAvailable, Target: "web"}); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	got := r.requests[0].Header.Get(SignatureHeader)
+	if got != Sign("s3cret", r.bodies[0]) {
+		t.Errorf("signature %q does not match the body", got)
+	}
+	// Known HMAC-SHA256 value, so receivers can verify with any implementation
+	if Sign("key", []byte("The quick brown fox jumps over the lazy dog")) != "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8" {
+		t.Error("Sign() does not produce HMAC-SHA256")
+	}
+
+	// Without a secret the header is left out
+	unsigned, _ := newWebhook(WebhookConfig{URL: server.URL})
+	if err := unsigned.deliver(context.Background(), server.Client(), Event{Type: EventUpdateAvailable, Target: "web"}); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+	if r.requests[1].Header.Get(SignatureHeader) != "" {
+		t.Error("expected no signature without a secret")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.yaml")
+	data := `webhooks:
+  - name: chat
+    url: https://hooks.slack.com/services/T000/B000/XXX
+    preset: slack
+    events: [update.failed]
+  - name: ci
+    url: https://ci.example.com/hook
+    secret: s3cret
+    max_retries: 1
+    timeout: 5s
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path, "https://ntfy.sh/updates", "ntfy", "")
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(cfg.Webhooks) != 3 {
+		t.Fatalf("expected 3 webhooks, got %d", len(cfg.Webhooks))
+	}
+	if cfg.Webhooks[0].Events[0] != EventUpdateFailed || *cfg.Webhooks[1].MaxRetries != 1 || cfg.Webhooks[1].Timeout != "5s" {
+		t.Errorf("unexpected file webhooks: %+v", cfg.Webhooks[:2])
+	}
+	if cfg.Webhooks[2].Name != "default" || cfg.Webhooks[2].Preset != "ntfy" {
+		t.Errorf("unexpected environment webhook: %+v", cfg.Webhooks[2])
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"), "", "", ""); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxStale bounds how old a cached digest may be to stand in for a registry
+// that is rate limited; older entries are pruned when the cache is opened
+const maxStale = 24 * time.Hour
+
+// digestsBucket holds cached digests keyed by image reference
+var digestsBucket = []byte("digests")
+
+// cacheEntry is a cached digest and when it was resolved
+type cacheEntry struct {
+	Digest     ManifestDigest `json:"digest"`
+	ResolvedAt time.Time      `json:"resolved_at"`
+}
+
+// Cache is a Resolver that remembers the digests resolved by another Resolver
+// for a TTL, in a bbolt database so they survive restarts. Every host's update
+// check shares it, so an image used on several hosts is resolved once per TTL.
+// While a registry is rate limited, stale digests are served instead.
+type Cache struct {
+	resolver Resolver
+	db       *bolt.DB
+	ttl      time.Duration
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	inflight map[string]*lookup
+}
+
+// lookup is a digest being resolved, shared by concurrent callers
+type lookup struct {
+	done   chan struct{}
+	digest *ManifestDigest
+	err    error
+}
+
+// OpenCache opens the digest cache at path in front of resolver, creating the
+// file and its directory if needed
+func OpenCache(path string, resolver Resolver, ttl time.Duration, logger *slog.Logger) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create registry cache directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry cache %s: %w", path, err)
+	}
+
+	c := &Cache{
+		resolver: resolver,
+		db:       db,
+		ttl:      ttl,
+		logger:   logger,
+		inflight: make(map[string]*lookup),
+	}
+	if err := db.Update(c.prune); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize registry cache: %w", err)
+	}
+	return c, nil
+}
+
+// Close closes the underlying database
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// prune creates the digests bucket and drops entries too old to be served
+func (c *Cache) prune(tx *bolt.Tx) error {
+	bucket, err := tx.CreateBucketIfNotExists(digestsBucket)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Add(-max(c.ttl, maxStale))
+	cursor := bucket.Cursor()
+	for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+		var entry cacheEntry
+		if err := json.Unmarshal(value, &entry); err != nil || entry.ResolvedAt.Before(cutoff) {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GetDigest returns the cached digest of an image while it is fresh, and
+// resolves it otherwise. Concurrent lookups of the same image share one
+// registry request.
+func (c *Cache) GetDigest(ctx context.Context, imageName string) (*ManifestDigest, error) {
+	entry, cached := c.get(imageName)
+	if cached && time.Since(entry.ResolvedAt) < c.ttl {
+		c.logger.Debug("using cached remote digest", "image", imageName, "resolved_at", entry.ResolvedAt)
+		return &entry.Digest, nil
+	}
+
+	c.mu.Lock()
+	l, ok := c.inflight[imageName]
+	if !ok {
+		l = &lookup{done: make(chan struct{})}
+		c.inflight[imageName] = l
+		go c.resolve(context.WithoutCancel(ctx), imageName, l)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if l.err != nil && cached && errors.Is(l.err, ErrRateLimited) && time.Since(entry.ResolvedAt) < maxStale {
+		c.logger.Info("registry rate limited, using stale cached digest",
+			"image", imageName,
+			"resolved_at", entry.ResolvedAt,
+		)
+		return &entry.Digest, nil
+	}
+	return l.digest, l.err
+}
+
+// resolve looks up a digest for every caller waiting on l and caches it. It
+// runs detached from the first caller's cancellation, bounded by the
+// resolver's own timeouts, so the other callers are not failed with it.
+func (c *Cache) resolve(ctx context.Context, imageName string, l *lookup) {
+	l.digest, l.err = c.resolver.GetDigest(ctx, imageName)
+	if l.err == nil {
+		if err := c.put(imageName, *l.digest); err != nil {
+			c.logger.Warn("failed to cache remote digest", "image", imageName, "error", err)
+		}
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, imageName)
+	c.mu.Unlock()
+	close(l.done)
+}
+
+// ListTags lists tags with the underlying resolver; tag lists are not cached
+func (c *Cache) ListTags(ctx context.Context, imageName string) ([]string, error) {
+	return c.resolver.ListTags(ctx, imageName)
+}
+
+// get returns the cached entry of an image, if any
+func (c *Cache) get(imageName string) (cacheEntry, bool) {
+	var entry cacheEntry
+	var found bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(digestsBucket).Get([]byte(imageName))
+		if value == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(value, &entry)
+	})
+	if err != nil {
+		c.logger.Warn("failed to read cached remote digest", "image", imageName, "error", err)
+		return cacheEntry{}, false
+	}
+	return entry, found
+}
+
+// put caches the digest of an image as resolved now
+func (c *Cache) put(imageName string, digest ManifestDigest) error {
+	value, err := json.Marshal(cacheEntry{Digest: digest, ResolvedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(digestsBucket).Put([]byte(imageName), value)
+	})
+}
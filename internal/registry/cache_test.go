@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func openTestCache(t *testing.T, path string, resolver Resolver, ttl time.Duration) *Cache {
+	t.Helper()
+	cache, err := OpenCache(path, resolver, ttl, slog.Default())
+	if err != nil {
+		t.Fatalf("OpenCache() error = %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+func TestCacheServesFreshDigests(t *testing.T) {
+	var calls atomic.Int32
+	resolver := &MockClient{GetDigestFunc: func(ctx context.Context, imageName string) (*ManifestDigest, error) {
+		calls.Add(1)
+		return &ManifestDigest{Digest: "sha256:" + imageName}, nil
+	}}
+	path := filepath.Join(t.TempDir(), "registry.db")
+
+	cache := openTestCache(t, path, resolver, time.Hour)
+	for range 3 {
+		got, err := cache.GetDigest(context.Background(), "nginx:latest")
+		if err != nil {
+			t.Fatalf("GetDigest() error = %v", err)
+		}
+		if got.Digest != "sha256:nginx:latest" {
+			t.Errorf("unexpected digest %s", got.Digest)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one registry lookup, got %d", calls.Load())
+	}
+
+	// Cached digests survive a restart
+	cache.Close()
+	cache = openTestCache(t, path, resolver, time.Hour)
+	if _, err := cache.GetDigest(context.Background(), "nginx:latest"); err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the persisted digest to be used, got %d lookups", calls.Load())
+	}
+}
+
+func TestCacheExpiresDigests(t *testing.T) {
+	var calls atomic.Int32
+	resolver := &MockClient{GetDigestFunc: func(ctx context.Context, imageName string) (*ManifestDigest, error) {
+		calls.Add(1)
+		return &ManifestDigest{Digest: "sha256:abc"}, nil
+	}}
+	cache := openTestCache(t, filepath.Join(t.TempDir(), "registry.db"), resolver, time.Nanosecond)
+
+	for range 2 {
+		if _, err := cache.GetDigest(context.Background(), "nginx:latest"); err != nil {
+			t.Fatalf("GetDigest() error = %v", err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected expired digests to be resolved again, got %d lookups", calls.Load())
+	}
+}
+
+func TestCacheStaleWhileRateLimited(t *testing.T) {
+	limited := false
+	resolver := &MockClient{GetDigestFunc: func(ctx context.Context, imageName string) (*ManifestDigest, error) {
+		if limited {
+			return nil, ErrRateLimited
+		}
+		return &ManifestDigest{Digest: "sha256:abc"}, nil
+	}}
+	cache := openTestCache(t, filepath.Join(t.TempDir(), "registry.db"), resolver, time.Nanosecond)
+
+	if _, err := cache.GetDigest(context.Background(), "nginx:latest"); err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+	limited = true
+
+	got, err := cache.GetDigest(context.Background(), "nginx:latest")
+	if err != nil || got.Digest != "sha256:abc" {
+		t.Errorf("expected the stale digest, got %+v, %v", got, err)
+	}
+	if _, err := cache.GetDigest(context.Background(), "redis:7"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited without a cached digest, got %v", err)
+	}
+}
+
+func TestCacheSharesConcurrentLookups(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	resolver := &MockClient{GetDigestFunc: func(ctx context.Context, imageName string) (*ManifestDigest, error) {
+		calls.Add(1)
+		<-release
+		return &ManifestDigest{Digest: "sha256:abc"}, nil
+	}}
+	cache := openTestCache(t, filepath.Join(t.TempDir(), "registry.db"), resolver, time.Hour)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.GetDigest(context.Background(), "nginx:latest"); err != nil {
+				t.Errorf("GetDigest() error = %v", err)
+			}
+		}()
+	}
+	// Let every caller reach the in-flight lookup before it completes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected concurrent lookups to share one registry request, got %d", calls.Load())
+	}
+}
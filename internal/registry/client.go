@@ -0,0 +1,646 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
+	"github.com/distribution/reference"
+)
+
+// Manifest media types accepted when resolving a tag
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+)
+
+// dockerHubDomain is the normalized domain for Docker Hub references
+const dockerHubDomain = "docker.io"
+
+// dockerHubRegistry is the API host that serves Docker Hub references
+const dockerHubRegistry = "registry-1.docker.io"
+
+// maxTagPages bounds how many pages of a repository's tag list are fetched
+const maxTagPages = 20
+
+// Resolver defines the interface for looking up remote image digests and tags
+type Resolver interface {
+	GetDigest(ctx context.Context, imageName string) (*ManifestDigest, error)
+	ListTags(ctx context.Context, imageName string) ([]string, error)
+}
+
+// ManifestDigest describes the manifest a tag currently points to in the registry
+type ManifestDigest struct {
+	Digest         string             // Digest of the manifest or index the tag resolves to
+	MediaType      string             // Media type of the manifest or index
+	PlatformDigest string             // Digest of the selected platform's manifest when Digest is an index
+	Manifests      []PlatformManifest // Every platform's manifest when Digest is an index
+}
+
+// Platform identifies the os/architecture used to select a manifest from an index
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string
+}
+
+// PlatformManifest is one platform's entry in a manifest index
+type PlatformManifest struct {
+	Platform Platform
+	Digest   string
+}
+
+// Client is a minimal OCI Distribution API client used for update detection
+type Client struct {
+	httpClient *http.Client
+	logger     *slog.Logger
+	platform   Platform
+
+	credentials *credentials.Store
+	insecure    map[string]bool // Registry hosts spoken to over plain HTTP
+
+	mu     sync.Mutex
+	auths  map[string]cachedAuth
+	limits map[string]RateLimit // Last reported quota, keyed by registry name
+}
+
+// cachedAuth is a cached Authorization header for a registry host and scope
+type cachedAuth struct {
+	header  string
+	expires time.Time
+}
+
+// NewClient creates a new registry client for the host platform
+func NewClient() *Client {
+	return NewClientWithLogger(slog.Default())
+}
+
+// NewClientWithLogger creates a new registry client with a custom logger
+func NewClientWithLogger(logger *slog.Logger) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		platform:   HostPlatform(),
+		auths:      make(map[string]cachedAuth),
+		limits:     make(map[string]RateLimit),
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used to talk to registries
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithCredentials authenticates to registries with the credentials in store
+// instead of anonymously
+func (c *Client) WithCredentials(store *credentials.Store) *Client {
+	c.credentials = store
+	return c
+}
+
+// WithInsecureRegistries talks plain HTTP instead of HTTPS to the given
+// registry hosts, given with their port if not the default, like the Docker
+// daemon's insecure-registries. Loopback hosts always use plain HTTP.
+func (c *Client) WithInsecureRegistries(hosts []string) *Client {
+	c.insecure = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		c.insecure[strings.ToLower(host)] = true
+	}
+	return c
+}
+
+// WithPlatform overrides the platform used to select manifests from an index
+func (c *Client) WithPlatform(platform Platform) *Client {
+	c.platform = platform
+	return c
+}
+
+// HostPlatform returns the platform of the running process, the default for
+// selecting manifests when the platform of the image being checked is unknown
+func HostPlatform() Platform {
+	platform := Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	if runtime.GOARCH == "arm" {
+		platform.Variant = "v7"
+	}
+	return platform
+}
+
+// GetDigest resolves an image reference to the digest of its remote manifest
+// without downloading any layers
+func (c *Client) GetDigest(ctx context.Context, imageName string) (*ManifestDigest, error) {
+	start := time.Now()
+	c.logger.Debug("resolving remote digest", "image", imageName)
+
+	repo, tag, err := parseReference(imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doManifestRequest(ctx, http.MethodHead, repo, tag)
+	if err != nil {
+		c.logger.Error("failed to resolve remote digest",
+			"image", imageName,
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return nil, err
+	}
+	resp.Body.Close()
+
+	result := &ManifestDigest{
+		Digest:    resp.Header.Get("Docker-Content-Digest"),
+		MediaType: mediaTypeOf(resp.Header.Get("Content-Type")),
+	}
+
+	// Some registries omit the digest header on HEAD, and indexes need their
+	// body to select the platform manifest, so fall back to GET in those cases
+	if result.Digest == "" || isIndex(result.MediaType) {
+		if err := c.resolveWithGet(ctx, repo, tag, result); err != nil {
+			c.logger.Error("failed to fetch remote manifest",
+				"image", imageName,
+				"error", err,
+				"duration_ms", time.Since(start).Milliseconds(),
+			)
+			return nil, err
+		}
+	}
+
+	c.logger.Debug("resolved remote digest successfully",
+		"image", imageName,
+		"digest", result.Digest,
+		"platform_digest", result.PlatformDigest,
+		"media_type", result.MediaType,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return result, nil
+}
+
+// resolveWithGet fetches the manifest body to compute its digest and, for
+// indexes, pick the manifest matching the configured platform
+func (c *Client) resolveWithGet(ctx context.Context, repo repository, tag string, result *ManifestDigest) error {
+	resp, err := c.doManifestRequest(ctx, http.MethodGet, repo, tag)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest for %s:%s: %w", repo.name, tag, err)
+	}
+
+	if result.Digest == "" {
+		if header := resp.Header.Get("Docker-Content-Digest"); header != "" {
+			result.Digest = header
+		} else {
+			sum := sha256.Sum256(body)
+			result.Digest = "sha256:" + hex.EncodeToString(sum[:])
+		}
+	}
+	if mediaType := mediaTypeOf(resp.Header.Get("Content-Type")); mediaType != "" {
+		result.MediaType = mediaType
+	}
+
+	if !isIndex(result.MediaType) {
+		return nil
+	}
+
+	var index manifestIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return fmt.Errorf("failed to decode manifest index for %s:%s: %w", repo.name, tag, err)
+	}
+	for _, m := range index.Manifests {
+		result.Manifests = append(result.Manifests, PlatformManifest{
+			Platform: Platform{OS: m.Platform.OS, Architecture: m.Platform.Architecture, Variant: m.Platform.Variant},
+			Digest:   m.Digest,
+		})
+	}
+	result.PlatformDigest = digestFor(result.Manifests, c.platform)
+	return nil
+}
+
+// ListTags returns every tag of an image's repository, following the
+// registry's pagination
+func (c *Client) ListTags(ctx context.Context, imageName string) ([]string, error) {
+	start := time.Now()
+	c.logger.Debug("listing remote tags", "image", imageName)
+
+	repo, _, err := parseReference(imageName)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=1000", c.baseURL(repo), repo.path)
+	for page := 0; next != "" && page < maxTagPages; page++ {
+		resp, err := c.doRequest(ctx, http.MethodGet, next, repo, "application/json")
+		if err == nil && resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			err = fmt.Errorf("registry returned %s listing tags of %s", resp.Status, repo.name)
+		}
+		if err != nil {
+			c.logger.Error("failed to list remote tags",
+				"image", imageName,
+				"error", err,
+				"duration_ms", time.Since(start).Milliseconds(),
+			)
+			return nil, err
+		}
+
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode tag list for %s: %w", repo.name, err)
+		}
+		tags = append(tags, body.Tags...)
+		next = nextPage(resp)
+	}
+
+	c.logger.Debug("listed remote tags successfully",
+		"image", imageName,
+		"count", len(tags),
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return tags, nil
+}
+
+// nextPage returns the URL of the next page named by a response's Link header,
+// or an empty string on the last page
+func nextPage(resp *http.Response) string {
+	for _, link := range strings.Split(resp.Header.Get("Link"), ",") {
+		target, params, _ := strings.Cut(strings.TrimSpace(link), ";")
+		if !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		next, err := resp.Request.URL.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		return next.String()
+	}
+	return ""
+}
+
+// doManifestRequest performs a manifest request for a tag or digest
+func (c *Client) doManifestRequest(ctx context.Context, method string, repo repository, tag string) (*http.Response, error) {
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(repo), repo.path, tag)
+	resp, err := c.doRequest(ctx, method, manifestURL, repo, strings.Join([]string{
+		MediaTypeOCIIndex,
+		MediaTypeDockerManifestList,
+		MediaTypeOCIManifest,
+		MediaTypeDockerManifest,
+	}, ", "))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned %s for %s:%s", resp.Status, repo.name, tag)
+	}
+	return resp, nil
+}
+
+// doRequest performs a registry API request for a repository, authenticating
+// if the registry challenges the anonymous request. Requests to a registry
+// whose rate limit is nearly exhausted fail with ErrRateLimited.
+func (c *Client) doRequest(ctx context.Context, method, requestURL string, repo repository, accept string) (*http.Response, error) {
+	if err := c.checkRateLimit(repo.host); err != nil {
+		return nil, err
+	}
+	scope := fmt.Sprintf("repository:%s:pull", repo.path)
+
+	resp, err := c.do(ctx, method, requestURL, c.cachedAuth(repo.host, scope), accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		authorization, err := c.authorize(ctx, repo.host, scope, challenge)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.do(ctx, method, requestURL, authorization, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.observeRateLimit(repo.host, resp)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, c.checkRateLimit(repo.host)
+	}
+	return resp, nil
+}
+
+// do sends a single request with the accepted media types
+func (c *Client) do(ctx context.Context, method, requestURL, authorization, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	return c.httpClient.Do(req)
+}
+
+// cachedAuth returns a still-valid Authorization header for the host and
+// scope, if any
+func (c *Client) cachedAuth(host, scope string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	auth, ok := c.auths[host+"|"+scope]
+	if !ok || time.Now().After(auth.expires) {
+		return ""
+	}
+	return auth.header
+}
+
+// authorize answers a WWW-Authenticate challenge with the host's credentials,
+// or anonymously without any, and caches the resulting Authorization header
+func (c *Client) authorize(ctx context.Context, host, scope, challenge string) (string, error) {
+	creds, err := c.credentials.Lookup(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	var header string
+	expires := time.Now().Add(time.Hour)
+	scheme, params := parseChallenge(challenge)
+	switch {
+	case strings.EqualFold(scheme, "basic"):
+		if creds.Username == "" {
+			return "", fmt.Errorf("registry %s requires credentials", host)
+		}
+		header = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.Username+":"+creds.Password))
+	case strings.EqualFold(scheme, "bearer") && params["realm"] != "":
+		token, expiresIn, err := c.fetchToken(ctx, creds, scope, params)
+		if err != nil {
+			return "", err
+		}
+		// Renew a little early
+		header = "Bearer " + token
+		expires = time.Now().Add(expiresIn - 5*time.Second)
+	default:
+		return "", fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+
+	c.mu.Lock()
+	c.auths[host+"|"+scope] = cachedAuth{header: header, expires: expires}
+	c.mu.Unlock()
+	return header, nil
+}
+
+// fetchToken requests a bearer token from the realm of a challenge, as the
+// user in creds or anonymously, and returns it with its lifetime
+func (c *Client) fetchToken(ctx context.Context, creds credentials.Credentials, scope string, params map[string]string) (string, time.Duration, error) {
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid token realm %q: %w", params["realm"], err)
+	}
+	if challengeScope := params["scope"]; challengeScope != "" {
+		scope = challengeScope
+	}
+	form := url.Values{"scope": {scope}}
+	if service := params["service"]; service != "" {
+		form.Set("service", service)
+	}
+
+	var req *http.Request
+	if creds.IdentityToken != "" {
+		// Identity tokens are OAuth refresh tokens exchanged with a POST
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", creds.IdentityToken)
+		form.Set("client_id", "bleeding-edge")
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, tokenURL.String(), strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", 0, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		query := tokenURL.Query()
+		for key, values := range form {
+			query[key] = values
+		}
+		tokenURL.RawQuery = query.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return "", 0, err
+		}
+		if creds.Username != "" {
+			req.SetBasicAuth(creds.Username, creds.Password)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to request registry token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", 0, fmt.Errorf("failed to decode registry token: %w", err)
+	}
+
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", 0, fmt.Errorf("token endpoint returned an empty token")
+	}
+
+	// Tokens default to 60 seconds per the token spec
+	expiresIn := time.Duration(body.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = 60 * time.Second
+	}
+	return token, expiresIn, nil
+}
+
+// repository is a parsed image repository and the registry host serving it
+type repository struct {
+	name string // Familiar name, used in messages
+	host string // Registry API host (with port)
+	path string // Repository path within the registry
+}
+
+// baseURL returns the registry API base URL for the repository, using plain
+// HTTP for loopback and insecure registries
+func (c *Client) baseURL(repo repository) string {
+	if c.insecure[strings.ToLower(repo.host)] || isLoopback(repo.host) {
+		return "http://" + repo.host
+	}
+	return "https://" + repo.host
+}
+
+// isLoopback reports whether a registry host, with or without a port, is
+// localhost or a loopback address
+func isLoopback(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// RegistryOf returns the registry an image is pulled from, named as in rate
+// limits, e.g. docker.io for nginx:latest, or "" for an invalid reference
+func RegistryOf(imageName string) string {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return ""
+	}
+	return reference.Domain(named)
+}
+
+// parseReference splits an image reference into repository and tag (or digest)
+func parseReference(imageName string) (repository, string, error) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return repository{}, "", fmt.Errorf("invalid image reference %q: %w", imageName, err)
+	}
+
+	repo := repository{
+		name: reference.FamiliarName(named),
+		host: reference.Domain(named),
+		path: reference.Path(named),
+	}
+	if repo.host == dockerHubDomain {
+		repo.host = dockerHubRegistry
+	}
+
+	if canonical, ok := named.(reference.Canonical); ok {
+		return repo, canonical.Digest().String(), nil
+	}
+	if tagged, ok := reference.TagNameOnly(named).(reference.Tagged); ok {
+		return repo, tagged.Tag(), nil
+	}
+	return repo, "latest", nil
+}
+
+// parseChallenge parses a WWW-Authenticate header into its scheme and parameters
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+
+	for rest != "" {
+		var key, value string
+		key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		if key = strings.TrimSpace(key); key != "" {
+			params[strings.ToLower(key)] = value
+		}
+	}
+
+	return scheme, params
+}
+
+// mediaTypeOf strips parameters from a Content-Type header
+func mediaTypeOf(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType)
+}
+
+// isIndex reports whether a media type is a multi-platform index
+func isIndex(mediaType string) bool {
+	return mediaType == MediaTypeOCIIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// manifestIndex is the subset of an OCI index / Docker manifest list we need
+type manifestIndex struct {
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// digestFor returns the digest of the manifest matching the platform, or an
+// empty string if the index does not contain one
+func digestFor(manifests []PlatformManifest, platform Platform) string {
+	fallback := ""
+	for _, m := range manifests {
+		if m.Platform.OS != platform.OS || m.Platform.Architecture != platform.Architecture {
+			continue
+		}
+		if platform.Variant == "" || m.Platform.Variant == platform.Variant {
+			return m.Digest
+		}
+		if fallback == "" {
+			fallback = m.Digest
+		}
+	}
+	return fallback
+}
+
+// ForPlatform returns the digest with the platform manifest selected for
+// platform instead of the client's, e.g. for the architecture of the Docker
+// host running the image. Digests of single manifests are returned as-is.
+func (d *ManifestDigest) ForPlatform(platform Platform) *ManifestDigest {
+	if len(d.Manifests) == 0 || platform.OS == "" || platform.Architecture == "" {
+		return d
+	}
+	resolved := *d
+	resolved.PlatformDigest = digestFor(d.Manifests, platform)
+	return &resolved
+}
+
+// MatchesAny reports whether any of the local repo digests (as returned in an
+// image's RepoDigests, e.g. "nginx@sha256:...") refers to the remote manifest
+func (d *ManifestDigest) MatchesAny(repoDigests []string) bool {
+	for _, repoDigest := range repoDigests {
+		digest := repoDigest
+		if _, after, found := strings.Cut(repoDigest, "@"); found {
+			digest = after
+		}
+		if digest == d.Digest || (d.PlatformDigest != "" && digest == d.PlatformDigest) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,508 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/credentials"
+)
+
+// fakeRegistry is an in-process registry serving manifests behind token auth
+type fakeRegistry struct {
+	server    *httptest.Server
+	manifests map[string]fakeManifest // keyed by "<repo>:<tag>"
+	omitHead  bool                    // omit Docker-Content-Digest on HEAD
+	tags      map[string][]string     // tag list pages, keyed by repo
+	login     string                  // "user:password" required by the token endpoint, if set
+	refresh   string                  // identity token the token endpoint also accepts, if set
+	basic     bool                    // challenge for basic auth with login instead of a token
+	remaining string                  // RateLimit-Remaining header sent with manifests, if set
+	tooMany   bool                    // answer manifest requests with 429 Too Many Requests
+
+	mu           sync.Mutex
+	tokenCalls   int
+	getCalls     int
+	headCalls    int
+	blobRequests int
+}
+
+type fakeManifest struct {
+	mediaType string
+	body      []byte
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+	reg := &fakeRegistry{manifests: make(map[string]fakeManifest)}
+	reg.server = httptest.NewTLSServer(http.HandlerFunc(reg.serveHTTP))
+	t.Cleanup(reg.server.Close)
+	return reg
+}
+
+// newPlainFakeRegistry starts a fake registry without TLS
+func newPlainFakeRegistry(t *testing.T) *fakeRegistry {
+	t.Helper()
+	reg := &fakeRegistry{manifests: make(map[string]fakeManifest)}
+	reg.server = httptest.NewServer(http.HandlerFunc(reg.serveHTTP))
+	t.Cleanup(reg.server.Close)
+	return reg
+}
+
+// host returns the registry's address under a name that is not loopback, so
+// the client treats it like a remote registry
+func (r *fakeRegistry) host() string {
+	_, port, _ := net.SplitHostPort(r.server.Listener.Addr().String())
+	return "example.com:" + port
+}
+
+// client returns a registry client that reaches the fake registry whatever
+// host name it is addressed by
+func (r *fakeRegistry) client() *Client {
+	transport := r.server.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, r.server.Listener.Addr().String())
+	}
+	return NewClient().WithHTTPClient(&http.Client{Transport: transport})
+}
+
+func (r *fakeRegistry) addManifest(repo, tag, mediaType string, body []byte) string {
+	r.manifests[repo+":"+tag] = fakeManifest{mediaType: mediaType, body: body}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (r *fakeRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		r.tokenCalls++
+		user, password, _ := req.BasicAuth()
+		refreshed := r.refresh != "" && req.Method == http.MethodPost && req.PostFormValue("refresh_token") == r.refresh
+		if r.login != "" && user+":"+password != r.login && !refreshed {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token": "secret-token", "expires_in": 300})
+		return
+	}
+
+	if r.basic {
+		if user, password, _ := req.BasicAuth(); user+":"+password != r.login {
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	} else if req.Header.Get("Authorization") != "Bearer secret-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if strings.Contains(req.URL.Path, "/blobs/") {
+		r.blobRequests++
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if repo, ok := strings.CutSuffix(path, "/tags/list"); ok {
+		r.serveTags(w, req, repo)
+		return
+	}
+
+	repo, tag, ok := strings.Cut(path, "/manifests/")
+	manifest, exists := r.manifests[repo+":"+tag]
+	if !ok || !exists {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.remaining != "" {
+		w.Header().Set("RateLimit-Limit", "100;w=21600")
+		w.Header().Set("RateLimit-Remaining", r.remaining)
+	}
+	if r.tooMany {
+		r.headCalls++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	sum := sha256.Sum256(manifest.body)
+	w.Header().Set("Content-Type", manifest.mediaType)
+	if req.Method == http.MethodHead {
+		r.headCalls++
+		if !r.omitHead {
+			w.Header().Set("Docker-Content-Digest", "sha256:"+hex.EncodeToString(sum[:]))
+		}
+		return
+	}
+	r.getCalls++
+	w.Header().Set("Docker-Content-Digest", "sha256:"+hex.EncodeToString(sum[:]))
+	w.Write(manifest.body)
+}
+
+// serveTags serves a repository's tags two per page, linking to the next page
+// the way Docker Hub and distribution do
+func (r *fakeRegistry) serveTags(w http.ResponseWriter, req *http.Request, repo string) {
+	tags, ok := r.tags[repo]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var start int
+	if last := req.URL.Query().Get("last"); last != "" {
+		start = slices.Index(tags, last) + 1
+	}
+	end := min(start+2, len(tags))
+	if end < len(tags) {
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=2&last=%s>; rel="next"`, repo, tags[end-1]))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags[start:end]})
+}
+
+func TestGetDigestSingleManifest(t *testing.T) {
+	reg := newFakeRegistry(t)
+	want := reg.addManifest("library/nginx", "latest", MediaTypeOCIManifest, []byte(`{"schemaVersion":2}`))
+
+	got, err := reg.client().GetDigest(context.Background(), reg.host()+"/library/nginx:latest")
+	if err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+
+	if got.Digest != want {
+		t.Errorf("expected digest %s, got %s", want, got.Digest)
+	}
+	if got.PlatformDigest != "" {
+		t.Errorf("expected no platform digest for a single manifest, got %s", got.PlatformDigest)
+	}
+	if reg.getCalls != 0 {
+		t.Errorf("expected HEAD only, got %d GET requests", reg.getCalls)
+	}
+	if reg.blobRequests != 0 {
+		t.Errorf("expected no blob downloads, got %d", reg.blobRequests)
+	}
+}
+
+func TestGetDigestIndexSelectsPlatform(t *testing.T) {
+	reg := newFakeRegistry(t)
+	index := `{"schemaVersion":2,"manifests":[
+		{"digest":"sha256:amd64","platform":{"os":"linux","architecture":"amd64"}},
+		{"digest":"sha256:armv6","platform":{"os":"linux","architecture":"arm","variant":"v6"}},
+		{"digest":"sha256:armv7","platform":{"os":"linux","architecture":"arm","variant":"v7"}}
+	]}`
+	want := reg.addManifest("team/app", "1.0", MediaTypeOCIIndex, []byte(index))
+
+	tests := []struct {
+		name     string
+		platform Platform
+		expected string
+	}{
+		{name: "amd64", platform: Platform{OS: "linux", Architecture: "amd64"}, expected: "sha256:amd64"},
+		{name: "arm variant", platform: Platform{OS: "linux", Architecture: "arm", Variant: "v7"}, expected: "sha256:armv7"},
+		{name: "unknown platform", platform: Platform{OS: "windows", Architecture: "amd64"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := reg.client().WithPlatform(tt.platform)
+			got, err := client.GetDigest(context.Background(), reg.host()+"/team/app:1.0")
+			if err != nil {
+				t.Fatalf("GetDigest() error = %v", err)
+			}
+			if got.Digest != want {
+				t.Errorf("expected index digest %s, got %s", want, got.Digest)
+			}
+			if got.PlatformDigest != tt.expected {
+				t.Errorf("expected platform digest %q, got %q", tt.expected, got.PlatformDigest)
+			}
+		})
+	}
+}
+
+func TestManifestDigestForPlatform(t *testing.T) {
+	reg := newFakeRegistry(t)
+	index := `{"schemaVersion":2,"manifests":[
+		{"digest":"sha256:amd64","platform":{"os":"linux","architecture":"amd64"}},
+		{"digest":"sha256:arm64","platform":{"os":"linux","architecture":"arm64","variant":"v8"}}
+	]}`
+	reg.addManifest("team/app", "1.0", MediaTypeOCIIndex, []byte(index))
+
+	// The server runs on amd64 but checks an image running on an arm64 host
+	client := reg.client().WithPlatform(Platform{OS: "linux", Architecture: "amd64"})
+	got, err := client.GetDigest(context.Background(), reg.host()+"/team/app:1.0")
+	if err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		platform Platform
+		expected string
+	}{
+		{name: "other host", platform: Platform{OS: "linux", Architecture: "arm64"}, expected: "sha256:arm64"},
+		{name: "other host with variant", platform: Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, expected: "sha256:arm64"},
+		{name: "unknown platform", platform: Platform{}, expected: "sha256:amd64"},
+		{name: "platform missing from index", platform: Platform{OS: "linux", Architecture: "s390x"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resolved := got.ForPlatform(tt.platform); resolved.PlatformDigest != tt.expected {
+				t.Errorf("expected platform digest %q, got %q", tt.expected, resolved.PlatformDigest)
+			}
+		})
+	}
+	if got.PlatformDigest != "sha256:amd64" {
+		t.Errorf("expected ForPlatform to leave the resolved digest unchanged, got %q", got.PlatformDigest)
+	}
+}
+
+func TestGetDigestPlainHTTPRegistry(t *testing.T) {
+	reg := newPlainFakeRegistry(t)
+	want := reg.addManifest("team/app", "latest", MediaTypeOCIManifest, []byte(`{"schemaVersion":2}`))
+	_, port, _ := net.SplitHostPort(reg.server.Listener.Addr().String())
+
+	tests := []struct {
+		name     string
+		host     string
+		insecure []string
+	}{
+		{name: "localhost", host: "localhost:" + port},
+		{name: "loopback address", host: "127.0.0.1:" + port},
+		{name: "insecure registry", host: "registry.lan:" + port, insecure: []string{"registry.lan:" + port}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reg.client().WithInsecureRegistries(tt.insecure).GetDigest(context.Background(), tt.host+"/team/app:latest")
+			if err != nil {
+				t.Fatalf("GetDigest() error = %v", err)
+			}
+			if got.Digest != want {
+				t.Errorf("expected digest %s, got %s", want, got.Digest)
+			}
+		})
+	}
+
+	// Other registries are only spoken to over HTTPS
+	if _, err := reg.client().GetDigest(context.Background(), "registry.lan:"+port+"/team/app:latest"); err == nil {
+		t.Error("expected a registry not listed as insecure to require HTTPS")
+	}
+}
+
+func TestGetDigestWithoutHeadDigest(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.omitHead = true
+	want := reg.addManifest("library/redis", "7", MediaTypeDockerManifest, []byte(`{"schemaVersion":2,"config":{}}`))
+
+	got, err := reg.client().GetDigest(context.Background(), reg.host()+"/library/redis:7")
+	if err != nil {
+		t.Fatalf("GetDigest() error = %v", err)
+	}
+	if got.Digest != want {
+		t.Errorf("expected digest %s, got %s", want, got.Digest)
+	}
+}
+
+func TestGetDigestReusesToken(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.addManifest("library/nginx", "latest", MediaTypeOCIManifest, []byte(`{}`))
+	client := reg.client()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetDigest(context.Background(), reg.host()+"/library/nginx:latest"); err != nil {
+			t.Fatalf("GetDigest() error = %v", err)
+		}
+	}
+
+	if reg.tokenCalls != 1 {
+		t.Errorf("expected token to be fetched once, got %d", reg.tokenCalls)
+	}
+}
+
+func TestGetDigestNotFound(t *testing.T) {
+	reg := newFakeRegistry(t)
+
+	if _, err := reg.client().GetDigest(context.Background(), reg.host()+"/missing/image:latest"); err == nil {
+		t.Error("expected error for missing manifest")
+	}
+}
+
+func TestGetDigestWithCredentials(t *testing.T) {
+	tests := []struct {
+		name        string
+		login       string
+		refresh     string
+		basic       bool
+		credentials string // Credentials file content; %s is the registry host
+		config      string // Docker config.json content; %s is the registry host
+		expectError bool
+	}{
+		{
+			name:        "token with password",
+			login:       "robot:secret",
+			credentials: "registries:\n  - registry: %s\n    username: robot\n    password: secret\n",
+		},
+		{
+			name:    "token with identity token",
+			login:   "robot:secret",
+			refresh: "refresh-me",
+			config:  `{"auths": {"https://%s": {"identitytoken": "refresh-me"}}}`,
+		},
+		{
+			name:   "basic auth",
+			login:  "robot:secret",
+			basic:  true,
+			config: `{"auths": {"%s": {"auth": "cm9ib3Q6c2VjcmV0"}}}`,
+		},
+		{
+			name:        "wrong password",
+			login:       "robot:secret",
+			credentials: "registries:\n  - registry: %s\n    username: robot\n    password: guess\n",
+			expectError: true,
+		},
+		{
+			name:        "basic auth without credentials",
+			login:       "robot:secret",
+			basic:       true,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newFakeRegistry(t)
+			reg.login, reg.refresh, reg.basic = tt.login, tt.refresh, tt.basic
+			want := reg.addManifest("private/app", "1.0", MediaTypeOCIManifest, []byte(`{}`))
+
+			dir := t.TempDir()
+			var file string
+			if tt.credentials != "" {
+				file = filepath.Join(dir, "credentials.yml")
+				os.WriteFile(file, []byte(fmt.Sprintf(tt.credentials, reg.host())), 0600)
+			}
+			if tt.config != "" {
+				os.WriteFile(filepath.Join(dir, "config.json"), []byte(fmt.Sprintf(tt.config, reg.host())), 0600)
+			}
+			store, err := credentials.Load(dir, file, slog.Default())
+			if err != nil {
+				t.Fatalf("credentials.Load() error = %v", err)
+			}
+
+			got, err := reg.client().WithCredentials(store).GetDigest(context.Background(), reg.host()+"/private/app:1.0")
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetDigest() error = %v, expectError %v", err, tt.expectError)
+			}
+			if !tt.expectError && got.Digest != want {
+				t.Errorf("expected digest %s, got %s", want, got.Digest)
+			}
+		})
+	}
+}
+
+func TestListTags(t *testing.T) {
+	reg := newFakeRegistry(t)
+	reg.tags = map[string][]string{"library/postgres": {"15", "16.3", "16.4-alpine", "17", "latest"}}
+
+	got, err := reg.client().ListTags(context.Background(), reg.host()+"/library/postgres:16.3")
+	if err != nil {
+		t.Fatalf("ListTags() error = %v", err)
+	}
+	if !slices.Equal(got, reg.tags["library/postgres"]) {
+		t.Errorf("expected every page of tags %v, got %v", reg.tags["library/postgres"], got)
+	}
+	if reg.tokenCalls != 1 {
+		t.Errorf("expected token to be fetched once across pages, got %d", reg.tokenCalls)
+	}
+
+	if _, err := reg.client().ListTags(context.Background(), reg.host()+"/missing/image:latest"); err == nil {
+		t.Error("expected error for missing repository")
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		imageName    string
+		expectedHost string
+		expectedPath string
+		expectedTag  string
+	}{
+		{imageName: "nginx", expectedHost: "registry-1.docker.io", expectedPath: "library/nginx", expectedTag: "latest"},
+		{imageName: "nginx:1.25", expectedHost: "registry-1.docker.io", expectedPath: "library/nginx", expectedTag: "1.25"},
+		{imageName: "user/app:dev", expectedHost: "registry-1.docker.io", expectedPath: "user/app", expectedTag: "dev"},
+		{imageName: "ghcr.io/org/app:v1", expectedHost: "ghcr.io", expectedPath: "org/app", expectedTag: "v1"},
+		{imageName: "registry.example.com:5000/app", expectedHost: "registry.example.com:5000", expectedPath: "app", expectedTag: "latest"},
+		{
+			imageName:    "alpine@sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844",
+			expectedHost: "registry-1.docker.io",
+			expectedPath: "library/alpine",
+			expectedTag:  "sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.imageName, func(t *testing.T) {
+			repo, tag, err := parseReference(tt.imageName)
+			if err != nil {
+				t.Fatalf("parseReference() error = %v", err)
+			}
+			if repo.host != tt.expectedHost || repo.path != tt.expectedPath || tag != tt.expectedTag {
+				t.Errorf("parseReference(%q) = %s/%s:%s, want %s/%s:%s",
+					tt.imageName, repo.host, repo.path, tag, tt.expectedHost, tt.expectedPath, tt.expectedTag)
+			}
+		})
+	}
+}
+
+func TestRegistryOf(t *testing.T) {
+	tests := map[string]string{
+		"nginx:latest":                 "docker.io",
+		"ghcr.io/example/app:1":        "ghcr.io",
+		"registry.local:5000/team/app": "registry.local:5000",
+		"Invalid:Reference":            "",
+	}
+	for image, expected := range tests {
+		if got := RegistryOf(image); got != expected {
+			t.Errorf("RegistryOf(%q) = %q, expected %q", image, got, expected)
+		}
+	}
+}
+
+func TestManifestDigestMatchesAny(t *testing.T) {
+	remote := &ManifestDigest{Digest: "sha256:index", PlatformDigest: "sha256:amd64"}
+
+	tests := []struct {
+		name        string
+		repoDigests []string
+		want        bool
+	}{
+		{name: "matches index digest", repoDigests: []string{"nginx@sha256:index"}, want: true},
+		{name: "matches platform digest", repoDigests: []string{"nginx@sha256:amd64"}, want: true},
+		{name: "stale digest", repoDigests: []string{"nginx@sha256:old"}, want: false},
+		{name: "no repo digests", repoDigests: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := remote.MatchesAny(tt.repoDigests); got != tt.want {
+				t.Errorf("MatchesAny(%v) = %v, want %v", tt.repoDigests, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package registry
+
+import (
+	"context"
+)
+
+// MockClient is a mock implementation of Resolver for testing
+type MockClient struct {
+	GetDigestFunc func(ctx context.Context, imageName string) (*ManifestDigest, error)
+	ListTagsFunc  func(ctx context.Context, imageName string) ([]string, error)
+}
+
+// GetDigest mocks resolving a remote digest
+func (m *MockClient) GetDigest(ctx context.Context, imageName string) (*ManifestDigest, error) {
+	if m.GetDigestFunc != nil {
+		return m.GetDigestFunc(ctx, imageName)
+	}
+	return &ManifestDigest{Digest: "sha256:mock-digest", MediaType: MediaTypeOCIManifest}, nil
+}
+
+// ListTags mocks listing a repository's tags
+func (m *MockClient) ListTags(ctx context.Context, imageName string) ([]string, error) {
+	if m.ListTagsFunc != nil {
+		return m.ListTagsFunc(ctx, imageName)
+	}
+	return nil, nil
+}
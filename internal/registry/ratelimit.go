@@ -0,0 +1,163 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
+)
+
+// rateLimitReserve is how much of a registry's remaining pull quota update
+// checks leave untouched for the pulls that apply updates
+const rateLimitReserve = 10
+
+// rateLimitBackoff is how long requests to a registry pause once its quota is
+// within the reserve, or after a 429 without a Retry-After header. The first
+// request afterwards refreshes the quota from the registry's headers.
+const rateLimitBackoff = 15 * time.Minute
+
+// ErrRateLimited is returned instead of querying a registry whose pull quota
+// is exhausted or within the reserve kept for pulls
+var ErrRateLimited = errors.New("registry rate limit reached")
+
+// RateLimit is a registry's pull quota as last reported by its RateLimit-Limit
+// and RateLimit-Remaining headers
+type RateLimit struct {
+	Registry    string        // Registry host, docker.io for Docker Hub
+	Limit       int           // Pulls allowed per window, 0 if not reported
+	Remaining   int           // Pulls left in the current window
+	Window      time.Duration // Length of the window, 0 if not reported
+	ObservedAt  time.Time     // When the registry last reported the quota
+	PausedUntil time.Time     // Requests to the registry are deferred until then
+}
+
+// Low reports whether the remaining quota is within the reserve kept for pulls
+func (l RateLimit) Low() bool {
+	return l.Remaining <= rateLimitReserve
+}
+
+// Paused reports whether requests to the registry are deferred at now
+func (l RateLimit) Paused(now time.Time) bool {
+	return now.Before(l.PausedUntil)
+}
+
+// RateLimits returns the quotas reported by every registry that sends rate
+// limit headers, sorted by registry. A nil client has none.
+func (c *Client) RateLimits() []RateLimit {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limits := make([]RateLimit, 0, len(c.limits))
+	for _, limit := range c.limits {
+		limits = append(limits, limit)
+	}
+	slices.SortFunc(limits, func(a, b RateLimit) int { return strings.Compare(a.Registry, b.Registry) })
+	return limits
+}
+
+// checkRateLimit returns ErrRateLimited while requests to host are paused
+func (c *Client) checkRateLimit(host string) error {
+	c.mu.Lock()
+	limit, ok := c.limits[registryName(host)]
+	c.mu.Unlock()
+
+	if ok && limit.Paused(time.Now()) {
+		return fmt.Errorf("%w for %s, checks resume at %s", ErrRateLimited, limit.Registry, limit.PausedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// observeRateLimit records the quota reported in a response from host and
+// pauses requests to it when the quota runs low or the registry answers 429
+func (c *Client) observeRateLimit(host string, resp *http.Response) {
+	remaining, window, ok := parseRateLimitHeader(resp.Header.Get("RateLimit-Remaining"))
+	tooMany := resp.StatusCode == http.StatusTooManyRequests
+	if !ok && !tooMany {
+		return
+	}
+
+	now := time.Now()
+	limit := RateLimit{
+		Registry:   registryName(host),
+		Remaining:  remaining,
+		Window:     window,
+		ObservedAt: now,
+	}
+	limit.Limit, _, _ = parseRateLimitHeader(resp.Header.Get("RateLimit-Limit"))
+
+	switch {
+	case tooMany:
+		limit.Remaining = 0
+		limit.PausedUntil = now.Add(retryAfter(resp.Header.Get("Retry-After"), now))
+	case limit.Low():
+		limit.PausedUntil = now.Add(rateLimitBackoff)
+	}
+
+	c.mu.Lock()
+	c.limits[limit.Registry] = limit
+	c.mu.Unlock()
+
+	metrics.RegistryRateLimitRemaining.WithLabelValues(limit.Registry).Set(float64(limit.Remaining))
+	if limit.Paused(now) {
+		c.logger.Warn("registry rate limit nearly exhausted, deferring update checks",
+			"registry", limit.Registry,
+			"remaining", limit.Remaining,
+			"limit", limit.Limit,
+			"paused_until", limit.PausedUntil,
+		)
+	}
+}
+
+// parseRateLimitHeader parses a quota header such as "76;w=21600" into the
+// count and the window it applies to
+func parseRateLimitHeader(header string) (int, time.Duration, bool) {
+	if header == "" {
+		return 0, 0, false
+	}
+	value, params, _ := strings.Cut(header, ";")
+	count, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	var window time.Duration
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key == "w" {
+			if seconds, err := strconv.Atoi(value); err == nil {
+				window = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return count, window, true
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date,
+// defaulting to rateLimitBackoff
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return rateLimitBackoff
+}
+
+// registryName returns the name a registry's quota is reported under; Docker
+// Hub's API host is reported as docker.io
+func registryName(host string) string {
+	if host == dockerHubRegistry {
+		return dockerHubDomain
+	}
+	return host
+}
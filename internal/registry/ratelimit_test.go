@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitTracking(t *testing.T) {
+	tests := []struct {
+		name            string
+		remaining       string
+		tooMany         bool
+		expectRemaining int
+		expectPaused    bool
+	}{
+		{name: "plenty left", remaining: "76;w=21600", expectRemaining: 76},
+		{name: "within reserve", remaining: "5;w=21600", expectRemaining: 5, expectPaused: true},
+		{name: "too many requests", tooMany: true, expectRemaining: 0, expectPaused: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := newFakeRegistry(t)
+			reg.remaining, reg.tooMany = tt.remaining, tt.tooMany
+			reg.addManifest("library/nginx", "latest", MediaTypeOCIManifest, []byte(`{}`))
+			client := reg.client()
+			image := reg.host() + "/library/nginx:latest"
+
+			_, err := client.GetDigest(context.Background(), image)
+			if tt.tooMany != errors.Is(err, ErrRateLimited) {
+				t.Fatalf("GetDigest() error = %v", err)
+			}
+
+			limits := client.RateLimits()
+			if len(limits) != 1 {
+				t.Fatalf("expected one rate limit, got %+v", limits)
+			}
+			limit := limits[0]
+			if limit.Registry != reg.host() || limit.Remaining != tt.expectRemaining {
+				t.Errorf("expected %d remaining for %s, got %+v", tt.expectRemaining, reg.host(), limit)
+			}
+			if limit.Paused(time.Now()) != tt.expectPaused {
+				t.Errorf("expected paused %v, got %+v", tt.expectPaused, limit)
+			}
+
+			// A paused registry is not queried again
+			requests := reg.headCalls
+			_, err = client.GetDigest(context.Background(), image)
+			if tt.expectPaused {
+				if !errors.Is(err, ErrRateLimited) {
+					t.Errorf("expected ErrRateLimited, got %v", err)
+				}
+				if reg.headCalls != requests {
+					t.Errorf("expected no request while paused, got %d", reg.headCalls-requests)
+				}
+			} else if err != nil {
+				t.Errorf("GetDigest() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitHeader(t *testing.T) {
+	tests := []struct {
+		header       string
+		expectCount  int
+		expectWindow time.Duration
+		expectOK     bool
+	}{
+		{header: "76;w=21600", expectCount: 76, expectWindow: 6 * time.Hour, expectOK: true},
+		{header: "100", expectCount: 100, expectOK: true},
+		{header: "0;w=60;comment=x", expectCount: 0, expectWindow: time.Minute, expectOK: true},
+		{header: "", expectOK: false},
+		{header: "many", expectOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			count, window, ok := parseRateLimitHeader(tt.header)
+			if ok != tt.expectOK || count != tt.expectCount || window != tt.expectWindow {
+				t.Errorf("parseRateLimitHeader(%q) = %d, %s, %v, expected %d, %s, %v",
+					tt.header, count, window, ok, tt.expectCount, tt.expectWindow, tt.expectOK)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		header   string
+		expected time.Duration
+	}{
+		{name: "seconds", header: "120", expected: 2 * time.Minute},
+		{name: "http date", header: now.Add(time.Hour).Format(http.TimeFormat), expected: time.Hour},
+		{name: "missing", header: "", expected: rateLimitBackoff},
+		{name: "past date", header: now.Add(-time.Hour).Format(http.TimeFormat), expected: rateLimitBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, now); got != tt.expected {
+				t.Errorf("retryAfter(%q) = %s, expected %s", tt.header, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"github.com/robfig/cron/v3"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// autoUpdateTick is how often the auto-updater evaluates pending updates.
+// Cron schedules have minute resolution, so there is no point going faster.
+const autoUpdateTick = time.Minute
+
+// autoUpdateTimeout bounds a single unattended update operation
+const autoUpdateTimeout = 10 * time.Minute
+
+// AutoUpdateResult describes one action taken by the auto-updater
+type AutoUpdateResult struct {
+	Host       string                  // Docker host running the group
+	GroupID    string                  // Container ID or compose project name
+	GroupName  string                  // Display name
+	Mode       services.AutoUpdateMode // Notify or enabled
+	Containers []models.ContainerInfo  // Containers with pending updates that triggered the action
+	Err        error                   // Update error, nil on success or for notify
+	Timestamp  time.Time               // When the action completed
+}
+
+// AutoUpdater applies updates found by the UpdateChecker to containers that
+// opted in through the bleedingedge.autoupdate label
+type AutoUpdater struct {
+	hosts    *docker.Hosts
+	cache    *services.UpdateCache
+	audit    *audit.Log
+	history  *history.Store
+	notifier *notify.Notifier
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	handled map[string]string // host/container ID -> latest digest already acted on
+}
+
+// NewAutoUpdater creates a new label-driven auto-updater that records the
+// updates it applies in the audit log, what they replaced in the history, and
+// notifies webhooks of their results
+func NewAutoUpdater(hosts *docker.Hosts, cache *services.UpdateCache, auditLog *audit.Log, historyStore *history.Store, notifier *notify.Notifier, logger *slog.Logger) *AutoUpdater {
+	return &AutoUpdater{
+		hosts:    hosts,
+		cache:    cache,
+		audit:    auditLog,
+		history:  historyStore,
+		notifier: notifier,
+		logger:   logger,
+		handled:  make(map[string]string),
+	}
+}
+
+// Run evaluates pending updates every minute until the context is cancelled
+func (a *AutoUpdater) Run(ctx context.Context) {
+	a.logger.Info("starting auto-updater")
+
+	ticker := time.NewTicker(autoUpdateTick)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			a.logger.Info("stopping auto-updater")
+			return
+		case now := <-ticker.C:
+			a.RunOnce(ctx, last, now)
+			last = now
+		}
+	}
+}
+
+// RunOnce acts on every container whose cached check shows a new digest and
+// whose schedule (if any) had a run due in the window (since, now]
+func (a *AutoUpdater) RunOnce(ctx context.Context, since, now time.Time) []AutoUpdateResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	groups, err := listGroups(listCtx, a.hosts, a.logger)
+	cancel()
+	if err != nil {
+		a.logger.Error("failed to list containers for auto-update",
+			"error", err,
+			"operation", "auto_update",
+		)
+		return nil
+	}
+	a.cache.Apply(groups)
+
+	var results []AutoUpdateResult
+	for i := range groups {
+		group := &groups[i]
+
+		var notify, update []models.ContainerInfo
+		for _, container := range group.Containers {
+			if !container.HasUpdate || a.handled[handledKey(container)] == updateTarget(container) {
+				continue
+			}
+
+			policy, err := services.GetAutoUpdatePolicy(container.Labels)
+			if err != nil {
+				a.logger.Warn("ignoring invalid auto-update label",
+					"container", container.Name,
+					"error", err,
+				)
+				continue
+			}
+			if policy.Mode == services.AutoUpdateOff || !a.isDue(container, policy, since, now) {
+				continue
+			}
+
+			if policy.Mode == services.AutoUpdateNotify {
+				notify = append(notify, container)
+			} else {
+				update = append(update, container)
+			}
+		}
+
+		if len(notify) > 0 {
+			for _, container := range notify {
+				a.logger.Info("update available",
+					"container", container.Name,
+					"image", container.Image,
+					"current_digest", container.ImageDigest,
+					"latest_digest", container.LatestDigest,
+					"operation", "auto_update",
+				)
+				a.handled[handledKey(container)] = updateTarget(container)
+			}
+			results = append(results, AutoUpdateResult{
+				Host:       group.Host,
+				GroupID:    group.ID,
+				GroupName:  group.Name,
+				Mode:       services.AutoUpdateNotify,
+				Containers: notify,
+				Timestamp:  time.Now(),
+			})
+		}
+
+		if len(update) > 0 {
+			results = append(results, a.update(ctx, group, update))
+		}
+	}
+
+	return results
+}
+
+// update applies the update for a group and records the digests or tags acted
+// on so a failing update is not retried until a newer one appears
+func (a *AutoUpdater) update(ctx context.Context, group *models.ContainerGroup, containers []models.ContainerInfo) AutoUpdateResult {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "scheduler.auto_update",
+		attribute.String("bleedingedge.host", group.Host),
+		attribute.String("bleedingedge.group", group.Name),
+		attribute.String("bleedingedge.group_type", string(group.Type)),
+	)
+	defer span.End()
+	a.logger.InfoContext(ctx, "starting automatic update",
+		"docker_host", group.Host,
+		"group", group.Name,
+		"type", group.Type,
+		"container_count", len(containers),
+		"operation", "auto_update",
+	)
+
+	updateCtx, cancel := context.WithTimeout(ctx, autoUpdateTimeout)
+	defer cancel()
+
+	names := make([]string, 0, len(containers))
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+	host, _ := a.hosts.Get(group.Host)
+	client := host.Client
+	snapshots := services.SnapshotContainers(updateCtx, client, containers)
+
+	var err error
+	if group.Type == models.GroupTypeCompose {
+		// Only the opted-in services are recreated, not the whole project
+		err = services.UpdateComposeProject(updateCtx, client, group.Name, group.WorkingDir, containers)
+	} else {
+		err = services.UpdateStandaloneContainerTo(updateCtx, client, group.ID, services.TrackedImage(containers[0]))
+	}
+	tracing.RecordError(span, err)
+
+	for _, container := range containers {
+		a.handled[handledKey(container)] = updateTarget(container)
+	}
+
+	before := make(map[string]string)
+	after := services.ImageDigests(updateCtx, client, names)
+	for i := range snapshots {
+		before[snapshots[i].Name] = snapshots[i].ImageDigest
+		snapshots[i].NewDigest = after[snapshots[i].Name]
+	}
+
+	entry := history.Entry{
+		Time:       start,
+		User:       audit.SystemUser,
+		Operation:  audit.OperationAutoUpdate,
+		Host:       group.Host,
+		Target:     group.Name,
+		GroupType:  group.Type,
+		Containers: snapshots,
+	}
+	if entry.Changed() {
+		if _, historyErr := a.history.Add(entry); historyErr != nil {
+			a.logger.Error("failed to write history entry",
+				"operation", entry.Operation,
+				"target", entry.Target,
+				"error", historyErr,
+			)
+		}
+	}
+
+	record := audit.Record{
+		Time:       start,
+		User:       audit.SystemUser,
+		Operation:  audit.OperationAutoUpdate,
+		Host:       group.Host,
+		Target:     group.Name,
+		TargetID:   group.ID,
+		Images:     audit.ImageChanges(containers, before, after),
+		DurationMS: time.Since(start).Milliseconds(),
+		Result:     audit.ResultSuccess,
+	}
+
+	var rollbackErr *services.RollbackError
+	rolledBack := errors.As(err, &rollbackErr) && rollbackErr.RolledBack
+	if err != nil {
+		record.Result = audit.ResultFailure
+		errResp := services.NewErrorResponse(audit.OperationAutoUpdate, group.Name, err)
+		record.Error = &errResp
+	}
+	if _, auditErr := a.audit.Append(record); auditErr != nil {
+		a.logger.Error("failed to write audit record",
+			"operation", record.Operation,
+			"target", record.Target,
+			"error", auditErr,
+		)
+	}
+	metrics.ObserveOperation(record.Operation, string(record.Result), time.Since(start))
+	if event, ok := notify.FromRecord(record); ok {
+		a.notifier.Notify(event)
+	}
+
+	if err != nil {
+		a.logger.Error("automatic update failed",
+			"group", group.Name,
+			"operation", "auto_update",
+			"error", err,
+			"rolled_back", rolledBack,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	} else {
+		a.logger.Info("automatic update completed",
+			"group", group.Name,
+			"operation", "auto_update",
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+
+	return AutoUpdateResult{
+		Host:       group.Host,
+		GroupID:    group.ID,
+		GroupName:  group.Name,
+		Mode:       services.AutoUpdateEnabled,
+		Containers: containers,
+		Err:        err,
+		Timestamp:  time.Now(),
+	}
+}
+
+// handledKey identifies a container across hosts; IDs are only unique per daemon
+func handledKey(container models.ContainerInfo) string {
+	return container.Host + "/" + container.ID
+}
+
+// updateTarget identifies the update available to a container: the newer tag it
+// tracks, or else the new digest of its current tag
+func updateTarget(container models.ContainerInfo) string {
+	if container.LatestTag != "" {
+		return container.LatestTag
+	}
+	return container.LatestDigest
+}
+
+// isDue reports whether the container's schedule allows acting now. Containers
+// without a schedule are always due.
+func (a *AutoUpdater) isDue(container models.ContainerInfo, policy services.AutoUpdatePolicy, since, now time.Time) bool {
+	if policy.Schedule == "" {
+		return true
+	}
+
+	schedule, err := cron.ParseStandard(policy.Schedule)
+	if err != nil {
+		a.logger.Warn("ignoring invalid auto-update schedule label",
+			"container", container.Name,
+			"schedule", policy.Schedule,
+			"error", err,
+		)
+		return false
+	}
+
+	return !schedule.Next(since).After(now)
+}
@@ -0,0 +1,245 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/bleeding-edge/bleeding-edge/internal/testutil"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// newAutoUpdateFixture returns a mock client listing the given containers, a
+// cache marking all of them as having updates, and a counter of recreations
+func newAutoUpdateFixture(containers []types.Container) (*docker.MockClient, *services.UpdateCache, *int) {
+	created := 0
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return containers, nil
+		},
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{
+					Name:       "/" + id,
+					State:      &types.ContainerState{Running: true, Status: "running"},
+					HostConfig: &container.HostConfig{},
+				},
+				Config: &container.Config{
+					Image:  "nginx:latest",
+					Labels: map[string]string{services.LabelVerifyWindow: "0s"},
+				},
+			}, nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			created++
+			return "new-" + name, nil
+		},
+	}
+
+	var infos []models.ContainerInfo
+	for _, c := range containers {
+		infos = append(infos, models.ContainerInfo{
+			ID:           c.ID,
+			Host:         docker.DefaultHostName,
+			Image:        c.Image,
+			ImageDigest:  "sha256:old",
+			LatestDigest: "sha256:new",
+			HasUpdate:    true,
+		})
+	}
+	cache := services.NewUpdateCache()
+	cache.Store([]models.ContainerGroup{{Containers: infos}}, time.Now(), time.Second)
+
+	return mockClient, cache, &created
+}
+
+// newTestNotifier returns a notifier posting to a local webhook receiver and a
+// function returning the events received so far
+func newTestNotifier(t *testing.T) (*notify.Notifier, func() []notify.Event) {
+	t.Helper()
+	var mu sync.Mutex
+	var events []notify.Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("webhook body is not an event: %v", err)
+		}
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	notifier, err := notify.New([]notify.WebhookConfig{{URL: server.URL}}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("failed to create notifier: %v", err)
+	}
+	return notifier, func() []notify.Event {
+		notifier.Wait()
+		mu.Lock()
+		defer mu.Unlock()
+		return events
+	}
+}
+
+func TestAutoUpdaterHonorsLabels(t *testing.T) {
+	mockClient, cache, created := newAutoUpdateFixture([]types.Container{
+		{ID: "enabled", Names: []string{"/enabled"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{services.LabelAutoUpdate: "true"}},
+		{ID: "notify", Names: []string{"/notify"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{services.LabelAutoUpdate: "notify"}},
+		{ID: "off", Names: []string{"/off"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{services.LabelAutoUpdate: "off"}},
+		{ID: "unlabeled", Names: []string{"/unlabeled"}, Image: "nginx:latest", State: "running"},
+	})
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	auditLog := testutil.OpenAuditLog(t)
+	historyStore := testutil.OpenHistory(t)
+	notifier, received := newTestNotifier(t)
+	updater := NewAutoUpdater(docker.SingleHost(mockClient), cache, auditLog, historyStore, notifier, logger)
+
+	now := time.Now()
+	results := updater.RunOnce(context.Background(), now.Add(-time.Minute), now)
+
+	if *created != 1 {
+		t.Errorf("expected exactly one container to be recreated, got %d", *created)
+	}
+
+	modes := make(map[string]services.AutoUpdateMode)
+	for _, result := range results {
+		if result.Err != nil {
+			t.Errorf("unexpected error for %s: %v", result.GroupID, result.Err)
+		}
+		modes[result.GroupID] = result.Mode
+	}
+	if len(modes) != 2 || modes["enabled"] != services.AutoUpdateEnabled || modes["notify"] != services.AutoUpdateNotify {
+		t.Errorf("unexpected results: %+v", modes)
+	}
+
+	// Only the applied update is an operation worth auditing
+	records, err := auditLog.List(audit.Filter{})
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if len(records) != 1 || records[0].Operation != audit.OperationAutoUpdate || records[0].User != audit.SystemUser ||
+		records[0].Target != "enabled" || records[0].Result != audit.ResultSuccess {
+		t.Errorf("expected one successful auto-update record for enabled, got %+v", records)
+	}
+
+	// What the update replaced is kept so it can be reverted
+	entries, err := historyStore.List(docker.DefaultHostName, "enabled")
+	if err != nil {
+		t.Fatalf("failed to read history: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Containers[0].ImageDigest != "sha256:old" || entries[0].Containers[0].Params == nil {
+		t.Errorf("expected one history entry with the previous digest and configuration, got %+v", entries)
+	}
+
+	// Webhooks hear about the applied update only
+	events := received()
+	if len(events) != 1 || events[0].Type != notify.EventUpdateSucceeded || events[0].Operation != audit.OperationAutoUpdate ||
+		events[0].Target != "enabled" || events[0].Containers[0].CurrentDigest != "sha256:old" {
+		t.Errorf("expected one update.succeeded event for enabled, got %+v", events)
+	}
+
+	// The same digests must not be acted on twice
+	results = updater.RunOnce(context.Background(), now, now.Add(time.Minute))
+	if len(results) != 0 || *created != 1 {
+		t.Errorf("expected no repeat actions, got %d results and %d recreations", len(results), *created)
+	}
+}
+
+func TestAutoUpdaterRecordsVerificationLogs(t *testing.T) {
+	mockClient, cache, _ := newAutoUpdateFixture([]types.Container{
+		{ID: "crashy", Names: []string{"/crashy"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{services.LabelAutoUpdate: "true"}},
+	})
+	inspect := mockClient.InspectContainerFunc
+	mockClient.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+		containerJSON, err := inspect(ctx, id)
+		if id == "new-crashy" {
+			containerJSON.State = &types.ContainerState{Status: "exited", ExitCode: 1}
+		}
+		return containerJSON, err
+	}
+	mockClient.ContainerLogsFunc = func(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+		return "panic: missing config\n", nil
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	auditLog := testutil.OpenAuditLog(t)
+	notifier, received := newTestNotifier(t)
+	updater := NewAutoUpdater(docker.SingleHost(mockClient), cache, auditLog, testutil.OpenHistory(t), notifier, logger)
+
+	now := time.Now()
+	results := updater.RunOnce(context.Background(), now.Add(-time.Minute), now)
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected the auto-update to fail, got %+v", results)
+	}
+
+	records, err := auditLog.List(audit.Filter{})
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if len(records) != 1 || records[0].Result != audit.ResultFailure || records[0].Error == nil {
+		t.Fatalf("expected one failed auto-update record, got %+v", records)
+	}
+	recordErr := records[0].Error
+	if !recordErr.RolledBack || !strings.HasSuffix(recordErr.Message, "The original container was restored.") {
+		t.Errorf("expected a rolled back message, got %+v", recordErr)
+	}
+	if !strings.Contains(recordErr.Details, "Container logs:\npanic: missing config") {
+		t.Errorf("expected the verification logs in the details, got %q", recordErr.Details)
+	}
+
+	// Notifications carry the same message and details
+	events := received()
+	if len(events) != 1 || events[0].Error != recordErr.Message || events[0].Details != recordErr.Details {
+		t.Errorf("expected one failure event matching the audit record, got %+v", events)
+	}
+}
+
+func TestAutoUpdaterHonorsSchedule(t *testing.T) {
+	mockClient, cache, created := newAutoUpdateFixture([]types.Container{
+		{ID: "nightly", Names: []string{"/nightly"}, Image: "nginx:latest", State: "running",
+			Labels: map[string]string{
+				services.LabelAutoUpdate: "true",
+				services.LabelSchedule:   "0 3 * * *",
+			}},
+	})
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, _ := newTestNotifier(t)
+	updater := NewAutoUpdater(docker.SingleHost(mockClient), cache, testutil.OpenAuditLog(t), testutil.OpenHistory(t), notifier, logger)
+
+	// Outside the maintenance window nothing happens
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	if results := updater.RunOnce(context.Background(), noon.Add(-time.Minute), noon); len(results) != 0 {
+		t.Errorf("expected no action outside the schedule, got %d results", len(results))
+	}
+
+	// The tick covering 03:00 applies the update
+	three := time.Date(2024, 1, 2, 3, 0, 0, 0, time.Local)
+	if results := updater.RunOnce(context.Background(), three.Add(-time.Minute), three); len(results) != 1 {
+		t.Errorf("expected one action inside the schedule, got %d results", len(results))
+	}
+	if *created != 1 {
+		t.Errorf("expected container to be recreated once, got %d", *created)
+	}
+}
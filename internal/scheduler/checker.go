@@ -0,0 +1,194 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+)
+
+// UpdateChecker periodically checks all containers on every host for updates
+// in the background, stores the results in an UpdateCache and notifies webhooks of
+// newly available updates
+type UpdateChecker struct {
+	hosts    *docker.Hosts
+	resolver registry.Resolver
+	cache    *services.UpdateCache
+	schedule Schedule
+	timeout  time.Duration
+	notifier *notify.Notifier
+	logger   *slog.Logger
+
+	parallelism int // Containers checked at once per host
+
+	mu       sync.Mutex // serializes check runs
+	running  atomic.Bool
+	notified map[string]string // host/container ID -> latest digest already notified
+}
+
+// NewUpdateChecker creates a new background update checker
+func NewUpdateChecker(hosts *docker.Hosts, resolver registry.Resolver, cache *services.UpdateCache, schedule Schedule, timeout time.Duration, notifier *notify.Notifier, logger *slog.Logger) *UpdateChecker {
+	return &UpdateChecker{
+		hosts:    hosts,
+		resolver: resolver,
+		cache:    cache,
+		schedule: schedule,
+		timeout:  timeout,
+		notifier: notifier,
+		logger:   logger,
+		notified: make(map[string]string),
+
+		parallelism: services.DefaultCheckParallelism,
+	}
+}
+
+// WithParallelism sets how many containers of each host are checked at once
+func (c *UpdateChecker) WithParallelism(parallelism int) *UpdateChecker {
+	c.parallelism = parallelism
+	return c
+}
+
+// imageTimeout derives the time allowed for each lookup of an image (its tag
+// list, its digest, the local image) from the timeout of the whole check, so
+// one slow registry cannot use up the check
+func imageTimeout(checkTimeout time.Duration) time.Duration {
+	return max(checkTimeout/5, min(checkTimeout, 30*time.Second))
+}
+
+// Run performs an initial check and then checks on the configured schedule
+// until the context is cancelled
+func (c *UpdateChecker) Run(ctx context.Context) {
+	c.logger.Info("starting background update checker")
+
+	for {
+		if err := c.CheckNow(ctx); err != nil {
+			c.logger.Warn("scheduled update check failed",
+				"error", err,
+				"operation", "check_updates",
+			)
+		}
+
+		next := c.schedule.Next(time.Now())
+		c.logger.Debug("next update check scheduled", "next_run", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.logger.Info("stopping background update checker")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// CheckNow runs an update check immediately. If a check is already in
+// progress, it waits for that check to finish instead of starting another.
+func (c *UpdateChecker) CheckNow(ctx context.Context) error {
+	requested := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Another caller completed a check while we were waiting
+	if c.cache.LastChecked().After(requested) {
+		return nil
+	}
+
+	c.running.Store(true)
+	defer c.running.Store(false)
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "scheduler.update_check")
+	defer span.End()
+	checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	groups, err := listGroups(checkCtx, c.hosts, c.logger)
+	if err != nil {
+		metrics.UpdateChecks.WithLabelValues("failure").Inc()
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to list containers for update check: %w", err)
+	}
+
+	// Containers that could not be checked are logged by CheckAllUpdates and
+	// keep no update; the results of the others are still stored
+	opts := services.CheckOptions{Parallelism: c.parallelism, ImageTimeout: imageTimeout(c.timeout)}
+	failed := services.FailedChecks(services.CheckAllUpdates(checkCtx, c.hosts, c.resolver, groups, opts))
+
+	duration := time.Since(start)
+	completed := time.Now()
+	c.cache.Store(groups, completed, duration)
+	metrics.ObserveCheck(groups, completed, duration)
+	c.notifyAvailable(groups)
+
+	c.logger.InfoContext(ctx, "update check completed",
+		"group_count", len(groups),
+		"failed_containers", len(failed),
+		"duration_ms", duration.Milliseconds(),
+	)
+	return nil
+}
+
+// listGroups lists the container groups on every host. Unreachable hosts are
+// logged and skipped so the others are still handled; listing only fails when
+// no host could be reached.
+func listGroups(ctx context.Context, hosts *docker.Hosts, logger *slog.Logger) ([]models.ContainerGroup, error) {
+	groups, hostErrors := services.GetAllContainerGroups(ctx, hosts)
+	if len(hostErrors) == hosts.Len() {
+		return nil, errors.Join(slices.Collect(maps.Values(hostErrors))...)
+	}
+	for host, err := range hostErrors {
+		logger.WarnContext(ctx, "skipping unreachable Docker host",
+			"docker_host", host,
+			"error", err,
+		)
+	}
+	return groups, nil
+}
+
+// notifyAvailable sends one update.available event per group with containers
+// whose latest digest has not been notified before. Must be called with mu held.
+func (c *UpdateChecker) notifyAvailable(groups []models.ContainerGroup) {
+	for _, group := range groups {
+		event := notify.Event{
+			Type:   notify.EventUpdateAvailable,
+			Time:   time.Now(),
+			Host:   group.Host,
+			Target: group.Name,
+		}
+		for _, container := range group.Containers {
+			if !container.HasUpdate || c.notified[handledKey(container)] == container.LatestDigest {
+				continue
+			}
+			c.notified[handledKey(container)] = container.LatestDigest
+			event.Containers = append(event.Containers, notify.Container{
+				Name:          container.Name,
+				Image:         container.Image,
+				CurrentDigest: container.ImageDigest,
+				NewDigest:     container.LatestDigest,
+			})
+		}
+		if len(event.Containers) > 0 {
+			c.notifier.Notify(event)
+		}
+	}
+}
+
+// Running reports whether an update check is currently in progress
+func (c *UpdateChecker) Running() bool {
+	return c.running.Load()
+}
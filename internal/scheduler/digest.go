@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+)
+
+// digestTimeout bounds listing containers and sending one digest
+const digestTimeout = 2 * time.Minute
+
+// DigestSender emails a digest of the pending updates found by the
+// UpdateChecker on a schedule
+type DigestSender struct {
+	hosts    *docker.Hosts
+	cache    *services.UpdateCache
+	mailer   *notify.Mailer
+	schedule Schedule
+	logger   *slog.Logger
+}
+
+// NewDigestSender creates a new scheduled digest sender
+func NewDigestSender(hosts *docker.Hosts, cache *services.UpdateCache, mailer *notify.Mailer, schedule Schedule, logger *slog.Logger) *DigestSender {
+	return &DigestSender{
+		hosts:    hosts,
+		cache:    cache,
+		mailer:   mailer,
+		schedule: schedule,
+		logger:   logger,
+	}
+}
+
+// Run sends a digest at every scheduled time until the context is cancelled
+func (d *DigestSender) Run(ctx context.Context) {
+	d.logger.Info("starting update digest emails")
+
+	for {
+		next := d.schedule.Next(time.Now())
+		d.logger.Debug("next update digest scheduled", "next_run", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.logger.Info("stopping update digest emails")
+			return
+		case <-timer.C:
+		}
+
+		if err := d.SendNow(ctx); err != nil {
+			d.logger.Error("failed to send update digest",
+				"error", err,
+				"operation", "send_digest",
+			)
+		}
+	}
+}
+
+// SendNow emails the containers the most recent check found updates for.
+// Nothing is sent when every container is up to date.
+func (d *DigestSender) SendNow(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, digestTimeout)
+	defer cancel()
+
+	groups, err := listGroups(ctx, d.hosts, d.logger)
+	if err != nil {
+		return fmt.Errorf("failed to list containers for digest: %w", err)
+	}
+	d.cache.Apply(groups)
+
+	sent, err := d.mailer.SendDigest(ctx, groups, d.cache.LastChecked())
+	if err != nil {
+		return err
+	}
+	if sent {
+		d.logger.Info("update digest sent", "group_count", len(groups))
+	} else {
+		d.logger.Debug("no pending updates, digest skipped")
+	}
+	return nil
+}
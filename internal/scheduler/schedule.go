@@ -0,0 +1,46 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule determines when the next run should happen
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+// intervalSchedule runs at a fixed interval after the previous run
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+// Next returns the time one interval after t
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Add(s.interval)
+}
+
+// ParseSchedule parses either a Go duration ("30m", "6h") or a standard
+// five-field cron expression ("0 */6 * * *", "@daily")
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("schedule cannot be empty")
+	}
+
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval < time.Minute {
+			return nil, fmt.Errorf("schedule interval %s is too short (minimum 1m)", interval)
+		}
+		return intervalSchedule{interval: interval}, nil
+	}
+
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: must be a duration like 6h or a cron expression: %w", spec, err)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,299 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/notify"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/bleeding-edge/bleeding-edge/internal/services"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, 1, 1, 10, 17, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		spec        string
+		expectedRun time.Time
+		expectError bool
+	}{
+		{name: "duration", spec: "30m", expectedRun: base.Add(30 * time.Minute)},
+		{name: "cron expression", spec: "0 */6 * * *", expectedRun: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "cron descriptor", spec: "@daily", expectedRun: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "interval too short", spec: "10s", expectError: true},
+		{name: "empty", spec: "", expectError: true},
+		{name: "garbage", spec: "every tuesday", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("ParseSchedule(%q) error = %v, expectError %v", tt.spec, err, tt.expectError)
+			}
+			if tt.expectError {
+				return
+			}
+			if next := schedule.Next(base); !next.Equal(tt.expectedRun) {
+				t.Errorf("expected next run %v, got %v", tt.expectedRun, next)
+			}
+		})
+	}
+}
+
+func TestUpdateCheckerPopulatesCache(t *testing.T) {
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+			}, nil
+		},
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+
+	cache := services.NewUpdateCache()
+	schedule, _ := ParseSchedule("1h")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, _ := newTestNotifier(t)
+	checker := NewUpdateChecker(docker.SingleHost(mockClient), mockResolver, cache, schedule, time.Minute, notifier, logger)
+
+	if err := checker.CheckNow(context.Background()); err != nil {
+		t.Fatalf("CheckNow() error = %v", err)
+	}
+
+	if cache.LastChecked().IsZero() {
+		t.Fatal("expected cache to record the check time")
+	}
+	result, ok := cache.Get(docker.DefaultHostName, "container1")
+	if !ok {
+		t.Fatal("expected cached result for container1")
+	}
+	if !result.HasUpdate || result.LatestDigest != "sha256:new" {
+		t.Errorf("unexpected cached result: %+v", result)
+	}
+}
+
+func TestUpdateCheckerChecksEveryHost(t *testing.T) {
+	host := func(digest string) *docker.MockClient {
+		return &docker.MockClient{
+			ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+				return []types.Container{
+					{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+				}, nil
+			},
+			InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+				return image.InspectResponse{RepoDigests: []string{"nginx@" + digest}}, nil
+			},
+		}
+	}
+	unreachable := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return nil, errors.New("connection refused")
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+
+	// The same container ID on two daemons is tracked separately, and an
+	// unreachable daemon does not fail the check of the others
+	hosts := docker.NewHosts(
+		docker.Host{Name: "local", Client: host("sha256:old")},
+		docker.Host{Name: "edge", Client: host("sha256:new")},
+		docker.Host{Name: "offline", Client: unreachable},
+	)
+	cache := services.NewUpdateCache()
+	schedule, _ := ParseSchedule("1h")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, _ := newTestNotifier(t)
+	checker := NewUpdateChecker(hosts, mockResolver, cache, schedule, time.Minute, notifier, logger)
+
+	if err := checker.CheckNow(context.Background()); err != nil {
+		t.Fatalf("CheckNow() error = %v", err)
+	}
+	if result, ok := cache.Get("local", "container1"); !ok || !result.HasUpdate {
+		t.Errorf("expected an update on local, got %+v (%v)", result, ok)
+	}
+	if result, ok := cache.Get("edge", "container1"); !ok || result.HasUpdate {
+		t.Errorf("expected edge to be up to date, got %+v (%v)", result, ok)
+	}
+
+	// Only when no host can be reached does the check fail
+	checker = NewUpdateChecker(docker.NewHosts(docker.Host{Name: "offline", Client: unreachable}), mockResolver, services.NewUpdateCache(), schedule, time.Minute, notifier, logger)
+	if err := checker.CheckNow(context.Background()); err == nil {
+		t.Error("expected the check to fail without any reachable host")
+	}
+}
+
+func TestUpdateCheckerNotifiesNewDigests(t *testing.T) {
+	var latest atomic.Value
+	latest.Store("sha256:new")
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+				{ID: "container2", Names: []string{"/redis"}, Image: "redis:7", State: "running"},
+			}, nil
+		},
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			if imageName == "redis:7" {
+				return &registry.ManifestDigest{Digest: "sha256:old"}, nil
+			}
+			return &registry.ManifestDigest{Digest: latest.Load().(string)}, nil
+		},
+	}
+
+	cache := services.NewUpdateCache()
+	schedule, _ := ParseSchedule("1h")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, received := newTestNotifier(t)
+	checker := NewUpdateChecker(docker.SingleHost(mockClient), mockResolver, cache, schedule, time.Minute, notifier, logger)
+
+	// The same digest is only notified once; a newer one is notified again
+	for _, digest := range []string{"sha256:new", "sha256:new", "sha256:newer"} {
+		latest.Store(digest)
+		if err := checker.CheckNow(context.Background()); err != nil {
+			t.Fatalf("CheckNow() error = %v", err)
+		}
+	}
+
+	events := received()
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	for i, expected := range []string{"sha256:new", "sha256:newer"} {
+		event := events[i]
+		if event.Type != notify.EventUpdateAvailable || event.Target != "nginx" || len(event.Containers) != 1 ||
+			event.Containers[0].CurrentDigest != "sha256:old" || event.Containers[0].NewDigest != expected {
+			t.Errorf("unexpected event %d: %+v", i, event)
+		}
+	}
+}
+
+func TestUpdateCheckerCoalescesConcurrentChecks(t *testing.T) {
+	var listCalls atomic.Int32
+	release := make(chan struct{})
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			if listCalls.Add(1) == 1 {
+				<-release
+			}
+			return []types.Container{}, nil
+		},
+	}
+
+	cache := services.NewUpdateCache()
+	schedule, _ := ParseSchedule("1h")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, _ := newTestNotifier(t)
+	checker := NewUpdateChecker(docker.SingleHost(mockClient), &registry.MockClient{}, cache, schedule, time.Minute, notifier, logger)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checker.CheckNow(context.Background())
+	}()
+
+	// Wait until the first check is in progress before requesting another
+	for !checker.Running() {
+		time.Sleep(time.Millisecond)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		checker.CheckNow(context.Background())
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// The second request waits for the in-flight check instead of starting its own
+	if calls := listCalls.Load(); calls != 1 {
+		t.Errorf("expected 1 check, got %d", calls)
+	}
+}
+
+func TestUpdateCheckerStoresPartialResults(t *testing.T) {
+	mockClient := &docker.MockClient{
+		ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+			return []types.Container{
+				{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"},
+				{ID: "container2", Names: []string{"/private"}, Image: "registry.example.com/private:latest", State: "running"},
+			}, nil
+		},
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			if imageName != "nginx:latest" {
+				return nil, errors.New("unauthorized")
+			}
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+
+	cache := services.NewUpdateCache()
+	schedule, _ := ParseSchedule("1h")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	notifier, _ := newTestNotifier(t)
+	checker := NewUpdateChecker(docker.SingleHost(mockClient), mockResolver, cache, schedule, time.Minute, notifier, logger).
+		WithParallelism(1)
+
+	// One container failing its check does not fail the others
+	if err := checker.CheckNow(context.Background()); err != nil {
+		t.Fatalf("CheckNow() error = %v", err)
+	}
+	if result, ok := cache.Get(docker.DefaultHostName, "container1"); !ok || !result.HasUpdate {
+		t.Errorf("expected an update for container1, got %+v", result)
+	}
+	if result, ok := cache.Get(docker.DefaultHostName, "container2"); !ok || result.HasUpdate {
+		t.Errorf("expected no update for the failed container2, got %+v", result)
+	}
+}
+
+func TestImageTimeout(t *testing.T) {
+	tests := []struct {
+		checkTimeout time.Duration
+		expected     time.Duration
+	}{
+		{checkTimeout: 5 * time.Minute, expected: time.Minute},
+		{checkTimeout: time.Minute, expected: 30 * time.Second},
+		{checkTimeout: 10 * time.Second, expected: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.checkTimeout.String(), func(t *testing.T) {
+			if got := imageTimeout(tt.checkTimeout); got != tt.expected {
+				t.Errorf("imageTimeout(%s) = %s, expected %s", tt.checkTimeout, got, tt.expected)
+			}
+		})
+	}
+}
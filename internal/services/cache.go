@@ -0,0 +1,117 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// UpdateCache stores the most recent update check result for each container
+// so pages can render without waiting on registry lookups
+type UpdateCache struct {
+	mu          sync.RWMutex
+	results     map[string]models.UpdateCheckResult
+	lastChecked time.Time
+	duration    time.Duration
+}
+
+// NewUpdateCache creates an empty update cache
+func NewUpdateCache() *UpdateCache {
+	return &UpdateCache{
+		results: make(map[string]models.UpdateCheckResult),
+	}
+}
+
+// Store replaces the cached results with those from a completed check. A
+// container whose check was deferred keeps the digests and update found by
+// its previous check.
+func (c *UpdateCache) Store(groups []models.ContainerGroup, checkedAt time.Time, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results := make(map[string]models.UpdateCheckResult)
+	for _, group := range groups {
+		for _, container := range group.Containers {
+			key := cacheKey(container.Host, container.ID)
+			result := models.UpdateCheckResult{
+				ContainerID:  container.ID,
+				Image:        container.Image,
+				ImageDigest:  container.ImageDigest,
+				LatestDigest: container.LatestDigest,
+				LatestTag:    container.LatestTag,
+				HasUpdate:    container.HasUpdate,
+				CheckStatus:  container.CheckStatus,
+				CheckError:   container.CheckError,
+				CheckedAt:    checkedAt,
+			}
+			if previous, ok := c.results[key]; ok && result.CheckStatus == models.CheckStatusDeferred && previous.Image == result.Image {
+				result.ImageDigest = previous.ImageDigest
+				result.LatestDigest = previous.LatestDigest
+				result.LatestTag = previous.LatestTag
+				result.HasUpdate = previous.HasUpdate
+				result.CheckedAt = previous.CheckedAt
+			}
+			results[key] = result
+		}
+	}
+
+	c.results = results
+	c.lastChecked = checkedAt
+	c.duration = duration
+}
+
+// Apply copies cached results onto freshly listed groups and recomputes the
+// group-level flags. Containers that have not been checked yet are left as-is.
+func (c *UpdateCache) Apply(groups []models.ContainerGroup) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for i := range groups {
+		group := &groups[i]
+		for j := range group.Containers {
+			container := &group.Containers[j]
+			result, ok := c.results[cacheKey(container.Host, container.ID)]
+			if !ok {
+				continue
+			}
+			container.ImageDigest = result.ImageDigest
+			container.LatestDigest = result.LatestDigest
+			container.LatestTag = result.LatestTag
+			container.HasUpdate = result.HasUpdate
+			container.CheckStatus = result.CheckStatus
+			container.CheckError = result.CheckError
+			container.CheckedAt = result.CheckedAt
+		}
+	}
+
+	summarizeGroups(groups)
+}
+
+// Get returns the cached result for a container on a host
+func (c *UpdateCache) Get(host, containerID string) (models.UpdateCheckResult, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result, ok := c.results[cacheKey(host, containerID)]
+	return result, ok
+}
+
+// cacheKey identifies a container across hosts; IDs are only unique per daemon
+func cacheKey(host, containerID string) string {
+	return host + "/" + containerID
+}
+
+// LastChecked returns when the most recent check completed, or the zero time
+// if no check has completed yet
+func (c *UpdateCache) LastChecked() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastChecked
+}
+
+// LastDuration returns how long the most recent check took
+func (c *UpdateCache) LastDuration() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.duration
+}
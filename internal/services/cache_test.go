@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+func TestUpdateCacheApply(t *testing.T) {
+	checkedAt := time.Now().Add(-5 * time.Minute)
+	cache := NewUpdateCache()
+
+	if !cache.LastChecked().IsZero() {
+		t.Fatal("expected empty cache to have zero LastChecked")
+	}
+
+	cache.Store([]models.ContainerGroup{
+		{
+			ID: "app",
+			Containers: []models.ContainerInfo{
+				{ID: "web", Image: "nginx:latest", ImageDigest: "sha256:old", LatestDigest: "sha256:new", HasUpdate: true},
+				{ID: "db", Image: "postgres:16", ImageDigest: "sha256:same", LatestDigest: "sha256:same", LatestTag: "17"},
+				{ID: "cache", Image: "ghcr.io/team/cache:1", CheckStatus: models.CheckStatusError, CheckError: "registry returned 401 Unauthorized"},
+			},
+		},
+	}, checkedAt, 2*time.Second)
+
+	// Freshly listed groups carry no update information until the cache is applied
+	groups := []models.ContainerGroup{
+		{
+			ID: "app",
+			Containers: []models.ContainerInfo{
+				{ID: "web", Image: "nginx:latest", State: "running"},
+				{ID: "db", Image: "postgres:16", State: "running"},
+				{ID: "cache", Image: "ghcr.io/team/cache:1", State: "running"},
+			},
+		},
+		{
+			ID:         "new",
+			Containers: []models.ContainerInfo{{ID: "new", Image: "redis:7", State: "exited"}},
+		},
+	}
+	cache.Apply(groups)
+
+	if !groups[0].HasUpdates {
+		t.Error("expected compose group to have updates after applying cache")
+	}
+	if !groups[0].AllRunning {
+		t.Error("expected AllRunning to be recomputed")
+	}
+	if web := groups[0].Containers[0]; web.LatestDigest != "sha256:new" || !web.CheckedAt.Equal(checkedAt) {
+		t.Errorf("unexpected cached container info: %+v", web)
+	}
+	if db := groups[0].Containers[1]; db.LatestTag != "17" {
+		t.Errorf("expected cached latest tag 17, got %q", db.LatestTag)
+	}
+	if c := groups[0].Containers[2]; c.CheckStatus != models.CheckStatusError || c.CheckError == "" {
+		t.Errorf("expected the cached check error, got %q %q", c.CheckStatus, c.CheckError)
+	}
+	if groups[1].HasUpdates || !groups[1].Containers[0].CheckedAt.IsZero() {
+		t.Error("expected unchecked container to be left as-is")
+	}
+	if !cache.LastChecked().Equal(checkedAt) || cache.LastDuration() != 2*time.Second {
+		t.Errorf("unexpected cache timestamps: %v %v", cache.LastChecked(), cache.LastDuration())
+	}
+}
+
+func TestUpdateCacheKeepsDeferredResults(t *testing.T) {
+	checkedAt := time.Now().Add(-time.Hour)
+	cache := NewUpdateCache()
+	cache.Store([]models.ContainerGroup{
+		{
+			ID: "web",
+			Containers: []models.ContainerInfo{
+				{ID: "web", Image: "nginx:latest", ImageDigest: "sha256:old", LatestDigest: "sha256:new", HasUpdate: true, CheckStatus: models.CheckStatusUpdateAvailable},
+			},
+		},
+	}, checkedAt, time.Second)
+
+	// The next check is deferred by the registry's rate limit
+	cache.Store([]models.ContainerGroup{
+		{
+			ID: "web",
+			Containers: []models.ContainerInfo{
+				{ID: "web", Image: "nginx:latest", CheckStatus: models.CheckStatusDeferred, CheckError: "registry rate limit reached for docker.io"},
+			},
+		},
+	}, time.Now(), time.Second)
+
+	groups := []models.ContainerGroup{
+		{ID: "web", Containers: []models.ContainerInfo{{ID: "web", Image: "nginx:latest", State: "running"}}},
+	}
+	cache.Apply(groups)
+
+	web := groups[0].Containers[0]
+	if web.CheckStatus != models.CheckStatusDeferred || web.CheckError == "" {
+		t.Errorf("expected the deferred status, got %q %q", web.CheckStatus, web.CheckError)
+	}
+	if !web.HasUpdate || web.LatestDigest != "sha256:new" || !web.CheckedAt.Equal(checkedAt) {
+		t.Errorf("expected the previous check's update to be kept, got %+v", web)
+	}
+	if !groups[0].HasUpdates {
+		t.Error("expected the group to still have updates")
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -9,8 +10,14 @@ import (
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/metrics"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"github.com/distribution/reference"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // GetContainerGroups lists all containers and groups them by compose project
@@ -40,11 +47,12 @@ func GetContainerGroups(ctx context.Context, client docker.DockerClient) ([]mode
 		
 		// Create ContainerInfo from container data
 		containerInfo := models.ContainerInfo{
-			ID:     container.ID,
-			Name:   getContainerName(container.Names),
-			Image:  container.Image,
-			State:  container.State,
-			Labels: container.Labels,
+			ID:      container.ID,
+			Name:    getContainerName(container.Names),
+			Image:   container.Image,
+			ImageID: container.ImageID,
+			State:   container.State,
+			Labels:  container.Labels,
 		}
 
 		if isCompose {
@@ -93,6 +101,199 @@ func GetContainerGroups(ctx context.Context, client docker.DockerClient) ([]mode
 	return groups, nil
 }
 
+// GetHostGroups lists the container groups on one host, tagging each group and
+// container with the host's name
+func GetHostGroups(ctx context.Context, host docker.Host) ([]models.ContainerGroup, error) {
+	groups, err := GetContainerGroups(ctx, host.Client)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		groups[i].Host = host.Name
+		for j := range groups[i].Containers {
+			groups[i].Containers[j].Host = host.Name
+		}
+	}
+	return groups, nil
+}
+
+// GetAllContainerGroups lists the container groups on every host concurrently,
+// in host order. Hosts that cannot be listed are left out and their errors
+// returned by host name, so one unreachable daemon does not hide the others.
+func GetAllContainerGroups(ctx context.Context, hosts *docker.Hosts) ([]models.ContainerGroup, map[string]error) {
+	all := hosts.All()
+	results := make([][]models.ContainerGroup, len(all))
+	errs := make([]error, len(all))
+
+	var wg sync.WaitGroup
+	for i, host := range all {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = GetHostGroups(ctx, host)
+		}()
+	}
+	wg.Wait()
+
+	var groups []models.ContainerGroup
+	hostErrors := make(map[string]error)
+	for i, host := range all {
+		if errs[i] != nil {
+			hostErrors[host.Name] = errs[i]
+			continue
+		}
+		groups = append(groups, results[i]...)
+	}
+	return groups, hostErrors
+}
+
+// CheckAllUpdates checks groups from several hosts for updates, inspecting
+// local images on the host each group runs on. Groups without a host are
+// checked on the default host. Hosts are checked concurrently, each with its
+// own pool of opts.Parallelism workers.
+func CheckAllUpdates(ctx context.Context, hosts *docker.Hosts, resolver registry.Resolver, groups []models.ContainerGroup, opts CheckOptions) error {
+	byHost := make(map[string][]int)
+	for i, group := range groups {
+		byHost[group.Host] = append(byHost[group.Host], i)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	for name, indexes := range byHost {
+		host, ok := hosts.Get(name)
+		if name == "" {
+			host, ok = hosts.Default(), true
+		}
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hostGroups := make([]models.ContainerGroup, len(indexes))
+			for i, index := range indexes {
+				hostGroups[i] = groups[index]
+			}
+			err := CheckUpdates(ctx, host.Client, resolver, hostGroups, opts)
+			for i, index := range indexes {
+				groups[index] = hostGroups[i]
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("host %s: %w", host.Name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// lookups runs each keyed lookup once per update check and shares its result
+// with every container that needs it, e.g. one registry request per image.
+// The lookup runs under the check's context with its own per-image timeout,
+// so one container giving up does not fail the others waiting on it.
+type lookups[T any] struct {
+	ctx     context.Context
+	timeout time.Duration
+
+	mu      sync.Mutex
+	results map[string]*lookup[T]
+}
+
+type lookup[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newLookups[T any](ctx context.Context, timeout time.Duration) *lookups[T] {
+	return &lookups[T]{ctx: ctx, timeout: timeout, results: make(map[string]*lookup[T])}
+}
+
+// do returns the result of fn for key, calling it only for the first caller;
+// every caller waits for that call to finish or for its own ctx to be done
+func (l *lookups[T]) do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	l.mu.Lock()
+	result, exists := l.results[key]
+	if !exists {
+		result = &lookup[T]{done: make(chan struct{})}
+		l.results[key] = result
+		go l.run(result, fn)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-result.done:
+		return result.value, result.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// run calls fn for a lookup and wakes its waiters
+func (l *lookups[T]) run(result *lookup[T], fn func(context.Context) (T, error)) {
+	ctx := l.ctx
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+	result.value, result.err = fn(ctx)
+	close(result.done)
+}
+
+// len returns how many keys were looked up
+func (l *lookups[T]) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.results)
+}
+
+// newestTag returns the newest tag a container's track label allows it to move
+// to, or an empty string if it does not track tags or is on the newest one
+func (u *updateCheck) newestTag(ctx context.Context, c *models.ContainerInfo) (string, error) {
+	logger := slog.Default()
+	tracking, err := GetTagTracking(c.Labels)
+	if err != nil {
+		logger.WarnContext(ctx, "ignoring invalid track label", "container", c.Name, "error", err)
+	}
+	if tracking == TrackDigest {
+		return "", nil
+	}
+	imageName := TaggedImage(c.Image, c.Labels)
+	repo, tag, ok := splitTag(imageName)
+	if !ok {
+		return "", nil
+	}
+
+	tags, err := u.tags.do(ctx, repo, func(ctx context.Context) ([]string, error) {
+		return u.resolver.ListTags(ctx, imageName)
+	})
+	if errors.Is(err, registry.ErrRateLimited) {
+		logger.InfoContext(ctx, "deferring tag tracking until the registry rate limit recovers",
+			"container", c.Name,
+			"image", imageName,
+			"error", err,
+		)
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "failed to list remote tags, skipping tag tracking",
+			"container", c.Name,
+			"image", imageName,
+			"error", err,
+		)
+		metrics.ImageCheckErrors.WithLabelValues(registry.RegistryOf(imageName)).Inc()
+		return "", fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	return newestTag(tag, tags, tracking), nil
+}
+
 // IsComposeProject checks if a container is part of a compose project
 // Returns (isCompose, projectName)
 func IsComposeProject(container types.Container) (bool, string) {
@@ -108,119 +309,326 @@ func IsComposeProject(container types.Container) (bool, string) {
 	return false, ""
 }
 
-// CheckUpdates pulls latest images and compares digests to mark update status
-func CheckUpdates(ctx context.Context, client docker.DockerClient, groups []models.ContainerGroup) error {
+// DefaultCheckParallelism is how many containers of a host are checked at
+// once when CheckOptions does not say
+const DefaultCheckParallelism = 4
+
+// CheckOptions tune an update check
+type CheckOptions struct {
+	Parallelism  int           // Containers checked at once per host, DefaultCheckParallelism if 0
+	ImageTimeout time.Duration // Bounds each registry or Docker lookup for an image, unbounded if 0
+}
+
+// CheckError is the failure to check one container for updates
+type CheckError struct {
+	Host      string
+	Container string
+	Image     string
+	Err       error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("container %s (%s): %v", e.Container, e.Image, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+// FailedChecks returns every CheckError in an error returned by CheckUpdates
+// or CheckAllUpdates
+func FailedChecks(err error) []*CheckError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *CheckError:
+		return []*CheckError{e}
+	case interface{ Unwrap() []error }:
+		var failed []*CheckError
+		for _, err := range e.Unwrap() {
+			failed = append(failed, FailedChecks(err)...)
+		}
+		return failed
+	case interface{ Unwrap() error }:
+		return FailedChecks(e.Unwrap())
+	}
+	return nil
+}
+
+// updateCheck holds the lookups shared by the containers of one update check
+type updateCheck struct {
+	client   docker.DockerClient
+	resolver registry.Resolver
+	digests  *lookups[*registry.ManifestDigest] // Remote digests by image reference
+	images   *lookups[image.InspectResponse]    // Local images by ID or reference
+	tags     *lookups[[]string]                 // Tag lists by repository
+}
+
+// CheckUpdates resolves the remote manifest digest for each image and compares
+// it with the local image's RepoDigests to mark update status. No images are
+// pulled; the pull happens only when the container is actually updated.
+// Containers are checked by a pool of opts.Parallelism workers, and each image
+// is resolved once however many containers run it. The failures of individual
+// containers are returned joined as CheckErrors; the other containers' results
+// are still set. Checks deferred by a registry rate limit are not failures.
+func CheckUpdates(ctx context.Context, client docker.DockerClient, resolver registry.Resolver, groups []models.ContainerGroup, opts CheckOptions) error {
 	start := time.Now()
 	logger := slog.Default()
-	
-	// Count total containers
-	totalContainers := 0
-	for _, group := range groups {
-		totalContainers += len(group.Containers)
+
+	type job struct {
+		host       string
+		container  *models.ContainerInfo
+		standalone bool
 	}
-	
-	logger.Debug("checking for updates",
+	var jobs []job
+	for i := range groups {
+		group := &groups[i]
+		// A compose service's tag is set in its compose file, so a newer tag is
+		// reported but not offered as an update
+		standalone := group.Type == models.GroupTypeStandalone
+		for j := range group.Containers {
+			jobs = append(jobs, job{host: group.Host, container: &group.Containers[j], standalone: standalone})
+		}
+	}
+
+	ctx, span := tracing.Start(ctx, "services.check_updates", attribute.Int("bleedingedge.container_count", len(jobs)))
+	defer span.End()
+
+	parallelism := opts.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultCheckParallelism
+	}
+	logger.DebugContext(ctx, "checking for updates",
 		"group_count", len(groups),
-		"container_count", totalContainers,
+		"container_count", len(jobs),
+		"parallelism", parallelism,
 	)
-	
-	// Track unique images to avoid duplicate pulls
-	imageDigests := make(map[string]string)
+
+	check := &updateCheck{
+		client:   client,
+		resolver: resolver,
+		digests:  newLookups[*registry.ManifestDigest](ctx, opts.ImageTimeout),
+		images:   newLookups[image.InspectResponse](ctx, opts.ImageTimeout),
+		tags:     newLookups[[]string](ctx, opts.ImageTimeout),
+	}
+
+	queue := make(chan job)
 	var mu sync.Mutex
+	var errs []error
 	var wg sync.WaitGroup
-
-	// Process all containers across all groups
-	for i := range groups {
-		group := &groups[i]
-		
-		for j := range group.Containers {
-			container := &group.Containers[j]
-			
-			wg.Add(1)
-			go func(c *models.ContainerInfo) {
-				defer wg.Done()
-
-				imageName := c.Image
-				
-				// Skip update check for locally built images (no registry prefix)
-				if isLocalImage(imageName) {
-					logger.Debug("skipping update check for local image",
-						"container", c.Name,
-						"image", imageName,
-					)
+	for range min(parallelism, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				// A deferred check is reported by its status, not as a failure
+				err := check.container(ctx, j.container, j.standalone)
+				if err != nil && !errors.Is(err, registry.ErrRateLimited) {
 					mu.Lock()
-					c.HasUpdate = false
+					errs = append(errs, &CheckError{Host: j.host, Container: j.container.Name, Image: j.container.Image, Err: err})
 					mu.Unlock()
-					return
 				}
-				
-				// Check if we already have the latest digest for this image
-				mu.Lock()
-				latestDigest, exists := imageDigests[imageName]
-				mu.Unlock()
+			}
+		}()
+	}
+	for _, j := range jobs {
+		queue <- j
+	}
+	close(queue)
+	wg.Wait()
 
-				if !exists {
-					// Pull the latest image
-					if err := client.PullImage(ctx, imageName); err != nil {
-						logger.Warn("failed to pull image, skipping update check",
-							"container", c.Name,
-							"image", imageName,
-							"error", err,
-						)
-						mu.Lock()
-						c.HasUpdate = false
-						mu.Unlock()
-						return
-					}
-
-					// Get the latest digest
-					digest, err := client.GetImageDigest(ctx, imageName)
-					if err != nil {
-						logger.Warn("failed to get digest for image, skipping update check",
-							"container", c.Name,
-							"image", imageName,
-							"error", err,
-						)
-						mu.Lock()
-						c.HasUpdate = false
-						mu.Unlock()
-						return
-					}
+	// Update group-level HasUpdates flag
+	groupsWithUpdates, containersWithUpdates := summarizeGroups(groups)
+	span.SetAttributes(attribute.Int("bleedingedge.containers_with_updates", containersWithUpdates))
 
-					mu.Lock()
-					imageDigests[imageName] = digest
-					latestDigest = digest
-					mu.Unlock()
-				}
+	duration := time.Since(start)
+	logger.DebugContext(ctx, "checked for updates",
+		"groups_with_updates", groupsWithUpdates,
+		"containers_with_updates", containersWithUpdates,
+		"failed_containers", len(errs),
+		"unique_images", check.digests.len(),
+		"duration_ms", duration.Milliseconds(),
+	)
 
-				// Get the current container's image digest
-				currentDigest, err := client.GetImageDigest(ctx, c.Image)
-				if err != nil {
-					logger.Warn("failed to get current digest for container, skipping update check",
-						"container", c.Name,
-						"image", imageName,
-						"error", err,
-					)
-					mu.Lock()
-					c.HasUpdate = false
-					mu.Unlock()
-					return
-				}
+	return errors.Join(errs...)
+}
 
-				// Update container info
-				mu.Lock()
-				c.ImageDigest = currentDigest
-				c.LatestDigest = latestDigest
-				c.HasUpdate = currentDigest != latestDigest
-				mu.Unlock()
-			}(container)
+// container checks one container for updates, setting its update and check
+// status. A container that cannot be checked is left without an update.
+func (u *updateCheck) container(ctx context.Context, c *models.ContainerInfo, standalone bool) (err error) {
+	logger := slog.Default()
+	// A reverted container is checked against the tag it was pinned from
+	imageName := TaggedImage(c.Image, c.Labels)
+	c.HasUpdate = false
+	c.CheckStatus, c.CheckError = "", ""
+	defer func() {
+		setCheckStatus(c, err)
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, span := tracing.Start(ctx, "services.check_image",
+		attribute.String("container.name", c.Name),
+		attribute.String("container.image.name", imageName),
+	)
+	defer span.End()
+
+	override, err := GetCheckOverride(c.Labels)
+	if err != nil {
+		logger.WarnContext(ctx, "ignoring invalid check label", "container", c.Name, "error", err)
+	}
+	if override == CheckNever {
+		logger.DebugContext(ctx, "update check disabled by label",
+			"container", c.Name,
+			"image", imageName,
+		)
+		c.CheckStatus = models.CheckStatusSkipped
+		return nil
+	}
+
+	// An image pinned by digest never changes
+	if isPinned(imageName) {
+		c.CheckStatus = models.CheckStatusPinned
+		return nil
+	}
+
+	// Inspect the image the container is actually running
+	localRef := c.ImageID
+	if localRef == "" {
+		localRef = imageName
+	}
+	localImage, err := u.images.do(ctx, localRef, func(ctx context.Context) (image.InspectResponse, error) {
+		return u.client.InspectImage(ctx, localRef)
+	})
+	if err != nil {
+		logger.WarnContext(ctx, "failed to inspect local image, skipping update check",
+			"container", c.Name,
+			"image", imageName,
+			"error", err,
+		)
+		tracing.RecordError(span, err)
+		metrics.ImageCheckErrors.WithLabelValues(registry.RegistryOf(imageName)).Inc()
+		return fmt.Errorf("failed to inspect local image: %w", err)
+	}
+
+	// Skip update check for images that were never pulled from a registry
+	if override != CheckAlways && isLocalImage(imageName, localImage.RepoDigests) {
+		logger.DebugContext(ctx, "skipping update check for local image",
+			"container", c.Name,
+			"image", imageName,
+		)
+		c.CheckStatus = models.CheckStatusSkipped
+		return nil
+	}
+	if len(localImage.RepoDigests) == 0 {
+		return errors.New("local image has no repo digests to compare with the registry")
+	}
+
+	// A newer version tag is an update whatever the current tag's digest
+	latestTag, tagErr := u.newestTag(ctx, c)
+	defer func() {
+		c.LatestTag = latestTag
+		if latestTag != "" && standalone {
+			c.HasUpdate = true
 		}
+	}()
+
+	remote, err := u.digests.do(ctx, imageName, func(ctx context.Context) (*registry.ManifestDigest, error) {
+		return u.resolver.GetDigest(ctx, imageName)
+	})
+	if errors.Is(err, registry.ErrRateLimited) {
+		logger.InfoContext(ctx, "deferring update check until the registry rate limit recovers",
+			"container", c.Name,
+			"image", imageName,
+			"error", err,
+		)
+		return err
+	}
+	if err != nil {
+		logger.WarnContext(ctx, "failed to resolve remote digest, skipping update check",
+			"container", c.Name,
+			"image", imageName,
+			"error", err,
+		)
+		tracing.RecordError(span, err)
+		metrics.ImageCheckErrors.WithLabelValues(registry.RegistryOf(imageName)).Inc()
+		return fmt.Errorf("failed to resolve remote digest: %w", err)
 	}
 
-	// Wait for all goroutines to complete
-	wg.Wait()
+	// Select from an index the manifest for the platform the local image was
+	// pulled for, i.e. the Docker host's, which need not be this process's
+	remote = remote.ForPlatform(registry.Platform{
+		OS:           localImage.Os,
+		Architecture: localImage.Architecture,
+		Variant:      localImage.Variant,
+	})
+	c.ImageDigest = localDigest(localImage.RepoDigests, remote)
+	c.LatestDigest = remote.Digest
+	c.HasUpdate = !remote.MatchesAny(localImage.RepoDigests)
+	span.SetAttributes(attribute.Bool("bleedingedge.update_available", c.HasUpdate))
+	return tagErr
+}
 
-	// Update group-level HasUpdates flag
+// setCheckStatus records the outcome of checking a container that was not
+// skipped. An available update wins over a failure, e.g. a newer tag found
+// even though the digest lookup failed, and a rate limited registry only
+// defers the check.
+func setCheckStatus(c *models.ContainerInfo, err error) {
+	switch {
+	case c.CheckStatus != "":
+	case c.HasUpdate:
+		c.CheckStatus = models.CheckStatusUpdateAvailable
+	case errors.Is(err, registry.ErrRateLimited):
+		c.CheckStatus = models.CheckStatusDeferred
+		c.CheckError = err.Error()
+	case err != nil:
+		c.CheckStatus = models.CheckStatusError
+		c.CheckError = err.Error()
+	default:
+		c.CheckStatus = models.CheckStatusUpToDate
+	}
+}
+
+// ImageDigests returns the repo digest of the image each named container is
+// running, keyed by container name. Containers that no longer exist or whose
+// image has no repo digest (e.g. locally built images) are left out.
+func ImageDigests(ctx context.Context, client docker.DockerClient, names []string) map[string]string {
+	digests := make(map[string]string)
+
+	containers, err := client.ListContainers(ctx)
+	if err != nil {
+		slog.Default().Warn("failed to list containers for image digests", "error", err)
+		return digests
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	for _, c := range containers {
+		name := getContainerName(c.Names)
+		if !wanted[name] {
+			continue
+		}
+		ref := c.ImageID
+		if ref == "" {
+			ref = c.Image
+		}
+		image, err := client.InspectImage(ctx, ref)
+		if err != nil || len(image.RepoDigests) == 0 {
+			continue
+		}
+		digests[name] = digestOnly(image.RepoDigests[0])
+	}
+
+	return digests
+}
+
+// summarizeGroups recomputes the group-level HasUpdates and AllRunning flags
+// Returns the number of groups and containers with updates available
+func summarizeGroups(groups []models.ContainerGroup) (int, int) {
 	groupsWithUpdates := 0
 	containersWithUpdates := 0
 	for i := range groups {
@@ -237,16 +645,7 @@ func CheckUpdates(ctx context.Context, client docker.DockerClient, groups []mode
 		}
 		group.AllRunning = areAllContainersRunning(group.Containers)
 	}
-
-	duration := time.Since(start)
-	logger.Debug("checked for updates successfully",
-		"groups_with_updates", groupsWithUpdates,
-		"containers_with_updates", containersWithUpdates,
-		"unique_images", len(imageDigests),
-		"duration_ms", duration.Milliseconds(),
-	)
-
-	return nil
+	return groupsWithUpdates, containersWithUpdates
 }
 
 // getContainerName extracts the container name from the Names slice
@@ -259,6 +658,25 @@ func getContainerName(names []string) string {
 	return strings.TrimPrefix(name, "/")
 }
 
+// localDigest returns the local repo digest that matches the remote manifest,
+// falling back to the first repo digest. The repository prefix is stripped.
+func localDigest(repoDigests []string, remote *registry.ManifestDigest) string {
+	for _, repoDigest := range repoDigests {
+		if remote.MatchesAny([]string{repoDigest}) {
+			return digestOnly(repoDigest)
+		}
+	}
+	return digestOnly(repoDigests[0])
+}
+
+// digestOnly strips the repository from a "repo@sha256:..." reference
+func digestOnly(repoDigest string) string {
+	if _, digest, found := strings.Cut(repoDigest, "@"); found {
+		return digest
+	}
+	return repoDigest
+}
+
 // areAllContainersRunning checks if all containers in a group are running
 func areAllContainersRunning(containers []models.ContainerInfo) bool {
 	if len(containers) == 0 {
@@ -272,40 +690,25 @@ func areAllContainersRunning(containers []models.ContainerInfo) bool {
 	return true
 }
 
-// isLocalImage checks if an image is locally built (not from a registry)
-// Local images typically don't have a registry prefix (e.g., "myapp:latest")
-// Registry images have formats like "docker.io/library/nginx:latest" or "nginx:latest"
-func isLocalImage(imageName string) bool {
-	// Images referenced by raw digest (sha256:...) cannot be pulled
-	if strings.HasPrefix(imageName, "sha256:") {
-		return true
-	}
-	
-	// Images with no tag or with localhost are local
-	if strings.HasPrefix(imageName, "localhost/") || strings.HasPrefix(imageName, "localhost:") {
+// isLocalImage reports whether an image only exists on the host, so there is
+// no registry to check it against: a reference that doesn't parse (e.g. an
+// image ID), or an image that was never pulled or pushed and so has no repo
+// digests, like most compose builds.
+func isLocalImage(imageName string, repoDigests []string) bool {
+	if len(repoDigests) == 0 || strings.HasPrefix(imageName, "sha256:") {
 		return true
 	}
-	
-	// Check if image has a registry domain (contains a dot before the first slash)
-	// Examples: docker.io/nginx, gcr.io/project/image, quay.io/repo/image
-	parts := strings.SplitN(imageName, "/", 2)
-	if len(parts) > 1 {
-		// If the first part contains a dot or colon, it's likely a registry
-		if strings.Contains(parts[0], ".") || strings.Contains(parts[0], ":") {
-			return false
-		}
-	}
-	
-	// Check for official Docker Hub images (single name like "nginx", "postgres")
-	// These are pullable from Docker Hub
-	imageParts := strings.Split(imageName, ":")
-	baseName := imageParts[0]
-	
-	// If it contains a hyphen or underscore and no slash, it's likely a local compose image
-	// Examples: "myproject-web", "subset-tvdb-api", "bleedingedge-bleeding-edge"
-	if !strings.Contains(baseName, "/") && (strings.Contains(baseName, "-") || strings.Contains(baseName, "_")) {
-		return true
+	_, err := reference.ParseNormalizedNamed(imageName)
+	return err != nil
+}
+
+// isPinned reports whether an image is referenced by digest, e.g.
+// nginx:1.25@sha256:...
+func isPinned(imageName string) bool {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return false
 	}
-	
-	return false
+	_, pinned := named.(reference.Digested)
+	return pinned
 }
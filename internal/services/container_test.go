@@ -2,12 +2,18 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
 )
 
 func TestGetContainerGroups(t *testing.T) {
@@ -132,6 +138,52 @@ func TestGetContainerGroups(t *testing.T) {
 	}
 }
 
+func TestGetAllContainerGroups(t *testing.T) {
+	// Each host runs nginx from a different local image
+	host := func(repoDigest string) *docker.MockClient {
+		return &docker.MockClient{
+			ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+				return []types.Container{{ID: "container1", Names: []string{"/nginx"}, Image: "nginx:latest", State: "running"}}, nil
+			},
+			InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+				return image.InspectResponse{RepoDigests: []string{repoDigest}}, nil
+			},
+		}
+	}
+	hosts := docker.NewHosts(
+		docker.Host{Name: "local", Client: host("nginx@sha256:old")},
+		docker.Host{Name: "offline", Client: &docker.MockClient{
+			ListContainersFunc: func(ctx context.Context) ([]types.Container, error) {
+				return nil, fmt.Errorf("connection refused")
+			},
+		}},
+		docker.Host{Name: "edge", Client: host("nginx@sha256:new")},
+	)
+
+	groups, hostErrors := GetAllContainerGroups(context.Background(), hosts)
+	if len(groups) != 2 || groups[0].Host != "local" || groups[1].Host != "edge" {
+		t.Fatalf("expected the groups of local and edge in host order, got %+v", groups)
+	}
+	if groups[1].Containers[0].Host != "edge" || groups[1].Path() != "/host/edge/container/container1" {
+		t.Errorf("expected containers to be tagged with their host, got %+v", groups[1])
+	}
+	if len(hostErrors) != 1 || hostErrors["offline"] == nil {
+		t.Errorf("expected an error for the offline host only, got %v", hostErrors)
+	}
+
+	resolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+	if err := CheckAllUpdates(context.Background(), hosts, resolver, groups, CheckOptions{}); err != nil {
+		t.Fatalf("CheckAllUpdates() error = %v", err)
+	}
+	if !groups[0].HasUpdates || groups[1].HasUpdates {
+		t.Errorf("expected local images to be compared on their own host, got local=%v edge=%v", groups[0].HasUpdates, groups[1].HasUpdates)
+	}
+}
+
 func TestIsComposeProject(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -250,30 +302,27 @@ func TestCheckUpdates(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			callCount := make(map[string]int)
+			pulled := false
 			mockClient := &docker.MockClient{
 				PullImageFunc: func(ctx context.Context, imageName string) error {
+					pulled = true
 					return nil
 				},
-				GetImageDigestFunc: func(ctx context.Context, imageName string) (string, error) {
-					callCount[imageName]++
-					// First call is for current digest, second call is for latest digest after pull
-					if callCount[imageName] == 1 {
-						// Return old digest for current image
-						if tt.expectedGroupUpdate {
-							return "sha256:old-digest", nil
-						}
-						return "sha256:same-digest", nil
-					}
-					// Second call returns the latest digest
-					if digest, ok := tt.imageDigests[imageName]; ok {
-						return digest, nil
+				InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+					// The local image is either stale or already at the same digest
+					if tt.expectedGroupUpdate {
+						return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old-digest"}}, nil
 					}
-					return "sha256:same-digest", nil
+					return image.InspectResponse{RepoDigests: []string{"nginx@sha256:same-digest"}}, nil
+				},
+			}
+			mockResolver := &registry.MockClient{
+				GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+					return &registry.ManifestDigest{Digest: tt.imageDigests[imageName]}, nil
 				},
 			}
 
-			err := CheckUpdates(context.Background(), mockClient, tt.groups)
+			err := CheckUpdates(context.Background(), mockClient, mockResolver, tt.groups, CheckOptions{})
 			if (err != nil) != tt.expectedError {
 				t.Errorf("CheckUpdates() error = %v, expectedError %v", err, tt.expectedError)
 			}
@@ -283,6 +332,13 @@ func TestCheckUpdates(t *testing.T) {
 				if hasUpdate != tt.expectedGroupUpdate {
 					t.Errorf("expected group HasUpdates=%v, got %v", tt.expectedGroupUpdate, hasUpdate)
 				}
+				if latest := tt.groups[0].Containers[0].LatestDigest; latest != tt.imageDigests["nginx:latest"] {
+					t.Errorf("expected LatestDigest=%s, got %s", tt.imageDigests["nginx:latest"], latest)
+				}
+			}
+
+			if pulled {
+				t.Error("expected CheckUpdates not to pull images")
 			}
 		})
 	}
@@ -305,91 +361,432 @@ func TestCheckUpdatesError(t *testing.T) {
 		},
 	}
 
-	mockClient := &docker.MockClient{
-		PullImageFunc: func(ctx context.Context, imageName string) error {
-			return fmt.Errorf("failed to pull image")
+	mockClient := &docker.MockClient{}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return nil, fmt.Errorf("registry unavailable")
 		},
 	}
 
-	err := CheckUpdates(context.Background(), mockClient, groups)
-	if err != nil {
-		t.Errorf("expected no error when pull fails (should be handled gracefully), got: %v", err)
+	err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{})
+	failed := FailedChecks(err)
+	if len(failed) != 1 || failed[0].Container != "nginx" || failed[0].Image != "nginx:latest" {
+		t.Fatalf("expected the container's check error, got %v", err)
 	}
-	
-	// Verify that the container is marked as having no update when pull fails
+
+	// Verify that the container is marked as having no update when the lookup fails
 	if groups[0].Containers[0].HasUpdate {
-		t.Error("expected HasUpdate to be false when pull fails")
+		t.Error("expected HasUpdate to be false when registry lookup fails")
 	}
 }
 
-func TestIsLocalImage(t *testing.T) {
+func TestCheckUpdatesStatus(t *testing.T) {
 	tests := []struct {
-		name      string
-		imageName string
-		want      bool
+		name         string
+		image        string
+		labels       map[string]string
+		repoDigests  []string
+		expected     models.CheckStatus
+		expectReason string
 	}{
-		{
-			name:      "local compose image with hyphen",
-			imageName: "bleedingedge-bleeding-edge",
-			want:      true,
+		{name: "update available", image: "nginx:latest", repoDigests: []string{"nginx@sha256:old"}, expected: models.CheckStatusUpdateAvailable},
+		{name: "up to date", image: "nginx:latest", repoDigests: []string{"nginx@sha256:new"}, expected: models.CheckStatusUpToDate},
+		{name: "local image", image: "myproject-web", expected: models.CheckStatusSkipped},
+		{name: "never pushed", image: "registry.example.com/app:dev", expected: models.CheckStatusSkipped},
+		{name: "pinned by digest", image: "nginx:1.25@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", expected: models.CheckStatusPinned},
+		{name: "lookup failed", image: "ghcr.io/team/private:1", repoDigests: []string{"ghcr.io/team/private@sha256:old"}, expected: models.CheckStatusError, expectReason: "401 Unauthorized"},
+		{name: "hub image with hyphen", image: "eclipse-mosquitto:2", repoDigests: []string{"eclipse-mosquitto@sha256:old"}, expected: models.CheckStatusUpdateAvailable},
+		{name: "disabled by label", image: "nginx:latest", labels: map[string]string{LabelCheck: "false"}, repoDigests: []string{"nginx@sha256:old"}, expected: models.CheckStatusSkipped},
+		{name: "localhost registry", image: "localhost:5000/app:1", repoDigests: []string{"localhost:5000/app@sha256:old"}, expected: models.CheckStatusUpdateAvailable},
+		{name: "rate limited", image: "limited/app:1", repoDigests: []string{"limited/app@sha256:old"}, expected: models.CheckStatusDeferred, expectReason: "checks resume at"},
+		{name: "forced without repo digests", image: "myproject-web", labels: map[string]string{LabelCheck: "true"}, expected: models.CheckStatusError, expectReason: "no repo digests"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := []models.ContainerGroup{
+				{
+					ID:         "app",
+					Type:       models.GroupTypeStandalone,
+					Containers: []models.ContainerInfo{{ID: "app", Name: "app", Image: tt.image, Labels: tt.labels}},
+				},
+			}
+			mockClient := &docker.MockClient{
+				InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+					return image.InspectResponse{RepoDigests: tt.repoDigests}, nil
+				},
+			}
+			resolved := false
+			mockResolver := &registry.MockClient{
+				GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+					resolved = true
+					if strings.HasPrefix(imageName, "ghcr.io/") {
+						return nil, errors.New("registry returned 401 Unauthorized for team/private:1")
+					}
+					if strings.HasPrefix(imageName, "limited/") {
+						return nil, fmt.Errorf("%w for docker.io, checks resume at 2024-06-01T12:15:00Z", registry.ErrRateLimited)
+					}
+					return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+				},
+			}
+
+			err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{})
+			if (err != nil) != (tt.expected == models.CheckStatusError) {
+				t.Errorf("unexpected check error %v for status %q", err, tt.expected)
+			}
+
+			c := groups[0].Containers[0]
+			if c.CheckStatus != tt.expected {
+				t.Errorf("expected status %q, got %q", tt.expected, c.CheckStatus)
+			}
+			if !strings.Contains(c.CheckError, tt.expectReason) || (tt.expectReason == "") != (c.CheckError == "") {
+				t.Errorf("expected reason containing %q, got %q", tt.expectReason, c.CheckError)
+			}
+			if groups[0].CheckStatus() != tt.expected {
+				t.Errorf("expected group status %q, got %q", tt.expected, groups[0].CheckStatus())
+			}
+			if tt.expected == models.CheckStatusPinned && resolved {
+				t.Error("expected a pinned image not to be looked up")
+			}
+		})
+	}
+}
+
+func TestCheckUpdatesWorkerPool(t *testing.T) {
+	var groups []models.ContainerGroup
+	for i := range 12 {
+		name := fmt.Sprintf("app-%d", i)
+		groups = append(groups, models.ContainerGroup{
+			ID:   name,
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: name, Name: name, Image: fmt.Sprintf("nginx:1.%d", i%3)},
+			},
+		})
+	}
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	lookups := make(map[string]int)
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
 		},
-		{
-			name:      "local compose image with underscore",
-			imageName: "subset_tvdb_api",
-			want:      true,
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			lookups[imageName]++
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
 		},
+	}
+
+	if err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{Parallelism: 2}); err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent lookups, got %d", maxRunning)
+	}
+	if len(lookups) != 3 {
+		t.Errorf("expected 3 distinct images, got %v", lookups)
+	}
+	for imageName, n := range lookups {
+		if n != 1 {
+			t.Errorf("expected %s to be resolved once, got %d", imageName, n)
+		}
+	}
+	for _, group := range groups {
+		if !group.Containers[0].HasUpdate {
+			t.Errorf("expected %s to have an update", group.Name)
+		}
+	}
+}
+
+func TestCheckUpdatesImageTimeout(t *testing.T) {
+	groups := []models.ContainerGroup{
 		{
-			name:      "localhost image",
-			imageName: "localhost/myapp:latest",
-			want:      true,
+			ID:   "slow",
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: "slow", Name: "slow", Image: "slow.example.com/app:latest"},
+			},
 		},
 		{
-			name:      "localhost with port",
-			imageName: "localhost:5000/myapp:latest",
-			want:      true,
+			ID:   "fast",
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: "fast", Name: "fast", Image: "nginx:latest"},
+			},
 		},
-		{
-			name:      "docker hub official image",
-			imageName: "nginx:latest",
-			want:      false,
+	}
+
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
 		},
-		{
-			name:      "docker hub user image",
-			imageName: "user/nginx:latest",
-			want:      false,
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			if strings.HasPrefix(imageName, "slow.") {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
 		},
+	}
+
+	err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{ImageTimeout: 20 * time.Millisecond})
+	failed := FailedChecks(err)
+	if len(failed) != 1 || failed[0].Container != "slow" || !errors.Is(failed[0], context.DeadlineExceeded) {
+		t.Fatalf("expected the slow container to time out, got %v", err)
+	}
+	if !groups[1].Containers[0].HasUpdate {
+		t.Error("expected the fast container to still be checked")
+	}
+}
+
+func TestCheckUpdatesTimeoutPerLookup(t *testing.T) {
+	groups := []models.ContainerGroup{
 		{
-			name:      "gcr.io registry",
-			imageName: "gcr.io/project/image:tag",
-			want:      false,
+			ID:   "db",
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: "db", Name: "db", Image: "postgres:16.3", Labels: map[string]string{LabelTrack: "semver:minor"}},
+			},
+		},
+	}
+
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"postgres@sha256:old"}}, nil
+		},
+	}
+	// Each lookup fits the timeout, but not both together
+	slow := func(ctx context.Context) error {
+		select {
+		case <-time.After(60 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	mockResolver := &registry.MockClient{
+		ListTagsFunc: func(ctx context.Context, imageName string) ([]string, error) {
+			return []string{"16.3"}, slow(ctx)
+		},
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			if err := slow(ctx); err != nil {
+				return nil, err
+			}
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+
+	if err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{ImageTimeout: 100 * time.Millisecond}); err != nil {
+		t.Fatalf("expected each lookup to get the full timeout, got %v", err)
+	}
+	if !groups[0].Containers[0].HasUpdate {
+		t.Error("expected the digest lookup to complete after the tag listing")
+	}
+}
+
+func TestCheckUpdatesSharedLookupOutlivesFirstCaller(t *testing.T) {
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"nginx@sha256:old"}}, nil
 		},
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var calls int
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			calls++
+			close(started)
+			select {
+			case <-release:
+				return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		},
+	}
+	check := &updateCheck{
+		client:   mockClient,
+		resolver: mockResolver,
+		digests:  newLookups[*registry.ManifestDigest](context.Background(), time.Minute),
+		images:   newLookups[image.InspectResponse](context.Background(), time.Minute),
+		tags:     newLookups[[]string](context.Background(), time.Minute),
+	}
+
+	first := &models.ContainerInfo{ID: "first", Name: "first", Image: "nginx:latest"}
+	second := &models.ContainerInfo{ID: "second", Name: "second", Image: "nginx:latest"}
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() { firstErr <- check.container(firstCtx, first, true) }()
+	<-started
+
+	secondErr := make(chan error, 1)
+	go func() { secondErr <- check.container(context.Background(), second, true) }()
+
+	// The first container gives up while the second is waiting on its lookup
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the first container to be cancelled, got %v", err)
+	}
+	close(release)
+
+	if err := <-secondErr; err != nil {
+		t.Fatalf("expected the second container to be checked, got %v", err)
+	}
+	if !second.HasUpdate || second.CheckStatus != models.CheckStatusUpdateAvailable {
+		t.Errorf("expected an update for the second container, got %+v", second)
+	}
+	if calls != 1 {
+		t.Errorf("expected one shared registry lookup, got %d", calls)
+	}
+}
+
+func TestCheckUpdatesUsesImagePlatform(t *testing.T) {
+	groups := []models.ContainerGroup{
 		{
-			name:      "docker.io registry",
-			imageName: "docker.io/library/nginx:latest",
-			want:      false,
+			ID:         "app",
+			Type:       models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{{ID: "app", Name: "app", Image: "nginx:latest"}},
 		},
+	}
+	// The image runs on an arm64 host while the resolver selected amd64
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{Os: "linux", Architecture: "arm64", RepoDigests: []string{"nginx@sha256:arm64"}}, nil
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return &registry.ManifestDigest{
+				Digest:         "sha256:index",
+				MediaType:      registry.MediaTypeOCIIndex,
+				PlatformDigest: "sha256:amd64",
+				Manifests: []registry.PlatformManifest{
+					{Platform: registry.Platform{OS: "linux", Architecture: "amd64"}, Digest: "sha256:amd64"},
+					{Platform: registry.Platform{OS: "linux", Architecture: "arm64"}, Digest: "sha256:arm64"},
+				},
+			}, nil
+		},
+	}
+
+	if err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{}); err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+	if c := groups[0].Containers[0]; c.HasUpdate || c.ImageDigest != "sha256:arm64" {
+		t.Errorf("expected the arm64 image to be up to date, got %+v", c)
+	}
+}
+
+func TestCheckUpdatesTracksTags(t *testing.T) {
+	groups := []models.ContainerGroup{
 		{
-			name:      "quay.io registry",
-			imageName: "quay.io/repo/image:latest",
-			want:      false,
+			ID:   "db",
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: "db", Name: "db", Image: "postgres:16.3-alpine", Labels: map[string]string{LabelTrack: "semver:minor"}},
+			},
 		},
 		{
-			name:      "private registry with port",
-			imageName: "registry.example.com:5000/myapp:latest",
-			want:      false,
+			ID:   "cache",
+			Type: models.GroupTypeStandalone,
+			Containers: []models.ContainerInfo{
+				{ID: "cache", Name: "cache", Image: "postgres:16.4-alpine", Labels: map[string]string{LabelTrack: "semver:minor"}},
+			},
 		},
 		{
-			name:      "raw sha256 digest",
-			imageName: "sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844",
-			want:      true,
+			ID:   "app",
+			Type: models.GroupTypeCompose,
+			Containers: []models.ContainerInfo{
+				{ID: "app-db", Name: "app-db-1", Image: "postgres:16.3-alpine", Labels: map[string]string{LabelTrack: "semver:major"}},
+			},
 		},
 	}
 
+	listed := 0
+	mockClient := &docker.MockClient{
+		InspectImageFunc: func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+			return image.InspectResponse{RepoDigests: []string{"postgres@sha256:same-digest"}}, nil
+		},
+	}
+	mockResolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			return &registry.ManifestDigest{Digest: "sha256:same-digest"}, nil
+		},
+		ListTagsFunc: func(ctx context.Context, imageName string) ([]string, error) {
+			listed++
+			return []string{"16.3-alpine", "16.4-alpine", "16.5", "17.0-alpine", "latest"}, nil
+		},
+	}
+
+	if err := CheckUpdates(context.Background(), mockClient, mockResolver, groups, CheckOptions{}); err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+
+	if db := groups[0].Containers[0]; db.LatestTag != "16.4-alpine" || !db.HasUpdate {
+		t.Errorf("expected an update to 16.4-alpine, got tag %q has_update %v", db.LatestTag, db.HasUpdate)
+	}
+	if cache := groups[1].Containers[0]; cache.LatestTag != "" || cache.HasUpdate {
+		t.Errorf("expected the newest allowed tag to have no update, got tag %q has_update %v", cache.LatestTag, cache.HasUpdate)
+	}
+	// Compose services report the newer tag without offering an update
+	if appDB := groups[2].Containers[0]; appDB.LatestTag != "17.0-alpine" || appDB.HasUpdate {
+		t.Errorf("expected compose service to report 17.0-alpine without an update, got tag %q has_update %v", appDB.LatestTag, appDB.HasUpdate)
+	}
+	if listed != 1 {
+		t.Errorf("expected the repository's tags to be listed once, got %d", listed)
+	}
+}
+
+func TestIsLocalImage(t *testing.T) {
+	pulled := []string{"example@sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844"}
+
+	tests := []struct {
+		name        string
+		imageName   string
+		repoDigests []string
+		want        bool
+	}{
+		{name: "local compose image with hyphen", imageName: "bleedingedge-bleeding-edge", want: true},
+		{name: "local compose image with underscore", imageName: "subset_tvdb_api", want: true},
+		{name: "never pushed registry image", imageName: "registry.example.com/app:dev", want: true},
+		{name: "localhost image never pushed", imageName: "localhost/myapp:latest", want: true},
+		{name: "localhost registry with port", imageName: "localhost:5000/myapp:latest", repoDigests: pulled, want: false},
+		{name: "raw sha256 digest", imageName: "sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844", repoDigests: pulled, want: true},
+		{name: "invalid reference", imageName: "MyApp:latest", repoDigests: pulled, want: true},
+		{name: "library image", imageName: "nginx", repoDigests: pulled, want: false},
+		{name: "library image with tag", imageName: "nginx:latest", repoDigests: pulled, want: false},
+		{name: "explicit library image", imageName: "docker.io/library/nginx:latest", repoDigests: pulled, want: false},
+		{name: "hub image with hyphen", imageName: "eclipse-mosquitto:2", repoDigests: pulled, want: false},
+		{name: "hub image with underscore", imageName: "my_service:1.0", repoDigests: pulled, want: false},
+		{name: "hub user image", imageName: "nodered/node-red:latest", repoDigests: pulled, want: false},
+		{name: "gcr.io registry", imageName: "gcr.io/project/image:tag", repoDigests: pulled, want: false},
+		{name: "quay.io registry", imageName: "quay.io/repo/image:latest", repoDigests: pulled, want: false},
+		{name: "registry with port", imageName: "registry.example.com:5000/myapp:latest", repoDigests: pulled, want: false},
+		{name: "ip registry with port", imageName: "10.0.0.5:5000/team/app:1.2", repoDigests: pulled, want: false},
+		{name: "registry host without dot", imageName: "registry:5000/myapp", repoDigests: pulled, want: false},
+		{name: "pinned by digest", imageName: "nginx:1.25@sha256:ca42d907c22d714ce175bb73258241cf4d8770566a4b53ec07a1d03936e77844", repoDigests: pulled, want: false},
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := isLocalImage(tt.imageName)
+			got := isLocalImage(tt.imageName, tt.repoDigests)
 			if got != tt.want {
-				t.Errorf("isLocalImage(%q) = %v, want %v", tt.imageName, got, tt.want)
+				t.Errorf("isLocalImage(%q, %v) = %v, want %v", tt.imageName, tt.repoDigests, got, tt.want)
 			}
 		})
 	}
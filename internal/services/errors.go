@@ -0,0 +1,102 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+// FormatErrorMessage converts technical error messages to user-friendly messages
+func FormatErrorMessage(err error) string {
+	errMsg := err.Error()
+
+	// Common error patterns and their user-friendly messages
+	if strings.Contains(errMsg, "No such container") {
+		return "Container not found. It may have been removed."
+	}
+	if strings.Contains(errMsg, "already in progress") {
+		return "Operation already in progress. Please wait."
+	}
+	if strings.Contains(errMsg, "is not running") {
+		return "Container is not running."
+	}
+	if strings.Contains(errMsg, "is already stopped") {
+		return "Container is already stopped."
+	}
+	if strings.Contains(errMsg, "failed to pull") || strings.Contains(errMsg, "pull access denied") {
+		return "Failed to pull image. Check your internet connection and image name."
+	}
+	if strings.Contains(errMsg, "permission denied") || strings.Contains(errMsg, "access denied") {
+		return "Permission denied. Check Docker socket permissions."
+	}
+	if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "context deadline exceeded") {
+		return "Operation timed out. The container may be unresponsive."
+	}
+	if strings.Contains(errMsg, "network") && strings.Contains(errMsg, "not found") {
+		return "Network not found. The container's network may have been removed."
+	}
+	if strings.Contains(errMsg, "port is already allocated") {
+		return "Port is already in use. Another container may be using the same port."
+	}
+	if strings.Contains(errMsg, "no such image") {
+		return "Image not found. The image may not exist or has been removed."
+	}
+	if strings.Contains(errMsg, "Conflict") {
+		return "Container name conflict. A container with this name already exists."
+	}
+	if strings.Contains(errMsg, "working directory") {
+		return "Working directory not found. The compose project directory may have been moved or deleted."
+	}
+	if strings.Contains(errMsg, "docker compose") {
+		return "Docker Compose command failed. Check the compose file and project configuration."
+	}
+
+	// Return the original error message if no pattern matches
+	return errMsg
+}
+
+// NewErrorResponse creates the structured error reported for a failed
+// operation, whether started from the UI or by the auto-updater
+func NewErrorResponse(operation, containerName string, err error) models.ErrorResponse {
+	userMessage := FormatErrorMessage(err)
+
+	// A failed update that was rolled back leaves the service running on its old image
+	rolledBack := false
+	var rollbackErr *RollbackError
+	if errors.As(err, &rollbackErr) {
+		rolledBack = rollbackErr.RolledBack
+		userMessage = sentence(FormatErrorMessage(rollbackErr.Err))
+		if rolledBack {
+			userMessage += " The original container was restored."
+		} else {
+			userMessage += " Restoring the original container also failed; check it manually."
+		}
+	}
+
+	// Include what the new container printed before it was judged unhealthy
+	details := err.Error()
+	var verifyErr *VerificationError
+	if errors.As(err, &verifyErr) && verifyErr.Logs != "" {
+		details += "\n\nContainer logs:\n" + verifyErr.Logs
+	}
+
+	return models.ErrorResponse{
+		Operation:  operation,
+		Container:  containerName,
+		Message:    userMessage,
+		Details:    details,
+		RolledBack: rolledBack,
+		Timestamp:  time.Now(),
+	}
+}
+
+// sentence ends a message with a full stop unless it already has terminal
+// punctuation, so another sentence can follow it
+func sentence(message string) string {
+	if strings.HasSuffix(message, ".") || strings.HasSuffix(message, "!") || strings.HasSuffix(message, "?") {
+		return message
+	}
+	return message + "."
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "container not found",
+			err:      &testError{msg: "No such container: abc123"},
+			expected: "Container not found. It may have been removed.",
+		},
+		{
+			name:     "operation in progress",
+			err:      &testError{msg: "operation already in progress"},
+			expected: "Operation already in progress. Please wait.",
+		},
+		{
+			name:     "timeout error",
+			err:      &testError{msg: "context deadline exceeded"},
+			expected: "Operation timed out. The container may be unresponsive.",
+		},
+		{
+			name:     "unknown error",
+			err:      &testError{msg: "some unknown error"},
+			expected: "some unknown error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatErrorMessage(tt.err)
+			if result != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNewErrorResponse(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectMessage    string
+		expectDetails    []string
+		expectRolledBack bool
+	}{
+		{
+			name:          "plain error",
+			err:           &testError{msg: "No such container: abc123"},
+			expectMessage: "Container not found. It may have been removed.",
+			expectDetails: []string{"No such container: abc123"},
+		},
+		{
+			name: "rolled back",
+			err: &RollbackError{
+				Err:        &testError{msg: "port is already allocated"},
+				RolledBack: true,
+			},
+			expectMessage:    "Port is already in use. Another container may be using the same port. The original container was restored.",
+			expectDetails:    []string{"port is already allocated (rolled back to the original container)"},
+			expectRolledBack: true,
+		},
+		{
+			name: "rollback failed",
+			err: &RollbackError{
+				Err:         &testError{msg: "start failed"},
+				RollbackErr: &testError{msg: "rename not permitted"},
+			},
+			expectMessage: "start failed. Restoring the original container also failed; check it manually.",
+			expectDetails: []string{"rollback failed: rename not permitted"},
+		},
+		{
+			name: "verification logs",
+			err: &RollbackError{
+				Err:        &VerificationError{Container: "app", Reason: "container exited with code 1", Logs: "panic: missing config"},
+				RolledBack: true,
+			},
+			expectMessage:    "container app failed verification: container exited with code 1. The original container was restored.",
+			expectDetails:    []string{"container exited with code 1", "\n\nContainer logs:\npanic: missing config"},
+			expectRolledBack: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := NewErrorResponse("update", "app", tt.err)
+			if resp.Operation != "update" || resp.Container != "app" {
+				t.Errorf("unexpected target %q %q", resp.Operation, resp.Container)
+			}
+			if resp.Message != tt.expectMessage {
+				t.Errorf("expected message %q, got %q", tt.expectMessage, resp.Message)
+			}
+			for _, want := range tt.expectDetails {
+				if !strings.Contains(resp.Details, want) {
+					t.Errorf("expected details containing %q, got %q", want, resp.Details)
+				}
+			}
+			if resp.RolledBack != tt.expectRolledBack {
+				t.Errorf("expected rolled back %v, got %v", tt.expectRolledBack, resp.RolledBack)
+			}
+		})
+	}
+}
+
+// testError is a simple error implementation for testing
+type testError struct {
+	msg string
+}
+
+func (e *testError) Error() string {
+	return e.msg
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Container labels understood by BleedingEdge
+const (
+	// LabelAutoUpdate opts a container into unattended updates: "true", "notify" or "off"
+	LabelAutoUpdate = "bleedingedge.autoupdate"
+	// LabelSchedule restricts unattended updates to a cron schedule
+	LabelSchedule = "bleedingedge.schedule"
+	// LabelVerifyWindow sets how long an updated container without a healthcheck must stay up
+	LabelVerifyWindow = "bleedingedge.verify-window"
+	// LabelTrack follows newer version tags instead of the current tag's digest
+	LabelTrack = "bleedingedge.track"
+	// LabelCheck overrides whether a container's image is checked for updates: "true" or "false"
+	LabelCheck = "bleedingedge.check"
+	// LabelRevertedFrom is set by a revert to the tagged image the container
+	// was pinned from, so the next update returns it to that tag
+	LabelRevertedFrom = "bleedingedge.reverted-from"
+)
+
+// DefaultVerifyWindow is used when a container has no verify-window label
+const DefaultVerifyWindow = 10 * time.Second
+
+// AutoUpdateMode describes what to do when a container has an update available
+type AutoUpdateMode string
+
+const (
+	// AutoUpdateOff leaves the container alone (the default)
+	AutoUpdateOff AutoUpdateMode = "off"
+	// AutoUpdateNotify reports the update without applying it
+	AutoUpdateNotify AutoUpdateMode = "notify"
+	// AutoUpdateEnabled applies the update automatically
+	AutoUpdateEnabled AutoUpdateMode = "true"
+)
+
+// AutoUpdatePolicy is the unattended update configuration read from labels
+type AutoUpdatePolicy struct {
+	Mode     AutoUpdateMode // What to do when an update is found
+	Schedule string         // Optional cron expression limiting when updates run
+}
+
+// GetAutoUpdatePolicy reads the auto-update policy from container labels
+// Unknown values are reported as errors and treated as AutoUpdateOff
+func GetAutoUpdatePolicy(labels map[string]string) (AutoUpdatePolicy, error) {
+	policy := AutoUpdatePolicy{
+		Mode:     AutoUpdateOff,
+		Schedule: strings.TrimSpace(labels[LabelSchedule]),
+	}
+
+	value, ok := labels[LabelAutoUpdate]
+	if !ok {
+		return policy, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "on", "yes", "1":
+		policy.Mode = AutoUpdateEnabled
+	case "notify":
+		policy.Mode = AutoUpdateNotify
+	case "off", "false", "no", "0", "":
+		policy.Mode = AutoUpdateOff
+	default:
+		return policy, fmt.Errorf("invalid %s label value %q (must be true, notify or off)", LabelAutoUpdate, value)
+	}
+
+	return policy, nil
+}
+
+// TagTracking describes which newer tags a container may move to
+type TagTracking string
+
+const (
+	// TrackDigest only follows the digest of the current tag (the default)
+	TrackDigest TagTracking = "digest"
+	// TrackMajor follows any newer version
+	TrackMajor TagTracking = "semver:major"
+	// TrackMinor follows newer versions with the same major version
+	TrackMinor TagTracking = "semver:minor"
+	// TrackPatch follows newer versions with the same major and minor version
+	TrackPatch TagTracking = "semver:patch"
+)
+
+// GetTagTracking reads the tag tracking mode from container labels
+// Unknown values are reported as errors and treated as TrackDigest
+func GetTagTracking(labels map[string]string) (TagTracking, error) {
+	value, ok := labels[LabelTrack]
+	if !ok {
+		return TrackDigest, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "digest", "":
+		return TrackDigest, nil
+	case "semver", "semver:major":
+		return TrackMajor, nil
+	case "semver:minor":
+		return TrackMinor, nil
+	case "semver:patch":
+		return TrackPatch, nil
+	default:
+		return TrackDigest, fmt.Errorf("invalid %s label value %q (must be digest, semver:major, semver:minor or semver:patch)", LabelTrack, value)
+	}
+}
+
+// CheckOverride decides whether a container's image is checked for updates
+type CheckOverride string
+
+const (
+	// CheckAuto checks images that came from a registry (the default)
+	CheckAuto CheckOverride = "auto"
+	// CheckAlways checks the image even if it looks locally built
+	CheckAlways CheckOverride = "true"
+	// CheckNever never checks the image
+	CheckNever CheckOverride = "false"
+)
+
+// GetCheckOverride reads the update check override from container labels
+// Unknown values are reported as errors and treated as CheckAuto
+func GetCheckOverride(labels map[string]string) (CheckOverride, error) {
+	value, ok := labels[LabelCheck]
+	if !ok {
+		return CheckAuto, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "auto", "":
+		return CheckAuto, nil
+	case "true", "on", "yes", "1":
+		return CheckAlways, nil
+	case "false", "off", "no", "0":
+		return CheckNever, nil
+	default:
+		return CheckAuto, fmt.Errorf("invalid %s label value %q (must be true or false)", LabelCheck, value)
+	}
+}
+
+// GetVerifyWindow reads the post-update verification window from container labels
+// A missing label yields DefaultVerifyWindow; "0s" only requires the container to be running
+func GetVerifyWindow(labels map[string]string) (time.Duration, error) {
+	value, ok := labels[LabelVerifyWindow]
+	if !ok || strings.TrimSpace(value) == "" {
+		return DefaultVerifyWindow, nil
+	}
+
+	window, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || window < 0 {
+		return DefaultVerifyWindow, fmt.Errorf("invalid %s label value %q (must be a duration such as 30s)", LabelVerifyWindow, value)
+	}
+	return window, nil
+}
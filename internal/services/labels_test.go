@@ -0,0 +1,118 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAutoUpdatePolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		labels       map[string]string
+		expectedMode AutoUpdateMode
+		expectError  bool
+	}{
+		{name: "no labels", labels: nil, expectedMode: AutoUpdateOff},
+		{name: "enabled", labels: map[string]string{LabelAutoUpdate: "true"}, expectedMode: AutoUpdateEnabled},
+		{name: "notify", labels: map[string]string{LabelAutoUpdate: "Notify"}, expectedMode: AutoUpdateNotify},
+		{name: "off", labels: map[string]string{LabelAutoUpdate: "off"}, expectedMode: AutoUpdateOff},
+		{name: "invalid", labels: map[string]string{LabelAutoUpdate: "sometimes"}, expectedMode: AutoUpdateOff, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := GetAutoUpdatePolicy(tt.labels)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetAutoUpdatePolicy() error = %v, expectError %v", err, tt.expectError)
+			}
+			if policy.Mode != tt.expectedMode {
+				t.Errorf("expected mode %q, got %q", tt.expectedMode, policy.Mode)
+			}
+		})
+	}
+}
+
+func TestGetVerifyWindow(t *testing.T) {
+	tests := []struct {
+		name           string
+		labels         map[string]string
+		expectedWindow time.Duration
+		expectError    bool
+	}{
+		{name: "no labels", labels: nil, expectedWindow: DefaultVerifyWindow},
+		{name: "custom window", labels: map[string]string{LabelVerifyWindow: "45s"}, expectedWindow: 45 * time.Second},
+		{name: "running only", labels: map[string]string{LabelVerifyWindow: "0s"}, expectedWindow: 0},
+		{name: "negative", labels: map[string]string{LabelVerifyWindow: "-5s"}, expectedWindow: DefaultVerifyWindow, expectError: true},
+		{name: "invalid", labels: map[string]string{LabelVerifyWindow: "a while"}, expectedWindow: DefaultVerifyWindow, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := GetVerifyWindow(tt.labels)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetVerifyWindow() error = %v, expectError %v", err, tt.expectError)
+			}
+			if window != tt.expectedWindow {
+				t.Errorf("expected window %v, got %v", tt.expectedWindow, window)
+			}
+		})
+	}
+}
+
+func TestGetTagTracking(t *testing.T) {
+	tests := []struct {
+		name             string
+		labels           map[string]string
+		expectedTracking TagTracking
+		expectError      bool
+	}{
+		{name: "no labels", labels: nil, expectedTracking: TrackDigest},
+		{name: "digest", labels: map[string]string{LabelTrack: "digest"}, expectedTracking: TrackDigest},
+		{name: "semver", labels: map[string]string{LabelTrack: "semver"}, expectedTracking: TrackMajor},
+		{name: "major", labels: map[string]string{LabelTrack: "semver:major"}, expectedTracking: TrackMajor},
+		{name: "minor", labels: map[string]string{LabelTrack: "SemVer:Minor"}, expectedTracking: TrackMinor},
+		{name: "patch", labels: map[string]string{LabelTrack: " semver:patch "}, expectedTracking: TrackPatch},
+		{name: "invalid", labels: map[string]string{LabelTrack: "semver:build"}, expectedTracking: TrackDigest, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracking, err := GetTagTracking(tt.labels)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetTagTracking() error = %v, expectError %v", err, tt.expectError)
+			}
+			if tracking != tt.expectedTracking {
+				t.Errorf("expected tracking %q, got %q", tt.expectedTracking, tracking)
+			}
+		})
+	}
+}
+
+func TestGetCheckOverride(t *testing.T) {
+	tests := []struct {
+		name             string
+		labels           map[string]string
+		expectedOverride CheckOverride
+		expectError      bool
+	}{
+		{name: "no labels", labels: nil, expectedOverride: CheckAuto},
+		{name: "empty", labels: map[string]string{LabelCheck: ""}, expectedOverride: CheckAuto},
+		{name: "true", labels: map[string]string{LabelCheck: "true"}, expectedOverride: CheckAlways},
+		{name: "yes", labels: map[string]string{LabelCheck: " Yes "}, expectedOverride: CheckAlways},
+		{name: "false", labels: map[string]string{LabelCheck: "false"}, expectedOverride: CheckNever},
+		{name: "off", labels: map[string]string{LabelCheck: "OFF"}, expectedOverride: CheckNever},
+		{name: "invalid", labels: map[string]string{LabelCheck: "sometimes"}, expectedOverride: CheckAuto, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override, err := GetCheckOverride(tt.labels)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("GetCheckOverride() error = %v, expectError %v", err, tt.expectError)
+			}
+			if override != tt.expectedOverride {
+				t.Errorf("expected override %q, got %q", tt.expectedOverride, override)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"gopkg.in/yaml.v3"
+)
+
+// revertOverridePrefix starts the name of the compose override files that pin
+// services to their previous digests during a revert
+const revertOverridePrefix = "bleedingedge-revert-"
+
+// SnapshotContainers records the image digest and full configuration of each
+// container so an update can later be reverted. The digest comes from the
+// container's last update check, or from its image's repo digests if it was
+// never checked. Containers that cannot be inspected are snapshotted without
+// a configuration.
+func SnapshotContainers(ctx context.Context, client docker.DockerClient, containers []models.ContainerInfo) []models.ContainerSnapshot {
+	logger := slog.Default()
+	snapshots := make([]models.ContainerSnapshot, 0, len(containers))
+
+	for _, c := range containers {
+		snapshot := models.ContainerSnapshot{
+			Name:        c.Name,
+			Service:     c.Labels[labelComposeService],
+			Image:       c.Image,
+			ImageDigest: c.ImageDigest,
+		}
+
+		containerJSON, err := client.InspectContainer(ctx, c.ID)
+		if err != nil {
+			logger.Warn("failed to inspect container for snapshot",
+				"container_name", c.Name,
+				"error", err,
+			)
+			snapshots = append(snapshots, snapshot)
+			continue
+		}
+
+		if params, err := ExtractContainerParams(containerJSON); err == nil {
+			snapshot.Params = params
+		}
+		if snapshot.ImageDigest == "" {
+			if image, err := client.InspectImage(ctx, containerJSON.Image); err == nil && len(image.RepoDigests) > 0 {
+				snapshot.ImageDigest = digestOnly(image.RepoDigests[0])
+			}
+		}
+
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots
+}
+
+// PinnedImage returns the image reference pinned to a digest, e.g.
+// nginx@sha256:... for nginx:latest
+func PinnedImage(image, digest string) (string, error) {
+	if digest == "" {
+		return "", fmt.Errorf("no previous digest recorded for %s", image)
+	}
+	if strings.HasPrefix(image, "sha256:") {
+		return "", fmt.Errorf("image %s is not from a registry", image)
+	}
+
+	repository, _, _ := strings.Cut(image, "@")
+	// A colon after the last slash separates the tag; one before it is a registry port
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	return repository + "@" + digest, nil
+}
+
+// TaggedImage returns the image a container is checked and updated against:
+// the tag a revert pinned it from, recorded in its LabelRevertedFrom label, or
+// its own image otherwise
+func TaggedImage(image string, labels map[string]string) string {
+	if tagged := labels[LabelRevertedFrom]; tagged != "" {
+		return tagged
+	}
+	return image
+}
+
+// RevertStandaloneContainer recreates a standalone container with the
+// configuration it had before an update, pinned to its previous image digest.
+// Like an update, the current container is restored if the revert fails.
+func RevertStandaloneContainer(ctx context.Context, client docker.DockerClient, containerID string, snapshot models.ContainerSnapshot) (err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "services.revert_standalone_container",
+		attribute.String("container.id", containerID),
+		attribute.String("container.name", snapshot.Name),
+	)
+	defer func() { tracing.End(span, err) }()
+	logger := slog.Default()
+	logger.InfoContext(ctx, "starting standalone container revert",
+		"container_id", containerID,
+		"container_name", snapshot.Name,
+		"digest", snapshot.ImageDigest,
+		"operation", "revert",
+	)
+
+	image, err := PinnedImage(snapshot.Image, snapshot.ImageDigest)
+	if err != nil {
+		return err
+	}
+	if snapshot.Params == nil {
+		return fmt.Errorf("no configuration recorded for %s", snapshot.Name)
+	}
+
+	// Copy the recorded configuration so the snapshot itself is never modified
+	params := &models.ContainerParams{}
+	if err := deepCopy(snapshot.Params, params); err != nil {
+		return fmt.Errorf("failed to copy recorded configuration for %s: %w", snapshot.Name, err)
+	}
+	params.Image = image
+	params.Config.Image = image
+	if tagged := TaggedImage(snapshot.Image, snapshotLabels(snapshot)); !isPinned(tagged) {
+		if params.Config.Labels == nil {
+			params.Config.Labels = make(map[string]string)
+		}
+		params.Config.Labels[LabelRevertedFrom] = tagged
+	}
+
+	stepCtx, stepSpan := step(ctx, "revert", "inspect", "Inspecting container configuration")
+	containerJSON, err := client.InspectContainer(stepCtx, containerID)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+
+	// The previous image is usually still present; pull it only if it was pruned
+	stepCtx, stepSpan = step(ctx, "revert", "pull", "Fetching "+image)
+	if _, err = client.InspectImage(stepCtx, image); err != nil {
+		err = client.PullImage(stepCtx, image)
+	}
+	tracing.End(stepSpan, err)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to pull previous image",
+			"container_name", snapshot.Name,
+			"image", image,
+			"operation", "revert",
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return fmt.Errorf("failed to pull previous image %s: %w", image, err)
+	}
+
+	return replaceStandaloneContainer(ctx, client, logger, start, "revert", containerID, containerJSON, params)
+}
+
+// RevertComposeServices re-runs the services of a compose project that appear
+// in the snapshots, pinned to their previous digests through an override file
+func RevertComposeServices(ctx context.Context, client docker.DockerClient, projectName, workDir string, containers []models.ContainerInfo, snapshots []models.ContainerSnapshot) error {
+	start := time.Now()
+	logger := slog.Default()
+	logger.Info("starting compose project revert",
+		"project_name", projectName,
+		"working_dir", workDir,
+		"operation", "revert",
+	)
+
+	if workDir == "" {
+		return fmt.Errorf("working directory is required for compose project %s", projectName)
+	}
+	// The override file is written here, where an agent's docker compose cannot read it
+	if docker.RunsCommandsRemotely(client) {
+		return fmt.Errorf("reverting compose project %s is not supported on agent hosts", projectName)
+	}
+
+	// Pin each service to the digest it ran before the update, recording the
+	// tag it was pinned from
+	images := make(map[string]string)
+	tags := make(map[string]string)
+	var services []string
+	for _, snapshot := range snapshots {
+		if snapshot.Service == "" || slices.Contains(services, snapshot.Service) {
+			continue
+		}
+		image, err := PinnedImage(snapshot.Image, snapshot.ImageDigest)
+		if err != nil {
+			return fmt.Errorf("cannot revert service %s: %w", snapshot.Service, err)
+		}
+		images[snapshot.Service] = image
+		if tagged := TaggedImage(snapshot.Image, snapshotLabels(snapshot)); !isPinned(tagged) {
+			tags[snapshot.Service] = tagged
+		}
+		services = append(services, snapshot.Service)
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("no services to revert in compose project %s", projectName)
+	}
+
+	var configFiles []string
+	for _, c := range containers {
+		if configFiles = composeConfigFiles(c.Labels); configFiles != nil {
+			break
+		}
+	}
+	// Compose only reads its default files when no -f is given, so the override
+	// can only be layered on top of files that are known
+	if len(configFiles) == 0 {
+		return fmt.Errorf("compose files of project %s are unknown", projectName)
+	}
+
+	jobs.Step(ctx, "override", "Pinning "+strings.Join(services, ", ")+" to their previous digests")
+	override, err := writeRevertOverride(images, tags)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(override)
+
+	jobs.Step(ctx, "recreate", "Recreating "+strings.Join(services, ", "))
+	if output, err := runCompose(ctx, client, workDir, composeUpArgs(projectName, append(configFiles, override), services)); err != nil {
+		logger.Error("failed to execute docker compose up",
+			"project_name", projectName,
+			"working_dir", workDir,
+			"services", services,
+			"operation", "revert",
+			"error", err,
+			"output", string(output),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return fmt.Errorf("failed to execute 'docker compose up' for project %s: %w\nOutput: %s", projectName, err, string(output))
+	}
+
+	jobs.Step(ctx, "verify", "Verifying reverted services are healthy")
+	if err := verifyComposeProject(ctx, client, projectName, services); err != nil {
+		return fmt.Errorf("compose project %s failed verification: %w", projectName, err)
+	}
+
+	logger.Info("compose project reverted successfully",
+		"project_name", projectName,
+		"services", services,
+		"operation", "revert",
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+
+	return nil
+}
+
+// snapshotLabels returns the labels recorded in a snapshot, if any
+func snapshotLabels(snapshot models.ContainerSnapshot) map[string]string {
+	if snapshot.Params == nil || snapshot.Params.Config == nil {
+		return nil
+	}
+	return snapshot.Params.Config.Labels
+}
+
+// writeRevertOverride writes a compose override file setting each service's
+// image, and the LabelRevertedFrom label of services with a tag, and returns
+// its path. The caller removes it when done.
+func writeRevertOverride(images, tags map[string]string) (string, error) {
+	services := make(map[string]map[string]interface{})
+	for service, image := range images {
+		services[service] = map[string]interface{}{"image": image}
+		if tag := tags[service]; tag != "" {
+			services[service]["labels"] = map[string]string{LabelRevertedFrom: tag}
+		}
+	}
+	data, err := yaml.Marshal(map[string]interface{}{"services": services})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode revert override: %w", err)
+	}
+
+	file, err := os.CreateTemp("", revertOverridePrefix+"*.yml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create revert override: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write revert override: %w", err)
+	}
+	return file.Name(), nil
+}
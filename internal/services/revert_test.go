@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/registry"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"gopkg.in/yaml.v3"
+)
+
+func TestPinnedImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		digest    string
+		expected  string
+		expectErr bool
+	}{
+		{name: "tagged", image: "nginx:latest", digest: "sha256:old", expected: "nginx@sha256:old"},
+		{name: "untagged", image: "nginx", digest: "sha256:old", expected: "nginx@sha256:old"},
+		{name: "registry with port", image: "registry.local:5000/team/app:1.2", digest: "sha256:old", expected: "registry.local:5000/team/app@sha256:old"},
+		{name: "already pinned", image: "ghcr.io/example/app@sha256:new", digest: "sha256:old", expected: "ghcr.io/example/app@sha256:old"},
+		{name: "no digest", image: "nginx:latest", expectErr: true},
+		{name: "image ID", image: "sha256:abc", digest: "sha256:old", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PinnedImage(tt.image, tt.digest)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("PinnedImage() error = %v, expectErr %v", err, tt.expectErr)
+			}
+			if got != tt.expected {
+				t.Errorf("PinnedImage() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotAndRevertStandaloneContainer(t *testing.T) {
+	current := richContainerJSON()
+	current.Config.Image = "ghcr.io/example/app:1"
+	current.Config.Env = []string{"MODE=debug"}
+
+	var pulled []string
+	var created *container.Config
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			if id == "new-container-id" {
+				return richContainerJSON(), nil
+			}
+			return current, nil
+		},
+		InspectImageFunc: func(ctx context.Context, ref string) (image.InspectResponse, error) {
+			if strings.Contains(ref, "@") {
+				return image.InspectResponse{}, fmt.Errorf("No such image: %s", ref)
+			}
+			return image.InspectResponse{ID: ref, RepoDigests: []string{"ghcr.io/example/app@sha256:old"}}, nil
+		},
+		PullImageFunc: func(ctx context.Context, ref string) error {
+			pulled = append(pulled, ref)
+			return nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			created = config
+			return "new-container-id", nil
+		},
+	}
+
+	// Snapshot the container as it was before an update
+	before := richContainerJSON()
+	snapshotClient := *mockClient
+	snapshotClient.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+		return before, nil
+	}
+	snapshots := SnapshotContainers(context.Background(), &snapshotClient, []models.ContainerInfo{
+		{ID: before.ID, Name: "app", Image: "ghcr.io/example/app:1"},
+	})
+	if len(snapshots) != 1 || snapshots[0].ImageDigest != "sha256:old" || snapshots[0].Params == nil {
+		t.Fatalf("expected a snapshot with digest and configuration, got %+v", snapshots)
+	}
+
+	if err := RevertStandaloneContainer(context.Background(), mockClient, current.ID, snapshots[0]); err != nil {
+		t.Fatalf("RevertStandaloneContainer() error = %v", err)
+	}
+
+	if !slices.Equal(pulled, []string{"ghcr.io/example/app@sha256:old"}) {
+		t.Errorf("expected the pruned previous image to be pulled by digest, got %v", pulled)
+	}
+	if created == nil {
+		t.Fatal("expected a container to be created")
+	}
+	if created.Image != "ghcr.io/example/app@sha256:old" {
+		t.Errorf("expected image pinned to the previous digest, got %s", created.Image)
+	}
+	if !slices.Equal(created.Env, []string{"MODE=production"}) {
+		t.Errorf("expected the recorded configuration, got env %v", created.Env)
+	}
+	if snapshots[0].Params.Config.Image != "ghcr.io/example/app:1" {
+		t.Errorf("expected the snapshot to be left unchanged, got image %s", snapshots[0].Params.Config.Image)
+	}
+}
+
+func TestUpdateRevertUpdateReturnsToTag(t *testing.T) {
+	// The container as it is after an update, before the revert
+	running := richContainerJSON()
+	running.Config.Image = "ghcr.io/example/app:1"
+
+	var pulled []string
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return running, nil
+		},
+		InspectImageFunc: func(ctx context.Context, ref string) (image.InspectResponse, error) {
+			return image.InspectResponse{ID: ref, RepoDigests: []string{"ghcr.io/example/app@sha256:old"}}, nil
+		},
+		PullImageFunc: func(ctx context.Context, ref string) error {
+			pulled = append(pulled, ref)
+			return nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			// The next operation finds the container as it was created
+			running.Config = config
+			running.Image = config.Image
+			return "new-container-id", nil
+		},
+	}
+
+	snapshots := SnapshotContainers(context.Background(), mockClient, []models.ContainerInfo{
+		{ID: running.ID, Name: "app", Image: "ghcr.io/example/app:1", ImageDigest: "sha256:old"},
+	})
+	snapshot := snapshots[0]
+	if err := RevertStandaloneContainer(context.Background(), mockClient, running.ID, snapshot); err != nil {
+		t.Fatalf("RevertStandaloneContainer() error = %v", err)
+	}
+	if running.Config.Image != "ghcr.io/example/app@sha256:old" || running.Config.Labels[LabelRevertedFrom] != "ghcr.io/example/app:1" {
+		t.Fatalf("expected the reverted container pinned and labelled with its tag, got %s %v", running.Config.Image, running.Config.Labels)
+	}
+
+	// The pinned container is checked against its tag rather than reported pinned
+	groups := []models.ContainerGroup{{
+		ID:         running.ID,
+		Type:       models.GroupTypeStandalone,
+		Containers: []models.ContainerInfo{{Name: "app", Image: running.Config.Image, Labels: running.Config.Labels}},
+	}}
+	resolver := &registry.MockClient{
+		GetDigestFunc: func(ctx context.Context, imageName string) (*registry.ManifestDigest, error) {
+			if imageName != "ghcr.io/example/app:1" {
+				t.Errorf("expected the tag to be checked, got %s", imageName)
+			}
+			return &registry.ManifestDigest{Digest: "sha256:new"}, nil
+		},
+	}
+	if err := CheckUpdates(context.Background(), mockClient, resolver, groups, CheckOptions{}); err != nil {
+		t.Fatalf("CheckUpdates() error = %v", err)
+	}
+	if c := groups[0].Containers[0]; !c.HasUpdate || c.CheckStatus != models.CheckStatusUpdateAvailable {
+		t.Errorf("expected an update back to the tag, got %+v", c)
+	}
+
+	pulled = nil
+	if err := UpdateStandaloneContainer(context.Background(), mockClient, running.ID); err != nil {
+		t.Fatalf("UpdateStandaloneContainer() error = %v", err)
+	}
+	if !slices.Equal(pulled, []string{"ghcr.io/example/app:1"}) {
+		t.Errorf("expected the tag to be pulled, got %v", pulled)
+	}
+	if running.Config.Image != "ghcr.io/example/app:1" {
+		t.Errorf("expected the container recreated from its tag, got %s", running.Config.Image)
+	}
+	if _, ok := running.Config.Labels[LabelRevertedFrom]; ok {
+		t.Errorf("expected the revert label to be removed, got %v", running.Config.Labels)
+	}
+}
+
+func TestRevertComposeServicesRequiresDigests(t *testing.T) {
+	containers := []models.ContainerInfo{
+		{Name: "shop-web-1", Labels: map[string]string{labelComposeService: "web", labelComposeConfigFiles: "/srv/shop/compose.yaml"}},
+	}
+	snapshots := []models.ContainerSnapshot{{Name: "shop-web-1", Service: "web", Image: "nginx:latest"}}
+
+	err := RevertComposeServices(context.Background(), &docker.MockClient{}, "shop", "/srv/shop", containers, snapshots)
+	if err == nil || !strings.Contains(err.Error(), "no previous digest") {
+		t.Errorf("expected a missing digest error, got %v", err)
+	}
+}
+
+func TestComposeConfigFiles(t *testing.T) {
+	labels := map[string]string{
+		labelComposeConfigFiles: "/srv/shop/compose.yaml,/srv/shop/compose.prod.yaml,/tmp/" + revertOverridePrefix + "123.yml",
+	}
+
+	got := composeConfigFiles(labels)
+	expected := []string{"/srv/shop/compose.yaml", "/srv/shop/compose.prod.yaml"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("composeConfigFiles() = %v, expected %v", got, expected)
+	}
+	if composeConfigFiles(nil) != nil {
+		t.Error("expected no files without the label")
+	}
+}
+
+func TestWriteRevertOverride(t *testing.T) {
+	path, err := writeRevertOverride(
+		map[string]string{"web": "nginx@sha256:old", "worker": "ghcr.io/example/worker@sha256:prev"},
+		map[string]string{"web": "nginx:latest"},
+	)
+	if err != nil {
+		t.Fatalf("writeRevertOverride() error = %v", err)
+	}
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read override: %v", err)
+	}
+
+	var override struct {
+		Services map[string]struct {
+			Image  string            `yaml:"image"`
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &override); err != nil {
+		t.Fatalf("override is not valid YAML: %v", err)
+	}
+	if override.Services["web"].Image != "nginx@sha256:old" || override.Services["worker"].Image != "ghcr.io/example/worker@sha256:prev" {
+		t.Errorf("unexpected override:\n%s", data)
+	}
+	if override.Services["web"].Labels[LabelRevertedFrom] != "nginx:latest" || override.Services["worker"].Labels != nil {
+		t.Errorf("expected only web to record its tag, got:\n%s", data)
+	}
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/distribution/reference"
+)
+
+// versionPattern matches version tags such as 16, v1.2 or 3.19.1-alpine
+var versionPattern = regexp.MustCompile(`^(v?)(\d+)(?:\.(\d+))?(?:\.(\d+))?(-[0-9A-Za-z][0-9A-Za-z.-]*)?$`)
+
+// version is a tag parsed as a semantic version. The prefix and suffix are kept
+// so a tag only ever moves to tags of the same variant, e.g. 16.3-alpine to
+// 16.4-alpine but never to 16.4 or 16.4-bookworm.
+type version struct {
+	prefix string // "v" or ""
+	parts  []int  // Major, minor and patch as far as the tag has them
+	suffix string // Variant or pre-release suffix including the dash, e.g. "-alpine"
+}
+
+// parseVersion parses a tag as a version, reporting false for tags such as
+// latest or stable that are not versions
+func parseVersion(tag string) (version, bool) {
+	m := versionPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return version{}, false
+	}
+
+	v := version{prefix: m[1], suffix: m[5]}
+	for _, part := range m[2:5] {
+		if part == "" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version{}, false
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, true
+}
+
+// compare returns -1, 0 or 1 as v is older than, equal to or newer than o
+func (v version) compare(o version) int {
+	for i := range min(len(v.parts), len(o.parts)) {
+		if v.parts[i] != o.parts[i] {
+			if v.parts[i] < o.parts[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// allows reports whether tracking lets a container on v move to candidate
+func (v version) allows(candidate version, tracking TagTracking) bool {
+	if candidate.prefix != v.prefix || candidate.suffix != v.suffix || len(candidate.parts) != len(v.parts) {
+		return false
+	}
+
+	var fixed int
+	switch tracking {
+	case TrackMajor:
+		fixed = 0
+	case TrackMinor:
+		fixed = 1
+	case TrackPatch:
+		fixed = 2
+	default:
+		return false
+	}
+	for i := range min(fixed, len(v.parts)) {
+		if candidate.parts[i] != v.parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// newestTag returns the newest of tags that tracking allows moving the current
+// tag to, or an empty string if none is newer than current
+func newestTag(current string, tags []string, tracking TagTracking) string {
+	base, ok := parseVersion(current)
+	if !ok {
+		return ""
+	}
+
+	var newest string
+	best := base
+	for _, tag := range tags {
+		candidate, ok := parseVersion(tag)
+		if !ok || !base.allows(candidate, tracking) || candidate.compare(best) <= 0 {
+			continue
+		}
+		newest, best = tag, candidate
+	}
+	return newest
+}
+
+// splitTag splits an image reference into its repository and tag, reporting
+// false for references pinned to a digest or without a tag
+func splitTag(imageName string) (string, string, bool) {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return "", "", false
+	}
+	if _, pinned := named.(reference.Digested); pinned {
+		return "", "", false
+	}
+	tagged, ok := named.(reference.Tagged)
+	if !ok {
+		return "", "", false
+	}
+
+	// Keep the repository as the user wrote it, e.g. postgres rather than
+	// docker.io/library/postgres
+	repo := strings.TrimSuffix(imageName, ":"+tagged.Tag())
+	return repo, tagged.Tag(), true
+}
+
+// TrackedImage returns the image a container should be updated to when tag
+// tracking found a newer tag, or an empty string to keep its current image
+func TrackedImage(c models.ContainerInfo) string {
+	if c.LatestTag == "" {
+		return ""
+	}
+	repo, _, ok := splitTag(TaggedImage(c.Image, c.Labels))
+	if !ok {
+		return ""
+	}
+	return repo + ":" + c.LatestTag
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
+)
+
+func TestNewestTag(t *testing.T) {
+	tags := []string{
+		"latest", "stable", "alpine",
+		"15", "16", "17",
+		"16.3", "16.4", "16.10", "17.0", "17.1",
+		"16.3-alpine", "16.4-alpine", "17.0-alpine", "16.4-bookworm",
+		"v1.2.3", "v1.2.4", "v1.3.0", "v2.0.0", "1.9.9",
+		"1.2.3", "1.2.10", "1.3.0-rc1", "2.0.0-beta",
+	}
+
+	tests := []struct {
+		name     string
+		current  string
+		tracking TagTracking
+		expected string
+	}{
+		{name: "major", current: "16.3", tracking: TrackMajor, expected: "17.1"},
+		{name: "minor compares numerically", current: "16.3", tracking: TrackMinor, expected: "16.10"},
+		{name: "patch", current: "1.2.3", tracking: TrackPatch, expected: "1.2.10"},
+		{name: "patch ignores pre-releases", current: "1.2.3", tracking: TrackMajor, expected: "1.9.9"},
+		{name: "major only tag", current: "16", tracking: TrackMajor, expected: "17"},
+		{name: "major only tag pinned by minor", current: "16", tracking: TrackMinor, expected: ""},
+		{name: "suffix kept", current: "16.3-alpine", tracking: TrackMinor, expected: "16.4-alpine"},
+		{name: "suffix kept across majors", current: "16.3-alpine", tracking: TrackMajor, expected: "17.0-alpine"},
+		{name: "v prefix kept", current: "v1.2.3", tracking: TrackMinor, expected: "v1.3.0"},
+		{name: "v prefix major", current: "v1.2.3", tracking: TrackMajor, expected: "v2.0.0"},
+		{name: "already newest", current: "17.1", tracking: TrackMajor, expected: ""},
+		{name: "newer than any listed", current: "18.0", tracking: TrackMajor, expected: ""},
+		{name: "not a version", current: "latest", tracking: TrackMajor, expected: ""},
+		{name: "variant only", current: "alpine", tracking: TrackMajor, expected: ""},
+		{name: "digest tracking", current: "16.3", tracking: TrackDigest, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newestTag(tt.current, tags, tt.tracking); got != tt.expected {
+				t.Errorf("newestTag(%q, %s) = %q, expected %q", tt.current, tt.tracking, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		tag         string
+		expectParts []int
+		expectOK    bool
+	}{
+		{tag: "16", expectParts: []int{16}, expectOK: true},
+		{tag: "v1.22.3", expectParts: []int{1, 22, 3}, expectOK: true},
+		{tag: "3.19.1-alpine3.20", expectParts: []int{3, 19, 1}, expectOK: true},
+		{tag: "1.2.3.4", expectOK: false},
+		{tag: "latest", expectOK: false},
+		{tag: "1.2-", expectOK: false},
+		{tag: "sha-1a2b3c", expectOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			v, ok := parseVersion(tt.tag)
+			if ok != tt.expectOK {
+				t.Fatalf("parseVersion(%q) ok = %v, expected %v", tt.tag, ok, tt.expectOK)
+			}
+			if ok && !slices.Equal(v.parts, tt.expectParts) {
+				t.Errorf("expected parts %v, got %v", tt.expectParts, v.parts)
+			}
+		})
+	}
+}
+
+func TestTrackedImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		container models.ContainerInfo
+		expected  string
+	}{
+		{name: "docker hub", container: models.ContainerInfo{Image: "postgres:16.3-alpine", LatestTag: "16.4-alpine"}, expected: "postgres:16.4-alpine"},
+		{name: "registry with port", container: models.ContainerInfo{Image: "registry.lan:5000/team/app:v1.2.3", LatestTag: "v1.3.0"}, expected: "registry.lan:5000/team/app:v1.3.0"},
+		{name: "no newer tag", container: models.ContainerInfo{Image: "postgres:16.3"}, expected: ""},
+		{name: "pinned digest", container: models.ContainerInfo{Image: "postgres:16.3@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", LatestTag: "16.4"}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrackedImage(tt.container); got != tt.expected {
+				t.Errorf("TrackedImage() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
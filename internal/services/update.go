@@ -2,96 +2,272 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"path"
+	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
 	"github.com/bleeding-edge/bleeding-edge/internal/models"
+	"github.com/bleeding-edge/bleeding-edge/internal/tracing"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/go-connections/nat"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ExtractContainerParams extracts all configuration parameters from a running container
-// This is used to recreate the container with the same configuration but a new image
+// This is used to recreate the container with the same configuration but a new image.
+// The container and host configs are deep-copied in full, and the networking config is
+// rebuilt from the container's endpoints so networks, aliases and static IPs survive.
 func ExtractContainerParams(containerJSON types.ContainerJSON) (*models.ContainerParams, error) {
 	if containerJSON.Config == nil {
 		return nil, fmt.Errorf("container config is nil")
 	}
-	if containerJSON.HostConfig == nil {
+	if containerJSON.ContainerJSONBase == nil || containerJSON.HostConfig == nil {
 		return nil, fmt.Errorf("container host config is nil")
 	}
 
-	config := containerJSON.Config
-	hostConfig := containerJSON.HostConfig
-
 	// Extract container name (remove leading slash)
 	name := strings.TrimPrefix(containerJSON.Name, "/")
 
-	// Extract port bindings
-	portBindings := make(nat.PortMap)
-	if hostConfig.PortBindings != nil {
-		for port, bindings := range hostConfig.PortBindings {
-			portBindings[port] = bindings
+	// Deep copy the configs so the caller's inspect result is never modified
+	config := &container.Config{}
+	if err := deepCopy(containerJSON.Config, config); err != nil {
+		return nil, fmt.Errorf("failed to copy container config: %w", err)
+	}
+	hostConfig := &container.HostConfig{}
+	if err := deepCopy(containerJSON.HostConfig, hostConfig); err != nil {
+		return nil, fmt.Errorf("failed to copy host config: %w", err)
+	}
+
+	shortID := containerJSON.ID
+	if len(shortID) > 12 {
+		shortID = shortID[:12]
+	}
+
+	// The daemon defaults the hostname to the short container ID; keeping it
+	// would give the new container the old container's ID as its hostname.
+	// Hostnames are also rejected when sharing another namespace's network.
+	if config.Hostname == shortID || !hostConfig.NetworkMode.IsPrivate() || hostConfig.NetworkMode.IsContainer() {
+		config.Hostname = ""
+	}
+
+	// Inspect reports legacy links as "/target:/name/alias"; create expects "target:alias"
+	hostConfig.Links = normalizeLinks(hostConfig.Links)
+
+	// Reattach anonymous volumes (from the image's VOLUME instructions) by name
+	// so their data survives recreation
+	hostConfig.Mounts = append(hostConfig.Mounts, anonymousVolumeMounts(containerJSON.Mounts, hostConfig)...)
+
+	params := &models.ContainerParams{
+		Image:            config.Image,
+		Name:             name,
+		Config:           config,
+		HostConfig:       hostConfig,
+		NetworkingConfig: extractNetworkingConfig(containerJSON, shortID),
+	}
+
+	return params, nil
+}
+
+// StripImageDefaults removes configuration the container inherited from its old image,
+// so the new image's defaults (environment, command, labels, ...) take effect.
+// Values the user explicitly set to something other than the image default are kept.
+func StripImageDefaults(params *models.ContainerParams, imageConfig *container.Config) {
+	if imageConfig == nil {
+		return
+	}
+	config := params.Config
+
+	config.Env = subtractStrings(config.Env, imageConfig.Env)
+	if slices.Equal(config.Cmd, imageConfig.Cmd) {
+		config.Cmd = nil
+	}
+	if slices.Equal(config.Entrypoint, imageConfig.Entrypoint) {
+		config.Entrypoint = nil
+	}
+	if slices.Equal(config.Shell, imageConfig.Shell) {
+		config.Shell = nil
+	}
+	if config.WorkingDir == imageConfig.WorkingDir {
+		config.WorkingDir = ""
+	}
+	if config.User == imageConfig.User {
+		config.User = ""
+	}
+	if config.StopSignal == imageConfig.StopSignal {
+		config.StopSignal = ""
+	}
+	if reflect.DeepEqual(config.Healthcheck, imageConfig.Healthcheck) {
+		config.Healthcheck = nil
+	}
+	for key, value := range imageConfig.Labels {
+		if config.Labels[key] == value {
+			delete(config.Labels, key)
+		}
+	}
+	for port := range imageConfig.ExposedPorts {
+		if _, bound := params.HostConfig.PortBindings[port]; !bound {
+			delete(config.ExposedPorts, port)
 		}
 	}
+	for volume := range imageConfig.Volumes {
+		delete(config.Volumes, volume)
+	}
+}
+
+// extractNetworkingConfig rebuilds the endpoint settings the container was created with
+func extractNetworkingConfig(containerJSON types.ContainerJSON, shortID string) *network.NetworkingConfig {
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: make(map[string]*network.EndpointSettings),
+	}
 
-	// Extract volume binds
-	binds := []string{}
-	if hostConfig.Binds != nil {
-		binds = append(binds, hostConfig.Binds...)
+	// Containers sharing the host's or another container's network stack have no endpoints of their own
+	networkMode := containerJSON.HostConfig.NetworkMode
+	if networkMode.IsHost() || networkMode.IsNone() || networkMode.IsContainer() {
+		return networkingConfig
+	}
+	if containerJSON.NetworkSettings == nil {
+		return networkingConfig
 	}
 
-	// Extract networks
-	networks := []string{}
-	if containerJSON.NetworkSettings != nil && containerJSON.NetworkSettings.Networks != nil {
-		for networkName := range containerJSON.NetworkSettings.Networks {
-			networks = append(networks, networkName)
+	for networkName, endpoint := range containerJSON.NetworkSettings.Networks {
+		if endpoint == nil {
+			continue
 		}
+
+		settings := &network.EndpointSettings{
+			NetworkID:  endpoint.NetworkID,
+			Links:      normalizeLinks(endpoint.Links),
+			DriverOpts: endpoint.DriverOpts,
+			GwPriority: endpoint.GwPriority,
+		}
+
+		// Static addresses are only present in IPAMConfig when the user requested them
+		if endpoint.IPAMConfig != nil {
+			ipam := *endpoint.IPAMConfig
+			settings.IPAMConfig = &ipam
+		}
+
+		// Drop the alias the daemon adds automatically for the old container ID
+		for _, alias := range endpoint.Aliases {
+			if alias != shortID && alias != containerJSON.ID {
+				settings.Aliases = append(settings.Aliases, alias)
+			}
+		}
+
+		networkingConfig.EndpointsConfig[networkName] = settings
 	}
 
-	// Extract exposed ports for container config
-	exposedPorts := make(nat.PortSet)
-	if config.ExposedPorts != nil {
-		for port := range config.ExposedPorts {
-			exposedPorts[port] = struct{}{}
+	return networkingConfig
+}
+
+// anonymousVolumeMounts returns volume mounts for volumes attached to the container
+// that are not already declared in its binds or mounts
+func anonymousVolumeMounts(mountPoints []container.MountPoint, hostConfig *container.HostConfig) []mount.Mount {
+	declared := make(map[string]bool)
+	for _, bind := range hostConfig.Binds {
+		parts := strings.Split(bind, ":")
+		if len(parts) >= 2 {
+			declared[parts[1]] = true
 		}
 	}
+	for _, m := range hostConfig.Mounts {
+		declared[m.Target] = true
+	}
+	for target := range hostConfig.Tmpfs {
+		declared[target] = true
+	}
 
-	params := &models.ContainerParams{
-		Image:         config.Image,
-		Name:          name,
-		Env:           config.Env,
-		Cmd:           config.Cmd,
-		Entrypoint:    config.Entrypoint,
-		PortBindings:  portBindings,
-		Binds:         binds,
-		Networks:      networks,
-		RestartPolicy: hostConfig.RestartPolicy,
-		Labels:        config.Labels,
-		Resources:     hostConfig.Resources,
+	var mounts []mount.Mount
+	for _, mp := range mountPoints {
+		if mp.Type != mount.TypeVolume || mp.Name == "" || declared[mp.Destination] {
+			continue
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeVolume,
+			Source:   mp.Name,
+			Target:   mp.Destination,
+			ReadOnly: !mp.RW,
+		})
 	}
+	return mounts
+}
 
-	return params, nil
+// normalizeLinks converts inspect-style links ("/db:/web/db") to create-style links ("db:db")
+func normalizeLinks(links []string) []string {
+	if len(links) == 0 {
+		return links
+	}
+	normalized := make([]string, 0, len(links))
+	for _, link := range links {
+		target, alias, found := strings.Cut(link, ":")
+		if !found {
+			normalized = append(normalized, strings.TrimPrefix(link, "/"))
+			continue
+		}
+		normalized = append(normalized, strings.TrimPrefix(target, "/")+":"+path.Base(alias))
+	}
+	return normalized
+}
+
+// subtractStrings returns the values of a that do not appear in b
+func subtractStrings(a, b []string) []string {
+	if len(a) == 0 || len(b) == 0 {
+		return a
+	}
+	var result []string
+	for _, value := range a {
+		if !slices.Contains(b, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// deepCopy copies src into dst via a JSON round-trip, the same encoding the Docker API uses
+func deepCopy(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
 }
 
 // UpdateStandaloneContainer updates a standalone container by recreating it with the latest image
 // This preserves all container configuration while updating to the latest image version
 func UpdateStandaloneContainer(ctx context.Context, client docker.DockerClient, containerID string) error {
+	return UpdateStandaloneContainerTo(ctx, client, containerID, "")
+}
+
+// UpdateStandaloneContainerTo updates a standalone container like
+// UpdateStandaloneContainer, recreating it from imageName instead of its
+// current image when imageName is not empty, e.g. to move to a newer tag
+func UpdateStandaloneContainerTo(ctx context.Context, client docker.DockerClient, containerID string, imageName string) (err error) {
 	start := time.Now()
+	ctx, span := tracing.Start(ctx, "services.update_standalone_container", attribute.String("container.id", containerID))
+	defer func() { tracing.End(span, err) }()
 	logger := slog.Default()
-	logger.Info("starting standalone container update",
+	logger.InfoContext(ctx, "starting standalone container update",
 		"container_id", containerID,
 		"operation", "update",
 	)
 	
 	// Step 1: Inspect the container to get full configuration
-	containerJSON, err := client.InspectContainer(ctx, containerID)
+	stepCtx, stepSpan := step(ctx, "update", "inspect", "Inspecting container configuration")
+	containerJSON, err := client.InspectContainer(stepCtx, containerID)
+	tracing.End(stepSpan, err)
 	if err != nil {
-		logger.Error("failed to inspect container for update",
+		logger.ErrorContext(ctx, "failed to inspect container for update",
 			"container_id", containerID,
 			"operation", "update",
 			"error", err,
@@ -105,7 +281,7 @@ func UpdateStandaloneContainer(ctx context.Context, client docker.DockerClient,
 	// Step 2: Extract all container parameters
 	params, err := ExtractContainerParams(containerJSON)
 	if err != nil {
-		logger.Error("failed to extract container parameters",
+		logger.ErrorContext(ctx, "failed to extract container parameters",
 			"container_id", containerID,
 			"container_name", containerName,
 			"operation", "update",
@@ -114,133 +290,279 @@ func UpdateStandaloneContainer(ctx context.Context, client docker.DockerClient,
 		)
 		return fmt.Errorf("failed to extract container parameters for %s: %w", containerID, err)
 	}
+	// A reverted container returns to the tag it was pinned from
+	if tagged := TaggedImage(params.Image, params.Config.Labels); imageName == "" && tagged != params.Image {
+		imageName = tagged
+	}
+	delete(params.Config.Labels, LabelRevertedFrom)
+	if imageName != "" {
+		logger.InfoContext(ctx, "moving container to a newer tag",
+			"container_name", containerName,
+			"from_image", params.Image,
+			"to_image", imageName,
+		)
+		params.Image = imageName
+		params.Config.Image = imageName
+	}
 	
-	logger.Debug("extracted container parameters",
+	span.SetAttributes(attribute.String("container.name", containerName), attribute.String("container.image.name", params.Image))
+	logger.DebugContext(ctx, "extracted container parameters",
 		"container_name", containerName,
 		"image", params.Image,
 	)
 
-	// Step 3: Pull the latest image
-	logger.Debug("pulling latest image",
-		"container_name", containerName,
-		"image", params.Image,
-	)
-	if err := client.PullImage(ctx, params.Image); err != nil {
-		logger.Error("failed to pull latest image",
+	// Drop defaults inherited from the old image so the new image's apply
+	var imageConfig container.Config
+	if oldImage, err := client.InspectImage(ctx, containerJSON.Image); err == nil && oldImage.Config != nil {
+		if err := deepCopy(oldImage.Config, &imageConfig); err == nil {
+			StripImageDefaults(params, &imageConfig)
+		}
+	} else if err != nil {
+		logger.WarnContext(ctx, "failed to inspect old image, keeping inherited defaults",
 			"container_name", containerName,
-			"image", params.Image,
-			"operation", "update",
+			"image", containerJSON.Image,
 			"error", err,
-			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to pull latest image %s: %w", params.Image, err)
 	}
 
-	// Step 4: Stop the old container
-	logger.Debug("stopping old container",
+	// Step 3: Pull the latest image
+	stepCtx, stepSpan = step(ctx, "update", "pull", "Pulling "+params.Image)
+	logger.DebugContext(stepCtx, "pulling latest image",
 		"container_name", containerName,
+		"image", params.Image,
 	)
-	if err := client.StopContainer(ctx, containerID); err != nil {
-		logger.Error("failed to stop container",
+	err = client.PullImage(stepCtx, params.Image)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to pull latest image",
 			"container_name", containerName,
+			"image", params.Image,
 			"operation", "update",
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to stop container %s: %w", containerID, err)
+		return fmt.Errorf("failed to pull latest image %s: %w", params.Image, err)
 	}
 
-	// Step 5: Remove the old container
-	logger.Debug("removing old container",
+	return replaceStandaloneContainer(ctx, client, logger, start, "update", containerID, containerJSON, params)
+}
+
+// replaceStandaloneContainer stops a container, keeps it aside and replaces it
+// with a new container created from params. The old container is removed once
+// the new one is verified, or restored if anything fails along the way.
+func replaceStandaloneContainer(ctx context.Context, client docker.DockerClient, logger *slog.Logger, start time.Time, operation, containerID string, containerJSON types.ContainerJSON, params *models.ContainerParams) error {
+	containerName := strings.TrimPrefix(containerJSON.Name, "/")
+
+	// Stop the old container
+	stepCtx, stepSpan := step(ctx, operation, "stop", "Stopping "+containerName)
+	logger.DebugContext(stepCtx, "stopping old container",
 		"container_name", containerName,
 	)
-	if err := client.RemoveContainer(ctx, containerID); err != nil {
-		logger.Error("failed to remove container",
+	err := client.StopContainer(stepCtx, containerID)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to stop container",
 			"container_name", containerName,
-			"operation", "update",
+			"operation", operation,
 			"error", err,
 			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to remove container %s: %w", containerID, err)
-	}
-
-	// Step 6: Create container config
-	exposedPorts := make(nat.PortSet)
-	for port := range params.PortBindings {
-		exposedPorts[port] = struct{}{}
+		return fmt.Errorf("failed to stop container %s: %w", containerID, err)
 	}
 
-	containerConfig := &container.Config{
-		Image:        params.Image,
-		Env:          params.Env,
-		Cmd:          params.Cmd,
-		Entrypoint:   params.Entrypoint,
-		Labels:       params.Labels,
-		ExposedPorts: exposedPorts,
+	// From here on the old container is kept aside until the new one is verified
+	wasRunning := containerJSON.State == nil || containerJSON.State.Running
+	renamed := false
+	rollback := func(newContainerID string, cause error) error {
+		return rollbackStandaloneContainer(ctx, client, logger, operation, containerID, containerName, newContainerID, renamed, wasRunning, cause)
 	}
 
-	hostConfig := &container.HostConfig{
-		PortBindings:  params.PortBindings,
-		Binds:         params.Binds,
-		RestartPolicy: params.RestartPolicy,
-		Resources:     params.Resources,
+	// Rename the old container aside so its name is free for the new one
+	stepCtx, stepSpan = step(ctx, operation, "rename", "Keeping the old container aside as "+containerName+backupNameSuffix)
+	backupName := containerName + backupNameSuffix
+	logger.DebugContext(stepCtx, "renaming old container aside",
+		"container_name", containerName,
+		"backup_name", backupName,
+	)
+	err = client.RenameContainer(stepCtx, containerID, backupName)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		return rollback("", fmt.Errorf("failed to rename container %s: %w", containerName, err))
 	}
+	renamed = true
 
-	// Step 7: Create new container with the same name and configuration
-	logger.Debug("creating new container",
+	// Create new container with the same name and configuration
+	stepCtx, stepSpan = step(ctx, operation, "create", "Creating new container "+params.Name)
+	logger.DebugContext(stepCtx, "creating new container",
 		"container_name", params.Name,
 		"image", params.Image,
+		"network_count", len(params.NetworkingConfig.EndpointsConfig),
 	)
-	newContainerID, err := client.CreateContainer(ctx, containerConfig, hostConfig, params.Name)
+	newContainerID, err := client.CreateContainer(stepCtx, params.Config, params.HostConfig, params.NetworkingConfig, params.Name)
+	tracing.End(stepSpan, err)
 	if err != nil {
-		logger.Error("failed to create new container",
-			"container_name", params.Name,
-			"operation", "update",
-			"error", err,
-			"duration_ms", time.Since(start).Milliseconds(),
-		)
-		return fmt.Errorf("failed to create new container %s: %w", params.Name, err)
+		return rollback("", fmt.Errorf("failed to create new container %s: %w", params.Name, err))
 	}
 
-	// Step 8: Start the new container
-	logger.Debug("starting new container",
+	// Start the new container
+	stepCtx, stepSpan = step(ctx, operation, "start", "Starting new container")
+	logger.DebugContext(stepCtx, "starting new container",
 		"container_name", params.Name,
 		"new_container_id", newContainerID,
 	)
-	if err := client.StartContainer(ctx, newContainerID); err != nil {
-		logger.Error("failed to start new container",
-			"container_name", params.Name,
-			"new_container_id", newContainerID,
-			"operation", "update",
+	err = client.StartContainer(stepCtx, newContainerID)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		return rollback(newContainerID, fmt.Errorf("failed to start new container %s: %w", newContainerID, err))
+	}
+
+	// Wait for the new container to become healthy or stay up for its verification window
+	stepCtx, stepSpan = step(ctx, operation, "verify", "Verifying the new container is healthy")
+	err = VerifyContainer(stepCtx, client, newContainerID)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		return rollback(newContainerID, err)
+	}
+
+	// Remove the old container now that the new one is verified
+	stepCtx, stepSpan = step(ctx, operation, "cleanup", "Removing the old container")
+	err = client.RemoveContainer(stepCtx, containerID)
+	tracing.End(stepSpan, err)
+	if err != nil {
+		logger.WarnContext(ctx, "failed to remove old container after "+operation,
+			"container_name", backupName,
+			"container_id", containerID,
 			"error", err,
-			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to start new container %s: %w", newContainerID, err)
 	}
 
 	duration := time.Since(start)
-	logger.Info("standalone container updated successfully",
+	logger.InfoContext(ctx, "standalone container replaced successfully",
 		"container_name", params.Name,
 		"new_container_id", newContainerID,
-		"operation", "update",
+		"operation", operation,
 		"duration_ms", duration.Milliseconds(),
 	)
 
 	return nil
 }
 
-// UpdateComposeProject updates all containers in a Docker Compose project
-// This uses docker compose commands to properly handle the project lifecycle
-func UpdateComposeProject(ctx context.Context, client docker.DockerClient, projectName, workDir string, containerImages []string) error {
+// step reports an update step to the job and starts its trace span, named after
+// the operation and step, e.g. update.pull
+func step(ctx context.Context, operation, name, message string) (context.Context, trace.Span) {
+	jobs.Step(ctx, name, message)
+	return tracing.Start(ctx, operation+"."+name)
+}
+
+// backupNameSuffix is appended to a container's name while it is kept aside during an update
+const backupNameSuffix = "-bleedingedge-old"
+
+// RollbackError reports a failed update and whether the original container was restored
+type RollbackError struct {
+	Err         error // The failure that triggered the rollback
+	RolledBack  bool  // True if the original container was restored under its name
+	RollbackErr error // Why the rollback failed, if it did
+}
+
+// Error implements the error interface
+func (e *RollbackError) Error() string {
+	if e.RolledBack {
+		return fmt.Sprintf("%v (rolled back to the original container)", e.Err)
+	}
+	return fmt.Sprintf("%v (rollback failed: %v)", e.Err, e.RollbackErr)
+}
+
+// Unwrap returns the failure that triggered the rollback
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// rollbackStandaloneContainer removes a partially created replacement and puts the
+// original container back under its own name, restarting it if it was running
+func rollbackStandaloneContainer(ctx context.Context, client docker.DockerClient, logger *slog.Logger, operation, oldContainerID, containerName, newContainerID string, renamed, wasRunning bool, cause error) error {
+	logger.ErrorContext(ctx, operation+" failed, rolling back",
+		"container_name", containerName,
+		"operation", operation,
+		"error", cause,
+	)
+	ctx, span := step(ctx, operation, "rollback", "Failed to "+operation+", restoring the original container")
+	defer span.End()
+
+	// Restore even if the update's context was cancelled or timed out
+	ctx = context.WithoutCancel(ctx)
+
+	result := &RollbackError{Err: cause}
+	defer func() { tracing.RecordError(span, result.RollbackErr) }()
+	if newContainerID != "" {
+		if err := client.RemoveContainer(ctx, newContainerID); err != nil {
+			result.RollbackErr = fmt.Errorf("failed to remove new container %s: %w", newContainerID, err)
+			return result
+		}
+	}
+	if renamed {
+		if err := client.RenameContainer(ctx, oldContainerID, containerName); err != nil {
+			result.RollbackErr = fmt.Errorf("failed to restore container name %s: %w", containerName, err)
+			return result
+		}
+	}
+	if wasRunning {
+		if err := client.StartContainer(ctx, oldContainerID); err != nil {
+			result.RollbackErr = fmt.Errorf("failed to restart original container %s: %w", containerName, err)
+			return result
+		}
+	}
+
+	result.RolledBack = true
+	logger.InfoContext(ctx, "rolled back to original container",
+		"container_name", containerName,
+		"container_id", oldContainerID,
+		"operation", operation,
+	)
+	return result
+}
+
+// Docker Compose labels used to recreate individual services
+const (
+	labelComposeProject     = "com.docker.compose.project"
+	labelComposeService     = "com.docker.compose.service"
+	labelComposeConfigFiles = "com.docker.compose.project.config_files"
+)
+
+// UpdateComposeProject updates a Docker Compose project by recreating only the
+// services whose image changed, leaving everything else (e.g. databases) running
+func UpdateComposeProject(ctx context.Context, client docker.DockerClient, projectName, workDir string, containers []models.ContainerInfo) error {
+	return updateComposeServices(ctx, client, projectName, workDir, containers, false)
+}
+
+// UpdateComposeService updates a single service of a Docker Compose project,
+// recreating it even if its image has not changed
+func UpdateComposeService(ctx context.Context, client docker.DockerClient, projectName, workDir string, containers []models.ContainerInfo, service string) error {
+	var serviceContainers []models.ContainerInfo
+	for _, c := range containers {
+		if c.Labels[labelComposeService] == service {
+			serviceContainers = append(serviceContainers, c)
+		}
+	}
+	if len(serviceContainers) == 0 {
+		return fmt.Errorf("service %s not found in compose project %s", service, projectName)
+	}
+
+	return updateComposeServices(ctx, client, projectName, workDir, serviceContainers, true)
+}
+
+// updateComposeServices pulls the images of the given containers and runs
+// docker compose up --no-deps for their services. Unless force is set, services
+// whose pulled image matches the one they are running are skipped.
+func updateComposeServices(ctx context.Context, client docker.DockerClient, projectName, workDir string, containers []models.ContainerInfo, force bool) error {
 	start := time.Now()
 	logger := slog.Default()
 	logger.Info("starting compose project update",
 		"project_name", projectName,
 		"working_dir", workDir,
-		"image_count", len(containerImages),
+		"container_count", len(containers),
 		"operation", "update",
 	)
-	
+
 	if workDir == "" {
 		logger.Error("working directory is required for compose project",
 			"project_name", projectName,
@@ -249,68 +571,161 @@ func UpdateComposeProject(ctx context.Context, client docker.DockerClient, proje
 		return fmt.Errorf("working directory is required for compose project %s", projectName)
 	}
 
-	// Step 1: Pull latest images for all containers in the project
+	// Step 1: Pull the latest image for each service and keep the ones that changed
+	jobs.Step(ctx, "pull", "Pulling service images")
 	logger.Debug("pulling images for compose project",
 		"project_name", projectName,
-		"image_count", len(containerImages),
+		"container_count", len(containers),
 	)
-	for _, image := range containerImages {
-		if err := client.PullImage(ctx, image); err != nil {
+	var services []string
+	var configFiles []string
+	for _, c := range containers {
+		service := c.Labels[labelComposeService]
+		if service == "" || slices.Contains(services, service) {
+			continue
+		}
+		if configFiles == nil {
+			configFiles = composeConfigFiles(c.Labels)
+		}
+
+		changed, err := pullServiceImage(ctx, client, c)
+		if err != nil {
 			logger.Error("failed to pull image for compose project",
 				"project_name", projectName,
-				"image", image,
+				"service", service,
+				"image", c.Image,
 				"operation", "update",
 				"error", err,
 				"duration_ms", time.Since(start).Milliseconds(),
 			)
-			return fmt.Errorf("failed to pull image %s for project %s: %w", image, projectName, err)
+			return fmt.Errorf("failed to pull image %s for project %s: %w", c.Image, projectName, err)
+		}
+		if changed || force {
+			services = append(services, service)
 		}
 	}
 
-	// Step 2: Execute docker compose down
-	logger.Debug("executing docker compose down",
-		"project_name", projectName,
-		"working_dir", workDir,
-	)
-	downCmd := exec.CommandContext(ctx, "docker", "compose", "down")
-	downCmd.Dir = workDir
-	if output, err := downCmd.CombinedOutput(); err != nil {
-		logger.Error("failed to execute docker compose down",
+	if len(services) == 0 {
+		jobs.Log(ctx, "All service images are up to date; nothing to recreate")
+		logger.Info("compose project already up to date",
 			"project_name", projectName,
-			"working_dir", workDir,
 			"operation", "update",
-			"error", err,
-			"output", string(output),
 			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to execute 'docker compose down' for project %s: %w\nOutput: %s", projectName, err, string(output))
+		return nil
 	}
 
-	// Step 3: Execute docker compose up -d --build
+	// Step 2: Recreate only the changed services, without touching their dependencies
+	jobs.Step(ctx, "recreate", "Recreating "+strings.Join(services, ", "))
 	logger.Debug("executing docker compose up",
 		"project_name", projectName,
 		"working_dir", workDir,
+		"services", services,
 	)
-	upCmd := exec.CommandContext(ctx, "docker", "compose", "up", "-d", "--build")
-	upCmd.Dir = workDir
-	if output, err := upCmd.CombinedOutput(); err != nil {
+	if output, err := runCompose(ctx, client, workDir, composeUpArgs(projectName, configFiles, services)); err != nil {
 		logger.Error("failed to execute docker compose up",
 			"project_name", projectName,
 			"working_dir", workDir,
+			"services", services,
 			"operation", "update",
 			"error", err,
 			"output", string(output),
 			"duration_ms", time.Since(start).Milliseconds(),
 		)
-		return fmt.Errorf("failed to execute 'docker compose up -d --build' for project %s: %w\nOutput: %s", projectName, err, string(output))
+		return fmt.Errorf("failed to execute 'docker compose up' for project %s: %w\nOutput: %s", projectName, err, string(output))
+	}
+
+	// Step 3: Verify the recreated containers come up healthy
+	jobs.Step(ctx, "verify", "Verifying recreated services are healthy")
+	if err := verifyComposeProject(ctx, client, projectName, services); err != nil {
+		logger.Error("compose project failed verification",
+			"project_name", projectName,
+			"operation", "update",
+			"error", err,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return fmt.Errorf("compose project %s failed verification: %w", projectName, err)
 	}
 
 	duration := time.Since(start)
 	logger.Info("compose project updated successfully",
 		"project_name", projectName,
+		"services", services,
 		"operation", "update",
 		"duration_ms", duration.Milliseconds(),
 	)
 
 	return nil
 }
+
+// pullServiceImage pulls a compose service's image and reports whether it differs
+// from the image the container is running. Locally built images are not pulled
+// and are reported unchanged, as are images whose check label disables them;
+// the label can also mark an image as pullable.
+func pullServiceImage(ctx context.Context, client docker.DockerClient, c models.ContainerInfo) (bool, error) {
+	override, _ := GetCheckOverride(c.Labels)
+	if override == CheckNever {
+		return false, nil
+	}
+	// A reverted service is pulled from, and always returns to, its tag
+	imageName := TaggedImage(c.Image, c.Labels)
+	reverted := imageName != c.Image
+	if override != CheckAlways && !reverted {
+		localRef := c.ImageID
+		if localRef == "" {
+			localRef = c.Image
+		}
+		running, err := client.InspectImage(ctx, localRef)
+		if err == nil && isLocalImage(c.Image, running.RepoDigests) {
+			return false, nil
+		}
+	}
+	if err := client.PullImage(ctx, imageName); err != nil {
+		return false, err
+	}
+
+	pulled, err := client.InspectImage(ctx, imageName)
+	if err != nil || c.ImageID == "" {
+		// Without both IDs there is nothing to compare; recreate to be safe
+		return true, nil
+	}
+	return reverted || pulled.ID != c.ImageID, nil
+}
+
+// runCompose runs docker compose in a project's working directory, reporting
+// its output to the job reporter. Agent hosts run it on the host itself, where
+// the project's files are.
+func runCompose(ctx context.Context, client docker.DockerClient, workDir string, args []string) ([]byte, error) {
+	if docker.RunsCommandsRemotely(client) {
+		// The output is part of the error and was already reported line by line
+		return nil, client.ExecuteCommand(ctx, workDir, "docker", args)
+	}
+	cmd := exec.CommandContext(ctx, "docker", append(docker.CLIArgs(client), args...)...)
+	cmd.Dir = workDir
+	return docker.RunStreaming(ctx, cmd)
+}
+
+// composeConfigFiles returns the compose files a container was created from,
+// leaving out revert override files so updates go back to the project's own tags
+func composeConfigFiles(labels map[string]string) []string {
+	if labels[labelComposeConfigFiles] == "" {
+		return nil
+	}
+	var files []string
+	for _, file := range strings.Split(labels[labelComposeConfigFiles], ",") {
+		if !strings.HasPrefix(path.Base(file), revertOverridePrefix) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// composeUpArgs builds the docker compose command that recreates the given services
+func composeUpArgs(projectName string, configFiles []string, services []string) []string {
+	args := []string{"compose", "-p", projectName}
+	for _, file := range configFiles {
+		args = append(args, "-f", file)
+	}
+	args = append(args, "up", "-d", "--no-deps")
+	return append(args, services...)
+}
@@ -2,13 +2,26 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/models"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/go-connections/nat"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestExtractContainerParams(t *testing.T) {
@@ -113,13 +126,15 @@ func TestUpdateStandaloneContainer(t *testing.T) {
 					return types.ContainerJSON{
 						ContainerJSONBase: &types.ContainerJSONBase{
 							Name: "/test-container",
+							State: &types.ContainerState{Running: true, Status: "running"},
 							HostConfig: &container.HostConfig{
 								Binds: []string{},
 							},
 						},
 						Config: &container.Config{
-							Image: "nginx:latest",
-							Env:   []string{"TEST=value"},
+							Image:  "nginx:latest",
+							Env:    []string{"TEST=value"},
+							Labels: map[string]string{LabelVerifyWindow: "0s"},
 						},
 					}, nil
 				}
@@ -132,7 +147,7 @@ func TestUpdateStandaloneContainer(t *testing.T) {
 				m.RemoveContainerFunc = func(ctx context.Context, id string) error {
 					return nil
 				}
-				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, name string) (string, error) {
+				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
 					return "new-container-id", nil
 				}
 				m.StartContainerFunc = func(ctx context.Context, id string) error {
@@ -212,19 +227,26 @@ func TestUpdateStandaloneContainer(t *testing.T) {
 }
 
 func TestUpdateComposeProject(t *testing.T) {
+	composeContainers := []models.ContainerInfo{
+		{ID: "web1", Image: "nginx:latest", ImageID: "sha256:web", Labels: map[string]string{"com.docker.compose.service": "web"}},
+		{ID: "db1", Image: "postgres:16", ImageID: "sha256:db", Labels: map[string]string{"com.docker.compose.service": "db"}},
+		{ID: "app1", Image: "myapp-api", ImageID: "sha256:app", Labels: map[string]string{"com.docker.compose.service": "api"}},
+	}
+
 	tests := []struct {
 		name        string
 		projectName string
 		workDir     string
-		images      []string
+		containers  []models.ContainerInfo
 		setupMock   func(*docker.MockClient)
+		expectPulls []string
 		expectError bool
 	}{
 		{
 			name:        "missing working directory",
 			projectName: "myapp",
 			workDir:     "",
-			images:      []string{"nginx:latest"},
+			containers:  composeContainers,
 			setupMock:   func(m *docker.MockClient) {},
 			expectError: true,
 		},
@@ -232,14 +254,62 @@ func TestUpdateComposeProject(t *testing.T) {
 			name:        "pull image fails",
 			projectName: "myapp",
 			workDir:     "/home/user/app",
-			images:      []string{"nginx:latest"},
+			containers:  composeContainers,
 			setupMock: func(m *docker.MockClient) {
 				m.PullImageFunc = func(ctx context.Context, imageName string) error {
 					return fmt.Errorf("failed to pull image")
 				}
 			},
+			expectPulls: []string{"nginx:latest"},
 			expectError: true,
 		},
+		{
+			name:        "no service images changed",
+			projectName: "myapp",
+			workDir:     "/home/user/app",
+			containers:  composeContainers,
+			setupMock: func(m *docker.MockClient) {
+				m.InspectImageFunc = func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+					images := map[string]image.InspectResponse{
+						"nginx:latest": {ID: "sha256:web"},
+						"postgres:16":  {ID: "sha256:db"},
+						"sha256:web":   {ID: "sha256:web", RepoDigests: []string{"nginx@sha256:web"}},
+						"sha256:db":    {ID: "sha256:db", RepoDigests: []string{"postgres@sha256:db"}},
+						"sha256:app":   {ID: "sha256:app"},
+					}
+					return images[imageName], nil
+				}
+			},
+			// Locally built images are never pulled
+			expectPulls: []string{"nginx:latest", "postgres:16"},
+			expectError: false,
+		},
+		{
+			name:        "check label marks image as pullable",
+			projectName: "myapp",
+			workDir:     "/home/user/app",
+			containers: []models.ContainerInfo{
+				{ID: "app1", Image: "registry.lan/myapp-api", ImageID: "sha256:app", Labels: map[string]string{"com.docker.compose.service": "api", LabelCheck: "true"}},
+			},
+			setupMock: func(m *docker.MockClient) {
+				m.InspectImageFunc = func(ctx context.Context, imageName string) (image.InspectResponse, error) {
+					return image.InspectResponse{ID: "sha256:app"}, nil
+				}
+			},
+			expectPulls: []string{"registry.lan/myapp-api"},
+			expectError: false,
+		},
+		{
+			name:        "check label disables pulls",
+			projectName: "myapp",
+			workDir:     "/home/user/app",
+			containers: []models.ContainerInfo{
+				{ID: "web1", Image: "nginx:latest", ImageID: "sha256:web", Labels: map[string]string{"com.docker.compose.service": "web", LabelCheck: "false"}},
+			},
+			setupMock:   func(m *docker.MockClient) {},
+			expectPulls: nil,
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -247,10 +317,504 @@ func TestUpdateComposeProject(t *testing.T) {
 			mockClient := &docker.MockClient{}
 			tt.setupMock(mockClient)
 
-			err := UpdateComposeProject(context.Background(), mockClient, tt.projectName, tt.workDir, tt.images)
+			var pulls []string
+			pull := mockClient.PullImageFunc
+			mockClient.PullImageFunc = func(ctx context.Context, imageName string) error {
+				pulls = append(pulls, imageName)
+				if pull != nil {
+					return pull(ctx, imageName)
+				}
+				return nil
+			}
+
+			err := UpdateComposeProject(context.Background(), mockClient, tt.projectName, tt.workDir, tt.containers)
 			if (err != nil) != tt.expectError {
 				t.Errorf("UpdateComposeProject() error = %v, expectError %v", err, tt.expectError)
 			}
+			if !slices.Equal(pulls, tt.expectPulls) {
+				t.Errorf("expected pulls %v, got %v", tt.expectPulls, pulls)
+			}
+		})
+	}
+}
+
+func TestUpdateComposeServiceNotFound(t *testing.T) {
+	containers := []models.ContainerInfo{
+		{ID: "web1", Image: "nginx:latest", Labels: map[string]string{"com.docker.compose.service": "web"}},
+	}
+
+	err := UpdateComposeService(context.Background(), &docker.MockClient{}, "myapp", "/home/user/app", containers, "db")
+	if err == nil {
+		t.Fatal("expected an error for an unknown service")
+	}
+}
+
+func TestComposeUpArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		configFiles []string
+		services    []string
+		expected    []string
+	}{
+		{
+			name:     "default compose file",
+			services: []string{"web"},
+			expected: []string{"compose", "-p", "myapp", "up", "-d", "--no-deps", "web"},
+		},
+		{
+			name:        "explicit compose files",
+			configFiles: []string{"/srv/app/compose.yml", "/srv/app/compose.override.yml"},
+			services:    []string{"web", "worker"},
+			expected:    []string{"compose", "-p", "myapp", "-f", "/srv/app/compose.yml", "-f", "/srv/app/compose.override.yml", "up", "-d", "--no-deps", "web", "worker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if args := composeUpArgs("myapp", tt.configFiles, tt.services); !slices.Equal(args, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, args)
+			}
+		})
+	}
+}
+
+// richContainerJSON returns an inspect result using most options a user can set on docker run
+func richContainerJSON() types.ContainerJSON {
+	stopTimeout := 30
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:    "0123456789abcdef0123456789abcdef",
+			Name:  "/app",
+			Image: "sha256:oldimage",
+			State: &types.ContainerState{Running: true, Status: "running"},
+			HostConfig: &container.HostConfig{
+				Binds:         []string{"/srv/app:/data:ro"},
+				NetworkMode:   "appnet",
+				RestartPolicy: container.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+				PortBindings: nat.PortMap{
+					"80/tcp": []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "8080"}},
+				},
+				Mounts: []mount.Mount{
+					{Type: mount.TypeVolume, Source: "app-cache", Target: "/cache"},
+				},
+				Tmpfs:       map[string]string{"/run": "size=64m"},
+				CapAdd:      []string{"NET_ADMIN"},
+				CapDrop:     []string{"MKNOD"},
+				SecurityOpt: []string{"no-new-privileges"},
+				ExtraHosts:  []string{"db.internal:10.0.0.5"},
+				DNS:         []string{"9.9.9.9"},
+				DNSSearch:   []string{"example.internal"},
+				Sysctls:     map[string]string{"net.core.somaxconn": "1024"},
+				LogConfig:   container.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m"}},
+				Links:       []string{"/db:/app/database"},
+				Resources: container.Resources{
+					Memory:  512 * 1024 * 1024,
+					Devices: []container.DeviceMapping{{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"}},
+					Ulimits: []*container.Ulimit{{Name: "nofile", Soft: 1024, Hard: 4096}},
+				},
+			},
+		},
+		Config: &container.Config{
+			Image:        "ghcr.io/example/app:1",
+			Hostname:     "0123456789ab",
+			User:         "1000:1000",
+			WorkingDir:   "/srv",
+			Env:          []string{"MODE=production"},
+			Cmd:          []string{"serve", "--port", "80"},
+			Labels:       map[string]string{"team": "platform", LabelVerifyWindow: "0s"},
+			ExposedPorts: nat.PortSet{"80/tcp": struct{}{}},
+			StopSignal:   "SIGINT",
+			StopTimeout:  &stopTimeout,
+			Healthcheck: &container.HealthConfig{
+				Test:     []string{"CMD", "wget", "-q", "-O-", "http://localhost/health"},
+				Interval: 10 * time.Second,
+				Retries:  3,
+			},
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeBind, Source: "/srv/app", Destination: "/data"},
+			{Type: mount.TypeVolume, Name: "app-cache", Destination: "/cache", RW: true},
+			{Type: mount.TypeVolume, Name: "f00dfeed", Destination: "/var/lib/app", RW: true},
+		},
+		NetworkSettings: &types.NetworkSettings{
+			Networks: map[string]*network.EndpointSettings{
+				"appnet": {
+					NetworkID:  "net123",
+					Aliases:    []string{"api", "0123456789ab"},
+					IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "172.30.0.10"},
+					IPAddress:  "172.30.0.10",
+					MacAddress: "02:42:ac:1e:00:0a",
+				},
+				"backend": {
+					NetworkID: "net456",
+					Aliases:   []string{"app-backend"},
+					IPAddress: "172.31.0.4",
+				},
+			},
+		},
+	}
+}
+
+func TestUpdateStandaloneContainerPreservesConfiguration(t *testing.T) {
+	before := richContainerJSON()
+
+	var created types.ContainerJSON
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return richContainerJSON(), nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			created = types.ContainerJSON{
+				ContainerJSONBase: &types.ContainerJSONBase{Name: "/" + name, HostConfig: hostConfig},
+				Config:            config,
+				NetworkSettings:   &types.NetworkSettings{Networks: networkingConfig.EndpointsConfig},
+			}
+			return "new-container-id", nil
+		},
+	}
+
+	if err := UpdateStandaloneContainer(context.Background(), mockClient, before.ID); err != nil {
+		t.Fatalf("UpdateStandaloneContainer() error = %v", err)
+	}
+	if created.Config == nil {
+		t.Fatal("expected container to be created")
+	}
+
+	// The new container gets its own hostname and ID alias from the daemon
+	expectedConfig := *before.Config
+	expectedConfig.Hostname = ""
+	if !reflect.DeepEqual(created.Config, &expectedConfig) {
+		t.Errorf("config not preserved:\nbefore: %+v\nafter:  %+v", &expectedConfig, created.Config)
+	}
+
+	// Links are recreated in create form and the anonymous volume is reattached by name
+	expectedHost := *before.HostConfig
+	expectedHost.Links = []string{"db:database"}
+	expectedHost.Mounts = append(slices.Clone(expectedHost.Mounts),
+		mount.Mount{Type: mount.TypeVolume, Source: "f00dfeed", Target: "/var/lib/app"})
+	if !reflect.DeepEqual(created.HostConfig, &expectedHost) {
+		t.Errorf("host config not preserved:\nbefore: %+v\nafter:  %+v", &expectedHost, created.HostConfig)
+	}
+
+	expectedNetworks := map[string]*network.EndpointSettings{
+		"appnet": {
+			NetworkID:  "net123",
+			Aliases:    []string{"api"},
+			IPAMConfig: &network.EndpointIPAMConfig{IPv4Address: "172.30.0.10"},
+		},
+		"backend": {
+			NetworkID: "net456",
+			Aliases:   []string{"app-backend"},
+		},
+	}
+	if !reflect.DeepEqual(created.NetworkSettings.Networks, expectedNetworks) {
+		t.Errorf("networks not preserved:\nexpected: %+v\ngot:      %+v", expectedNetworks, created.NetworkSettings.Networks)
+	}
+
+	if created.Name != before.Name {
+		t.Errorf("expected name %s, got %s", before.Name, created.Name)
+	}
+}
+
+func TestUpdateStandaloneContainerToNewTag(t *testing.T) {
+	var pulled, createdImage string
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return richContainerJSON(), nil
+		},
+		PullImageFunc: func(ctx context.Context, imageName string) error {
+			pulled = imageName
+			return nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			createdImage = config.Image
+			return "new-container-id", nil
+		},
+	}
+
+	if err := UpdateStandaloneContainerTo(context.Background(), mockClient, "abc123", "registry.lan/team/app:2.1"); err != nil {
+		t.Fatalf("UpdateStandaloneContainerTo() error = %v", err)
+	}
+	if pulled != "registry.lan/team/app:2.1" || createdImage != "registry.lan/team/app:2.1" {
+		t.Errorf("expected the new tag to be pulled and used, pulled %q, created from %q", pulled, createdImage)
+	}
+}
+
+func TestExtractContainerParamsSharedNetwork(t *testing.T) {
+	containerJSON := richContainerJSON()
+	containerJSON.HostConfig.NetworkMode = "container:sidecar"
+	containerJSON.Config.Hostname = "custom-host"
+
+	params, err := ExtractContainerParams(containerJSON)
+	if err != nil {
+		t.Fatalf("ExtractContainerParams() error = %v", err)
+	}
+	if params.Config.Hostname != "" {
+		t.Errorf("expected hostname to be cleared for shared network namespace, got %q", params.Config.Hostname)
+	}
+	if len(params.NetworkingConfig.EndpointsConfig) != 0 {
+		t.Errorf("expected no endpoints for shared network namespace, got %d", len(params.NetworkingConfig.EndpointsConfig))
+	}
+
+	// The inspect result must not be modified
+	if containerJSON.Config.Hostname != "custom-host" {
+		t.Error("expected inspect result to be left untouched")
+	}
+}
+
+func TestStripImageDefaults(t *testing.T) {
+	imageConfig := &container.Config{
+		Env:          []string{"PATH=/usr/bin", "APP_VERSION=1.0"},
+		Cmd:          []string{"serve"},
+		WorkingDir:   "/srv",
+		Labels:       map[string]string{"org.opencontainers.image.version": "1.0"},
+		ExposedPorts: nat.PortSet{"80/tcp": struct{}{}, "443/tcp": struct{}{}},
+		Volumes:      map[string]struct{}{"/var/lib/app": {}},
+	}
+
+	params := &models.ContainerParams{
+		Config: &container.Config{
+			Env:          []string{"PATH=/usr/bin", "APP_VERSION=1.0", "MODE=production"},
+			Cmd:          []string{"serve"},
+			WorkingDir:   "/custom",
+			Labels:       map[string]string{"org.opencontainers.image.version": "1.0", "team": "platform"},
+			ExposedPorts: nat.PortSet{"80/tcp": struct{}{}, "443/tcp": struct{}{}},
+			Volumes:      map[string]struct{}{"/var/lib/app": {}},
+		},
+		HostConfig: &container.HostConfig{
+			PortBindings: nat.PortMap{"80/tcp": []nat.PortBinding{{HostPort: "8080"}}},
+		},
+	}
+
+	StripImageDefaults(params, imageConfig)
+
+	expected := &container.Config{
+		Env:          []string{"MODE=production"},
+		WorkingDir:   "/custom",
+		Labels:       map[string]string{"team": "platform"},
+		ExposedPorts: nat.PortSet{"80/tcp": struct{}{}},
+		Volumes:      map[string]struct{}{},
+	}
+	if !reflect.DeepEqual(params.Config, expected) {
+		t.Errorf("unexpected config after stripping defaults:\nexpected: %+v\ngot:      %+v", expected, params.Config)
+	}
+}
+
+func TestUpdateStandaloneContainerRollback(t *testing.T) {
+	verifyPollInterval = time.Millisecond
+	healthTimeout = 50 * time.Millisecond
+	defer func() {
+		verifyPollInterval = time.Second
+		healthTimeout = 2 * time.Minute
+	}()
+
+	tests := []struct {
+		name             string
+		setupMock        func(*docker.MockClient)
+		expectError      bool
+		expectRolledBack bool
+		expectCalls      []string
+	}{
+		{
+			name:        "healthy container replaces original",
+			setupMock:   func(m *docker.MockClient) {},
+			expectCalls: []string{"stop old", "rename old app-bleedingedge-old", "create app", "start new", "remove old"},
+		},
+		{
+			name: "create fails",
+			setupMock: func(m *docker.MockClient) {
+				m.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+					return "", fmt.Errorf("port is already allocated")
+				}
+			},
+			expectError:      true,
+			expectRolledBack: true,
+			expectCalls:      []string{"stop old", "rename old app-bleedingedge-old", "rename old app", "start old"},
+		},
+		{
+			name: "new container exits",
+			setupMock: func(m *docker.MockClient) {
+				m.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					containerJSON := richContainerJSON()
+					if id == "new" {
+						containerJSON.State = &types.ContainerState{Status: "exited", ExitCode: 1}
+					}
+					return containerJSON, nil
+				}
+			},
+			expectError:      true,
+			expectRolledBack: true,
+			expectCalls:      []string{"stop old", "rename old app-bleedingedge-old", "create app", "start new", "remove new", "rename old app", "start old"},
+		},
+		{
+			name: "new container unhealthy",
+			setupMock: func(m *docker.MockClient) {
+				m.InspectContainerFunc = func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					containerJSON := richContainerJSON()
+					if id == "new" {
+						containerJSON.State.Health = &types.Health{Status: types.Unhealthy}
+					}
+					return containerJSON, nil
+				}
+			},
+			expectError:      true,
+			expectRolledBack: true,
+			expectCalls:      []string{"stop old", "rename old app-bleedingedge-old", "create app", "start new", "remove new", "rename old app", "start old"},
+		},
+		{
+			name: "rename fails",
+			setupMock: func(m *docker.MockClient) {
+				m.RenameContainerFunc = func(ctx context.Context, id string, newName string) error {
+					return fmt.Errorf("rename not permitted")
+				}
+			},
+			expectError:      true,
+			expectRolledBack: true,
+			expectCalls:      []string{"stop old", "start old"},
+		},
+		{
+			name: "restoring the original fails",
+			setupMock: func(m *docker.MockClient) {
+				m.StartContainerFunc = func(ctx context.Context, id string) error {
+					return fmt.Errorf("start failed")
+				}
+			},
+			expectError:      true,
+			expectRolledBack: false,
+			expectCalls:      []string{"stop old", "rename old app-bleedingedge-old", "create app", "remove new", "rename old app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			mockClient := &docker.MockClient{
+				InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					return richContainerJSON(), nil
+				},
+			}
+			tt.setupMock(mockClient)
+
+			// Record the lifecycle calls made against the old ("old") and new ("new") containers
+			containerRole := func(id string) string {
+				if id == "new" {
+					return "new"
+				}
+				return "old"
+			}
+			stop, start, remove, rename, create := mockClient.StopContainerFunc, mockClient.StartContainerFunc, mockClient.RemoveContainerFunc, mockClient.RenameContainerFunc, mockClient.CreateContainerFunc
+			mockClient.StopContainerFunc = func(ctx context.Context, id string) error {
+				calls = append(calls, "stop "+containerRole(id))
+				if stop != nil {
+					return stop(ctx, id)
+				}
+				return nil
+			}
+			mockClient.StartContainerFunc = func(ctx context.Context, id string) error {
+				if start != nil {
+					return start(ctx, id)
+				}
+				calls = append(calls, "start "+containerRole(id))
+				return nil
+			}
+			mockClient.RemoveContainerFunc = func(ctx context.Context, id string) error {
+				calls = append(calls, "remove "+containerRole(id))
+				if remove != nil {
+					return remove(ctx, id)
+				}
+				return nil
+			}
+			mockClient.RenameContainerFunc = func(ctx context.Context, id string, newName string) error {
+				if rename != nil {
+					return rename(ctx, id, newName)
+				}
+				calls = append(calls, "rename "+containerRole(id)+" "+newName)
+				return nil
+			}
+			mockClient.CreateContainerFunc = func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+				if create != nil {
+					return create(ctx, config, hostConfig, networkingConfig, name)
+				}
+				calls = append(calls, "create "+name)
+				return "new", nil
+			}
+
+			err := UpdateStandaloneContainer(context.Background(), mockClient, "0123456789abcdef0123456789abcdef")
+			if (err != nil) != tt.expectError {
+				t.Fatalf("UpdateStandaloneContainer() error = %v, expectError %v", err, tt.expectError)
+			}
+
+			if tt.expectError {
+				var rollbackErr *RollbackError
+				if !errors.As(err, &rollbackErr) {
+					t.Fatalf("expected a RollbackError, got %T: %v", err, err)
+				}
+				if rollbackErr.RolledBack != tt.expectRolledBack {
+					t.Errorf("expected RolledBack = %v, got %v (%v)", tt.expectRolledBack, rollbackErr.RolledBack, err)
+				}
+			}
+
+			if !slices.Equal(calls, tt.expectCalls) {
+				t.Errorf("unexpected calls:\nexpected: %v\ngot:      %v", tt.expectCalls, calls)
+			}
 		})
 	}
 }
+
+func TestUpdateStandaloneContainerSpans(t *testing.T) {
+	verifyPollInterval = time.Millisecond
+	healthTimeout = 50 * time.Millisecond
+	defer func() {
+		verifyPollInterval = time.Second
+		healthTimeout = 2 * time.Minute
+	}()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	mockClient := &docker.MockClient{
+		InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+			return richContainerJSON(), nil
+		},
+		CreateContainerFunc: func(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, name string) (string, error) {
+			return "new", nil
+		},
+		StartContainerFunc: func(ctx context.Context, id string) error {
+			if id == "new" {
+				return fmt.Errorf("port is already allocated")
+			}
+			return nil
+		},
+	}
+
+	err := UpdateStandaloneContainer(context.Background(), mockClient, "0123456789abcdef0123456789abcdef")
+	if err == nil {
+		t.Fatal("expected the update to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) == 0 {
+		t.Fatal("expected spans to be recorded")
+	}
+	root := spans[len(spans)-1]
+	if root.Name() != "services.update_standalone_container" || root.Status().Code != codes.Error {
+		t.Fatalf("expected a failed update span last, got %s (%v)", root.Name(), root.Status())
+	}
+
+	// Every step is a direct child of the update span, and only the failed step is marked as an error
+	var steps []string
+	for _, span := range spans[:len(spans)-1] {
+		if span.Parent().SpanID() != root.SpanContext().SpanID() {
+			continue
+		}
+		steps = append(steps, span.Name())
+		if failed := span.Status().Code == codes.Error; failed != (span.Name() == "update.start") {
+			t.Errorf("unexpected status %v for %s", span.Status(), span.Name())
+		}
+	}
+	expected := []string{"update.inspect", "update.pull", "update.stop", "update.rename", "update.create", "update.start", "update.rollback"}
+	if !slices.Equal(steps, expected) {
+		t.Errorf("unexpected step spans:\nexpected: %v\ngot:      %v", expected, steps)
+	}
+}
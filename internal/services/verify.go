@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/bleeding-edge/bleeding-edge/internal/jobs"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+var (
+	// verifyPollInterval is how often an updated container's state is sampled
+	verifyPollInterval = time.Second
+	// healthTimeout bounds how long a container with a healthcheck may take to become healthy
+	healthTimeout = 2 * time.Minute
+)
+
+// verifyLogTail is the number of log lines captured when verification fails
+const verifyLogTail = 100
+
+// VerificationError reports an updated container that did not come up healthy
+type VerificationError struct {
+	Container string // Container name
+	Reason    string // Why verification failed (unhealthy, exited, restarting, ...)
+	Logs      string // Container output captured during the verification window
+}
+
+// Error implements the error interface
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("container %s failed verification: %s", e.Container, e.Reason)
+}
+
+// VerifyContainer waits for an updated container to prove it works. Containers
+// with a healthcheck must report healthy; others must stay running without
+// restarts for their verification window (see LabelVerifyWindow). On failure
+// the logs written during the window are returned in a *VerificationError.
+func VerifyContainer(ctx context.Context, client docker.DockerClient, containerID string) error {
+	start := time.Now()
+	logger := slog.Default()
+
+	inspect, err := client.InspectContainer(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container %s: %w", containerID, err)
+	}
+	name := strings.TrimPrefix(inspect.Name, "/")
+
+	var labels map[string]string
+	if inspect.Config != nil {
+		labels = inspect.Config.Labels
+	}
+	window, err := GetVerifyWindow(labels)
+	if err != nil {
+		logger.Warn("ignoring invalid verify-window label",
+			"container_name", name,
+			"error", err,
+		)
+	}
+
+	logger.Debug("verifying updated container",
+		"container_name", name,
+		"window_ms", window.Milliseconds(),
+	)
+
+	reason := watchContainer(ctx, client, containerID, inspect, start, window)
+	if reason == "" {
+		jobs.Log(ctx, name+" is healthy")
+		logger.Info("updated container verified",
+			"container_name", name,
+			"operation", "verify",
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+		return nil
+	}
+
+	// Capture what the container printed while it was being watched, even if the update context expired
+	logs, logErr := client.ContainerLogs(context.WithoutCancel(ctx), containerID, start, verifyLogTail)
+	if logErr != nil {
+		logs = fmt.Sprintf("(failed to fetch logs: %v)", logErr)
+	}
+
+	jobs.Log(ctx, name+" failed verification: "+reason)
+	logger.Error("updated container failed verification",
+		"container_name", name,
+		"operation", "verify",
+		"reason", reason,
+		"duration_ms", time.Since(start).Milliseconds(),
+	)
+	return &VerificationError{Container: name, Reason: reason, Logs: logs}
+}
+
+// watchContainer samples the container until it is verified or fails, returning
+// an empty string on success and the failure reason otherwise
+func watchContainer(ctx context.Context, client docker.DockerClient, containerID string, inspect types.ContainerJSON, start time.Time, window time.Duration) string {
+	ctx, cancel := context.WithTimeout(ctx, max(window, healthTimeout))
+	defer cancel()
+
+	initialRestarts := inspect.RestartCount
+	for {
+		state := inspect.State
+		if state == nil {
+			return "container state unavailable"
+		}
+
+		switch {
+		case state.Health != nil && state.Health.Status == container.Unhealthy:
+			return "healthcheck reported unhealthy"
+		case inspect.RestartCount > initialRestarts || state.Restarting:
+			return fmt.Sprintf("container is crash-looping (restarted %d times)", max(inspect.RestartCount-initialRestarts, 1))
+		case !state.Running && state.Status != "created":
+			return fmt.Sprintf("container exited with code %d", state.ExitCode)
+		case state.Running && state.Health != nil && state.Health.Status == container.Healthy:
+			return ""
+		case state.Running && (state.Health == nil || state.Health.Status == container.NoHealthcheck) && time.Since(start) >= window:
+			return ""
+		}
+
+		select {
+		case <-ctx.Done():
+			if state.Health != nil {
+				return "timed out waiting for healthcheck to report healthy"
+			}
+			return "timed out waiting for container to start"
+		case <-time.After(verifyPollInterval):
+		}
+
+		var err error
+		inspect, err = client.InspectContainer(ctx, containerID)
+		if err != nil {
+			return fmt.Sprintf("failed to inspect container: %v", err)
+		}
+	}
+}
+
+// verifyComposeProject concurrently verifies the containers of the given compose services
+func verifyComposeProject(ctx context.Context, client docker.DockerClient, projectName string, services []string) error {
+	containers, err := client.ListContainers(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list containers for project %s: %w", projectName, err)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, c := range containers {
+		if c.Labels[labelComposeProject] != projectName || !slices.Contains(services, c.Labels[labelComposeService]) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(containerID string) {
+			defer wg.Done()
+			if err := VerifyContainer(ctx, client, containerID); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}(c.ID)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/docker"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestVerifyContainer(t *testing.T) {
+	verifyPollInterval = time.Millisecond
+	healthTimeout = 50 * time.Millisecond
+	defer func() {
+		verifyPollInterval = time.Second
+		healthTimeout = 2 * time.Minute
+	}()
+
+	running := &types.ContainerState{Running: true, Status: "running"}
+	withHealth := func(status container.HealthStatus) *types.ContainerState {
+		return &types.ContainerState{Running: true, Status: "running", Health: &types.Health{Status: status}}
+	}
+
+	tests := []struct {
+		name         string
+		window       string
+		states       []*types.ContainerState // State reported by successive inspects; the last one repeats
+		restarts     []int                   // RestartCount reported by successive inspects; the last one repeats
+		expectReason string                  // Empty when verification should succeed
+	}{
+		{name: "healthcheck becomes healthy", window: "1h", states: []*types.ContainerState{withHealth(container.Starting), withHealth(container.Healthy)}},
+		{name: "healthcheck unhealthy", window: "0s", states: []*types.ContainerState{withHealth(container.Starting), withHealth(container.Unhealthy)}, expectReason: "unhealthy"},
+		{name: "healthcheck never settles", window: "0s", states: []*types.ContainerState{withHealth(container.Starting)}, expectReason: "timed out"},
+		{name: "stays running for window", window: "20ms", states: []*types.ContainerState{running}},
+		{name: "exits during window", window: "1h", states: []*types.ContainerState{running, {Status: "exited", ExitCode: 137}}, expectReason: "exited with code 137"},
+		{name: "crash loop", window: "1h", states: []*types.ContainerState{running}, restarts: []int{0, 0, 2}, expectReason: "crash-looping"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inspects := 0
+			mockClient := &docker.MockClient{
+				InspectContainerFunc: func(ctx context.Context, id string) (types.ContainerJSON, error) {
+					state := tt.states[min(inspects, len(tt.states)-1)]
+					restarts := 0
+					if len(tt.restarts) > 0 {
+						restarts = tt.restarts[min(inspects, len(tt.restarts)-1)]
+					}
+					inspects++
+					return types.ContainerJSON{
+						ContainerJSONBase: &types.ContainerJSONBase{
+							Name:         "/app",
+							State:        state,
+							RestartCount: restarts,
+						},
+						Config: &container.Config{Labels: map[string]string{LabelVerifyWindow: tt.window}},
+					}, nil
+				},
+				ContainerLogsFunc: func(ctx context.Context, id string, since time.Time, tail int) (string, error) {
+					return "panic: config file missing\n", nil
+				},
+			}
+
+			err := VerifyContainer(context.Background(), mockClient, "new")
+			if tt.expectReason == "" {
+				if err != nil {
+					t.Fatalf("VerifyContainer() error = %v", err)
+				}
+				return
+			}
+
+			var verifyErr *VerificationError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("expected a VerificationError, got %v", err)
+			}
+			if !strings.Contains(verifyErr.Reason, tt.expectReason) {
+				t.Errorf("expected reason containing %q, got %q", tt.expectReason, verifyErr.Reason)
+			}
+			if verifyErr.Logs != "panic: config file missing\n" {
+				t.Errorf("expected verification logs to be captured, got %q", verifyErr.Logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package testutil provides fixtures shared by the tests of several packages
+package testutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bleeding-edge/bleeding-edge/internal/audit"
+	"github.com/bleeding-edge/bleeding-edge/internal/history"
+)
+
+// OpenHistory opens an update history that is removed when the test ends
+func OpenHistory(t testing.TB) *history.Store {
+	t.Helper()
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("failed to open history: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// OpenAuditLog opens an audit log that is removed when the test ends
+func OpenAuditLog(t testing.TB) *audit.Log {
+	t.Helper()
+	auditLog, err := audit.Open(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("failed to open audit log: %v", err)
+	}
+	t.Cleanup(func() { auditLog.Close() })
+	return auditLog
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span for every request, continuing any trace the
+// caller propagated in a traceparent header. route returns the route template
+// the request matched, e.g. "/container/{id}", which names the span so span
+// names stay low-cardinality; the raw path is kept as an attribute.
+func Middleware(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			template := route(r)
+			name := r.Method
+			if template != "" {
+				name += " " + template
+			}
+			ctx, span := otel.Tracer(instrumentationName).Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPRequestMethodKey.String(r.Method),
+					semconv.HTTPRoute(template),
+					semconv.URLPath(r.URL.Path),
+					semconv.ClientAddress(r.RemoteAddr),
+					semconv.UserAgentOriginal(r.UserAgent()),
+				),
+			)
+			defer span.End()
+
+			recorder := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.statusCode))
+			if recorder.statusCode >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+			}
+		})
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap returns the writer the recorder sits on, which may itself be another
+// middleware's wrapper. http.ResponseController follows it to flush the job
+// event streams of traced requests, which would otherwise stall behind the span.
+func (rw *statusRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	tests := []struct {
+		name         string
+		route        string
+		status       int
+		traceparent  string
+		expectName   string
+		expectStatus codes.Code
+	}{
+		{name: "matched route", route: "/container/{id}", status: http.StatusOK, expectName: "GET /container/{id}", expectStatus: codes.Unset},
+		{name: "unmatched route", status: http.StatusNotFound, expectName: "GET", expectStatus: codes.Unset},
+		{name: "server error", route: "/container/{id}", status: http.StatusBadGateway, expectName: "GET /container/{id}", expectStatus: codes.Error},
+		{
+			name:         "propagated trace",
+			route:        "/container/{id}",
+			status:       http.StatusOK,
+			traceparent:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expectName:   "GET /container/{id}",
+			expectStatus: codes.Unset,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.Reset()
+
+			var handlerSpan trace.SpanContext
+			handler := Middleware(func(*http.Request) string { return tt.route })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.status)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/container/abc123", nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got %d", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.expectName {
+				t.Errorf("expected span %q, got %q", tt.expectName, span.Name())
+			}
+			if span.SpanKind() != trace.SpanKindServer {
+				t.Errorf("expected a server span, got %v", span.SpanKind())
+			}
+			if span.Status().Code != tt.expectStatus {
+				t.Errorf("expected status %v, got %v", tt.expectStatus, span.Status())
+			}
+			if span.SpanContext().SpanID() != handlerSpan.SpanID() {
+				t.Error("expected the handler's context to carry the request span")
+			}
+
+			attrs := attribute.NewSet(span.Attributes()...)
+			if v, _ := attrs.Value("http.response.status_code"); v.AsInt64() != int64(tt.status) {
+				t.Errorf("expected status code attribute %d, got %v", tt.status, v.Emit())
+			}
+			if v, _ := attrs.Value("url.path"); v.AsString() != "/container/abc123" {
+				t.Errorf("expected url.path attribute, got %q", v.Emit())
+			}
+
+			if tt.traceparent != "" {
+				if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+					t.Errorf("expected the propagated trace ID, got %s", got)
+				}
+				if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+					t.Errorf("expected the propagated parent span, got %s", got)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddlewareFlushesStreams(t *testing.T) {
+	recordSpans(t)
+
+	handler := Middleware(func(*http.Request) string { return "/jobs/{id}/events" })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: started\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("expected the stream to flush through the recorder, got %v", err)
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/abc/events", nil))
+	if !w.Flushed {
+		t.Error("expected the underlying writer to be flushed")
+	}
+}
@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	recordSpans(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil))).With("component", "test")
+
+	ctx, span := Start(context.Background(), "operation")
+	defer span.End()
+
+	logger.InfoContext(ctx, "traced")
+	traced := buf.String()
+	buf.Reset()
+	logger.InfoContext(context.Background(), "untraced")
+	untraced := buf.String()
+
+	sc := span.SpanContext()
+	for _, want := range []string{"component=test", "trace_id=" + sc.TraceID().String(), "span_id=" + sc.SpanID().String()} {
+		if !strings.Contains(traced, want) {
+			t.Errorf("expected %q in %q", want, traced)
+		}
+	}
+	if strings.Contains(untraced, "trace_id") {
+		t.Errorf("expected no trace IDs without a span, got %q", untraced)
+	}
+}
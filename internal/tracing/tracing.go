@@ -0,0 +1,99 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP when an endpoint is configured with the standard OTEL_*
+// environment variables; without one every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the tracer of BleedingEdge's own spans
+const instrumentationName = "github.com/bleeding-edge/bleeding-edge"
+
+// Enabled reports whether the environment configures an OTLP endpoint and
+// doesn't turn tracing off with OTEL_SDK_DISABLED or OTEL_TRACES_EXPORTER=none
+func Enabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider and W3C trace context propagation.
+// The exporter reads its endpoint, headers and timeout from the standard
+// OTEL_EXPORTER_OTLP_* variables, sampling follows OTEL_TRACES_SAMPLER
+// (parent-based always-on by default) and OTEL_SERVICE_NAME overrides
+// serviceName. The returned function flushes pending spans and should be called
+// before exit; when tracing isn't enabled it does nothing.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	noop := func(context.Context) error { return nil }
+	if !Enabled() {
+		return noop, nil
+	}
+	if exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter != "" && exporter != "otlp" {
+		return noop, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q (supported: otlp, none)", exporter)
+	}
+	for _, key := range []string{"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"} {
+		if protocol := os.Getenv(key); protocol != "" && protocol != "http/protobuf" {
+			return noop, fmt.Errorf("unsupported %s %q (supported: http/protobuf)", key, protocol)
+		}
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// Attributes from the environment take precedence over the default service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError marks a span as failed with err; a nil err leaves it unchanged
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End records err, if any, on a span and ends it
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
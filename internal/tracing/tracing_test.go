@@ -0,0 +1,113 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordSpans installs a tracer provider that keeps ended spans in memory
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	return recorder
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		expectError bool
+		enabled     bool
+	}{
+		{name: "no endpoint"},
+		{name: "endpoint", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318"}, enabled: true},
+		{name: "traces endpoint", env: map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4318/v1/traces"}, enabled: true},
+		{name: "exporter none", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_TRACES_EXPORTER": "none"}},
+		{name: "sdk disabled", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_SDK_DISABLED": "true"}},
+		{name: "unsupported exporter", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_TRACES_EXPORTER": "zipkin"}, expectError: true, enabled: true},
+		{name: "grpc protocol", env: map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, expectError: true, enabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_TRACES_EXPORTER", "OTEL_SDK_DISABLED", "OTEL_EXPORTER_OTLP_PROTOCOL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+			if Enabled() != tt.enabled {
+				t.Errorf("expected Enabled() = %v", tt.enabled)
+			}
+			shutdown, err := Setup(context.Background(), "bleedingedge")
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Setup() error = %v, expectError %v", err, tt.expectError)
+			}
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetupExportsSpans(t *testing.T) {
+	var received atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" && r.Header.Get("Content-Type") == "application/x-protobuf" {
+			received.Add(1)
+		}
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	shutdown, err := Setup(context.Background(), "bleedingedge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Start(context.Background(), "services.check_updates")
+	span.End()
+
+	// Shutting down flushes the batched span to the collector
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if received.Load() != 1 {
+		t.Errorf("expected one export request, got %d", received.Load())
+	}
+}
+
+func TestEnd(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, parent := Start(context.Background(), "parent")
+	_, child := Start(ctx, "child")
+	End(child, errors.New("daemon unavailable"))
+	End(parent, nil)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("expected the child span to be parented to the span in its context")
+	}
+	if spans[0].Status().Code != codes.Error || spans[0].Status().Description != "daemon unavailable" || len(spans[0].Events()) != 1 {
+		t.Errorf("expected the child span to record the error, got %v", spans[0].Status())
+	}
+	if spans[1].Status().Code != codes.Unset {
+		t.Errorf("expected the parent span to stay unset, got %v", spans[1].Status())
+	}
+}